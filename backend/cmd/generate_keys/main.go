@@ -1,21 +1,118 @@
+// Command generate_keys creates and rotates the keys that sign access tokens.
+//
+// Rotating the signing key:
+//
+//  1. generate_keys -dir keys            writes a new key pair; once deployed
+//     it signs new tokens while the old keys keep verifying.
+//  2. generate_keys -dir keys -retire ID  after the access token lifetime,
+//     keeps only the public half of an old key so it can no longer sign.
+//  3. Delete the retired key's file once no token signed with it is valid.
 package main
 
 import (
 	"crypto/rand"
 	"encoding/base64"
+	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
+
+	"github.com/forfarm/backend/internal/utilities"
 )
 
 func main() {
-	key := make([]byte, 64)
-	_, err := rand.Read(key)
+	alg := flag.String("alg", utilities.KeyAlgEdDSA, "key algorithm: EdDSA, RS256, or HS256 for a shared secret")
+	dir := flag.String("dir", "keys", "signing key directory (JWT_KEYS_DIR)")
+	retire := flag.String("retire", "", "ID of a key to keep only for verification")
+	list := flag.Bool("list", false, "list the keys in -dir")
+	flag.Parse()
+
+	var err error
+	switch {
+	case *list:
+		err = listKeys(*dir)
+	case *retire != "":
+		err = retireKey(*dir, *retire)
+	case *alg == "HS256":
+		err = generateSecret()
+	default:
+		err = generateKey(*dir, *alg)
+	}
 	if err != nil {
-		fmt.Println("Error generating key:", err)
+		fmt.Println("Error:", err)
 		os.Exit(1)
 	}
+}
+
+func generateSecret() error {
+	key := make([]byte, 64)
+	if _, err := rand.Read(key); err != nil {
+		return fmt.Errorf("generating key: %w", err)
+	}
 
 	secret := base64.StdEncoding.EncodeToString(key)
 	fmt.Println("Generated JWT Secret (add to your .env as JWT_SECRET_KEY):")
 	fmt.Println(secret)
+	return nil
+}
+
+func generateKey(dir, alg string) error {
+	id, privPEM, err := utilities.GenerateSigningKey(alg)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	path := filepath.Join(dir, id+".pem")
+	if err := os.WriteFile(path, privPEM, 0o600); err != nil {
+		return err
+	}
+
+	fmt.Printf("Generated %s signing key %s in %s\n", alg, id, path)
+	fmt.Println("It becomes the active key on the next start unless JWT_ACTIVE_KEY_ID pins another one.")
+	return nil
+}
+
+func retireKey(dir, id string) error {
+	path := filepath.Join(dir, id+".pem")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	key, err := utilities.ParseSigningKey(id, data)
+	if err != nil {
+		return err
+	}
+	if key.Private == nil {
+		fmt.Printf("Key %s is already retired\n", id)
+		return nil
+	}
+
+	pubPEM, err := key.PublicKeyPEM()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, pubPEM, 0o600); err != nil {
+		return err
+	}
+
+	fmt.Printf("Retired key %s; it now only verifies tokens\n", id)
+	return nil
+}
+
+func listKeys(dir string) error {
+	ks, err := utilities.LoadSigningKeys(dir, "")
+	if err != nil {
+		return err
+	}
+	for _, jwk := range ks.JWKS() {
+		marker := ""
+		if jwk.KeyID == ks.ActiveKeyID() {
+			marker = " (newest signing key)"
+		}
+		fmt.Printf("%s\t%s%s\n", jwk.KeyID, jwk.Algorithm, marker)
+	}
+	return nil
 }
@@ -0,0 +1,278 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/forfarm/backend/internal/config"
+	"github.com/forfarm/backend/internal/domain"
+	m "github.com/forfarm/backend/internal/middlewares"
+	"github.com/forfarm/backend/internal/services"
+	"github.com/forfarm/backend/internal/utilities"
+)
+
+const (
+	tokenPurposeVerifyEmail   = "verify-email"
+	tokenPurposeResetPassword = "reset-password"
+
+	verifyEmailTokenTTL   = 24 * time.Hour
+	resetPasswordTokenTTL = time.Hour
+)
+
+func (a *api) registerAccountRoutes(_ chi.Router, api huma.API) {
+	tags := []string{"auth"}
+	prefix := "/auth"
+
+	huma.Register(api, m.WithPolicy(m.PolicyPublic, huma.Operation{
+		OperationID:   "requestEmailVerification",
+		Method:        http.MethodPost,
+		Path:          prefix + "/verify-email/request",
+		Tags:          tags,
+		Summary:       "Send an email verification link",
+		DefaultStatus: http.StatusAccepted,
+	}), a.requestEmailVerificationHandler)
+
+	huma.Register(api, m.WithPolicy(m.PolicyPublic, huma.Operation{
+		OperationID: "confirmEmailVerification",
+		Method:      http.MethodPost,
+		Path:        prefix + "/verify-email/confirm",
+		Tags:        tags,
+	}), a.confirmEmailVerificationHandler)
+
+	huma.Register(api, m.WithPolicy(m.PolicyPublic, huma.Operation{
+		OperationID:   "requestPasswordReset",
+		Method:        http.MethodPost,
+		Path:          prefix + "/password-reset/request",
+		Tags:          tags,
+		Summary:       "Send a password reset link",
+		DefaultStatus: http.StatusAccepted,
+	}), a.requestPasswordResetHandler)
+
+	huma.Register(api, m.WithPolicy(m.PolicyPublic, huma.Operation{
+		OperationID: "confirmPasswordReset",
+		Method:      http.MethodPost,
+		Path:        prefix + "/password-reset/confirm",
+		Tags:        tags,
+		Summary:     "Set a new password and sign out every session",
+	}), a.confirmPasswordResetHandler)
+}
+
+type AccountEmailInput struct {
+	Body struct {
+		Email string `json:"email" required:"true"`
+	}
+}
+
+type AccountTokenInput struct {
+	Body struct {
+		Token string `json:"token" required:"true"`
+	}
+}
+
+type ConfirmPasswordResetInput struct {
+	Body struct {
+		Token    string `json:"token" required:"true"`
+		Password string `json:"password" required:"true"`
+	}
+}
+
+type AccountMessageOutput struct {
+	Body struct {
+		Message string `json:"message"`
+	}
+}
+
+func accountMessage(msg string) *AccountMessageOutput {
+	resp := &AccountMessageOutput{}
+	resp.Body.Message = msg
+	return resp
+}
+
+// actionTokenSecret signs verification, reset and login challenge tokens.
+func actionTokenSecret() []byte {
+	if config.EMAIL_TOKEN_SECRET != "" {
+		return []byte(config.EMAIL_TOKEN_SECRET)
+	}
+	return []byte(config.JWT_SECRET_KEY)
+}
+
+// tokenFingerprint ties a token to state its action changes, so that it can
+// only be used once.
+func tokenFingerprint(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return hex.EncodeToString(sum[:16])
+}
+
+func emailVerificationFingerprint(u *domain.User) string {
+	return tokenFingerprint(strings.ToLower(u.Email))
+}
+
+func passwordResetFingerprint(u *domain.User) string {
+	return tokenFingerprint(u.Password)
+}
+
+func appLink(path, token string) string {
+	return strings.TrimRight(config.APP_BASE_URL, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+// sendVerificationEmail mails the user a link that verifies their address.
+func (a *api) sendVerificationEmail(ctx context.Context, u *domain.User) error {
+	token := utilities.NewActionToken(actionTokenSecret(), tokenPurposeVerifyEmail, u.UUID, emailVerificationFingerprint(u), verifyEmailTokenTTL)
+	return a.mailer.Send(ctx, domain.Email{
+		To:      u.Email,
+		Subject: "Verify your ForFarm email address",
+		Body: fmt.Sprintf("Welcome to ForFarm!\n\nConfirm your email address by opening this link within %s:\n\n%s\n\nIf you did not create an account, you can ignore this email.\n",
+			verifyEmailTokenTTL, appLink("/auth/verify-email", token)),
+	})
+}
+
+func (a *api) requestEmailVerificationHandler(ctx context.Context, input *AccountEmailInput) (*AccountMessageOutput, error) {
+	// The response is the same whether or not the address has an account.
+	resp := accountMessage("If the address belongs to an unverified account, a verification email has been sent")
+
+	user, err := a.userRepo.GetByEmail(ctx, strings.TrimSpace(input.Body.Email))
+	if errors.Is(err, domain.ErrNotFound) {
+		return resp, nil
+	}
+	if err != nil {
+		a.logger.Error("Failed to look up user for email verification", "error", err)
+		return nil, huma.Error500InternalServerError("Failed to send verification email")
+	}
+	if user.IsEmailVerified() || !user.IsActive {
+		return resp, nil
+	}
+
+	if err := a.sendVerificationEmail(ctx, &user); err != nil {
+		a.logger.Error("Failed to send verification email", "user_uuid", user.UUID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to send verification email")
+	}
+	return resp, nil
+}
+
+// userFromActionToken returns the user a token was issued for, provided the
+// fingerprint still matches their state.
+func (a *api) userFromActionToken(ctx context.Context, token, purpose string, fingerprint func(*domain.User) string) (*domain.User, error) {
+	userID, fp, err := utilities.ParseActionToken(actionTokenSecret(), token, purpose)
+	if errors.Is(err, utilities.ErrActionTokenExpired) {
+		return nil, huma.Error410Gone("This link has expired")
+	}
+	if err != nil {
+		return nil, huma.Error400BadRequest("Invalid token")
+	}
+
+	user, err := a.userRepo.GetByUUID(ctx, userID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, huma.Error400BadRequest("Invalid token")
+	}
+	if err != nil {
+		a.logger.Error("Failed to get user for token", "user_uuid", userID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to verify token")
+	}
+	if fingerprint(&user) != fp {
+		return nil, huma.Error410Gone("This link has already been used")
+	}
+	return &user, nil
+}
+
+func (a *api) confirmEmailVerificationHandler(ctx context.Context, input *AccountTokenInput) (*AccountMessageOutput, error) {
+	user, err := a.userFromActionToken(ctx, input.Body.Token, tokenPurposeVerifyEmail, emailVerificationFingerprint)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsEmailVerified() {
+		return nil, huma.Error410Gone("This link has already been used")
+	}
+
+	now := time.Now()
+	user.EmailVerifiedAt = &now
+	if err := a.userRepo.CreateOrUpdate(ctx, user); err != nil {
+		a.logger.Error("Failed to mark email verified", "user_uuid", user.UUID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to verify email")
+	}
+
+	a.logger.Info("Email verified", "user_uuid", user.UUID)
+	a.auditAccount(ctx, domain.AuditEmailVerified, user.UUID, nil)
+	return accountMessage("Email address verified"), nil
+}
+
+func (a *api) requestPasswordResetHandler(ctx context.Context, input *AccountEmailInput) (*AccountMessageOutput, error) {
+	resp := accountMessage("If the address belongs to an account, a password reset email has been sent")
+
+	user, err := a.userRepo.GetByEmail(ctx, strings.TrimSpace(input.Body.Email))
+	if errors.Is(err, domain.ErrNotFound) {
+		return resp, nil
+	}
+	if err != nil {
+		a.logger.Error("Failed to look up user for password reset", "error", err)
+		return nil, huma.Error500InternalServerError("Failed to send password reset email")
+	}
+	if !user.IsActive {
+		return resp, nil
+	}
+
+	token := utilities.NewActionToken(actionTokenSecret(), tokenPurposeResetPassword, user.UUID, passwordResetFingerprint(&user), resetPasswordTokenTTL)
+	err = a.mailer.Send(ctx, domain.Email{
+		To:      user.Email,
+		Subject: "Reset your ForFarm password",
+		Body: fmt.Sprintf("Someone asked to reset the password of your ForFarm account.\n\nChoose a new password by opening this link within %s:\n\n%s\n\nIf it was not you, you can ignore this email; your password is unchanged.\n",
+			resetPasswordTokenTTL, appLink("/auth/reset-password", token)),
+	})
+	if err != nil {
+		a.logger.Error("Failed to send password reset email", "user_uuid", user.UUID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to send password reset email")
+	}
+	return resp, nil
+}
+
+func (a *api) confirmPasswordResetHandler(ctx context.Context, input *ConfirmPasswordResetInput) (*AccountMessageOutput, error) {
+	if err := validatePassword(input.Body.Password); err != nil {
+		return nil, huma.Error422UnprocessableEntity("Validation failed", err)
+	}
+
+	user, err := a.userFromActionToken(ctx, input.Body.Token, tokenPurposeResetPassword, passwordResetFingerprint)
+	if err != nil {
+		return nil, err
+	}
+	if !user.IsActive {
+		return nil, huma.Error403Forbidden("Account is inactive")
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Body.Password), bcrypt.DefaultCost)
+	if err != nil {
+		a.logger.Error("Failed to hash password during reset", "error", err)
+		return nil, huma.Error500InternalServerError("Failed to reset password")
+	}
+	user.Password = string(hashedPassword)
+	user.PasswordSet = true
+	// Following the emailed link proves the address too.
+	if !user.IsEmailVerified() {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+	if err := a.userRepo.CreateOrUpdate(ctx, user); err != nil {
+		a.logger.Error("Failed to save reset password", "user_uuid", user.UUID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to reset password")
+	}
+
+	if err := a.sessionRepo.RevokeAllForUser(ctx, user.UUID); err != nil {
+		a.logger.Error("Failed to revoke sessions after password reset", "user_uuid", user.UUID, "error", err)
+	}
+	if _, err := a.loginGuard.Unlock(ctx, services.LoginAttempt{Email: user.Email, UserID: user.UUID}, user.UUID, "password_reset"); err != nil {
+		a.logger.Error("Failed to lift sign-in lockout after password reset", "user_uuid", user.UUID, "error", err)
+	}
+
+	a.logger.Info("Password reset", "user_uuid", user.UUID)
+	a.auditAccount(ctx, domain.AuditPasswordReset, user.UUID, nil)
+	return accountMessage("Password has been reset; sign in with the new password"), nil
+}
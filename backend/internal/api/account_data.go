@@ -0,0 +1,268 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/forfarm/backend/internal/config"
+	"github.com/forfarm/backend/internal/domain"
+	m "github.com/forfarm/backend/internal/middlewares"
+)
+
+const defaultAccountDeletionGrace = 30 * 24 * time.Hour
+
+func (a *api) registerAccountDataRoutes(_ chi.Router, api huma.API) {
+	tags := []string{"user"}
+	prefix := "/user/me"
+
+	huma.Register(api, m.WithPolicy(m.PolicyOwner, huma.Operation{
+		OperationID:   "requestDataExport",
+		Method:        http.MethodPost,
+		Path:          prefix + "/exports",
+		Tags:          tags,
+		Summary:       "Request an archive of everything stored about the caller",
+		Description:   "The archive is built in the background; poll the export until it is ready, then download it before it expires.",
+		DefaultStatus: http.StatusAccepted,
+	}), a.requestDataExportHandler)
+
+	huma.Register(api, m.WithPolicy(m.PolicyOwner, huma.Operation{
+		OperationID: "getDataExports",
+		Method:      http.MethodGet,
+		Path:        prefix + "/exports",
+		Tags:        tags,
+		Summary:     "List the caller's data exports",
+	}), a.getDataExportsHandler)
+
+	huma.Register(api, m.WithPolicy(m.PolicyOwner, huma.Operation{
+		OperationID: "downloadDataExport",
+		Method:      http.MethodGet,
+		Path:        prefix + "/exports/{exportId}/download",
+		Tags:        tags,
+		Summary:     "Download a ready data export as a zip archive",
+	}), a.downloadDataExportHandler)
+
+	huma.Register(api, m.WithPolicy(m.PolicyOwner, huma.Operation{
+		OperationID: "scheduleAccountDeletion",
+		Method:      http.MethodPost,
+		Path:        prefix + "/deletion",
+		Tags:        tags,
+		Summary:     "Schedule the caller's account for deletion",
+		Description: "The account and the farms it owns are deleted once the grace period is over. Until then the user can sign in and cancel. The password is required if the account has one, and a two-factor code if it is enabled.",
+	}), a.scheduleAccountDeletionHandler)
+
+	huma.Register(api, m.WithPolicy(m.PolicyOwner, huma.Operation{
+		OperationID: "cancelAccountDeletion",
+		Method:      http.MethodDelete,
+		Path:        prefix + "/deletion",
+		Tags:        tags,
+		Summary:     "Cancel a scheduled account deletion",
+	}), a.cancelAccountDeletionHandler)
+}
+
+type DataExportOutput struct {
+	Body domain.DataExport
+}
+
+type DataExportsOutput struct {
+	Body []domain.DataExport
+}
+
+type DataExportInput struct {
+	ExportID string `path:"exportId" format:"uuid"`
+}
+
+type DownloadDataExportOutput struct {
+	ContentType        string `header:"Content-Type"`
+	ContentDisposition string `header:"Content-Disposition"`
+	Body               []byte
+}
+
+type ScheduleAccountDeletionInput struct {
+	Body struct {
+		Password string `json:"password,omitempty"`
+		Code     string `json:"code,omitempty" doc:"Authenticator or recovery code, if two-factor authentication is enabled"`
+	}
+}
+
+type AccountDeletionOutput struct {
+	Body struct {
+		Message             string     `json:"message"`
+		DeletionScheduledAt *time.Time `json:"deletionScheduledAt,omitempty"`
+	}
+}
+
+func accountDeletionGrace() time.Duration {
+	if config.ACCOUNT_DELETION_GRACE > 0 {
+		return config.ACCOUNT_DELETION_GRACE
+	}
+	return defaultAccountDeletionGrace
+}
+
+func (a *api) requestDataExportHandler(ctx context.Context, _ *struct{}) (*DataExportOutput, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("Authentication failed", err)
+	}
+
+	exports, err := a.dataExportRepo.ListByUserID(ctx, userID)
+	if err != nil {
+		a.logger.Error("Failed to list data exports", "user_uuid", userID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to request data export")
+	}
+	for _, e := range exports {
+		if e.Status == domain.DataExportPending || e.Status == domain.DataExportRunning {
+			return nil, huma.Error409Conflict("An export is already being prepared")
+		}
+	}
+
+	export := &domain.DataExport{UserID: userID}
+	if err := a.dataExportRepo.Create(ctx, export); err != nil {
+		a.logger.Error("Failed to create data export", "user_uuid", userID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to request data export")
+	}
+	a.auditAccount(ctx, domain.AuditDataExportRequested, userID, map[string]interface{}{"exportId": export.UUID})
+	return &DataExportOutput{Body: *export}, nil
+}
+
+func (a *api) getDataExportsHandler(ctx context.Context, _ *struct{}) (*DataExportsOutput, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("Authentication failed", err)
+	}
+
+	exports, err := a.dataExportRepo.ListByUserID(ctx, userID)
+	if err != nil {
+		a.logger.Error("Failed to list data exports", "user_uuid", userID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to retrieve data exports")
+	}
+	return &DataExportsOutput{Body: exports}, nil
+}
+
+func (a *api) downloadDataExportHandler(ctx context.Context, input *DataExportInput) (*DownloadDataExportOutput, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("Authentication failed", err)
+	}
+
+	export, err := a.dataExportRepo.GetByID(ctx, input.ExportID)
+	if errors.Is(err, domain.ErrNotFound) || (err == nil && export.UserID != userID) {
+		return nil, huma.Error404NotFound("Export not found")
+	}
+	if err != nil {
+		a.logger.Error("Failed to get data export", "export_id", input.ExportID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to download export")
+	}
+	if export.Status != domain.DataExportReady {
+		return nil, huma.Error409Conflict("The export is not ready")
+	}
+	if !export.IsDownloadable(time.Now()) {
+		return nil, huma.Error410Gone("The export has expired; request a new one")
+	}
+
+	archive, err := a.dataExportRepo.GetArchive(ctx, export.UUID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, huma.Error410Gone("The export has expired; request a new one")
+	}
+	if err != nil {
+		a.logger.Error("Failed to read data export", "export_id", export.UUID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to download export")
+	}
+
+	return &DownloadDataExportOutput{
+		ContentType:        "application/zip",
+		ContentDisposition: fmt.Sprintf(`attachment; filename="forfarm-export-%s.zip"`, export.CreatedAt.Format("2006-01-02")),
+		Body:               archive,
+	}, nil
+}
+
+func (a *api) scheduleAccountDeletionHandler(ctx context.Context, input *ScheduleAccountDeletionInput) (*AccountDeletionOutput, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("Authentication failed", err)
+	}
+	user, err := a.userRepo.GetByUUID(ctx, userID)
+	if err != nil {
+		a.logger.Error("Failed to get user", "user_uuid", userID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to schedule account deletion")
+	}
+	if user.DeletionScheduledAt != nil {
+		return nil, huma.Error409Conflict("Account deletion is already scheduled")
+	}
+
+	// Whoever holds the session must still prove they are the user.
+	if user.PasswordSet {
+		if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Body.Password)) != nil {
+			return nil, huma.Error422UnprocessableEntity("Incorrect password")
+		}
+	}
+	tf, err := a.twoFactorRepo.Get(ctx, userID)
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		a.logger.Error("Failed to get two-factor enrolment", "user_uuid", userID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to schedule account deletion")
+	}
+	if err == nil && tf.IsEnabled() {
+		ok, err := a.verifySecondFactor(ctx, tf, input.Body.Code)
+		if err != nil {
+			a.logger.Error("Failed to verify two-factor code", "user_uuid", userID, "error", err)
+			return nil, huma.Error500InternalServerError("Failed to schedule account deletion")
+		}
+		if !ok {
+			return nil, huma.Error422UnprocessableEntity("Invalid code")
+		}
+	}
+
+	scheduledAt := time.Now().Add(accountDeletionGrace())
+	if err := a.userRepo.SetDeletionScheduledAt(ctx, userID, &scheduledAt); err != nil {
+		a.logger.Error("Failed to schedule account deletion", "user_uuid", userID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to schedule account deletion")
+	}
+
+	a.logger.Info("Account deletion scheduled", "user_uuid", userID, "at", scheduledAt)
+	a.auditAccount(ctx, domain.AuditDeletionScheduled, userID, map[string]interface{}{"scheduledAt": scheduledAt})
+	if err := a.mailer.Send(ctx, domain.Email{
+		To:      user.Email,
+		Subject: "Your ForFarm account will be deleted",
+		Body: fmt.Sprintf("Your ForFarm account and the farms you own will be deleted on %s.\n\nTo keep your account, sign in before then and cancel the deletion from your account settings.\n",
+			scheduledAt.UTC().Format(time.RFC1123)),
+	}); err != nil {
+		a.logger.Error("Failed to send account deletion email", "user_uuid", userID, "error", err)
+	}
+
+	resp := &AccountDeletionOutput{}
+	resp.Body.Message = "Account deletion scheduled"
+	resp.Body.DeletionScheduledAt = &scheduledAt
+	return resp, nil
+}
+
+func (a *api) cancelAccountDeletionHandler(ctx context.Context, _ *struct{}) (*AccountDeletionOutput, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("Authentication failed", err)
+	}
+	user, err := a.userRepo.GetByUUID(ctx, userID)
+	if err != nil {
+		a.logger.Error("Failed to get user", "user_uuid", userID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to cancel account deletion")
+	}
+	if user.DeletionScheduledAt == nil {
+		return nil, huma.Error409Conflict("Account deletion is not scheduled")
+	}
+
+	if err := a.userRepo.SetDeletionScheduledAt(ctx, userID, nil); err != nil {
+		a.logger.Error("Failed to cancel account deletion", "user_uuid", userID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to cancel account deletion")
+	}
+
+	a.logger.Info("Account deletion cancelled", "user_uuid", userID)
+	a.auditAccount(ctx, domain.AuditDeletionCancelled, userID, nil)
+	resp := &AccountDeletionOutput{}
+	resp.Body.Message = "Account deletion cancelled"
+	return resp, nil
+}
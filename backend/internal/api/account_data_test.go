@@ -0,0 +1,74 @@
+package api
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/forfarm/backend/internal/domain"
+)
+
+func TestAccountDeletionSchedule(t *testing.T) {
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("ValidPass123!"), bcrypt.MinCost)
+	user := &domain.User{
+		UUID:        uuid.New().String(),
+		Email:       "test@example.com",
+		Password:    string(hashedPassword),
+		PasswordSet: true,
+		IsActive:    true,
+	}
+	users := memoryUsers{user.UUID: user}
+	mailer := &recordingMailer{}
+	audit := &memoryAuditLog{}
+	api := &api{
+		userRepo:      users,
+		twoFactorRepo: newMemoryTwoFactor(),
+		auditRepo:     audit,
+		mailer:        mailer,
+		logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	ctx := domain.ContextWithPrincipal(context.Background(), domain.Principal{UserID: user.UUID, Role: domain.RoleUser})
+
+	input := &ScheduleAccountDeletionInput{}
+	input.Body.Password = "wrongpassword"
+	_, err := api.scheduleAccountDeletionHandler(ctx, input)
+	var statusErr huma.StatusError
+	require.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, 422, statusErr.GetStatus())
+	assert.Nil(t, users[user.UUID].DeletionScheduledAt)
+
+	input.Body.Password = "ValidPass123!"
+	resp, err := api.scheduleAccountDeletionHandler(ctx, input)
+	require.NoError(t, err)
+	require.NotNil(t, resp.Body.DeletionScheduledAt)
+	assert.WithinDuration(t, time.Now().Add(defaultAccountDeletionGrace), *resp.Body.DeletionScheduledAt, time.Minute)
+	assert.Equal(t, resp.Body.DeletionScheduledAt, users[user.UUID].DeletionScheduledAt)
+	require.Len(t, mailer.sent, 1)
+	assert.Equal(t, user.Email, mailer.sent[0].To)
+
+	_, err = api.scheduleAccountDeletionHandler(ctx, input)
+	require.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, 409, statusErr.GetStatus())
+
+	_, err = api.cancelAccountDeletionHandler(ctx, nil)
+	require.NoError(t, err)
+	assert.Nil(t, users[user.UUID].DeletionScheduledAt)
+
+	_, err = api.cancelAccountDeletionHandler(ctx, nil)
+	require.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, 409, statusErr.GetStatus())
+
+	var actions []string
+	for _, e := range *audit {
+		actions = append(actions, e.Action)
+	}
+	assert.Equal(t, []string{domain.AuditDeletionScheduled, domain.AuditDeletionCancelled}, actions)
+}
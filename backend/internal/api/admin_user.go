@@ -0,0 +1,186 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/go-chi/chi/v5"
+
+	"github.com/forfarm/backend/internal/domain"
+	m "github.com/forfarm/backend/internal/middlewares"
+)
+
+const defaultUserPageSize = 50
+
+func (a *api) registerAdminUserRoutes(_ chi.Router, api huma.API) {
+	tags := []string{"admin"}
+	prefix := "/admin/users"
+
+	huma.Register(api, m.WithPolicy(m.PolicyAdmin, huma.Operation{
+		OperationID: "getUsers",
+		Method:      http.MethodGet,
+		Path:        prefix,
+		Tags:        tags,
+		Summary:     "List and search user accounts",
+		Description: "Users are newest first; pass nextCursor as cursor for the next page.",
+	}), a.getUsersHandler)
+
+	huma.Register(api, m.WithPolicy(m.PolicyAdmin, huma.Operation{
+		OperationID: "getUser",
+		Method:      http.MethodGet,
+		Path:        prefix + "/{userId}",
+		Tags:        tags,
+		Summary:     "Get a user account",
+	}), a.getUserHandler)
+
+	huma.Register(api, m.WithPolicy(m.PolicyAdmin, huma.Operation{
+		OperationID: "deactivateUser",
+		Method:      http.MethodPost,
+		Path:        prefix + "/{userId}/deactivate",
+		Tags:        tags,
+		Summary:     "Deactivate a user account",
+		Description: "The user is signed out everywhere, and can neither sign in nor use their API keys until the account is reactivated.",
+	}), a.deactivateUserHandler)
+
+	huma.Register(api, m.WithPolicy(m.PolicyAdmin, huma.Operation{
+		OperationID: "reactivateUser",
+		Method:      http.MethodPost,
+		Path:        prefix + "/{userId}/reactivate",
+		Tags:        tags,
+		Summary:     "Reactivate a deactivated user account",
+	}), a.reactivateUserHandler)
+}
+
+type GetUsersInput struct {
+	Query  string `query:"q" doc:"Part of the email address or username"`
+	Role   string `query:"role" enum:"user,admin"`
+	Status string `query:"status" enum:"active,inactive"`
+	Cursor string `query:"cursor"`
+	Limit  int    `query:"limit" minimum:"1" maximum:"200" doc:"Users per page (default 50)"`
+}
+
+type GetUsersOutput struct {
+	Body struct {
+		Users      []domain.User `json:"users"`
+		NextCursor string        `json:"nextCursor,omitempty"`
+	}
+}
+
+type AdminUserInput struct {
+	UserID string `path:"userId" required:"true" format:"uuid"`
+}
+
+type AdminUserOutput struct {
+	Body domain.User
+}
+
+func (a *api) getUsersHandler(ctx context.Context, input *GetUsersInput) (*GetUsersOutput, error) {
+	filter := domain.UserFilter{
+		Query: input.Query,
+		Role:  input.Role,
+		Limit: input.Limit,
+	}
+	if filter.Limit == 0 {
+		filter.Limit = defaultUserPageSize
+	}
+	// One more than the page holds tells whether there is a next page.
+	filter.Limit++
+	if input.Status != "" {
+		active := input.Status == "active"
+		filter.IsActive = &active
+	}
+	if input.Cursor != "" {
+		id, err := strconv.ParseInt(input.Cursor, 10, 64)
+		if err != nil || id <= 0 {
+			return nil, huma.Error400BadRequest("Invalid cursor")
+		}
+		filter.BeforeID = id
+	}
+
+	users, err := a.userRepo.List(ctx, filter)
+	if err != nil {
+		a.logger.Error("Failed to list users", "error", err)
+		return nil, huma.Error500InternalServerError("Failed to retrieve users")
+	}
+
+	resp := &GetUsersOutput{}
+	if len(users) == filter.Limit {
+		users = users[:len(users)-1]
+		resp.Body.NextCursor = strconv.FormatInt(users[len(users)-1].ID, 10)
+	}
+	resp.Body.Users = users
+	return resp, nil
+}
+
+func (a *api) getUserHandler(ctx context.Context, input *AdminUserInput) (*AdminUserOutput, error) {
+	user, err := a.adminGetUser(ctx, input.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return &AdminUserOutput{Body: user}, nil
+}
+
+func (a *api) deactivateUserHandler(ctx context.Context, input *AdminUserInput) (*AdminUserOutput, error) {
+	adminID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("Authentication failed", err)
+	}
+	if input.UserID == adminID {
+		return nil, huma.Error409Conflict("You cannot deactivate your own account")
+	}
+	return a.setUserActive(ctx, input.UserID, false)
+}
+
+func (a *api) reactivateUserHandler(ctx context.Context, input *AdminUserInput) (*AdminUserOutput, error) {
+	return a.setUserActive(ctx, input.UserID, true)
+}
+
+func (a *api) adminGetUser(ctx context.Context, userID string) (domain.User, error) {
+	user, err := a.userRepo.GetByUUID(ctx, userID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.User{}, huma.Error404NotFound("User not found")
+	}
+	if err != nil {
+		a.logger.Error("Failed to get user", "user_uuid", userID, "error", err)
+		return domain.User{}, huma.Error500InternalServerError("Failed to retrieve user")
+	}
+	return user, nil
+}
+
+// setUserActive deactivates or reactivates an account. Deactivating also
+// ends the user's sessions; their API keys stop working with the account.
+func (a *api) setUserActive(ctx context.Context, userID string, active bool) (*AdminUserOutput, error) {
+	user, err := a.adminGetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsActive == active {
+		return &AdminUserOutput{Body: user}, nil
+	}
+
+	if err := a.userRepo.SetActive(ctx, user.UUID, active); err != nil {
+		a.logger.Error("Failed to update user", "user_uuid", userID, "active", active, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to update user")
+	}
+	user.IsActive = active
+
+	action := domain.AuditAccountReactivated
+	if !active {
+		action = domain.AuditAccountDeactivated
+		if err := a.sessionRepo.RevokeAllForUser(ctx, user.UUID); err != nil {
+			a.logger.Error("Failed to revoke sessions of deactivated user", "user_uuid", user.UUID, "error", err)
+			return nil, huma.Error500InternalServerError("Account deactivated, but its sessions could not be revoked")
+		}
+	}
+	a.logger.Info("User active status changed", "user_uuid", user.UUID, "active", active)
+	a.audit(ctx, domain.AuditEntry{
+		Action:       action,
+		UserID:       user.UUID,
+		ResourceType: domain.AuditResourceUser,
+		ResourceID:   user.UUID,
+	})
+	return &AdminUserOutput{Body: user}, nil
+}
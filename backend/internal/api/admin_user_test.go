@@ -0,0 +1,111 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/forfarm/backend/internal/domain"
+)
+
+func TestAdminUsers(t *testing.T) {
+	users := memoryUsers{}
+	for i := 1; i <= 3; i++ {
+		u := &domain.User{
+			ID:       int64(i),
+			UUID:     uuid.New().String(),
+			Email:    fmt.Sprintf("farmer%d@example.com", i),
+			IsActive: true,
+			Role:     domain.RoleUser,
+		}
+		users[u.UUID] = u
+	}
+	admin := &domain.User{ID: 4, UUID: uuid.New().String(), Email: "admin@example.com", IsActive: true, Role: domain.RoleAdmin}
+	users[admin.UUID] = admin
+
+	sessions := newMockSessions()
+	audit := &memoryAuditLog{}
+	api := &api{
+		userRepo:    users,
+		sessionRepo: sessions,
+		auditRepo:   audit,
+		logger:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	ctx := domain.ContextWithPrincipal(context.Background(), domain.Principal{UserID: admin.UUID, Role: domain.RoleAdmin, TwoFactor: true})
+
+	t.Run("search pages newest first", func(t *testing.T) {
+		page, err := api.getUsersHandler(ctx, &GetUsersInput{Query: "farmer", Limit: 2})
+		require.NoError(t, err)
+		require.Len(t, page.Body.Users, 2)
+		assert.Equal(t, "farmer3@example.com", page.Body.Users[0].Email)
+		require.NotEmpty(t, page.Body.NextCursor)
+
+		page, err = api.getUsersHandler(ctx, &GetUsersInput{Query: "farmer", Limit: 2, Cursor: page.Body.NextCursor})
+		require.NoError(t, err)
+		require.Len(t, page.Body.Users, 1)
+		assert.Equal(t, "farmer1@example.com", page.Body.Users[0].Email)
+		assert.Empty(t, page.Body.NextCursor)
+
+		admins, err := api.getUsersHandler(ctx, &GetUsersInput{Role: domain.RoleAdmin})
+		require.NoError(t, err)
+		require.Len(t, admins.Body.Users, 1)
+		assert.Equal(t, admin.UUID, admins.Body.Users[0].UUID)
+
+		_, err = api.getUsersHandler(ctx, &GetUsersInput{Cursor: "not-a-cursor"})
+		var statusErr huma.StatusError
+		require.ErrorAs(t, err, &statusErr)
+		assert.Equal(t, 400, statusErr.GetStatus())
+	})
+
+	t.Run("deactivate and reactivate", func(t *testing.T) {
+		target, err := users.GetByEmail(ctx, "farmer2@example.com")
+		require.NoError(t, err)
+		sessions.On("RevokeAllForUser", mock.Anything, target.UUID).Return(nil).Once()
+
+		resp, err := api.deactivateUserHandler(ctx, &AdminUserInput{UserID: target.UUID})
+		require.NoError(t, err)
+		assert.False(t, resp.Body.IsActive)
+		assert.False(t, users[target.UUID].IsActive)
+		sessions.AssertCalled(t, "RevokeAllForUser", mock.Anything, target.UUID)
+
+		inactive, err := api.getUsersHandler(ctx, &GetUsersInput{Status: "inactive"})
+		require.NoError(t, err)
+		require.Len(t, inactive.Body.Users, 1)
+		assert.Equal(t, target.UUID, inactive.Body.Users[0].UUID)
+
+		resp, err = api.reactivateUserHandler(ctx, &AdminUserInput{UserID: target.UUID})
+		require.NoError(t, err)
+		assert.True(t, resp.Body.IsActive)
+		assert.True(t, users[target.UUID].IsActive)
+
+		var actions []string
+		for _, e := range *audit {
+			assert.Equal(t, admin.UUID, e.ActorID)
+			actions = append(actions, e.Action)
+		}
+		assert.Equal(t, []string{domain.AuditAccountDeactivated, domain.AuditAccountReactivated}, actions)
+	})
+
+	t.Run("admins cannot deactivate themselves", func(t *testing.T) {
+		_, err := api.deactivateUserHandler(ctx, &AdminUserInput{UserID: admin.UUID})
+		var statusErr huma.StatusError
+		require.ErrorAs(t, err, &statusErr)
+		assert.Equal(t, 409, statusErr.GetStatus())
+		assert.True(t, users[admin.UUID].IsActive)
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		_, err := api.getUserHandler(ctx, &AdminUserInput{UserID: uuid.New().String()})
+		var statusErr huma.StatusError
+		require.ErrorAs(t, err, &statusErr)
+		assert.Equal(t, 404, statusErr.GetStatus())
+	})
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/forfarm/backend/internal/domain"
+	m "github.com/forfarm/backend/internal/middlewares"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 )
@@ -15,28 +16,27 @@ func (a *api) registerAnalyticsRoutes(_ chi.Router, api huma.API) {
 	tags := []string{"analytics"}
 	prefix := "/analytics"
 
-	huma.Register(api, huma.Operation{
+	huma.Register(api, m.WithScope(domain.ScopeAnalyticsRead, m.WithPolicy(m.PolicyOwner, huma.Operation{
 		OperationID: "getFarmAnalytics",
 		Method:      http.MethodGet,
 		Path:        prefix + "/farm/{farmId}", // Changed path param name
 		Tags:        tags,
 		Summary:     "Get aggregated analytics data for a specific farm",
 		Description: "Retrieves various analytics metrics for a farm, requiring user ownership.",
-	}, a.getFarmAnalyticsHandler)
+	})), a.getFarmAnalyticsHandler)
 
 	// New endpoint for Crop Analytics
-	huma.Register(api, huma.Operation{
+	huma.Register(api, m.WithScope(domain.ScopeAnalyticsRead, m.WithPolicy(m.PolicyOwner, huma.Operation{
 		OperationID: "getCropAnalytics",
 		Method:      http.MethodGet,
 		Path:        prefix + "/crop/{cropId}", // Changed path param name
 		Tags:        tags,
 		Summary:     "Get analytics data for a specific crop",
 		Description: "Retrieves analytics metrics for a specific crop/cropland, requiring user ownership of the parent farm.",
-	}, a.getCropAnalyticsHandler)
+	})), a.getCropAnalyticsHandler)
 }
 
 type GetFarmAnalyticsInput struct {
-	Header string `header:"Authorization" required:"true" example:"Bearer token"`
 	FarmID string `path:"farmId" required:"true" doc:"UUID of the farm to get analytics for" example:"a1b2c3d4-e5f6-7890-1234-567890abcdef"` // Changed path param name
 }
 
@@ -46,7 +46,6 @@ type GetFarmAnalyticsOutput struct {
 
 // New Input Type for Crop Analytics
 type GetCropAnalyticsInput struct {
-	Header string `header:"Authorization" required:"true" example:"Bearer token"`
 	CropID string `path:"cropId" required:"true" doc:"UUID of the crop/cropland to get analytics for" example:"b2c3d4e5-f6a7-8901-2345-67890abcdef1"` // Changed path param name
 }
 
@@ -56,7 +55,7 @@ type GetCropAnalyticsOutput struct {
 }
 
 func (a *api) getFarmAnalyticsHandler(ctx context.Context, input *GetFarmAnalyticsInput) (*GetFarmAnalyticsOutput, error) {
-	userID, err := a.getUserIDFromHeader(input.Header)
+	userID, err := userIDFromContext(ctx)
 	if err != nil {
 		return nil, huma.Error401Unauthorized("Authentication failed: " + err.Error())
 	}
@@ -75,9 +74,14 @@ func (a *api) getFarmAnalyticsHandler(ctx context.Context, input *GetFarmAnalyti
 		return nil, huma.Error500InternalServerError("Failed to retrieve analytics data.")
 	}
 
-	// Authorization Check: User must own the farm
-	if analyticsData.OwnerID != userID {
-		a.logger.Warn("User attempted to access analytics for farm they do not own", "user_id", userID, "farm_id", input.FarmID, "owner_id", analyticsData.OwnerID)
+	// Authorization Check: User must have access to the farm
+	role, err := a.farmRole(ctx, analyticsData.FarmID, analyticsData.OwnerID, userID)
+	if err != nil {
+		a.logger.Error("Failed to get farm role", "farm_id", input.FarmID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to verify farm access.")
+	}
+	if !domain.FarmRoleAllows(role, domain.FarmPermView) {
+		a.logger.Warn("User attempted to access analytics for farm they are not a member of", "user_id", userID, "farm_id", input.FarmID)
 		return nil, huma.Error403Forbidden("You are not authorized to view analytics for this farm.")
 	}
 
@@ -89,7 +93,7 @@ func (a *api) getFarmAnalyticsHandler(ctx context.Context, input *GetFarmAnalyti
 
 // New Handler for Crop Analytics
 func (a *api) getCropAnalyticsHandler(ctx context.Context, input *GetCropAnalyticsInput) (*GetCropAnalyticsOutput, error) {
-	userID, err := a.getUserIDFromHeader(input.Header)
+	userID, err := userIDFromContext(ctx)
 	if err != nil {
 		return nil, huma.Error401Unauthorized("Authentication failed: " + err.Error())
 	}
@@ -109,7 +113,7 @@ func (a *api) getCropAnalyticsHandler(ctx context.Context, input *GetCropAnalyti
 		return nil, huma.Error500InternalServerError("Failed to retrieve crop analytics data.")
 	}
 
-	// Authorization Check: Verify user owns the farm this crop belongs to
+	// Authorization Check: Verify user has access to the farm this crop belongs to
 	farm, err := a.farmRepo.GetByID(ctx, cropAnalytics.FarmID)
 	if err != nil {
 		// This case is less likely if cropAnalytics was found, but handle defensively
@@ -121,8 +125,13 @@ func (a *api) getCropAnalyticsHandler(ctx context.Context, input *GetCropAnalyti
 		return nil, huma.Error500InternalServerError("Failed to verify ownership.")
 	}
 
-	if farm.OwnerID != userID {
-		a.logger.Warn("User attempted to access analytics for crop on farm they do not own", "user_id", userID, "crop_id", input.CropID, "farm_id", cropAnalytics.FarmID)
+	role, err := a.farmRole(ctx, farm.UUID, farm.OwnerID, userID)
+	if err != nil {
+		a.logger.Error("Failed to get farm role", "farm_id", farm.UUID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to verify ownership.")
+	}
+	if !domain.FarmRoleAllows(role, domain.FarmPermView) {
+		a.logger.Warn("User attempted to access analytics for crop on farm they are not a member of", "user_id", userID, "crop_id", input.CropID, "farm_id", cropAnalytics.FarmID)
 		return nil, huma.Error403Forbidden("You are not authorized to view analytics for this crop.")
 	}
 
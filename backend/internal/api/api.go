@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
-	"strings"
 	"time"
 
 	"github.com/danielgtaylor/huma/v2"
@@ -23,8 +22,9 @@ import (
 	m "github.com/forfarm/backend/internal/middlewares"
 	"github.com/forfarm/backend/internal/repository"
 	"github.com/forfarm/backend/internal/services"
+	"github.com/forfarm/backend/internal/services/mailer"
+	"github.com/forfarm/backend/internal/services/oidc"
 	"github.com/forfarm/backend/internal/services/weather"
-	"github.com/forfarm/backend/internal/utilities"
 )
 
 type api struct {
@@ -36,21 +36,44 @@ type api struct {
 	userRepo         domain.UserRepository
 	cropRepo         domain.CroplandRepository
 	farmRepo         domain.FarmRepository
+	farmMemberRepo   domain.FarmMemberRepository
 	plantRepo        domain.PlantRepository
 	inventoryRepo    domain.InventoryRepository
 	harvestRepo      domain.HarvestRepository
 	analyticsRepo    domain.AnalyticsRepository
 	knowledgeHubRepo domain.KnowledgeHubRepository
-
-	weatherFetcher domain.WeatherFetcher
+	webhookRepo      domain.WebhookRepository
+	sessionRepo      domain.SessionRepository
+	apiKeyRepo       domain.APIKeyRepository
+	twoFactorRepo    domain.TwoFactorRepository
+	auditRepo        domain.AuditLogRepository
+	identityRepo     domain.IdentityRepository
+	dataExportRepo   domain.DataExportRepository
+
+	weatherFetcher    domain.WeatherFetcher
+	mailer            domain.Mailer
+	identityProviders *oidc.Registry
 
 	chatService *services.ChatService
+	loginGuard  *services.LoginGuard
+
+	eventBusHealth func(context.Context) error
+	eventListener  eventListener
+	eventStore     domain.EventStore
+
+	// shutdown is closed when the server shuts down, ending long-lived streams.
+	shutdown <-chan struct{}
 }
 
 func (a *api) GetWeatherFetcher() domain.WeatherFetcher {
 	return a.weatherFetcher
 }
 
+// SetEventBusHealthCheck makes /health report the event bus connection.
+func (a *api) SetEventBusHealthCheck(check func(context.Context) error) {
+	a.eventBusHealth = check
+}
+
 func NewAPI(
 	ctx context.Context,
 	logger *slog.Logger,
@@ -71,7 +94,8 @@ func NewAPI(
 	harvestRepository := repository.NewPostgresHarvest(pool, memoryCache)
 	knowledgeHubRepository := repository.NewPostgresKnowledgeHub(pool)
 	croplandRepo := repository.NewPostgresCropland(pool)
-	croplandRepo.SetEventPublisher(eventPublisher)
+	farmMemberRepo := repository.NewPostgresFarmMember(pool)
+	auditRepo := repository.NewPostgresAuditLog(pool)
 
 	owmFetcher := weather.NewOpenWeatherMapFetcher(config.OPENWEATHER_API_KEY, client, logger)
 	cacheTTL, err := time.ParseDuration(config.OPENWEATHER_CACHE_TTL)
@@ -85,7 +109,19 @@ func NewAPI(
 	}
 	cachedWeatherFetcher := weather.NewCachedWeatherFetcher(owmFetcher, cacheTTL, cleanupInterval, logger)
 
-	chatService, chatErr := services.NewChatService(logger, analyticsRepo, farmRepo, croplandRepo, inventoryRepo, plantRepository)
+	mailSender, err := mailer.New(logger)
+	if err != nil {
+		logger.Error("Failed to initialize mailer, logging emails instead", "driver", config.MAILER_DRIVER, "error", err)
+		mailSender = mailer.NewLogMailer(logger)
+	}
+
+	identityProviders, err := oidc.New(client)
+	if err != nil {
+		logger.Error("Failed to configure identity providers, disabling provider sign-in", "providers", config.OIDC_PROVIDERS, "error", err)
+		identityProviders = oidc.NewRegistry(client)
+	}
+
+	chatService, chatErr := services.NewChatService(logger, analyticsRepo, farmRepo, farmMemberRepo, croplandRepo, inventoryRepo, plantRepository)
 	if chatErr != nil {
 		logger.Error("Failed to initialize ChatService", "error", chatErr)
 		chatService = nil
@@ -100,24 +136,39 @@ func NewAPI(
 		userRepo:         userRepository,
 		cropRepo:         croplandRepo,
 		farmRepo:         farmRepo,
+		farmMemberRepo:   farmMemberRepo,
 		plantRepo:        plantRepository,
 		inventoryRepo:    inventoryRepo,
 		harvestRepo:      harvestRepository,
 		analyticsRepo:    analyticsRepo,
 		knowledgeHubRepo: knowledgeHubRepository,
-		weatherFetcher:   cachedWeatherFetcher,
+		webhookRepo:      repository.NewPostgresWebhook(pool),
+		sessionRepo:      repository.NewPostgresSession(pool),
+		apiKeyRepo:       repository.NewPostgresAPIKey(pool),
+		twoFactorRepo:    repository.NewPostgresTwoFactor(pool),
+		auditRepo:        auditRepo,
+		identityRepo:     repository.NewPostgresIdentity(pool),
+		dataExportRepo:   repository.NewPostgresDataExport(pool),
+
+		weatherFetcher:    cachedWeatherFetcher,
+		mailer:            mailSender,
+		identityProviders: identityProviders,
 
 		chatService: chatService,
+		loginGuard:  services.NewLoginGuard(logger, repository.NewPostgresLoginThrottle(pool), auditRepo),
+
+		shutdown: ctx.Done(),
 	}
 }
 
-func (a *api) getUserIDFromHeader(authHeader string) (string, error) {
-	const bearerPrefix = "Bearer "
-	if !strings.HasPrefix(authHeader, bearerPrefix) {
-		return "", errors.New("invalid authorization header")
+// userIDFromContext returns the ID of the caller the auth middleware
+// authenticated.
+func userIDFromContext(ctx context.Context) (string, error) {
+	principal, ok := domain.PrincipalFromContext(ctx)
+	if !ok {
+		return "", errors.New("request is not authenticated")
 	}
-	tokenString := strings.TrimPrefix(authHeader, bearerPrefix)
-	return utilities.ExtractUUIDFromToken(tokenString)
+	return principal.UserID, nil
 }
 
 func (a *api) Server(port int) *http.Server {
@@ -128,12 +179,17 @@ func (a *api) Server(port int) *http.Server {
 
 func (a *api) Routes() *chi.Mux {
 	router := chi.NewRouter()
+	if config.TRUST_PROXY_HEADERS {
+		// Take the client address from X-Forwarded-For and X-Real-IP, for
+		// rate limits and sign-in lockouts behind a load balancer.
+		router.Use(middleware.RealIP)
+	}
 	router.Use(middleware.Logger)
 
 	router.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"http://localhost:3000", "http://localhost:5173", "http://127.0.0.1:3000"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "Last-Event-ID"},
 		ExposedHeaders:   []string{"Link"},
 		AllowCredentials: true,
 		MaxAge:           300,
@@ -155,24 +211,44 @@ func (a *api) Routes() *chi.Mux {
 	} // --- End Rate Limiter Middleware ---
 
 	humaConfig := huma.DefaultConfig("ForFarm Public API", "v1.0.0")
+	humaConfig.Components.SecuritySchemes = map[string]*huma.SecurityScheme{
+		m.BearerAuthScheme: {
+			Type:         "http",
+			Scheme:       "bearer",
+			BearerFormat: "JWT",
+			Description:  "An access token, or an API key (starting with `ffk_`) for operations that list a scope.",
+		},
+	}
 	api := humachi.New(router, humaConfig)
 
+	// Every operation declares its access policy with m.WithPolicy; the auth
+	// middleware enforces it and refuses operations that have none.
+	api.UseMiddleware(m.RequestInfoMiddleware)
+	api.UseMiddleware(m.AuthMiddleware(api, a.sessionRepo, a.apiKeyRepo))
+
 	router.Group(func(r chi.Router) {
 		a.registerAuthRoutes(r, api)
-		a.registerCropRoutes(r, api)
+		a.registerSessionRoutes(r, api)
+		a.registerTwoFactorRoutes(r, api)
+		a.registerLoginGuardRoutes(r, api)
+		a.registerAdminUserRoutes(r, api)
+		a.registerAuditRoutes(r, api)
+		a.registerAPIKeyRoutes(r, api)
+		a.registerAccountRoutes(r, api)
+		a.registerAccountDataRoutes(r, api)
+		a.registerOauthRoutes(r, api)
+		a.registerHealthRoutes(r, api)
 		a.registerPlantRoutes(r, api)
 		a.registerKnowledgeHubRoutes(r, api)
-		a.registerOauthRoutes(r, api)
-		a.registerChatRoutes(r, api)
 		a.registerInventoryRoutes(r, api)
-		a.registerHealthRoutes(r, api)
-	})
-
-	router.Group(func(r chi.Router) {
-		api.UseMiddleware(m.AuthMiddleware(api))
+		a.registerCropRoutes(r, api)
+		a.registerChatRoutes(r, api)
 		a.registerFarmRoutes(r, api)
+		a.registerFarmMemberRoutes(r, api)
 		a.registerUserRoutes(r, api)
 		a.registerAnalyticsRoutes(r, api)
+		a.registerEventRoutes(r, api)
+		a.registerWebhookRoutes(r, api)
 	})
 
 	return router
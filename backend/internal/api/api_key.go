@@ -0,0 +1,193 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/go-chi/chi/v5"
+
+	"github.com/forfarm/backend/internal/domain"
+	m "github.com/forfarm/backend/internal/middlewares"
+	"github.com/forfarm/backend/internal/utilities"
+)
+
+func (a *api) registerAPIKeyRoutes(_ chi.Router, api huma.API) {
+	tags := []string{"api-key"}
+	prefix := "/api-keys"
+
+	huma.Register(api, m.WithPolicy(m.PolicyOwner, huma.Operation{
+		OperationID: "getAPIKeys",
+		Method:      http.MethodGet,
+		Path:        prefix,
+		Tags:        tags,
+	}), a.getAPIKeysHandler)
+
+	huma.Register(api, m.WithPolicy(m.PolicyOwner, huma.Operation{
+		OperationID:   "createAPIKey",
+		Method:        http.MethodPost,
+		Path:          prefix,
+		Tags:          tags,
+		DefaultStatus: http.StatusCreated,
+		Summary:       "Create an API key for a machine client",
+		Description: "The key is sent as a bearer token and reaches only the operations whose scope it was " +
+			"granted, and only farmId's farm if one is set. It is returned on creation only.",
+	}), a.createAPIKeyHandler)
+
+	huma.Register(api, m.WithPolicy(m.PolicyOwner, huma.Operation{
+		OperationID: "revokeAPIKey",
+		Method:      http.MethodDelete,
+		Path:        prefix + "/{keyId}",
+		Tags:        tags,
+	}), a.revokeAPIKeyHandler)
+}
+
+//
+// Input and Output types
+//
+
+type GetAPIKeysOutput struct {
+	Body []domain.APIKey
+}
+
+type CreateAPIKeyInput struct {
+	Body struct {
+		Name      string     `json:"name" required:"true" maxLength:"100" example:"Irrigation controller"`
+		Scopes    []string   `json:"scopes" required:"true" minItems:"1" example:"[\"farms:read\",\"crops:write\"]"`
+		FarmID    *string    `json:"farmId,omitempty" format:"uuid" doc:"Restrict the key to one farm"`
+		ExpiresAt *time.Time `json:"expiresAt,omitempty" doc:"When the key stops working; never if omitted"`
+	}
+}
+
+// APIKeyWithSecret is an API key together with the key itself.
+type APIKeyWithSecret struct {
+	domain.APIKey
+	Key string `json:"key"`
+}
+
+type CreateAPIKeyOutput struct {
+	Body APIKeyWithSecret
+}
+
+type RevokeAPIKeyInput struct {
+	KeyID string `path:"keyId" required:"true"`
+}
+
+type RevokeAPIKeyOutput struct {
+	Body struct {
+		Message string `json:"message"`
+	}
+}
+
+//
+// API Handlers
+//
+
+func (a *api) getAPIKeysHandler(ctx context.Context, _ *struct{}) (*GetAPIKeysOutput, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("Authentication failed", err)
+	}
+
+	keys, err := a.apiKeyRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		a.logger.Error("Failed to get API keys", "userId", userID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to retrieve API keys")
+	}
+	return &GetAPIKeysOutput{Body: keys}, nil
+}
+
+func (a *api) createAPIKeyHandler(ctx context.Context, input *CreateAPIKeyInput) (*CreateAPIKeyOutput, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("Authentication failed", err)
+	}
+
+	key, displayPrefix, hash, err := utilities.NewAPIKey()
+	if err != nil {
+		a.logger.Error("Failed to generate API key", "error", err)
+		return nil, huma.Error500InternalServerError("Failed to create API key")
+	}
+
+	apiKey := &domain.APIKey{
+		UserID:    userID,
+		Name:      input.Body.Name,
+		Prefix:    displayPrefix,
+		KeyHash:   hash,
+		Scopes:    input.Body.Scopes,
+		FarmID:    input.Body.FarmID,
+		ExpiresAt: input.Body.ExpiresAt,
+	}
+	if err := apiKey.Validate(); err != nil {
+		return nil, huma.Error422UnprocessableEntity("Validation failed", err)
+	}
+	if apiKey.FarmID != nil {
+		if _, err := a.authorizeFarm(ctx, *apiKey.FarmID, userID, domain.FarmPermView); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := a.apiKeyRepo.Create(ctx, apiKey); err != nil {
+		a.logger.Error("Failed to create API key", "userId", userID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to create API key")
+	}
+
+	a.logger.Info("API key created", "keyId", apiKey.UUID, "userId", userID, "scopes", apiKey.Scopes)
+	a.audit(ctx, domain.AuditEntry{
+		Action:       domain.AuditAPIKeyCreated,
+		UserID:       userID,
+		ResourceType: domain.AuditResourceAPIKey,
+		ResourceID:   apiKey.UUID,
+		Changes:      domain.AuditChanges(nil, apiKey),
+	})
+	return &CreateAPIKeyOutput{Body: APIKeyWithSecret{APIKey: *apiKey, Key: key}}, nil
+}
+
+func (a *api) revokeAPIKeyHandler(ctx context.Context, input *RevokeAPIKeyInput) (*RevokeAPIKeyOutput, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("Authentication failed", err)
+	}
+
+	apiKey, err := a.apiKeyRepo.GetByID(ctx, input.KeyID)
+	if errors.Is(err, domain.ErrNotFound) || (err == nil && (apiKey.UserID != userID || apiKey.RevokedAt != nil)) {
+		return nil, huma.Error404NotFound("API key not found")
+	}
+	if err != nil {
+		a.logger.Error("Failed to get API key", "keyId", input.KeyID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to revoke API key")
+	}
+
+	if err := a.apiKeyRepo.Revoke(ctx, apiKey.UUID); err != nil {
+		a.logger.Error("Failed to revoke API key", "keyId", apiKey.UUID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to revoke API key")
+	}
+
+	a.logger.Info("API key revoked", "keyId", apiKey.UUID, "userId", userID)
+	a.audit(ctx, domain.AuditEntry{
+		Action:       domain.AuditAPIKeyRevoked,
+		UserID:       userID,
+		ResourceType: domain.AuditResourceAPIKey,
+		ResourceID:   apiKey.UUID,
+	})
+	resp := &RevokeAPIKeyOutput{}
+	resp.Body.Message = "API key revoked"
+	return resp, nil
+}
+
+// accessibleFarms drops the farms the caller's API key is not restricted to.
+func accessibleFarms(ctx context.Context, farms []domain.Farm) []domain.Farm {
+	p, ok := domain.PrincipalFromContext(ctx)
+	if !ok || p.FarmID == "" {
+		return farms
+	}
+	filtered := []domain.Farm{}
+	for _, farm := range farms {
+		if p.CanAccessFarm(farm.UUID) {
+			filtered = append(filtered, farm)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,211 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/go-chi/chi/v5"
+
+	"github.com/forfarm/backend/internal/domain"
+	m "github.com/forfarm/backend/internal/middlewares"
+	"github.com/forfarm/backend/internal/services"
+)
+
+const defaultAuditPageSize = 50
+
+func (a *api) registerAuditRoutes(_ chi.Router, api huma.API) {
+	huma.Register(api, m.WithPolicy(m.PolicyOwner, huma.Operation{
+		OperationID: "getFarmAuditLog",
+		Method:      http.MethodGet,
+		Path:        "/farms/{farmId}/audit-log",
+		Tags:        []string{"farm"},
+		Summary:     "List changes made to a farm and its data",
+		Description: "Only the farm's owner may read it. Entries are newest first; pass nextCursor as cursor for the next page.",
+	}), a.getFarmAuditLogHandler)
+
+	huma.Register(api, m.WithPolicy(m.PolicyAdmin, huma.Operation{
+		OperationID: "getAuditLog",
+		Method:      http.MethodGet,
+		Path:        "/admin/audit-log",
+		Tags:        []string{"admin"},
+		Summary:     "List audit entries across the platform",
+		Description: "Entries are newest first; pass nextCursor as cursor for the next page.",
+	}), a.getAuditLogHandler)
+}
+
+// AuditLogQuery holds the filters shared by the audit log listings.
+type AuditLogQuery struct {
+	ActorID      string    `query:"actorId" format:"uuid"`
+	Action       string    `query:"action" example:"inventory_item.deleted"`
+	ResourceType string    `query:"resourceType" example:"inventory_item"`
+	ResourceID   string    `query:"resourceId"`
+	Since        time.Time `query:"since" format:"date-time"`
+	Until        time.Time `query:"until" format:"date-time"`
+	Cursor       string    `query:"cursor"`
+	Limit        int       `query:"limit" minimum:"1" maximum:"200" doc:"Entries per page (default 50)"`
+}
+
+// filter turns the query into a repository filter that asks for one entry
+// more than the page holds, to tell whether there is a next page.
+func (q *AuditLogQuery) filter() (domain.AuditLogFilter, error) {
+	f := domain.AuditLogFilter{
+		ActorID:      q.ActorID,
+		Action:       q.Action,
+		ResourceType: q.ResourceType,
+		ResourceID:   q.ResourceID,
+		Limit:        q.Limit,
+	}
+	if f.Limit == 0 {
+		f.Limit = defaultAuditPageSize
+	}
+	f.Limit++
+	if !q.Since.IsZero() {
+		f.Since = &q.Since
+	}
+	if !q.Until.IsZero() {
+		f.Until = &q.Until
+	}
+	if q.Cursor != "" {
+		cursor, err := decodeAuditCursor(q.Cursor)
+		if err != nil {
+			return f, huma.Error400BadRequest("Invalid cursor")
+		}
+		f.Before = cursor
+	}
+	return f, nil
+}
+
+func encodeAuditCursor(e domain.AuditEntry) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(e.CreatedAt.Format(time.RFC3339Nano) + "|" + e.UUID))
+}
+
+func decodeAuditCursor(s string) (*domain.AuditCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	createdAt, id, _ := strings.Cut(string(data), "|")
+	t, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.AuditCursor{CreatedAt: t, UUID: id}, nil
+}
+
+type GetFarmAuditLogInput struct {
+	FarmID string `path:"farmId" required:"true" format:"uuid"`
+	AuditLogQuery
+}
+
+type GetAuditLogInput struct {
+	FarmID string `query:"farmId" format:"uuid"`
+	UserID string `query:"userId" format:"uuid" doc:"Account the entries concern"`
+	AuditLogQuery
+}
+
+type GetAuditLogOutput struct {
+	Body struct {
+		Entries    []domain.AuditEntry `json:"entries"`
+		NextCursor string              `json:"nextCursor,omitempty"`
+	}
+}
+
+// audit records an action in the audit log, filling in the caller and where
+// the request came from. The action has already happened, so a failure to
+// record it is logged rather than returned.
+func (a *api) audit(ctx context.Context, e domain.AuditEntry) {
+	if p, ok := domain.PrincipalFromContext(ctx); ok {
+		if e.ActorID == "" {
+			e.ActorID = p.UserID
+		}
+		e.APIKeyID = p.APIKeyID
+	}
+	info := domain.RequestInfoFromContext(ctx)
+	if e.IPAddress == "" {
+		e.IPAddress = info.IPAddress
+	}
+	e.UserAgent = info.UserAgent
+
+	if err := a.auditRepo.Record(ctx, &e); err != nil {
+		a.logger.Error("Failed to record audit entry", "action", e.Action, "resourceId", e.ResourceID, "error", err)
+	}
+}
+
+// auditDetails encodes the details of an audit entry.
+func auditDetails(details map[string]interface{}) json.RawMessage {
+	data, _ := json.Marshal(details)
+	return data
+}
+
+// auditAccount records an action users take on their own account.
+func (a *api) auditAccount(ctx context.Context, action, userID string, details map[string]interface{}) {
+	e := domain.AuditEntry{
+		Action:       action,
+		UserID:       userID,
+		ActorID:      userID,
+		ResourceType: domain.AuditResourceUser,
+		ResourceID:   userID,
+	}
+	if details != nil {
+		e.Details = auditDetails(details)
+	}
+	a.audit(ctx, e)
+}
+
+func (a *api) auditLoginFailed(ctx context.Context, attempt services.LoginAttempt, reason string) {
+	a.audit(ctx, domain.AuditEntry{
+		Action:       domain.AuditLoginFailed,
+		UserID:       attempt.UserID,
+		ResourceType: domain.AuditResourceUser,
+		ResourceID:   attempt.UserID,
+		Details:      auditDetails(map[string]interface{}{"email": attempt.Email, "reason": reason}),
+	})
+}
+
+func (a *api) listAuditLog(ctx context.Context, filter domain.AuditLogFilter) (*GetAuditLogOutput, error) {
+	entries, err := a.auditRepo.List(ctx, filter)
+	if err != nil {
+		a.logger.Error("Failed to list audit log", "error", err)
+		return nil, huma.Error500InternalServerError("Failed to retrieve audit log")
+	}
+
+	resp := &GetAuditLogOutput{}
+	if pageSize := filter.Limit - 1; len(entries) > pageSize {
+		entries = entries[:pageSize]
+		resp.Body.NextCursor = encodeAuditCursor(entries[pageSize-1])
+	}
+	resp.Body.Entries = entries
+	return resp, nil
+}
+
+func (a *api) getFarmAuditLogHandler(ctx context.Context, input *GetFarmAuditLogInput) (*GetAuditLogOutput, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("Authentication failed", err)
+	}
+	if _, err := a.authorizeFarm(ctx, input.FarmID, userID, domain.FarmPermViewAuditLog); err != nil {
+		return nil, err
+	}
+
+	filter, err := input.filter()
+	if err != nil {
+		return nil, err
+	}
+	filter.FarmID = input.FarmID
+	return a.listAuditLog(ctx, filter)
+}
+
+func (a *api) getAuditLogHandler(ctx context.Context, input *GetAuditLogInput) (*GetAuditLogOutput, error) {
+	filter, err := input.filter()
+	if err != nil {
+		return nil, err
+	}
+	filter.FarmID = input.FarmID
+	filter.UserID = input.UserID
+	return a.listAuditLog(ctx, filter)
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/forfarm/backend/internal/domain"
+)
+
+// memoryAuditLog is an in-memory domain.AuditLogRepository.
+type memoryAuditLog []domain.AuditEntry
+
+func (m *memoryAuditLog) Record(_ context.Context, e *domain.AuditEntry) error {
+	e.UUID = uuid.New().String()
+	e.CreatedAt = time.Now()
+	*m = append(*m, *e)
+	return nil
+}
+
+func (m *memoryAuditLog) List(_ context.Context, filter domain.AuditLogFilter) ([]domain.AuditEntry, error) {
+	entries := []domain.AuditEntry{}
+	for _, e := range *m {
+		if (filter.FarmID != "" && e.FarmID != filter.FarmID) ||
+			(filter.UserID != "" && e.UserID != filter.UserID) ||
+			(filter.ActorID != "" && e.ActorID != filter.ActorID) ||
+			(filter.Action != "" && e.Action != filter.Action) {
+			continue
+		}
+		if b := filter.Before; b != nil && !(e.CreatedAt.Before(b.CreatedAt) || (e.CreatedAt.Equal(b.CreatedAt) && e.UUID < b.UUID)) {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].CreatedAt.Equal(entries[j].CreatedAt) {
+			return entries[i].CreatedAt.After(entries[j].CreatedAt)
+		}
+		return entries[i].UUID > entries[j].UUID
+	})
+	if len(entries) > filter.Limit {
+		entries = entries[:filter.Limit]
+	}
+	return entries, nil
+}
+
+func (m *memoryAuditLog) Anonymize(_ context.Context, userID string, farmIDs []string) error {
+	for i, e := range *m {
+		inFarm := false
+		for _, id := range farmIDs {
+			inFarm = inFarm || e.FarmID == id
+		}
+		if e.UserID == userID || inFarm {
+			if e.UserID == userID {
+				e.UserID = ""
+			}
+			e.Changes, e.Details = nil, nil
+		}
+		if e.ActorID == userID {
+			e.ActorID, e.APIKeyID, e.IPAddress, e.UserAgent = "", "", "", ""
+		}
+		(*m)[i] = e
+	}
+	return nil
+}
+
+func TestAuditLog(t *testing.T) {
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("ValidPass123!"), bcrypt.MinCost)
+	user := domain.User{
+		UUID:     uuid.New().String(),
+		Email:    "test@example.com",
+		Password: string(hashedPassword),
+		IsActive: true,
+	}
+	mockRepo := &MockUserRepository{}
+	mockRepo.On("GetByEmail", mock.Anything, user.Email).Return(user, nil)
+
+	audit := &memoryAuditLog{}
+	api := &api{
+		userRepo:      mockRepo,
+		sessionRepo:   newMockSessions(),
+		twoFactorRepo: newMemoryTwoFactor(),
+		loginGuard:    newTestLoginGuard(),
+		auditRepo:     audit,
+		logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	ctx := domain.ContextWithRequestInfo(context.Background(), domain.RequestInfo{IPAddress: "203.0.113.7", UserAgent: "test-agent"})
+
+	for _, password := range []string{"wrongpassword", "wrongpassword", "ValidPass123!"} {
+		_, _ = api.loginHandler(ctx, &LoginInput{ClientIP: "203.0.113.7", Body: EmailPasswordInput{Email: user.Email, Password: password}})
+	}
+
+	// Sign-in attempts are recorded with where they came from.
+	require.Len(t, *audit, 3)
+	failed := (*audit)[0]
+	assert.Equal(t, domain.AuditLoginFailed, failed.Action)
+	assert.Equal(t, user.UUID, failed.UserID)
+	assert.Equal(t, "203.0.113.7", failed.IPAddress)
+	assert.Equal(t, "test-agent", failed.UserAgent)
+	assert.JSONEq(t, `{"email":"test@example.com","reason":"wrong_password"}`, string(failed.Details))
+	assert.Equal(t, domain.AuditLoginSucceeded, (*audit)[2].Action)
+	assert.Equal(t, user.UUID, (*audit)[2].ActorID)
+
+	// The listing pages through the log newest first.
+	var seen []string
+	input := &GetAuditLogInput{UserID: user.UUID, AuditLogQuery: AuditLogQuery{Limit: 2}}
+	for page := 0; page < 3; page++ {
+		resp, err := api.getAuditLogHandler(context.Background(), input)
+		require.NoError(t, err)
+		for _, e := range resp.Body.Entries {
+			seen = append(seen, e.UUID)
+		}
+		if resp.Body.NextCursor == "" {
+			break
+		}
+		input.Cursor = resp.Body.NextCursor
+	}
+	require.Len(t, seen, 3)
+	assert.Equal(t, (*audit)[2].UUID, seen[0])
+	assert.Equal(t, (*audit)[0].UUID, seen[2])
+
+	input.Cursor = "not a cursor"
+	_, err := api.getAuditLogHandler(context.Background(), input)
+	assertStatus(t, err, 400)
+}
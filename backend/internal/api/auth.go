@@ -7,8 +7,10 @@ import (
 	"regexp"
 
 	"github.com/danielgtaylor/huma/v2"
+	"github.com/forfarm/backend/internal/config"
 	"github.com/forfarm/backend/internal/domain"
-	"github.com/forfarm/backend/internal/utilities"
+	m "github.com/forfarm/backend/internal/middlewares"
+	"github.com/forfarm/backend/internal/services"
 	"github.com/go-chi/chi/v5"
 	validation "github.com/go-ozzo/ozzo-validation/v4"
 	"golang.org/x/crypto/bcrypt"
@@ -18,36 +20,42 @@ func (a *api) registerAuthRoutes(_ chi.Router, api huma.API) {
 	tags := []string{"auth"}
 	prefix := "/auth"
 
-	huma.Register(api, huma.Operation{
+	huma.Register(api, m.WithPolicy(m.PolicyPublic, huma.Operation{
 		OperationID: "register",
 		Method:      http.MethodPost,
 		Path:        prefix + "/register",
 		Tags:        tags,
-	}, a.registerHandler)
+	}), a.registerHandler)
 
-	huma.Register(api, huma.Operation{
+	huma.Register(api, m.WithPolicy(m.PolicyPublic, huma.Operation{
 		OperationID: "login",
 		Method:      http.MethodPost,
 		Path:        prefix + "/login",
 		Tags:        tags,
-	}, a.loginHandler)
+	}), a.loginHandler)
 }
 
 type LoginInput struct {
-	Body struct {
+	UserAgent string `header:"User-Agent"`
+	ClientIP  string
+	Body      struct {
 		Email    string `json:"email" example:"Email address of the user"`
 		Password string `json:"password" example:"Password of the user"`
 	}
 }
 
+func (i *LoginInput) Resolve(ctx huma.Context) []error {
+	i.ClientIP = m.ClientIP(ctx)
+	return nil
+}
+
 type LoginOutput struct {
-	Body struct {
-		Token string `json:"token" example:"JWT token for the user"`
-	}
+	Body LoginResult
 }
 
 type RegisterInput struct {
-	Body struct {
+	UserAgent string `header:"User-Agent"`
+	Body      struct {
 		Email    string `json:"email" example:"Email address of the user"`
 		Password string `json:"password" example:"Password of the user"`
 	}
@@ -55,8 +63,10 @@ type RegisterInput struct {
 
 type RegisterOutput struct {
 	Body struct {
-		// Use camelCase for JSON tags
-		Token string `json:"token" example:"JWT token for the user"`
+		TokenPair
+		// VerificationRequired is set, and no tokens are issued, when the
+		// email address must be verified before signing in.
+		VerificationRequired bool `json:"verificationRequired,omitempty"`
 	}
 }
 
@@ -103,9 +113,10 @@ func (a *api) registerHandler(ctx context.Context, input *RegisterInput) (*Regis
 		}
 
 		newUser := &domain.User{
-			Email:    input.Body.Email,
-			Password: string(hashedPassword),
-			IsActive: true,
+			Email:       input.Body.Email,
+			Password:    string(hashedPassword),
+			IsActive:    true,
+			PasswordSet: true,
 		}
 
 		createErr := a.userRepo.CreateOrUpdate(ctx, newUser)
@@ -114,8 +125,17 @@ func (a *api) registerHandler(ctx context.Context, input *RegisterInput) (*Regis
 			// Check for specific database errors if needed (e.g., unique constraint violation)
 			return nil, huma.Error500InternalServerError("Failed to register user")
 		}
+		a.auditAccount(ctx, domain.AuditRegistered, newUser.UUID, nil)
 
-		token, tokenErr := utilities.CreateJwtToken(newUser.UUID)
+		if mailErr := a.sendVerificationEmail(ctx, newUser); mailErr != nil {
+			a.logger.Error("Failed to send verification email after registration", "user_uuid", newUser.UUID, "error", mailErr)
+		}
+		if config.REQUIRE_VERIFIED_EMAIL {
+			resp.Body.VerificationRequired = true
+			return resp, nil
+		}
+
+		tokens, tokenErr := a.startSession(ctx, newUser, input.UserAgent, false)
 		if tokenErr != nil {
 			a.logger.Error("Failed to create JWT token after registration", "user_uuid", newUser.UUID, "error", tokenErr)
 			// Consider how to handle this - user is created but can't log in immediately.
@@ -123,7 +143,7 @@ func (a *api) registerHandler(ctx context.Context, input *RegisterInput) (*Regis
 			return nil, huma.Error500InternalServerError("Registration partially succeeded, but failed to generate token")
 		}
 
-		resp.Body.Token = token
+		resp.Body.TokenPair = tokens
 		return resp, nil
 	} else if err == nil {
 		return nil, huma.Error409Conflict("User with this email already exists")
@@ -151,10 +171,18 @@ func (a *api) loginHandler(ctx context.Context, input *LoginInput) (*LoginOutput
 		return nil, huma.Error422UnprocessableEntity("Validation failed", err)
 	}
 
+	attempt := services.LoginAttempt{Email: input.Body.Email, IP: input.ClientIP}
+	if err := a.loginGuard.Check(ctx, attempt); err != nil {
+		a.logger.Warn("Throttled login attempt", "email", input.Body.Email, "ip", input.ClientIP, "error", err)
+		return nil, loginThrottled(err)
+	}
+
 	user, err := a.userRepo.GetByEmail(ctx, input.Body.Email)
 	if err != nil {
 		if errors.Is(err, domain.ErrNotFound) {
 			a.logger.Warn("Login attempt for non-existent user", "email", input.Body.Email)
+			a.loginGuard.Fail(ctx, attempt)
+			a.auditLoginFailed(ctx, attempt, "unknown_email")
 			return nil, huma.Error401Unauthorized("Invalid email or password") // Generic error for security
 		}
 		a.logger.Error("Database error during login lookup", "email", input.Body.Email, "error", err)
@@ -169,16 +197,29 @@ func (a *api) loginHandler(ctx context.Context, input *LoginInput) (*LoginOutput
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Body.Password)); err != nil {
 		a.logger.Warn("Incorrect password attempt", "email", input.Body.Email, "user_uuid", user.UUID)
+		attempt.UserID = user.UUID
+		a.loginGuard.Fail(ctx, attempt)
+		a.auditLoginFailed(ctx, attempt, "wrong_password")
 		// Do not differentiate between wrong email and wrong password for security
 		return nil, huma.Error401Unauthorized("Invalid email or password")
 	}
 
-	token, err := utilities.CreateJwtToken(user.UUID)
+	if config.REQUIRE_VERIFIED_EMAIL && !user.IsEmailVerified() {
+		a.logger.Warn("Login attempt with unverified email", "user_uuid", user.UUID)
+		return nil, huma.Error403Forbidden("Email address is not verified")
+	}
+
+	result, err := a.completeLogin(ctx, &user, input.UserAgent)
 	if err != nil {
-		a.logger.Error("Failed to create JWT token during login", "user_uuid", user.UUID, "error", err)
+		a.logger.Error("Failed to start session during login", "user_uuid", user.UUID, "error", err)
 		return nil, huma.Error500InternalServerError("Failed to generate login token")
 	}
 
-	resp.Body.Token = token
+	if !result.TwoFactorRequired {
+		a.loginGuard.Succeed(ctx, attempt)
+		a.auditAccount(ctx, domain.AuditLoginSucceeded, user.UUID, map[string]interface{}{"method": "password"})
+	}
+
+	resp.Body = result
 	return resp, nil
 }
@@ -2,17 +2,25 @@ package api
 
 import (
 	"context"
+	"errors"
 	"github.com/danielgtaylor/huma/v2"
+	"github.com/forfarm/backend/internal/services"
 	"github.com/forfarm/backend/internal/utilities"
 	"golang.org/x/crypto/bcrypt"
+	"io"
 	"log/slog"
+	"net/http"
+	"net/url"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/forfarm/backend/internal/domain"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 type MockUserRepository struct {
@@ -54,6 +62,205 @@ func (m *MockUserRepository) Delete(ctx context.Context, id int64) error {
 	return args.Error(0)
 }
 
+func (m *MockUserRepository) SetActive(ctx context.Context, userID string, active bool) error {
+	args := m.Called(ctx, userID, active)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) SetDeletionScheduledAt(ctx context.Context, userID string, at *time.Time) error {
+	args := m.Called(ctx, userID, at)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) GetDueForDeletion(ctx context.Context, now time.Time) ([]domain.User, error) {
+	args := m.Called(ctx, now)
+	return args.Get(0).([]domain.User), args.Error(1)
+}
+
+func (m *MockUserRepository) DeleteIfDue(ctx context.Context, userID string, now time.Time, cleanup func(domain.User, domain.AccountDeletionStores) error) (bool, error) {
+	args := m.Called(ctx, userID, now, cleanup)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockUserRepository) List(ctx context.Context, filter domain.UserFilter) ([]domain.User, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]domain.User), args.Error(1)
+}
+
+type MockSessionRepository struct {
+	mock.Mock
+}
+
+func (m *MockSessionRepository) Create(ctx context.Context, s *domain.Session) error {
+	args := m.Called(ctx, s)
+	if s.UUID == "" {
+		s.UUID = uuid.New().String()
+	}
+	return args.Error(0)
+}
+
+func (m *MockSessionRepository) GetByID(ctx context.Context, id string) (*domain.Session, error) {
+	args := m.Called(ctx, id)
+	s, _ := args.Get(0).(*domain.Session)
+	return s, args.Error(1)
+}
+
+func (m *MockSessionRepository) GetActiveByUserID(ctx context.Context, userID string) ([]domain.Session, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]domain.Session), args.Error(1)
+}
+
+func (m *MockSessionRepository) Rotate(ctx context.Context, tokenHash, newHash string, expiresAt time.Time) (*domain.Session, error) {
+	args := m.Called(ctx, tokenHash, newHash, expiresAt)
+	s, _ := args.Get(0).(*domain.Session)
+	return s, args.Error(1)
+}
+
+func (m *MockSessionRepository) IsActive(ctx context.Context, id string) (bool, error) {
+	args := m.Called(ctx, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockSessionRepository) Revoke(ctx context.Context, id string) error {
+	return m.Called(ctx, id).Error(0)
+}
+
+func (m *MockSessionRepository) RevokeAllForUser(ctx context.Context, userID string) error {
+	return m.Called(ctx, userID).Error(0)
+}
+
+func newMockSessions() *MockSessionRepository {
+	m := &MockSessionRepository{}
+	m.On("Create", mock.Anything, mock.AnythingOfType("*domain.Session")).Return(nil).Maybe()
+	return m
+}
+
+// memoryThrottles is an in-memory domain.LoginThrottleRepository.
+type memoryThrottles map[string]*domain.LoginThrottle
+
+func (m memoryThrottles) Get(_ context.Context, key string) (*domain.LoginThrottle, error) {
+	t, ok := m[key]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	record := *t
+	return &record, nil
+}
+
+func (m memoryThrottles) RecordFailure(_ context.Context, key string, windowStart time.Time) (*domain.LoginThrottle, error) {
+	t, ok := m[key]
+	if !ok || t.LastFailedAt.Before(windowStart) {
+		t = &domain.LoginThrottle{Key: key}
+		m[key] = t
+	}
+	t.Failures++
+	t.LastFailedAt = time.Now()
+	record := *t
+	return &record, nil
+}
+
+func (m memoryThrottles) Lock(_ context.Context, key string, until time.Time) error {
+	m[key].LockedUntil = &until
+	return nil
+}
+
+func (m memoryThrottles) Delete(_ context.Context, key string) error {
+	delete(m, key)
+	return nil
+}
+
+func newTestLoginGuard() *services.LoginGuard {
+	return services.NewLoginGuard(slog.New(slog.NewTextHandler(io.Discard, nil)), memoryThrottles{}, &memoryAuditLog{})
+}
+
+// memoryTwoFactor is an in-memory domain.TwoFactorRepository.
+type memoryTwoFactor struct {
+	enrolments map[string]*domain.TwoFactor
+	codes      map[string]map[string]bool // user -> code hash -> used
+}
+
+func newMemoryTwoFactor() *memoryTwoFactor {
+	return &memoryTwoFactor{enrolments: map[string]*domain.TwoFactor{}, codes: map[string]map[string]bool{}}
+}
+
+func (m *memoryTwoFactor) Get(_ context.Context, userID string) (*domain.TwoFactor, error) {
+	tf, ok := m.enrolments[userID]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	enrolment := *tf
+	return &enrolment, nil
+}
+
+func (m *memoryTwoFactor) SavePending(_ context.Context, userID, secret string) error {
+	if m.enrolments[userID].IsEnabled() {
+		return domain.ErrConflict
+	}
+	m.enrolments[userID] = &domain.TwoFactor{UserID: userID, Secret: secret, CreatedAt: time.Now()}
+	return nil
+}
+
+func (m *memoryTwoFactor) Enable(ctx context.Context, userID string, codeHashes []string) error {
+	tf, ok := m.enrolments[userID]
+	if !ok || tf.IsEnabled() {
+		return domain.ErrNotFound
+	}
+	now := time.Now()
+	tf.EnabledAt = &now
+	return m.ReplaceRecoveryCodes(ctx, userID, codeHashes)
+}
+
+func (m *memoryTwoFactor) Disable(_ context.Context, userID string) error {
+	delete(m.enrolments, userID)
+	delete(m.codes, userID)
+	return nil
+}
+
+func (m *memoryTwoFactor) UseStep(_ context.Context, userID string, step int64) (bool, error) {
+	tf, ok := m.enrolments[userID]
+	if !ok || tf.LastUsedStep >= step {
+		return false, nil
+	}
+	tf.LastUsedStep = step
+	return true, nil
+}
+
+func (m *memoryTwoFactor) ReplaceRecoveryCodes(_ context.Context, userID string, codeHashes []string) error {
+	m.codes[userID] = map[string]bool{}
+	for _, h := range codeHashes {
+		m.codes[userID][h] = false
+	}
+	return nil
+}
+
+func (m *memoryTwoFactor) UseRecoveryCode(_ context.Context, userID, codeHash string) (bool, error) {
+	used, ok := m.codes[userID][codeHash]
+	if !ok || used {
+		return false, nil
+	}
+	m.codes[userID][codeHash] = true
+	return true, nil
+}
+
+func (m *memoryTwoFactor) CountRecoveryCodes(_ context.Context, userID string) (int, error) {
+	n := 0
+	for _, used := range m.codes[userID] {
+		if !used {
+			n++
+		}
+	}
+	return n, nil
+}
+
+type recordingMailer struct {
+	sent []domain.Email
+}
+
+func (m *recordingMailer) Send(_ context.Context, email domain.Email) error {
+	m.sent = append(m.sent, email)
+	return nil
+}
+
 func TestRegisterHandler(t *testing.T) {
 	var tests = []struct {
 		name          string
@@ -106,8 +313,11 @@ func TestRegisterHandler(t *testing.T) {
 			}
 
 			api := &api{
-				userRepo: mockRepo,
-				logger:   nil,
+				userRepo:    mockRepo,
+				sessionRepo: newMockSessions(),
+				mailer:      &recordingMailer{},
+				auditRepo:   &memoryAuditLog{},
+				logger:      nil,
 			}
 
 			_, err := api.registerHandler(context.Background(), &tt.input)
@@ -184,8 +394,12 @@ func TestLoginHandler(t *testing.T) {
 			}
 
 			api := &api{
-				userRepo: mockRepo,
-				logger:   logger,
+				userRepo:      mockRepo,
+				sessionRepo:   newMockSessions(),
+				twoFactorRepo: newMemoryTwoFactor(),
+				loginGuard:    newTestLoginGuard(),
+				auditRepo:     &memoryAuditLog{},
+				logger:        logger,
 			}
 
 			_, err := api.loginHandler(context.Background(), &tt.input)
@@ -209,14 +423,20 @@ func TestLoginHandler_TokenGeneration(t *testing.T) {
 		Email:    "test@example.com",
 		Password: string(hashedPassword),
 		IsActive: true,
+		Role:     domain.RoleAdmin,
 	}
 
 	mockRepo := &MockUserRepository{}
 	mockRepo.On("GetByEmail", mock.Anything, "test@example.com").Return(testUser, nil)
 
+	sessions := newMockSessions()
 	api := &api{
-		userRepo: mockRepo,
-		logger:   nil,
+		userRepo:      mockRepo,
+		sessionRepo:   sessions,
+		twoFactorRepo: newMemoryTwoFactor(),
+		loginGuard:    newTestLoginGuard(),
+		auditRepo:     &memoryAuditLog{},
+		logger:        nil,
 	}
 
 	input := &LoginInput{
@@ -236,4 +456,196 @@ func TestLoginHandler_TokenGeneration(t *testing.T) {
 	extractedUUID, err := utilities.ExtractUUIDFromToken(output.Body.Token)
 	assert.NoError(t, err)
 	assert.Equal(t, userUUID, extractedUUID)
+
+	assert.NotEmpty(t, output.Body.RefreshToken)
+	claims, err := utilities.ParseJwtToken(output.Body.Token)
+	assert.NoError(t, err)
+	created := sessions.Calls[0].Arguments.Get(1).(*domain.Session)
+	assert.Equal(t, created.UUID, claims.SessionID)
+	assert.Equal(t, domain.RoleAdmin, claims.Role)
+	assert.Equal(t, utilities.HashRefreshToken(output.Body.RefreshToken), created.RefreshTokenHash)
+}
+
+func TestPasswordResetFlow(t *testing.T) {
+	oldPassword, _ := bcrypt.GenerateFromPassword([]byte("OldPass123!"), bcrypt.MinCost)
+	user := domain.User{
+		UUID:     uuid.New().String(),
+		Email:    "test@example.com",
+		Password: string(oldPassword),
+		IsActive: true,
+	}
+
+	mockRepo := &MockUserRepository{}
+	mockRepo.On("GetByEmail", mock.Anything, "test@example.com").Return(user, nil)
+	mockRepo.On("GetByEmail", mock.Anything, "nobody@example.com").Return(domain.User{}, domain.ErrNotFound)
+	mockRepo.On("GetByUUID", mock.Anything, user.UUID).Return(user, nil).Once()
+	mockRepo.On("CreateOrUpdate", mock.Anything, mock.AnythingOfType("*domain.User")).Return(nil).Run(func(args mock.Arguments) {
+		user = *args.Get(1).(*domain.User)
+	})
+	sessions := newMockSessions()
+	sessions.On("RevokeAllForUser", mock.Anything, user.UUID).Return(nil)
+	mailer := &recordingMailer{}
+
+	api := &api{
+		userRepo:    mockRepo,
+		sessionRepo: sessions,
+		mailer:      mailer,
+		loginGuard:  newTestLoginGuard(),
+		auditRepo:   &memoryAuditLog{},
+		logger:      slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError})),
+	}
+
+	request := &AccountEmailInput{}
+	request.Body.Email = "nobody@example.com"
+	_, err := api.requestPasswordResetHandler(context.Background(), request)
+	assert.NoError(t, err)
+	assert.Empty(t, mailer.sent, "unknown addresses get the same response but no email")
+
+	request.Body.Email = "test@example.com"
+	_, err = api.requestPasswordResetHandler(context.Background(), request)
+	assert.NoError(t, err)
+	assert.Len(t, mailer.sent, 1)
+	token := tokenFromEmail(t, mailer.sent[0])
+
+	confirm := &ConfirmPasswordResetInput{}
+	confirm.Body.Token = token
+	confirm.Body.Password = "NewPass123!"
+	_, err = api.confirmPasswordResetHandler(context.Background(), confirm)
+	assert.NoError(t, err)
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(user.Password), []byte("NewPass123!")))
+	assert.True(t, user.IsEmailVerified())
+	sessions.AssertCalled(t, "RevokeAllForUser", mock.Anything, user.UUID)
+
+	// The token is single use: the password hash it was bound to changed.
+	mockRepo.On("GetByUUID", mock.Anything, user.UUID).Return(user, nil)
+	_, err = api.confirmPasswordResetHandler(context.Background(), confirm)
+	assert.EqualError(t, err, huma.Error410Gone("This link has already been used").Error())
+}
+
+func TestLoginHandler_Lockout(t *testing.T) {
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("ValidPass123!"), bcrypt.MinCost)
+	user := domain.User{
+		UUID:     uuid.New().String(),
+		Email:    "test@example.com",
+		Password: string(hashedPassword),
+		IsActive: true,
+	}
+
+	mockRepo := &MockUserRepository{}
+	mockRepo.On("GetByEmail", mock.Anything, user.Email).Return(user, nil)
+	api := &api{
+		userRepo:      mockRepo,
+		sessionRepo:   newMockSessions(),
+		twoFactorRepo: newMemoryTwoFactor(),
+		loginGuard:    newTestLoginGuard(),
+		auditRepo:     &memoryAuditLog{},
+		logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	wrong := &LoginInput{ClientIP: "203.0.113.7", Body: EmailPasswordInput{Email: user.Email, Password: "wrongpassword"}}
+	_, err := api.loginHandler(context.Background(), wrong)
+	assert.EqualError(t, err, huma.Error401Unauthorized("Invalid email or password").Error())
+	for i := 0; i < 20; i++ {
+		if _, err = api.loginHandler(context.Background(), wrong); err != nil && !strings.Contains(err.Error(), "Invalid email") {
+			break
+		}
+	}
+
+	// Further attempts are refused before the password is checked, even the
+	// right one.
+	var statusErr huma.StatusError
+	require.True(t, errors.As(err, &statusErr))
+	assert.Equal(t, http.StatusTooManyRequests, statusErr.GetStatus())
+	right := &LoginInput{ClientIP: "203.0.113.7", Body: EmailPasswordInput{Email: user.Email, Password: "ValidPass123!"}}
+	_, err = api.loginHandler(context.Background(), right)
+	require.True(t, errors.As(err, &statusErr))
+	assert.Equal(t, http.StatusTooManyRequests, statusErr.GetStatus())
+}
+
+// tokenFromEmail pulls the token out of the link in an account email.
+func tokenFromEmail(t *testing.T, email domain.Email) string {
+	t.Helper()
+	for _, line := range strings.Split(email.Body, "\n") {
+		if link, err := url.Parse(line); err == nil && link.Query().Get("token") != "" {
+			return link.Query().Get("token")
+		}
+	}
+	t.Fatalf("no token link in email %q", email.Body)
+	return ""
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("ValidPass123!"), bcrypt.MinCost)
+	user := domain.User{
+		UUID:     uuid.New().String(),
+		Email:    "test@example.com",
+		Password: string(hashedPassword),
+		IsActive: true,
+	}
+
+	mockRepo := &MockUserRepository{}
+	mockRepo.On("GetByEmail", mock.Anything, user.Email).Return(user, nil)
+	mockRepo.On("GetByUUID", mock.Anything, user.UUID).Return(user, nil)
+	sessions := newMockSessions()
+	api := &api{
+		userRepo:      mockRepo,
+		sessionRepo:   sessions,
+		twoFactorRepo: newMemoryTwoFactor(),
+		loginGuard:    newTestLoginGuard(),
+		auditRepo:     &memoryAuditLog{},
+		logger:        slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError})),
+	}
+
+	// Enrol.
+	ctx := domain.ContextWithPrincipal(context.Background(), domain.Principal{UserID: user.UUID, Role: domain.RoleUser})
+	setup, err := api.setupTwoFactorHandler(ctx, nil)
+	require.NoError(t, err)
+	assert.Contains(t, setup.Body.ProvisioningURI, "secret="+setup.Body.Secret)
+
+	enable := &TwoFactorCodeInput{}
+	enable.Body.Code = "000000"
+	_, err = api.enableTwoFactorHandler(ctx, enable)
+	assert.Error(t, err, "a wrong code does not enable two-factor authentication")
+	now := time.Now()
+	enable.Body.Code, _ = utilities.TOTPCode(setup.Body.Secret, utilities.TOTPStep(now))
+	enabled, err := api.enableTwoFactorHandler(ctx, enable)
+	require.NoError(t, err)
+	assert.Len(t, enabled.Body.RecoveryCodes, domain.RecoveryCodeCount)
+
+	// The password alone now yields a challenge instead of tokens.
+	login := &LoginInput{Body: EmailPasswordInput{Email: user.Email, Password: "ValidPass123!"}}
+	first, err := api.loginHandler(context.Background(), login)
+	require.NoError(t, err)
+	assert.True(t, first.Body.TwoFactorRequired)
+	assert.Empty(t, first.Body.Token)
+	require.NotEmpty(t, first.Body.ChallengeToken)
+
+	second := &LoginTwoFactorInput{}
+	second.Body.ChallengeToken = first.Body.ChallengeToken
+	second.Body.Code = enable.Body.Code
+	_, err = api.loginTwoFactorHandler(context.Background(), second)
+	assert.EqualError(t, err, huma.Error401Unauthorized("Invalid code").Error(), "the code used to enrol cannot be replayed")
+
+	second.Body.Code, _ = utilities.TOTPCode(setup.Body.Secret, utilities.TOTPStep(now)+1)
+	out, err := api.loginTwoFactorHandler(context.Background(), second)
+	require.NoError(t, err)
+	claims, err := utilities.ParseJwtToken(out.Body.Token)
+	require.NoError(t, err)
+	assert.True(t, claims.TwoFactor)
+
+	// A recovery code works once.
+	second.Body.Code = strings.ToUpper(enabled.Body.RecoveryCodes[0])
+	_, err = api.loginTwoFactorHandler(context.Background(), second)
+	assert.NoError(t, err)
+	_, err = api.loginTwoFactorHandler(context.Background(), second)
+	assert.Error(t, err)
+
+	status, err := api.getTwoFactorStatusHandler(ctx, nil)
+	require.NoError(t, err)
+	assert.True(t, status.Body.Enabled)
+	assert.Equal(t, domain.RecoveryCodeCount-1, status.Body.RecoveryCodesRemaining)
+
+	second.Body.ChallengeToken = "garbage"
+	_, err = api.loginTwoFactorHandler(context.Background(), second)
+	assert.EqualError(t, err, huma.Error401Unauthorized("Login challenge is invalid or has expired; sign in again").Error())
 }
@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/danielgtaylor/huma/v2"
+	m "github.com/forfarm/backend/internal/middlewares"
 	"github.com/forfarm/backend/internal/services"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/generative-ai-go/genai"
@@ -13,23 +14,23 @@ import (
 func (a *api) registerChatRoutes(_ chi.Router, apiInstance huma.API) {
 	tags := []string{"chat"}
 
-	huma.Register(apiInstance, huma.Operation{
+	huma.Register(apiInstance, m.WithPolicy(m.PolicyOwner, huma.Operation{
 		OperationID: "chatWithAssistantContextual",
 		Method:      http.MethodPost,
 		Path:        "/chat/specific",
 		Tags:        tags,
 		Summary:     "Send a message to the assistant with farm/crop context",
 		Description: "Allows users to interact with the AI chatbot, providing farm/crop context.",
-	}, a.chatHandler)
+	}), a.chatHandler)
 
-	huma.Register(apiInstance, huma.Operation{
+	huma.Register(apiInstance, m.WithPolicy(m.PolicyOwner, huma.Operation{
 		OperationID: "chatWithAssistantGeneral",
 		Method:      http.MethodPost,
 		Path:        "/chat",
 		Tags:        tags,
 		Summary:     "Send a message to the general farming assistant",
 		Description: "Allows users to interact with the AI chatbot without specific farm/crop context.",
-	}, a.generalChatHandler)
+	}), a.generalChatHandler)
 }
 
 type HistoryItem struct {
@@ -38,8 +39,7 @@ type HistoryItem struct {
 }
 
 type ChatInput struct {
-	Header string `header:"Authorization" required:"true" example:"Bearer token"`
-	Body   struct {
+	Body struct {
 		Message string        `json:"message" required:"true" doc:"The user's message to the assistant"`
 		FarmID  string        `json:"farmId,omitempty" doc:"Optional UUID of the farm context"`
 		CropID  string        `json:"cropId,omitempty" doc:"Optional UUID of the crop context"`
@@ -54,8 +54,7 @@ type ChatOutput struct {
 }
 
 type GeneralChatInput struct {
-	Header string `header:"Authorization" required:"true" example:"Bearer token"`
-	Body   struct {
+	Body struct {
 		Message string        `json:"message" required:"true" doc:"The user's message to the assistant"`
 		History []HistoryItem `json:"history,omitempty" doc:"Previous turns in the conversation"`
 	}
@@ -82,7 +81,7 @@ func convertInputHistory(apiHistory []HistoryItem) []*genai.Content {
 }
 
 func (a *api) chatHandler(ctx context.Context, input *ChatInput) (*ChatOutput, error) {
-	userID, err := a.getUserIDFromHeader(input.Header)
+	userID, err := userIDFromContext(ctx)
 	if err != nil {
 		return nil, huma.Error401Unauthorized("Authentication failed", err)
 	}
@@ -113,7 +112,7 @@ func (a *api) chatHandler(ctx context.Context, input *ChatInput) (*ChatOutput, e
 }
 
 func (a *api) generalChatHandler(ctx context.Context, input *GeneralChatInput) (*GeneralChatOutput, error) {
-	userID, err := a.getUserIDFromHeader(input.Header)
+	userID, err := userIDFromContext(ctx)
 	if err != nil {
 		return nil, huma.Error401Unauthorized("Authentication failed", err)
 	}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/forfarm/backend/internal/domain"
+	m "github.com/forfarm/backend/internal/middlewares"
 	"github.com/go-chi/chi/v5"
 	"github.com/gofrs/uuid"
 )
@@ -17,40 +18,40 @@ func (a *api) registerCropRoutes(_ chi.Router, api huma.API) {
 	tags := []string{"crop"}
 	prefix := "/crop"
 
-	huma.Register(api, huma.Operation{
+	huma.Register(api, m.WithScope(domain.ScopeCropsRead, m.WithPolicy(m.PolicyOwner, huma.Operation{
 		OperationID: "getAllCroplands",
 		Method:      http.MethodGet,
 		Path:        prefix,
 		Tags:        tags,
-	}, a.getAllCroplandsHandler)
+	})), a.getAllCroplandsHandler)
 
-	huma.Register(api, huma.Operation{
+	huma.Register(api, m.WithScope(domain.ScopeCropsRead, m.WithPolicy(m.PolicyOwner, huma.Operation{
 		OperationID: "getCroplandByID",
 		Method:      http.MethodGet,
 		Path:        prefix + "/{uuid}",
 		Tags:        tags,
-	}, a.getCroplandByIDHandler)
+	})), a.getCroplandByIDHandler)
 
-	huma.Register(api, huma.Operation{
+	huma.Register(api, m.WithScope(domain.ScopeCropsRead, m.WithPolicy(m.PolicyOwner, huma.Operation{
 		OperationID: "getAllCroplandsByFarmID",
 		Method:      http.MethodGet,
 		Path:        prefix + "/farm/{farmId}",
 		Tags:        tags,
-	}, a.getAllCroplandsByFarmIDHandler)
+	})), a.getAllCroplandsByFarmIDHandler)
 
-	huma.Register(api, huma.Operation{
+	huma.Register(api, m.WithScope(domain.ScopeCropsWrite, m.WithPolicy(m.PolicyOwner, huma.Operation{
 		OperationID: "createCropland",
 		Method:      http.MethodPost,
 		Path:        prefix,
 		Tags:        tags,
-	}, a.createCroplandHandler)
+	})), a.createCroplandHandler)
 
-	huma.Register(api, huma.Operation{
+	huma.Register(api, m.WithScope(domain.ScopeCropsWrite, m.WithPolicy(m.PolicyOwner, huma.Operation{
 		OperationID: "updateCropland",
 		Method:      http.MethodPut,
 		Path:        prefix + "/{uuid}",
 		Tags:        tags,
-	}, a.updateCroplandHandler)
+	})), a.updateCroplandHandler)
 }
 
 // --- Common Output Structs ---
@@ -70,16 +71,15 @@ type GetCroplandByIDOutput struct {
 // --- Create Structs ---
 
 type CreateCroplandInput struct {
-	Header string `header:"Authorization" required:"true" example:"Bearer token"`
-	Body   struct {
+	Body struct {
 		Name        string          `json:"name" required:"true"`
 		Status      string          `json:"status" required:"true"`
 		Priority    int             `json:"priority"`
-		LandSize    float64         `json:"landSize"`
+		LandSize    float64         `json:"landSize" doc:"Hectares; computed from geoFeature when it is a Polygon or MultiPolygon"`
 		GrowthStage string          `json:"growthStage" required:"true"`
 		PlantID     string          `json:"plantId" required:"true" example:"a1b2c3d4-e5f6-7890-1234-567890abcdef"`
 		FarmID      string          `json:"farmId" required:"true" example:"b2c3d4e5-f6a7-8901-2345-67890abcdef0"`
-		GeoFeature  json.RawMessage `json:"geoFeature,omitempty"`
+		GeoFeature  json.RawMessage `json:"geoFeature,omitempty" doc:"GeoJSON Point, Polygon or MultiPolygon geometry, or a Feature holding one"`
 	}
 }
 
@@ -92,16 +92,15 @@ type CreateCroplandOutput struct {
 // --- Update Structs ---
 
 type UpdateCroplandInput struct {
-	Header string `header:"Authorization" required:"true" example:"Bearer token"`
-	UUID   string `path:"uuid" required:"true" example:"c3d4e5f6-a7b8-9012-3456-7890abcdef01"`
-	Body   struct {
+	UUID string `path:"uuid" required:"true" example:"c3d4e5f6-a7b8-9012-3456-7890abcdef01"`
+	Body struct {
 		Name        string          `json:"name" required:"true"`
 		Status      string          `json:"status" required:"true"`
 		Priority    int             `json:"priority"`
-		LandSize    float64         `json:"landSize"`
+		LandSize    float64         `json:"landSize" doc:"Hectares; computed from geoFeature when it is a Polygon or MultiPolygon"`
 		GrowthStage string          `json:"growthStage" required:"true"`
 		PlantID     string          `json:"plantId" required:"true" example:"a1b2c3d4-e5f6-7890-1234-567890abcdef"`
-		GeoFeature  json.RawMessage `json:"geoFeature,omitempty"`
+		GeoFeature  json.RawMessage `json:"geoFeature,omitempty" doc:"GeoJSON Point, Polygon or MultiPolygon geometry, or a Feature holding one"`
 	}
 }
 
@@ -113,32 +112,39 @@ type UpdateCroplandOutput struct {
 
 // --- Handlers ---
 
-func (a *api) getAllCroplandsHandler(ctx context.Context, input *struct {
-	Header string `header:"Authorization" required:"true" example:"Bearer token"`
-}) (*GetCroplandsOutput, error) {
-	// Note: This currently fetches ALL croplands. Might need owner filtering later.
-	_, err := a.getUserIDFromHeader(input.Header)
+func (a *api) getAllCroplandsHandler(ctx context.Context, input *struct{}) (*GetCroplandsOutput, error) {
+	userID, err := userIDFromContext(ctx)
 	if err != nil {
 		return nil, huma.Error401Unauthorized("Authentication failed", err)
 	}
 
 	resp := &GetCroplandsOutput{}
 
-	croplands, err := a.cropRepo.GetAll(ctx)
+	farms, err := a.farmRepo.GetByMemberID(ctx, userID)
 	if err != nil {
-		a.logger.Error("Failed to get all croplands", "error", err)
+		a.logger.Error("Failed to get farms for cropland list", "userId", userID, "error", err)
 		return nil, huma.Error500InternalServerError("Failed to retrieve croplands")
 	}
+	farms = accessibleFarms(ctx, farms)
+
+	croplands := []domain.Cropland{}
+	for _, farm := range farms {
+		farmCroplands, err := a.cropRepo.GetByFarmID(ctx, farm.UUID)
+		if err != nil {
+			a.logger.Error("Failed to get croplands by farm ID", "farmId", farm.UUID, "error", err)
+			return nil, huma.Error500InternalServerError("Failed to retrieve croplands")
+		}
+		croplands = append(croplands, farmCroplands...)
+	}
 
 	resp.Body.Croplands = croplands
 	return resp, nil
 }
 
 func (a *api) getCroplandByIDHandler(ctx context.Context, input *struct {
-	Header string `header:"Authorization" required:"true" example:"Bearer token"`
-	UUID   string `path:"uuid" example:"550e8400-e29b-41d4-a716-446655440000"`
+	UUID string `path:"uuid" example:"550e8400-e29b-41d4-a716-446655440000"`
 }) (*GetCroplandByIDOutput, error) {
-	userID, err := a.getUserIDFromHeader(input.Header)
+	userID, err := userIDFromContext(ctx)
 	if err != nil {
 		return nil, huma.Error401Unauthorized("Authentication failed", err)
 	}
@@ -174,8 +180,13 @@ func (a *api) getCroplandByIDHandler(ctx context.Context, input *struct {
 		return nil, huma.Error500InternalServerError("Failed to verify ownership")
 	}
 
-	if farm.OwnerID != userID {
-		a.logger.Warn("Unauthorized attempt to access cropland", "croplandId", input.UUID, "requestingUserId", userID, "farmOwnerId", farm.OwnerID)
+	role, err := a.farmRole(ctx, farm.UUID, farm.OwnerID, userID)
+	if err != nil {
+		a.logger.Error("Failed to get farm role", "farmId", farm.UUID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to verify farm access")
+	}
+	if !domain.FarmRoleAllows(role, domain.FarmPermView) {
+		a.logger.Warn("Unauthorized attempt to access cropland", "croplandId", input.UUID, "requestingUserId", userID, "role", role)
 		return nil, huma.Error403Forbidden("You are not authorized to view this cropland")
 	}
 
@@ -184,10 +195,9 @@ func (a *api) getCroplandByIDHandler(ctx context.Context, input *struct {
 }
 
 func (a *api) getAllCroplandsByFarmIDHandler(ctx context.Context, input *struct {
-	Header string `header:"Authorization" required:"true" example:"Bearer token"`
 	FarmID string `path:"farmId" example:"550e8400-e29b-41d4-a716-446655440000"`
 }) (*GetCroplandsOutput, error) {
-	userID, err := a.getUserIDFromHeader(input.Header)
+	userID, err := userIDFromContext(ctx)
 	if err != nil {
 		return nil, huma.Error401Unauthorized("Authentication failed", err)
 	}
@@ -212,8 +222,13 @@ func (a *api) getAllCroplandsByFarmIDHandler(ctx context.Context, input *struct
 		a.logger.Error("Failed to fetch farm for cropland list authorization", "farmId", input.FarmID, "error", err)
 		return nil, huma.Error500InternalServerError("Failed to verify ownership")
 	}
-	if farm.OwnerID != userID {
-		a.logger.Warn("Unauthorized attempt to list crops for farm", "farmId", input.FarmID, "requestingUserId", userID, "farmOwnerId", farm.OwnerID)
+	role, err := a.farmRole(ctx, farm.UUID, farm.OwnerID, userID)
+	if err != nil {
+		a.logger.Error("Failed to get farm role", "farmId", farm.UUID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to verify farm access")
+	}
+	if !domain.FarmRoleAllows(role, domain.FarmPermView) {
+		a.logger.Warn("Unauthorized attempt to list crops for farm", "farmId", input.FarmID, "requestingUserId", userID, "role", role)
 		return nil, huma.Error403Forbidden("You are not authorized to view crops for this farm")
 	}
 
@@ -232,7 +247,7 @@ func (a *api) getAllCroplandsByFarmIDHandler(ctx context.Context, input *struct
 }
 
 func (a *api) createCroplandHandler(ctx context.Context, input *CreateCroplandInput) (*CreateCroplandOutput, error) {
-	userID, err := a.getUserIDFromHeader(input.Header)
+	userID, err := userIDFromContext(ctx)
 	if err != nil {
 		return nil, huma.Error401Unauthorized("Authentication failed", err)
 	}
@@ -247,10 +262,6 @@ func (a *api) createCroplandHandler(ctx context.Context, input *CreateCroplandIn
 		return nil, huma.Error400BadRequest("invalid farmId UUID format")
 	}
 
-	if input.Body.GeoFeature != nil && !json.Valid(input.Body.GeoFeature) {
-		return nil, huma.Error400BadRequest("invalid JSON format for geoFeature")
-	}
-
 	farm, err := a.farmRepo.GetByID(ctx, farmUUID.String())
 	if err != nil {
 		if errors.Is(err, domain.ErrNotFound) || errors.Is(err, sql.ErrNoRows) {
@@ -260,8 +271,13 @@ func (a *api) createCroplandHandler(ctx context.Context, input *CreateCroplandIn
 		a.logger.Error("Failed to fetch farm for create cropland authorization", "farmId", input.Body.FarmID, "error", err)
 		return nil, huma.Error500InternalServerError("Failed to verify ownership")
 	}
-	if farm.OwnerID != userID {
-		a.logger.Warn("Unauthorized attempt to create crop on farm", "farmId", input.Body.FarmID, "requestingUserId", userID, "farmOwnerId", farm.OwnerID)
+	role, err := a.farmRole(ctx, farm.UUID, farm.OwnerID, userID)
+	if err != nil {
+		a.logger.Error("Failed to get farm role", "farmId", farm.UUID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to verify farm access")
+	}
+	if !domain.FarmRoleAllows(role, domain.FarmPermEditCrops) {
+		a.logger.Warn("Unauthorized attempt to create crop on farm", "farmId", input.Body.FarmID, "requestingUserId", userID, "role", role)
 		return nil, huma.Error403Forbidden("You are not authorized to add crops to this farm")
 	}
 
@@ -273,7 +289,9 @@ func (a *api) createCroplandHandler(ctx context.Context, input *CreateCroplandIn
 		GrowthStage: input.Body.GrowthStage,
 		PlantID:     input.Body.PlantID,
 		FarmID:      input.Body.FarmID,
-		GeoFeature:  input.Body.GeoFeature,
+	}
+	if err := cropland.SetGeoFeature(input.Body.GeoFeature); err != nil {
+		return nil, huma.Error422UnprocessableEntity("Invalid geoFeature: " + err.Error())
 	}
 
 	err = a.cropRepo.CreateOrUpdate(ctx, cropland)
@@ -283,13 +301,20 @@ func (a *api) createCroplandHandler(ctx context.Context, input *CreateCroplandIn
 	}
 
 	a.logger.Info("Cropland created successfully", "croplandId", cropland.UUID, "farmId", cropland.FarmID)
+	a.audit(ctx, domain.AuditEntry{
+		Action:       domain.AuditCroplandCreated,
+		ResourceType: domain.AuditResourceCropland,
+		ResourceID:   cropland.UUID,
+		FarmID:       cropland.FarmID,
+		Changes:      domain.AuditChanges(nil, cropland),
+	})
 
 	resp.Body.Cropland = *cropland
 	return resp, nil
 }
 
 func (a *api) updateCroplandHandler(ctx context.Context, input *UpdateCroplandInput) (*UpdateCroplandOutput, error) {
-	userID, err := a.getUserIDFromHeader(input.Header)
+	userID, err := userIDFromContext(ctx)
 	if err != nil {
 		return nil, huma.Error401Unauthorized("Authentication failed", err)
 	}
@@ -305,10 +330,6 @@ func (a *api) updateCroplandHandler(ctx context.Context, input *UpdateCroplandIn
 		return nil, huma.Error400BadRequest("invalid plantId UUID format in body")
 	}
 
-	if input.Body.GeoFeature != nil && !json.Valid(input.Body.GeoFeature) {
-		return nil, huma.Error400BadRequest("invalid JSON format for geoFeature")
-	}
-
 	existingCrop, err := a.cropRepo.GetByID(ctx, croplandUUID.String())
 	if err != nil {
 		if errors.Is(err, domain.ErrNotFound) || errors.Is(err, sql.ErrNoRows) {
@@ -328,8 +349,13 @@ func (a *api) updateCroplandHandler(ctx context.Context, input *UpdateCroplandIn
 		a.logger.Error("Failed to fetch farm for update cropland authorization", "farmId", existingCrop.FarmID, "error", err)
 		return nil, huma.Error500InternalServerError("Failed to verify ownership for update")
 	}
-	if farm.OwnerID != userID {
-		a.logger.Warn("Unauthorized attempt to update crop on farm", "croplandId", input.UUID, "farmId", existingCrop.FarmID, "requestingUserId", userID, "farmOwnerId", farm.OwnerID)
+	role, err := a.farmRole(ctx, farm.UUID, farm.OwnerID, userID)
+	if err != nil {
+		a.logger.Error("Failed to get farm role", "farmId", farm.UUID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to verify farm access")
+	}
+	if !domain.FarmRoleAllows(role, domain.FarmPermEditCrops) {
+		a.logger.Warn("Unauthorized attempt to update crop on farm", "croplandId", input.UUID, "farmId", existingCrop.FarmID, "requestingUserId", userID, "role", role)
 		return nil, huma.Error403Forbidden("You are not authorized to modify this cropland")
 	}
 
@@ -342,9 +368,11 @@ func (a *api) updateCroplandHandler(ctx context.Context, input *UpdateCroplandIn
 		LandSize:    input.Body.LandSize,
 		GrowthStage: input.Body.GrowthStage,
 		PlantID:     input.Body.PlantID,
-		GeoFeature:  input.Body.GeoFeature,
 		CreatedAt:   existingCrop.CreatedAt,
 	}
+	if err := updatedCropland.SetGeoFeature(input.Body.GeoFeature); err != nil {
+		return nil, huma.Error422UnprocessableEntity("Invalid geoFeature: " + err.Error())
+	}
 
 	err = a.cropRepo.CreateOrUpdate(ctx, updatedCropland)
 	if err != nil {
@@ -353,6 +381,13 @@ func (a *api) updateCroplandHandler(ctx context.Context, input *UpdateCroplandIn
 	}
 
 	a.logger.Info("Cropland updated successfully", "croplandId", updatedCropland.UUID, "farmId", updatedCropland.FarmID)
+	a.audit(ctx, domain.AuditEntry{
+		Action:       domain.AuditCroplandUpdated,
+		ResourceType: domain.AuditResourceCropland,
+		ResourceID:   updatedCropland.UUID,
+		FarmID:       updatedCropland.FarmID,
+		Changes:      domain.AuditChanges(existingCrop, updatedCropland),
+	})
 
 	resp.Body.Cropland = *updatedCropland
 	return resp, nil
@@ -9,6 +9,7 @@ import (
 
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/forfarm/backend/internal/domain"
+	m "github.com/forfarm/backend/internal/middlewares"
 	"github.com/go-chi/chi/v5"
 )
 
@@ -17,40 +18,40 @@ func (a *api) registerFarmRoutes(_ chi.Router, api huma.API) {
 	tags := []string{"farm"}
 	prefix := "/farms"
 
-	huma.Register(api, huma.Operation{
+	huma.Register(api, m.WithScope(domain.ScopeFarmsRead, m.WithPolicy(m.PolicyOwner, huma.Operation{
 		OperationID: "getAllFarms",
 		Method:      http.MethodGet,
 		Path:        prefix,
 		Tags:        tags,
-	}, a.getAllFarmsHandler)
+	})), a.getAllFarmsHandler)
 
-	huma.Register(api, huma.Operation{
+	huma.Register(api, m.WithScope(domain.ScopeFarmsRead, m.WithPolicy(m.PolicyOwner, huma.Operation{
 		OperationID: "getFarmByID",
 		Method:      http.MethodGet,
 		Path:        prefix + "/{farmId}",
 		Tags:        tags,
-	}, a.getFarmByIDHandler)
+	})), a.getFarmByIDHandler)
 
-	huma.Register(api, huma.Operation{
+	huma.Register(api, m.WithScope(domain.ScopeFarmsWrite, m.WithPolicy(m.PolicyOwner, huma.Operation{
 		OperationID: "createFarm",
 		Method:      http.MethodPost,
 		Path:        prefix,
 		Tags:        tags,
-	}, a.createFarmHandler)
+	})), a.createFarmHandler)
 
-	huma.Register(api, huma.Operation{
+	huma.Register(api, m.WithScope(domain.ScopeFarmsWrite, m.WithPolicy(m.PolicyOwner, huma.Operation{
 		OperationID: "updateFarm",
 		Method:      http.MethodPut,
 		Path:        prefix + "/{farmId}",
 		Tags:        tags,
-	}, a.updateFarmHandler)
+	})), a.updateFarmHandler)
 
-	huma.Register(api, huma.Operation{
+	huma.Register(api, m.WithScope(domain.ScopeFarmsWrite, m.WithPolicy(m.PolicyOwner, huma.Operation{
 		OperationID: "deleteFarm",
 		Method:      http.MethodDelete,
 		Path:        prefix + "/{farmId}",
 		Tags:        tags,
-	}, a.deleteFarmHandler)
+	})), a.deleteFarmHandler)
 }
 
 //
@@ -58,8 +59,7 @@ func (a *api) registerFarmRoutes(_ chi.Router, api huma.API) {
 //
 
 type CreateFarmInput struct {
-	Header string `header:"Authorization" required:"true" example:"Bearer token"`
-	Body   struct {
+	Body struct {
 		Name      string  `json:"name" required:"true"`
 		Lat       float64 `json:"lat" required:"true"`
 		Lon       float64 `json:"lon" required:"true"`
@@ -75,7 +75,6 @@ type CreateFarmOutput struct {
 }
 
 type GetAllFarmsInput struct {
-	Header string `header:"Authorization" required:"true" example:"Bearer token"`
 }
 
 type GetAllFarmsOutput struct {
@@ -83,7 +82,6 @@ type GetAllFarmsOutput struct {
 }
 
 type GetFarmByIDInput struct {
-	Header string `header:"Authorization" required:"true" example:"Bearer token"`
 	FarmID string `path:"farmId" required:"true"`
 }
 
@@ -92,7 +90,6 @@ type GetFarmByIDOutput struct {
 }
 
 type UpdateFarmInput struct {
-	Header string `header:"Authorization" required:"true" example:"Bearer token"`
 	FarmID string `path:"farmId" required:"true"`
 	Body   struct {
 		Name      *string  `json:"name,omitempty"`
@@ -108,7 +105,6 @@ type UpdateFarmOutput struct {
 }
 
 type DeleteFarmInput struct {
-	Header string `header:"Authorization" required:"true" example:"Bearer token"`
 	FarmID string `path:"farmId" required:"true"`
 }
 
@@ -123,11 +119,15 @@ type DeleteFarmOutput struct {
 //
 
 func (a *api) createFarmHandler(ctx context.Context, input *CreateFarmInput) (*CreateFarmOutput, error) {
-	userID, err := a.getUserIDFromHeader(input.Header)
+	userID, err := userIDFromContext(ctx)
 	if err != nil {
 		return nil, huma.Error401Unauthorized("Authentication failed", err)
 	}
 
+	if p, ok := domain.PrincipalFromContext(ctx); ok && p.FarmID != "" {
+		return nil, huma.Error403Forbidden("This API key is restricted to one farm")
+	}
+
 	farm := &domain.Farm{
 		Name:      input.Body.Name,
 		Lat:       input.Body.Lat,
@@ -150,6 +150,13 @@ func (a *api) createFarmHandler(ctx context.Context, input *CreateFarmInput) (*C
 	}
 
 	a.logger.Info("Farm created successfully", "farmId", farm.UUID, "ownerId", userID)
+	a.audit(ctx, domain.AuditEntry{
+		Action:       domain.AuditFarmCreated,
+		ResourceType: domain.AuditResourceFarm,
+		ResourceID:   farm.UUID,
+		FarmID:       farm.UUID,
+		Changes:      domain.AuditChanges(nil, farm),
+	})
 
 	return &CreateFarmOutput{
 		Body: struct {
@@ -159,16 +166,17 @@ func (a *api) createFarmHandler(ctx context.Context, input *CreateFarmInput) (*C
 }
 
 func (a *api) getAllFarmsHandler(ctx context.Context, input *GetAllFarmsInput) (*GetAllFarmsOutput, error) {
-	userID, err := a.getUserIDFromHeader(input.Header)
+	userID, err := userIDFromContext(ctx)
 	if err != nil {
 		return nil, huma.Error401Unauthorized("Authentication failed", err)
 	}
 
-	farms, err := a.farmRepo.GetByOwnerID(ctx, userID)
+	farms, err := a.farmRepo.GetByMemberID(ctx, userID)
 	if err != nil {
-		a.logger.Error("Failed to get farms by owner ID", "ownerId", userID, "error", err)
+		a.logger.Error("Failed to get farms by member ID", "userId", userID, "error", err)
 		return nil, huma.Error500InternalServerError("Failed to retrieve farms")
 	}
+	farms = accessibleFarms(ctx, farms)
 
 	// Handle case where user has no farms (return empty list, not error)
 	if farms == nil {
@@ -179,7 +187,7 @@ func (a *api) getAllFarmsHandler(ctx context.Context, input *GetAllFarmsInput) (
 }
 
 func (a *api) getFarmByIDHandler(ctx context.Context, input *GetFarmByIDInput) (*GetFarmByIDOutput, error) {
-	userID, err := a.getUserIDFromHeader(input.Header)
+	userID, err := userIDFromContext(ctx)
 	if err != nil {
 		return nil, huma.Error401Unauthorized("Authentication failed", err)
 	}
@@ -194,16 +202,22 @@ func (a *api) getFarmByIDHandler(ctx context.Context, input *GetFarmByIDInput) (
 		return nil, huma.Error500InternalServerError("Failed to retrieve farm")
 	}
 
-	if farm.OwnerID != userID {
-		a.logger.Warn("Unauthorized attempt to access farm", "farmId", input.FarmID, "requestingUserId", userID, "ownerId", farm.OwnerID)
+	role, err := a.farmRole(ctx, farm.UUID, farm.OwnerID, userID)
+	if err != nil {
+		a.logger.Error("Failed to get farm role", "farmId", input.FarmID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to verify farm access")
+	}
+	if !domain.FarmRoleAllows(role, domain.FarmPermView) {
+		a.logger.Warn("Unauthorized attempt to access farm", "farmId", input.FarmID, "requestingUserId", userID, "role", role)
 		return nil, huma.Error403Forbidden("You are not authorized to view this farm")
 	}
+	farm.Role = role
 
 	return &GetFarmByIDOutput{Body: *farm}, nil
 }
 
 func (a *api) updateFarmHandler(ctx context.Context, input *UpdateFarmInput) (*UpdateFarmOutput, error) {
-	userID, err := a.getUserIDFromHeader(input.Header)
+	userID, err := userIDFromContext(ctx)
 	if err != nil {
 		return nil, huma.Error401Unauthorized("Authentication failed", err)
 	}
@@ -218,12 +232,18 @@ func (a *api) updateFarmHandler(ctx context.Context, input *UpdateFarmInput) (*U
 		return nil, huma.Error500InternalServerError("Failed to retrieve farm for update")
 	}
 
-	if farm.OwnerID != userID {
-		a.logger.Warn("Unauthorized attempt to update farm", "farmId", input.FarmID, "requestingUserId", userID, "ownerId", farm.OwnerID)
+	role, err := a.farmRole(ctx, farm.UUID, farm.OwnerID, userID)
+	if err != nil {
+		a.logger.Error("Failed to get farm role", "farmId", input.FarmID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to verify farm access")
+	}
+	if !domain.FarmRoleAllows(role, domain.FarmPermEditFarm) {
+		a.logger.Warn("Unauthorized attempt to update farm", "farmId", input.FarmID, "requestingUserId", userID, "role", role)
 		return nil, huma.Error403Forbidden("You are not authorized to update this farm")
 	}
 
 	// Apply updates selectively
+	before := *farm
 	updated := false
 	if input.Body.Name != nil && *input.Body.Name != "" && *input.Body.Name != farm.Name {
 		farm.Name = *input.Body.Name
@@ -263,6 +283,13 @@ func (a *api) updateFarmHandler(ctx context.Context, input *UpdateFarmInput) (*U
 	}
 
 	a.logger.Info("Farm updated successfully", "farmId", farm.UUID, "ownerId", userID)
+	a.audit(ctx, domain.AuditEntry{
+		Action:       domain.AuditFarmUpdated,
+		ResourceType: domain.AuditResourceFarm,
+		ResourceID:   farm.UUID,
+		FarmID:       farm.UUID,
+		Changes:      domain.AuditChanges(before, farm),
+	})
 
 	// Fetch the updated farm again to ensure we return the latest state (including UpdatedAt)
 	updatedFarm, fetchErr := a.farmRepo.GetByID(ctx, input.FarmID)
@@ -276,7 +303,7 @@ func (a *api) updateFarmHandler(ctx context.Context, input *UpdateFarmInput) (*U
 }
 
 func (a *api) deleteFarmHandler(ctx context.Context, input *DeleteFarmInput) (*DeleteFarmOutput, error) {
-	userID, err := a.getUserIDFromHeader(input.Header)
+	userID, err := userIDFromContext(ctx)
 	if err != nil {
 		return nil, huma.Error401Unauthorized("Authentication failed", err)
 	}
@@ -292,8 +319,13 @@ func (a *api) deleteFarmHandler(ctx context.Context, input *DeleteFarmInput) (*D
 		return nil, huma.Error500InternalServerError("Failed to retrieve farm for deletion")
 	}
 
-	if farm.OwnerID != userID {
-		a.logger.Warn("Unauthorized attempt to delete farm", "farmId", input.FarmID, "requestingUserId", userID, "ownerId", farm.OwnerID)
+	role, err := a.farmRole(ctx, farm.UUID, farm.OwnerID, userID)
+	if err != nil {
+		a.logger.Error("Failed to get farm role", "farmId", input.FarmID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to verify farm access")
+	}
+	if !domain.FarmRoleAllows(role, domain.FarmPermDelete) {
+		a.logger.Warn("Unauthorized attempt to delete farm", "farmId", input.FarmID, "requestingUserId", userID, "role", role)
 		return nil, huma.Error403Forbidden("You are not authorized to delete this farm")
 	}
 
@@ -304,6 +336,15 @@ func (a *api) deleteFarmHandler(ctx context.Context, input *DeleteFarmInput) (*D
 	}
 
 	a.logger.Info("Farm deleted successfully", "farmId", input.FarmID, "ownerId", userID)
+	deleted := *farm
+	deleted.Role, deleted.Crops = "", nil
+	a.audit(ctx, domain.AuditEntry{
+		Action:       domain.AuditFarmDeleted,
+		ResourceType: domain.AuditResourceFarm,
+		ResourceID:   farm.UUID,
+		FarmID:       farm.UUID,
+		Changes:      domain.AuditChanges(deleted, nil),
+	})
 
 	return &DeleteFarmOutput{
 		Body: struct {
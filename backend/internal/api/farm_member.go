@@ -0,0 +1,541 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/go-chi/chi/v5"
+
+	"github.com/forfarm/backend/internal/config"
+	"github.com/forfarm/backend/internal/domain"
+	m "github.com/forfarm/backend/internal/middlewares"
+)
+
+const farmInvitationTTL = 7 * 24 * time.Hour
+
+func (a *api) registerFarmMemberRoutes(_ chi.Router, api huma.API) {
+	tags := []string{"farm"}
+	prefix := "/farms/{farmId}"
+
+	huma.Register(api, m.WithScope(domain.ScopeFarmsRead, m.WithPolicy(m.PolicyOwner, huma.Operation{
+		OperationID: "getFarmMembers",
+		Method:      http.MethodGet,
+		Path:        prefix + "/members",
+		Tags:        tags,
+		Summary:     "List the users with access to a farm",
+	})), a.getFarmMembersHandler)
+
+	huma.Register(api, m.WithPolicy(m.PolicyOwner, huma.Operation{
+		OperationID: "updateFarmMember",
+		Method:      http.MethodPut,
+		Path:        prefix + "/members/{userId}",
+		Tags:        tags,
+		Summary:     "Change a member's role",
+	}), a.updateFarmMemberHandler)
+
+	huma.Register(api, m.WithPolicy(m.PolicyOwner, huma.Operation{
+		OperationID: "removeFarmMember",
+		Method:      http.MethodDelete,
+		Path:        prefix + "/members/{userId}",
+		Tags:        tags,
+		Summary:     "Remove a member, or leave the farm",
+	}), a.removeFarmMemberHandler)
+
+	huma.Register(api, m.WithPolicy(m.PolicyOwner, huma.Operation{
+		OperationID: "createFarmInvitation",
+		Method:      http.MethodPost,
+		Path:        prefix + "/invitations",
+		Tags:        tags,
+		Summary:     "Invite someone to a farm by email",
+		Description: "The invitee is emailed and can accept once they have signed in with that address and verified it.",
+	}), a.createFarmInvitationHandler)
+
+	huma.Register(api, m.WithPolicy(m.PolicyOwner, huma.Operation{
+		OperationID: "getFarmInvitations",
+		Method:      http.MethodGet,
+		Path:        prefix + "/invitations",
+		Tags:        tags,
+		Summary:     "List a farm's pending invitations",
+	}), a.getFarmInvitationsHandler)
+
+	huma.Register(api, m.WithPolicy(m.PolicyOwner, huma.Operation{
+		OperationID: "revokeFarmInvitation",
+		Method:      http.MethodDelete,
+		Path:        prefix + "/invitations/{invitationId}",
+		Tags:        tags,
+	}), a.revokeFarmInvitationHandler)
+
+	huma.Register(api, m.WithPolicy(m.PolicyOwner, huma.Operation{
+		OperationID: "getMyFarmInvitations",
+		Method:      http.MethodGet,
+		Path:        "/farm-invitations",
+		Tags:        tags,
+		Summary:     "List pending invitations sent to the caller's email",
+		Description: "The caller's email address must be verified.",
+	}), a.getMyFarmInvitationsHandler)
+
+	huma.Register(api, m.WithPolicy(m.PolicyOwner, huma.Operation{
+		OperationID: "acceptFarmInvitation",
+		Method:      http.MethodPost,
+		Path:        "/farm-invitations/{invitationId}/accept",
+		Tags:        tags,
+	}), a.acceptFarmInvitationHandler)
+
+	huma.Register(api, m.WithPolicy(m.PolicyOwner, huma.Operation{
+		OperationID: "declineFarmInvitation",
+		Method:      http.MethodDelete,
+		Path:        "/farm-invitations/{invitationId}",
+		Tags:        tags,
+	}), a.declineFarmInvitationHandler)
+}
+
+//
+// Input and Output types
+//
+
+type FarmMembersInput struct {
+	FarmID string `path:"farmId" required:"true" format:"uuid"`
+}
+
+type FarmMembersOutput struct {
+	Body []domain.FarmMember
+}
+
+type UpdateFarmMemberInput struct {
+	FarmID string `path:"farmId" required:"true" format:"uuid"`
+	UserID string `path:"userId" required:"true" format:"uuid"`
+	Body   struct {
+		Role string `json:"role" required:"true" enum:"manager,worker,viewer"`
+	}
+}
+
+type FarmMemberOutput struct {
+	Body domain.FarmMember
+}
+
+type RemoveFarmMemberInput struct {
+	FarmID string `path:"farmId" required:"true" format:"uuid"`
+	UserID string `path:"userId" required:"true" format:"uuid"`
+}
+
+type FarmMessageOutput struct {
+	Body struct {
+		Message string `json:"message"`
+	}
+}
+
+type CreateFarmInvitationInput struct {
+	FarmID string `path:"farmId" required:"true" format:"uuid"`
+	Body   struct {
+		Email string `json:"email" required:"true" format:"email"`
+		Role  string `json:"role" required:"true" enum:"manager,worker,viewer"`
+	}
+}
+
+type FarmInvitationOutput struct {
+	Body domain.FarmInvitation
+}
+
+type FarmInvitationsOutput struct {
+	Body []domain.FarmInvitation
+}
+
+type FarmInvitationInput struct {
+	FarmID       string `path:"farmId" required:"true" format:"uuid"`
+	InvitationID string `path:"invitationId" required:"true" format:"uuid"`
+}
+
+type MyFarmInvitationInput struct {
+	InvitationID string `path:"invitationId" required:"true" format:"uuid"`
+}
+
+//
+// Authorization helpers
+//
+
+// farmRole returns userID's role on the farm owned by ownerID, or "" if the
+// user has no access to it or the caller's API key is restricted to another
+// farm.
+func (a *api) farmRole(ctx context.Context, farmID, ownerID, userID string) (string, error) {
+	if p, ok := domain.PrincipalFromContext(ctx); ok && !p.CanAccessFarm(farmID) {
+		return "", nil
+	}
+	if ownerID == userID {
+		return domain.FarmRoleOwner, nil
+	}
+	role, err := a.farmMemberRepo.GetRole(ctx, farmID, userID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return "", nil
+	}
+	return role, err
+}
+
+// authorizeFarm loads a farm and checks that the caller's role on it grants
+// perm, returning the farm with Role set or a response error.
+func (a *api) authorizeFarm(ctx context.Context, farmID, userID string, perm domain.FarmPermission) (*domain.Farm, error) {
+	farm, err := a.farmRepo.GetByID(ctx, farmID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, huma.Error404NotFound("Farm not found")
+	}
+	if err != nil {
+		a.logger.Error("Failed to get farm", "farmId", farmID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to retrieve farm")
+	}
+
+	role, err := a.farmRole(ctx, farm.UUID, farm.OwnerID, userID)
+	if err != nil {
+		a.logger.Error("Failed to get farm role", "farmId", farmID, "userId", userID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to verify farm access")
+	}
+	if role == "" {
+		return nil, huma.Error404NotFound("Farm not found")
+	}
+	if !domain.FarmRoleAllows(role, perm) {
+		a.logger.Warn("Farm role does not allow action", "farmId", farmID, "userId", userID, "role", role)
+		return nil, huma.Error403Forbidden("Your role on this farm does not allow this action")
+	}
+
+	farm.Role = role
+	return farm, nil
+}
+
+// callerEmail returns the email address of the authenticated user.
+func (a *api) callerEmail(ctx context.Context, userID string) (string, error) {
+	user, err := a.userRepo.GetByUUID(ctx, userID)
+	if err != nil {
+		a.logger.Error("Failed to get user", "userId", userID, "error", err)
+		return "", huma.Error500InternalServerError("Failed to retrieve user")
+	}
+	return user.Email, nil
+}
+
+// invitationEmail returns the address invitations are matched against. It
+// must be verified: anyone can register with the invitee's address.
+func (a *api) invitationEmail(ctx context.Context, userID string) (string, error) {
+	user, err := a.userRepo.GetByUUID(ctx, userID)
+	if err != nil {
+		a.logger.Error("Failed to get user", "userId", userID, "error", err)
+		return "", huma.Error500InternalServerError("Failed to retrieve user")
+	}
+	if !user.IsEmailVerified() {
+		return "", huma.Error403Forbidden("Verify your email address to see the invitations sent to it")
+	}
+	return user.Email, nil
+}
+
+//
+// API Handlers
+//
+
+func (a *api) getFarmMembersHandler(ctx context.Context, input *FarmMembersInput) (*FarmMembersOutput, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("Authentication failed", err)
+	}
+	if _, err := a.authorizeFarm(ctx, input.FarmID, userID, domain.FarmPermView); err != nil {
+		return nil, err
+	}
+
+	members, err := a.farmMemberRepo.ListMembers(ctx, input.FarmID)
+	if err != nil {
+		a.logger.Error("Failed to list farm members", "farmId", input.FarmID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to retrieve farm members")
+	}
+	return &FarmMembersOutput{Body: members}, nil
+}
+
+// findMember returns the member of the farm with userID.
+func (a *api) findMember(ctx context.Context, farmID, userID string) (*domain.FarmMember, error) {
+	members, err := a.farmMemberRepo.ListMembers(ctx, farmID)
+	if err != nil {
+		a.logger.Error("Failed to list farm members", "farmId", farmID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to retrieve farm members")
+	}
+	for i := range members {
+		if members[i].UserID == userID {
+			return &members[i], nil
+		}
+	}
+	return nil, huma.Error404NotFound("Member not found")
+}
+
+func (a *api) updateFarmMemberHandler(ctx context.Context, input *UpdateFarmMemberInput) (*FarmMemberOutput, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("Authentication failed", err)
+	}
+	farm, err := a.authorizeFarm(ctx, input.FarmID, userID, domain.FarmPermManageMembers)
+	if err != nil {
+		return nil, err
+	}
+
+	member, err := a.findMember(ctx, input.FarmID, input.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !domain.CanAssignFarmRole(farm.Role, member.Role) || !domain.CanAssignFarmRole(farm.Role, input.Body.Role) {
+		return nil, huma.Error403Forbidden("You can only manage members below your own role")
+	}
+
+	if err := a.farmMemberRepo.SetRole(ctx, input.FarmID, input.UserID, input.Body.Role); err != nil {
+		a.logger.Error("Failed to update farm member", "farmId", input.FarmID, "memberId", input.UserID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to update member")
+	}
+
+	a.logger.Info("Farm member role changed", "farmId", input.FarmID, "memberId", input.UserID, "role", input.Body.Role, "by", userID)
+	before := *member
+	member.Role = input.Body.Role
+	a.audit(ctx, domain.AuditEntry{
+		Action:       domain.AuditFarmMemberUpdated,
+		UserID:       member.UserID,
+		ResourceType: domain.AuditResourceFarmMember,
+		ResourceID:   member.UserID,
+		FarmID:       input.FarmID,
+		Changes:      domain.AuditChanges(before, member),
+	})
+	return &FarmMemberOutput{Body: *member}, nil
+}
+
+func (a *api) removeFarmMemberHandler(ctx context.Context, input *RemoveFarmMemberInput) (*FarmMessageOutput, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("Authentication failed", err)
+	}
+
+	// Any member may leave; removing someone else needs a higher role.
+	perm := domain.FarmPermManageMembers
+	if input.UserID == userID {
+		perm = domain.FarmPermView
+	}
+	farm, err := a.authorizeFarm(ctx, input.FarmID, userID, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	member, err := a.findMember(ctx, input.FarmID, input.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if member.Role == domain.FarmRoleOwner {
+		return nil, huma.Error400BadRequest("The owner cannot be removed from a farm")
+	}
+	if input.UserID != userID && !domain.CanAssignFarmRole(farm.Role, member.Role) {
+		return nil, huma.Error403Forbidden("You can only manage members below your own role")
+	}
+
+	if err := a.farmMemberRepo.RemoveMember(ctx, input.FarmID, input.UserID); err != nil && !errors.Is(err, domain.ErrNotFound) {
+		a.logger.Error("Failed to remove farm member", "farmId", input.FarmID, "memberId", input.UserID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to remove member")
+	}
+
+	a.logger.Info("Farm member removed", "farmId", input.FarmID, "memberId", input.UserID, "by", userID)
+	a.audit(ctx, domain.AuditEntry{
+		Action:       domain.AuditFarmMemberRemoved,
+		UserID:       member.UserID,
+		ResourceType: domain.AuditResourceFarmMember,
+		ResourceID:   member.UserID,
+		FarmID:       input.FarmID,
+		Changes:      domain.AuditChanges(member, nil),
+	})
+	resp := &FarmMessageOutput{}
+	resp.Body.Message = "Member removed"
+	return resp, nil
+}
+
+func (a *api) createFarmInvitationHandler(ctx context.Context, input *CreateFarmInvitationInput) (*FarmInvitationOutput, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("Authentication failed", err)
+	}
+	farm, err := a.authorizeFarm(ctx, input.FarmID, userID, domain.FarmPermManageMembers)
+	if err != nil {
+		return nil, err
+	}
+	if !domain.CanAssignFarmRole(farm.Role, input.Body.Role) {
+		return nil, huma.Error403Forbidden("You can only invite members below your own role")
+	}
+
+	invitation := &domain.FarmInvitation{
+		FarmID:    farm.UUID,
+		FarmName:  farm.Name,
+		Email:     strings.TrimSpace(input.Body.Email),
+		Role:      input.Body.Role,
+		InvitedBy: userID,
+		ExpiresAt: time.Now().Add(farmInvitationTTL),
+	}
+	if err := invitation.Validate(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	if err := a.farmMemberRepo.CreateInvitation(ctx, invitation); err != nil {
+		a.logger.Error("Failed to create farm invitation", "farmId", farm.UUID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to create invitation")
+	}
+
+	a.logger.Info("Farm invitation created", "farmId", farm.UUID, "invitationId", invitation.UUID, "role", invitation.Role, "by", userID)
+	a.auditInvitation(ctx, domain.AuditFarmInvitationCreated, invitation)
+	if err := a.mailer.Send(ctx, domain.Email{
+		To:      invitation.Email,
+		Subject: fmt.Sprintf("You have been invited to %s on ForFarm", farm.Name),
+		Body: fmt.Sprintf("You have been invited to join the farm %q on ForFarm as a %s.\n\nSign in or create an account with this email address at %s, verify the address, and accept the invitation before %s.\n\nIf you were not expecting this, you can ignore this email.\n",
+			farm.Name, invitation.Role, strings.TrimRight(config.APP_BASE_URL, "/"), invitation.ExpiresAt.UTC().Format(time.RFC1123)),
+	}); err != nil {
+		a.logger.Error("Failed to send farm invitation email", "invitationId", invitation.UUID, "error", err)
+	}
+	return &FarmInvitationOutput{Body: *invitation}, nil
+}
+
+func (a *api) getFarmInvitationsHandler(ctx context.Context, input *FarmMembersInput) (*FarmInvitationsOutput, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("Authentication failed", err)
+	}
+	if _, err := a.authorizeFarm(ctx, input.FarmID, userID, domain.FarmPermManageMembers); err != nil {
+		return nil, err
+	}
+
+	invitations, err := a.farmMemberRepo.ListPendingInvitations(ctx, input.FarmID)
+	if err != nil {
+		a.logger.Error("Failed to list farm invitations", "farmId", input.FarmID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to retrieve invitations")
+	}
+	return &FarmInvitationsOutput{Body: invitations}, nil
+}
+
+func (a *api) revokeFarmInvitationHandler(ctx context.Context, input *FarmInvitationInput) (*FarmMessageOutput, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("Authentication failed", err)
+	}
+	farm, err := a.authorizeFarm(ctx, input.FarmID, userID, domain.FarmPermManageMembers)
+	if err != nil {
+		return nil, err
+	}
+
+	invitation, err := a.farmMemberRepo.GetInvitation(ctx, input.InvitationID)
+	if errors.Is(err, domain.ErrNotFound) || (err == nil && invitation.FarmID != farm.UUID) {
+		return nil, huma.Error404NotFound("Invitation not found")
+	}
+	if err != nil {
+		a.logger.Error("Failed to get farm invitation", "invitationId", input.InvitationID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to revoke invitation")
+	}
+	if !domain.CanAssignFarmRole(farm.Role, invitation.Role) {
+		return nil, huma.Error403Forbidden("You can only manage members below your own role")
+	}
+
+	if err := a.farmMemberRepo.DeleteInvitation(ctx, invitation.UUID); err != nil && !errors.Is(err, domain.ErrNotFound) {
+		a.logger.Error("Failed to delete farm invitation", "invitationId", invitation.UUID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to revoke invitation")
+	}
+	a.auditInvitation(ctx, domain.AuditFarmInvitationRevoked, invitation)
+
+	resp := &FarmMessageOutput{}
+	resp.Body.Message = "Invitation revoked"
+	return resp, nil
+}
+
+func (a *api) getMyFarmInvitationsHandler(ctx context.Context, _ *struct{}) (*FarmInvitationsOutput, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("Authentication failed", err)
+	}
+	email, err := a.invitationEmail(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	invitations, err := a.farmMemberRepo.ListInvitationsForEmail(ctx, email)
+	if err != nil {
+		a.logger.Error("Failed to list invitations for user", "userId", userID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to retrieve invitations")
+	}
+	return &FarmInvitationsOutput{Body: invitations}, nil
+}
+
+// pendingInvitationFor returns the invitation if it is pending and addressed
+// to the caller. Invitations for someone else look like missing ones.
+func (a *api) pendingInvitationFor(ctx context.Context, invitationID, userID string) (*domain.FarmInvitation, error) {
+	email, err := a.invitationEmail(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	invitation, err := a.farmMemberRepo.GetInvitation(ctx, invitationID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, huma.Error404NotFound("Invitation not found")
+	}
+	if err != nil {
+		a.logger.Error("Failed to get farm invitation", "invitationId", invitationID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to retrieve invitation")
+	}
+	if !strings.EqualFold(invitation.Email, email) {
+		return nil, huma.Error404NotFound("Invitation not found")
+	}
+	if invitation.AcceptedAt != nil || time.Now().After(invitation.ExpiresAt) {
+		return nil, huma.Error410Gone("Invitation is no longer valid")
+	}
+	return invitation, nil
+}
+
+func (a *api) acceptFarmInvitationHandler(ctx context.Context, input *MyFarmInvitationInput) (*FarmMessageOutput, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("Authentication failed", err)
+	}
+	invitation, err := a.pendingInvitationFor(ctx, input.InvitationID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = a.farmMemberRepo.AcceptInvitation(ctx, invitation.UUID, userID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, huma.Error410Gone("Invitation is no longer valid")
+	}
+	if err != nil {
+		a.logger.Error("Failed to accept farm invitation", "invitationId", invitation.UUID, "userId", userID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to accept invitation")
+	}
+
+	a.logger.Info("Farm invitation accepted", "farmId", invitation.FarmID, "invitationId", invitation.UUID, "userId", userID)
+	a.auditInvitation(ctx, domain.AuditFarmInvitationAccepted, invitation)
+	resp := &FarmMessageOutput{}
+	resp.Body.Message = "Invitation accepted"
+	return resp, nil
+}
+
+func (a *api) declineFarmInvitationHandler(ctx context.Context, input *MyFarmInvitationInput) (*FarmMessageOutput, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("Authentication failed", err)
+	}
+	invitation, err := a.pendingInvitationFor(ctx, input.InvitationID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.farmMemberRepo.DeleteInvitation(ctx, invitation.UUID); err != nil && !errors.Is(err, domain.ErrNotFound) {
+		a.logger.Error("Failed to delete farm invitation", "invitationId", invitation.UUID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to decline invitation")
+	}
+	a.auditInvitation(ctx, domain.AuditFarmInvitationDeclined, invitation)
+
+	resp := &FarmMessageOutput{}
+	resp.Body.Message = "Invitation declined"
+	return resp, nil
+}
+
+func (a *api) auditInvitation(ctx context.Context, action string, invitation *domain.FarmInvitation) {
+	a.audit(ctx, domain.AuditEntry{
+		Action:       action,
+		ResourceType: domain.AuditResourceFarmInvitation,
+		ResourceID:   invitation.UUID,
+		FarmID:       invitation.FarmID,
+		Details:      auditDetails(map[string]interface{}{"email": invitation.Email, "role": invitation.Role}),
+	})
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/forfarm/backend/internal/domain"
+)
+
+// memoryFarms serves GetByID from a fixed set of farms.
+type memoryFarms struct {
+	domain.FarmRepository
+	farms map[string]*domain.Farm
+}
+
+func (m memoryFarms) GetByID(_ context.Context, id string) (*domain.Farm, error) {
+	if f, ok := m.farms[id]; ok {
+		farm := *f
+		return &farm, nil
+	}
+	return nil, domain.ErrNotFound
+}
+
+// memoryFarmMembers keeps invitations and memberships in memory.
+type memoryFarmMembers struct {
+	domain.FarmMemberRepository
+	invitations map[string]*domain.FarmInvitation
+	roles       map[string]string
+}
+
+func newMemoryFarmMembers() *memoryFarmMembers {
+	return &memoryFarmMembers{invitations: map[string]*domain.FarmInvitation{}, roles: map[string]string{}}
+}
+
+func (m *memoryFarmMembers) GetRole(_ context.Context, farmID, userID string) (string, error) {
+	if role, ok := m.roles[farmID+"/"+userID]; ok {
+		return role, nil
+	}
+	return "", domain.ErrNotFound
+}
+
+func (m *memoryFarmMembers) CreateInvitation(_ context.Context, inv *domain.FarmInvitation) error {
+	inv.UUID = uuid.New().String()
+	stored := *inv
+	m.invitations[inv.UUID] = &stored
+	return nil
+}
+
+func (m *memoryFarmMembers) GetInvitation(_ context.Context, id string) (*domain.FarmInvitation, error) {
+	if inv, ok := m.invitations[id]; ok {
+		stored := *inv
+		return &stored, nil
+	}
+	return nil, domain.ErrNotFound
+}
+
+func (m *memoryFarmMembers) ListInvitationsForEmail(_ context.Context, email string) ([]domain.FarmInvitation, error) {
+	invitations := []domain.FarmInvitation{}
+	for _, inv := range m.invitations {
+		if strings.EqualFold(inv.Email, email) && inv.AcceptedAt == nil {
+			invitations = append(invitations, *inv)
+		}
+	}
+	return invitations, nil
+}
+
+func (m *memoryFarmMembers) AcceptInvitation(_ context.Context, invitationID, userID string) error {
+	inv, ok := m.invitations[invitationID]
+	if !ok || inv.AcceptedAt != nil {
+		return domain.ErrNotFound
+	}
+	now := time.Now()
+	inv.AcceptedAt = &now
+	m.roles[inv.FarmID+"/"+userID] = inv.Role
+	return nil
+}
+
+func TestFarmInvitations(t *testing.T) {
+	verified := time.Now()
+	owner := &domain.User{UUID: uuid.New().String(), Email: "owner@example.com", IsActive: true, EmailVerifiedAt: &verified}
+	farm := &domain.Farm{UUID: uuid.New().String(), Name: "North Field", OwnerID: owner.UUID}
+	users := memoryUsers{owner.UUID: owner}
+
+	members := newMemoryFarmMembers()
+	mailer := &recordingMailer{}
+	api := &api{
+		userRepo:       users,
+		farmRepo:       memoryFarms{farms: map[string]*domain.Farm{farm.UUID: farm}},
+		farmMemberRepo: members,
+		auditRepo:      &memoryAuditLog{},
+		mailer:         mailer,
+		logger:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	as := func(u *domain.User) context.Context {
+		return domain.ContextWithPrincipal(context.Background(), domain.Principal{UserID: u.UUID})
+	}
+
+	input := &CreateFarmInvitationInput{FarmID: farm.UUID}
+	input.Body.Email = "worker@example.com"
+	input.Body.Role = domain.FarmRoleWorker
+	created, err := api.createFarmInvitationHandler(as(owner), input)
+	require.NoError(t, err)
+	invitationID := created.Body.UUID
+
+	require.Len(t, mailer.sent, 1, "the invitee is emailed")
+	assert.Equal(t, "worker@example.com", mailer.sent[0].To)
+	assert.Contains(t, mailer.sent[0].Body, farm.Name)
+
+	t.Run("unverified address", func(t *testing.T) {
+		squatter := &domain.User{UUID: uuid.New().String(), Email: "worker@example.com", IsActive: true}
+		users[squatter.UUID] = squatter
+
+		_, err := api.getMyFarmInvitationsHandler(as(squatter), nil)
+		var statusErr huma.StatusError
+		require.ErrorAs(t, err, &statusErr)
+		assert.Equal(t, 403, statusErr.GetStatus())
+
+		_, err = api.acceptFarmInvitationHandler(as(squatter), &MyFarmInvitationInput{InvitationID: invitationID})
+		require.ErrorAs(t, err, &statusErr)
+		assert.Equal(t, 403, statusErr.GetStatus())
+		assert.Nil(t, members.invitations[invitationID].AcceptedAt)
+	})
+
+	t.Run("verified address", func(t *testing.T) {
+		invitee := &domain.User{UUID: uuid.New().String(), Email: "Worker@Example.com", IsActive: true, EmailVerifiedAt: &verified}
+		users[invitee.UUID] = invitee
+
+		list, err := api.getMyFarmInvitationsHandler(as(invitee), nil)
+		require.NoError(t, err)
+		require.Len(t, list.Body, 1)
+
+		_, err = api.acceptFarmInvitationHandler(as(invitee), &MyFarmInvitationInput{InvitationID: invitationID})
+		require.NoError(t, err)
+		assert.Equal(t, domain.FarmRoleWorker, members.roles[farm.UUID+"/"+invitee.UUID])
+	})
+}
@@ -7,25 +7,27 @@ import (
 	"time"
 
 	"github.com/danielgtaylor/huma/v2"
+	m "github.com/forfarm/backend/internal/middlewares"
 	"github.com/go-chi/chi/v5"
 )
 
 type HealthCheckOutput struct {
 	Body struct {
-		Status     string `json:"status"`
-		CacheCheck string `json:"cache_check"`
+		Status        string `json:"status"`
+		CacheCheck    string `json:"cache_check"`
+		EventBusCheck string `json:"event_bus_check,omitempty"`
 	}
 }
 
 func (a *api) registerHealthRoutes(_ chi.Router, api huma.API) {
-	huma.Register(api, huma.Operation{
+	huma.Register(api, m.WithPolicy(m.PolicyPublic, huma.Operation{
 		OperationID: "healthCheck",
 		Method:      http.MethodGet,
 		Path:        "/health",
 		Tags:        []string{"_ops"},
 		Summary:     "Check API Health",
 		Description: "Performs basic health checks and returns the service status.",
-	}, a.healthCheckHandler)
+	}), a.healthCheckHandler)
 }
 
 func (a *api) healthCheckHandler(ctx context.Context, input *struct{}) (*HealthCheckOutput, error) {
@@ -45,8 +47,24 @@ func (a *api) healthCheckHandler(ctx context.Context, input *struct{}) (*HealthC
 		a.cache.Delete(testKey)
 	}
 
+	// A disconnected event bus buffers events and reconnects on its own, so it
+	// degrades the service without failing the probe and restarting the pod.
+	eventBusOK := true
+	if a.eventBusHealth != nil {
+		if err := a.eventBusHealth(ctx); err != nil {
+			a.logger.WarnContext(ctx, "Event bus health check failed", "error", err)
+			resp.Body.EventBusCheck = err.Error()
+			eventBusOK = false
+		} else {
+			resp.Body.EventBusCheck = "ok"
+		}
+	}
+
 	if cacheOK {
 		resp.Body.Status = "ok"
+		if !eventBusOK {
+			resp.Body.Status = "degraded"
+		}
 		return resp, nil
 	}
 
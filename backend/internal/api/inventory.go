@@ -7,6 +7,7 @@ import (
 
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/forfarm/backend/internal/domain"
+	m "github.com/forfarm/backend/internal/middlewares"
 	"github.com/go-chi/chi/v5"
 )
 
@@ -14,61 +15,61 @@ func (a *api) registerInventoryRoutes(_ chi.Router, api huma.API) {
 	tags := []string{"inventory"}
 	prefix := "/inventory"
 
-	huma.Register(api, huma.Operation{
+	huma.Register(api, m.WithScope(domain.ScopeInventoryWrite, m.WithPolicy(m.PolicyOwner, huma.Operation{
 		OperationID: "createInventoryItem",
 		Method:      http.MethodPost,
 		Path:        prefix,
 		Tags:        tags,
-	}, a.createInventoryItemHandler)
+	})), a.createInventoryItemHandler)
 
-	huma.Register(api, huma.Operation{
+	huma.Register(api, m.WithScope(domain.ScopeInventoryRead, m.WithPolicy(m.PolicyOwner, huma.Operation{
 		OperationID: "getInventoryItemsByUser",
 		Method:      http.MethodGet,
 		Path:        prefix,
 		Tags:        tags,
-	}, a.getInventoryItemsByUserHandler)
+	})), a.getInventoryItemsByUserHandler)
 
-	huma.Register(api, huma.Operation{
+	huma.Register(api, m.WithScope(domain.ScopeInventoryRead, m.WithPolicy(m.PolicyOwner, huma.Operation{
 		OperationID: "getInventoryItem",
 		Method:      http.MethodGet,
 		Path:        prefix + "/{id}",
 		Tags:        tags,
-	}, a.getInventoryItemHandler)
+	})), a.getInventoryItemHandler)
 
-	huma.Register(api, huma.Operation{
+	huma.Register(api, m.WithScope(domain.ScopeInventoryWrite, m.WithPolicy(m.PolicyOwner, huma.Operation{
 		OperationID: "updateInventoryItem",
 		Method:      http.MethodPut,
 		Path:        prefix + "/{id}",
 		Tags:        tags,
-	}, a.updateInventoryItemHandler)
+	})), a.updateInventoryItemHandler)
 
-	huma.Register(api, huma.Operation{
+	huma.Register(api, m.WithScope(domain.ScopeInventoryWrite, m.WithPolicy(m.PolicyOwner, huma.Operation{
 		OperationID: "deleteInventoryItem",
 		Method:      http.MethodDelete,
 		Path:        prefix + "/{id}",
 		Tags:        tags,
-	}, a.deleteInventoryItemHandler)
+	})), a.deleteInventoryItemHandler)
 
-	huma.Register(api, huma.Operation{
+	huma.Register(api, m.WithPolicy(m.PolicyPublic, huma.Operation{
 		OperationID: "getInventoryStatus",
 		Method:      http.MethodGet,
 		Path:        prefix + "/status",
 		Tags:        tags,
-	}, a.getInventoryStatusHandler)
+	}), a.getInventoryStatusHandler)
 
-	huma.Register(api, huma.Operation{
+	huma.Register(api, m.WithPolicy(m.PolicyPublic, huma.Operation{
 		OperationID: "getInventoryCategory",
 		Method:      http.MethodGet,
 		Path:        prefix + "/category",
 		Tags:        tags,
-	}, a.getInventoryCategoryHandler)
+	}), a.getInventoryCategoryHandler)
 
-	huma.Register(api, huma.Operation{
+	huma.Register(api, m.WithPolicy(m.PolicyPublic, huma.Operation{
 		OperationID: "getHarvestUnits",
 		Method:      http.MethodGet,
 		Path:        "/harvest/units",
 		Tags:        []string{"harvest"},
-	}, a.getHarvestUnitsHandler)
+	}), a.getHarvestUnitsHandler)
 }
 
 type InventoryItemResponse struct {
@@ -99,8 +100,7 @@ type HarvestUnit struct {
 }
 
 type CreateInventoryItemInput struct {
-	Header string `header:"Authorization" required:"true" example:"Bearer token"`
-	Body   struct {
+	Body struct {
 		Name       string    `json:"name" required:"true"`
 		CategoryID int       `json:"categoryId" required:"true"`
 		Quantity   float64   `json:"quantity" required:"true"`
@@ -117,9 +117,8 @@ type CreateInventoryItemOutput struct {
 }
 
 type UpdateInventoryItemInput struct {
-	Header string `header:"Authorization" required:"true" example:"Bearer token"`
-	ID     string `path:"id"`
-	Body   struct {
+	ID   string `path:"id"`
+	Body struct {
 		Name       string    `json:"name"`
 		CategoryID int       `json:"categoryId"`
 		Quantity   float64   `json:"quantity"`
@@ -134,7 +133,6 @@ type UpdateInventoryItemOutput struct {
 }
 
 type GetInventoryItemsInput struct {
-	Header      string    `header:"Authorization" required:"true" example:"Bearer token"`
 	CategoryID  int       `query:"categoryId"`
 	StatusID    int       `query:"statusId"`
 	StartDate   time.Time `query:"startDate" format:"date-time"`
@@ -149,9 +147,7 @@ type GetInventoryItemsOutput struct {
 }
 
 type GetInventoryItemInput struct {
-	Header string `header:"Authorization" required:"true" example:"Bearer token"`
-	UserID string `header:"userId" required:"true" example:"user-uuid"`
-	ID     string `path:"id"`
+	ID string `path:"id"`
 }
 
 type GetInventoryItemOutput struct {
@@ -159,8 +155,7 @@ type GetInventoryItemOutput struct {
 }
 
 type DeleteInventoryItemInput struct {
-	Header string `header:"Authorization" required:"true" example:"Bearer token"`
-	ID     string `path:"id"`
+	ID string `path:"id"`
 }
 
 type DeleteInventoryItemOutput struct {
@@ -182,7 +177,10 @@ type GetHarvestUnitsOutput struct {
 }
 
 func (a *api) createInventoryItemHandler(ctx context.Context, input *CreateInventoryItemInput) (*CreateInventoryItemOutput, error) {
-	userID, err := a.getUserIDFromHeader(input.Header)
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("Authentication failed", err)
+	}
 	item := &domain.InventoryItem{
 		UserID:     userID,
 		Name:       input.Body.Name,
@@ -201,6 +199,13 @@ func (a *api) createInventoryItemHandler(ctx context.Context, input *CreateInven
 	if err != nil {
 		return nil, err
 	}
+	a.audit(ctx, domain.AuditEntry{
+		Action:       domain.AuditInventoryItemCreated,
+		UserID:       userID,
+		ResourceType: domain.AuditResourceInventoryItem,
+		ResourceID:   item.ID,
+		Changes:      domain.AuditChanges(nil, item),
+	})
 
 	return &CreateInventoryItemOutput{Body: struct {
 		ID string `json:"id"`
@@ -208,7 +213,10 @@ func (a *api) createInventoryItemHandler(ctx context.Context, input *CreateInven
 }
 
 func (a *api) getInventoryItemsByUserHandler(ctx context.Context, input *GetInventoryItemsInput) (*GetInventoryItemsOutput, error) {
-	userID, err := a.getUserIDFromHeader(input.Header)
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("Authentication failed", err)
+	}
 	filter := domain.InventoryFilter{
 		UserID:      userID,
 		CategoryID:  input.CategoryID,
@@ -256,7 +264,12 @@ func (a *api) getInventoryItemsByUserHandler(ctx context.Context, input *GetInve
 }
 
 func (a *api) getInventoryItemHandler(ctx context.Context, input *GetInventoryItemInput) (*GetInventoryItemOutput, error) {
-	item, err := a.inventoryRepo.GetByID(ctx, input.ID, input.UserID)
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("Authentication failed", err)
+	}
+
+	item, err := a.inventoryRepo.GetByID(ctx, input.ID, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -284,11 +297,15 @@ func (a *api) getInventoryItemHandler(ctx context.Context, input *GetInventoryIt
 }
 
 func (a *api) updateInventoryItemHandler(ctx context.Context, input *UpdateInventoryItemInput) (*UpdateInventoryItemOutput, error) {
-	userID, err := a.getUserIDFromHeader(input.Header)
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("Authentication failed", err)
+	}
 	item, err := a.inventoryRepo.GetByID(ctx, input.ID, userID)
 	if err != nil {
 		return nil, err
 	}
+	before := item
 
 	if input.Body.Name != "" {
 		item.Name = input.Body.Name
@@ -322,6 +339,13 @@ func (a *api) updateInventoryItemHandler(ctx context.Context, input *UpdateInven
 	if err != nil {
 		return nil, err
 	}
+	a.audit(ctx, domain.AuditEntry{
+		Action:       domain.AuditInventoryItemUpdated,
+		UserID:       userID,
+		ResourceType: domain.AuditResourceInventoryItem,
+		ResourceID:   updatedItem.ID,
+		Changes:      domain.AuditChanges(before, updatedItem),
+	})
 
 	return &UpdateInventoryItemOutput{Body: InventoryItemResponse{
 		ID:   updatedItem.ID,
@@ -346,11 +370,25 @@ func (a *api) updateInventoryItemHandler(ctx context.Context, input *UpdateInven
 }
 
 func (a *api) deleteInventoryItemHandler(ctx context.Context, input *DeleteInventoryItemInput) (*DeleteInventoryItemOutput, error) {
-	userID, err := a.getUserIDFromHeader(input.Header)
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("Authentication failed", err)
+	}
+	item, err := a.inventoryRepo.GetByID(ctx, input.ID, userID)
+	if err != nil {
+		return nil, err
+	}
 	err = a.inventoryRepo.Delete(ctx, input.ID, userID)
 	if err != nil {
 		return nil, err
 	}
+	a.audit(ctx, domain.AuditEntry{
+		Action:       domain.AuditInventoryItemDeleted,
+		UserID:       userID,
+		ResourceType: domain.AuditResourceInventoryItem,
+		ResourceID:   item.ID,
+		Changes:      domain.AuditChanges(item, nil),
+	})
 
 	return &DeleteInventoryItemOutput{Body: struct {
 		Message string `json:"message"`
@@ -9,6 +9,7 @@ import (
 
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/forfarm/backend/internal/domain"
+	m "github.com/forfarm/backend/internal/middlewares"
 	"github.com/go-chi/chi/v5"
 	"github.com/gofrs/uuid"
 )
@@ -18,61 +19,61 @@ func (a *api) registerKnowledgeHubRoutes(_ chi.Router, api huma.API) {
 
 	prefix := "/knowledge-hub"
 
-	huma.Register(api, huma.Operation{
+	huma.Register(api, m.WithPolicy(m.PolicyPublic, huma.Operation{
 		OperationID: "getAllKnowledgeArticles",
 		Method:      http.MethodGet,
 		Path:        prefix,
 		Tags:        tags,
-	}, a.getAllKnowledgeArticlesHandler)
+	}), a.getAllKnowledgeArticlesHandler)
 
-	huma.Register(api, huma.Operation{
+	huma.Register(api, m.WithPolicy(m.PolicyPublic, huma.Operation{
 		OperationID: "getKnowledgeArticleByID",
 		Method:      http.MethodGet,
 		Path:        prefix + "/{uuid}",
 		Tags:        tags,
-	}, a.getKnowledgeArticleByIDHandler)
+	}), a.getKnowledgeArticleByIDHandler)
 
-	huma.Register(api, huma.Operation{
+	huma.Register(api, m.WithPolicy(m.PolicyPublic, huma.Operation{
 		OperationID: "getKnowledgeArticlesByCategory",
 		Method:      http.MethodGet,
 		Path:        prefix + "/category/{category}",
 		Tags:        tags,
-	}, a.getKnowledgeArticlesByCategoryHandler)
+	}), a.getKnowledgeArticlesByCategoryHandler)
 
-	huma.Register(api, huma.Operation{
+	huma.Register(api, m.WithPolicy(m.PolicyAdmin, huma.Operation{
 		OperationID: "createOrUpdateKnowledgeArticle",
 		Method:      http.MethodPost,
 		Path:        prefix,
 		Tags:        tags,
-	}, a.createOrUpdateKnowledgeArticleHandler)
+	}), a.createOrUpdateKnowledgeArticleHandler)
 
-	huma.Register(api, huma.Operation{
+	huma.Register(api, m.WithPolicy(m.PolicyPublic, huma.Operation{
 		OperationID: "getArticleTableOfContents",
 		Method:      http.MethodGet,
 		Path:        prefix + "/{uuid}/toc",
 		Tags:        tags,
-	}, a.getArticleTableOfContentsHandler)
+	}), a.getArticleTableOfContentsHandler)
 
-	huma.Register(api, huma.Operation{
+	huma.Register(api, m.WithPolicy(m.PolicyPublic, huma.Operation{
 		OperationID: "getArticleRelatedArticles",
 		Method:      http.MethodGet,
 		Path:        prefix + "/{uuid}/related",
 		Tags:        tags,
-	}, a.getArticleRelatedArticlesHandler)
+	}), a.getArticleRelatedArticlesHandler)
 
-	huma.Register(api, huma.Operation{
+	huma.Register(api, m.WithPolicy(m.PolicyAdmin, huma.Operation{
 		OperationID: "createRelatedArticle",
 		Method:      http.MethodPost,
 		Path:        prefix + "/{uuid}/related",
 		Tags:        tags,
-	}, a.createRelatedArticleHandler)
+	}), a.createRelatedArticleHandler)
 
-	huma.Register(api, huma.Operation{
+	huma.Register(api, m.WithPolicy(m.PolicyAdmin, huma.Operation{
 		OperationID: "generateTableOfContents",
 		Method:      http.MethodPost,
 		Path:        prefix + "/{uuid}/generate-toc",
 		Tags:        tags,
-	}, a.generateTOCHandler)
+	}), a.generateTOCHandler)
 }
 
 type GetKnowledgeArticlesOutput struct {
@@ -224,9 +225,30 @@ func (a *api) createOrUpdateKnowledgeArticleHandler(ctx context.Context, input *
 		ImageURL:    input.Body.ImageURL,
 	}
 
+	var before *domain.KnowledgeArticle
+	if article.UUID != "" {
+		existing, err := a.knowledgeHubRepo.GetArticleByID(ctx, article.UUID)
+		if err != nil && !errors.Is(err, domain.ErrNotFound) {
+			return nil, err
+		}
+		if err == nil {
+			before = &existing
+		}
+	}
+
 	if err := a.knowledgeHubRepo.CreateOrUpdateArticle(ctx, article); err != nil {
 		return nil, err
 	}
+	action := domain.AuditArticleCreated
+	if before != nil {
+		action = domain.AuditArticleUpdated
+	}
+	a.audit(ctx, domain.AuditEntry{
+		Action:       action,
+		ResourceType: domain.AuditResourceArticle,
+		ResourceID:   article.UUID,
+		Changes:      domain.AuditChanges(before, article),
+	})
 
 	resp.Body.Article = *article
 	return resp, nil
@@ -300,6 +322,12 @@ func (a *api) createRelatedArticleHandler(
 	if err := a.knowledgeHubRepo.CreateRelatedArticle(ctx, input.UUID, related); err != nil {
 		return nil, huma.Error500InternalServerError("failed to create related article")
 	}
+	a.audit(ctx, domain.AuditEntry{
+		Action:       domain.AuditArticleUpdated,
+		ResourceType: domain.AuditResourceArticle,
+		ResourceID:   input.UUID,
+		Details:      auditDetails(map[string]interface{}{"relatedArticleAdded": related.RelatedTitle}),
+	})
 
 	return nil, nil
 }
@@ -367,6 +395,13 @@ func (a *api) generateTOCHandler(
 		return nil, huma.Error500InternalServerError("failed to save table of contents")
 	}
 
+	a.audit(ctx, domain.AuditEntry{
+		Action:       domain.AuditArticleUpdated,
+		ResourceType: domain.AuditResourceArticle,
+		ResourceID:   input.UUID,
+		Details:      auditDetails(map[string]interface{}{"tableOfContentsGenerated": len(tocItems)}),
+	})
+
 	resp.Body.TableOfContents = tocItems
 	return resp, nil
 }
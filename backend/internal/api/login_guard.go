@@ -0,0 +1,82 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/go-chi/chi/v5"
+
+	"github.com/forfarm/backend/internal/domain"
+	m "github.com/forfarm/backend/internal/middlewares"
+	"github.com/forfarm/backend/internal/services"
+)
+
+func (a *api) registerLoginGuardRoutes(_ chi.Router, api huma.API) {
+	huma.Register(api, m.WithPolicy(m.PolicyAdmin, huma.Operation{
+		OperationID: "unlockUserSignIn",
+		Method:      http.MethodPost,
+		Path:        "/admin/users/{userId}/unlock",
+		Tags:        []string{"admin"},
+		Summary:     "Lift a lockout caused by failed sign-ins",
+	}), a.unlockUserSignInHandler)
+}
+
+type UnlockUserSignInInput struct {
+	UserID string `path:"userId" required:"true" format:"uuid"`
+}
+
+type UnlockUserSignInOutput struct {
+	Body struct {
+		Unlocked bool   `json:"unlocked" doc:"Whether the account was locked"`
+		Message  string `json:"message"`
+	}
+}
+
+// loginThrottled turns a LoginGuard refusal into a 429 with Retry-After.
+func loginThrottled(err error) error {
+	var throttled *services.LoginThrottledError
+	if !errors.As(err, &throttled) {
+		return huma.Error500InternalServerError("Login failed due to an internal error")
+	}
+
+	msg := "Too many failed sign-in attempts; try again later"
+	if throttled.Locked {
+		msg = "Sign-in is temporarily locked after too many failed attempts"
+	}
+	retryAfter := strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds())))
+	return huma.ErrorWithHeaders(huma.Error429TooManyRequests(msg), http.Header{"Retry-After": {retryAfter}})
+}
+
+func (a *api) unlockUserSignInHandler(ctx context.Context, input *UnlockUserSignInInput) (*UnlockUserSignInOutput, error) {
+	adminID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("Authentication failed", err)
+	}
+
+	user, err := a.userRepo.GetByUUID(ctx, input.UserID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, huma.Error404NotFound("User not found")
+	}
+	if err != nil {
+		a.logger.Error("Failed to get user to unlock", "user_uuid", input.UserID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to unlock sign-in")
+	}
+
+	unlocked, err := a.loginGuard.Unlock(ctx, services.LoginAttempt{Email: user.Email, UserID: user.UUID}, adminID, "admin")
+	if err != nil {
+		a.logger.Error("Failed to unlock sign-in", "user_uuid", user.UUID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to unlock sign-in")
+	}
+
+	resp := &UnlockUserSignInOutput{}
+	resp.Body.Unlocked = unlocked
+	resp.Body.Message = "Account was not locked"
+	if unlocked {
+		resp.Body.Message = "Account unlocked"
+	}
+	return resp, nil
+}
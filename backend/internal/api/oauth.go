@@ -3,37 +3,123 @@ package api
 import (
 	"context"
 	"crypto/rand"
-	"database/sql"
 	"errors"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/forfarm/backend/internal/domain"
-	"github.com/forfarm/backend/internal/utilities"
+	m "github.com/forfarm/backend/internal/middlewares"
+	"github.com/forfarm/backend/internal/services"
+	"github.com/forfarm/backend/internal/services/oidc"
 	"github.com/go-chi/chi/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
 func (a *api) registerOauthRoutes(_ chi.Router, apiInstance huma.API) {
 	tags := []string{"oauth"}
-	huma.Register(apiInstance, huma.Operation{
+	huma.Register(apiInstance, m.WithPolicy(m.PolicyPublic, huma.Operation{
 		OperationID: "oauth_exchange",
 		Method:      http.MethodPost,
 		Path:        "/oauth/exchange",
 		Tags:        tags,
-	}, a.exchangeHandler)
+		Summary:     "Sign in with an ID token from an identity provider",
+	}), a.exchangeHandler)
+
+	huma.Register(apiInstance, m.WithPolicy(m.PolicyPublic, huma.Operation{
+		OperationID: "oauth_providers",
+		Method:      http.MethodGet,
+		Path:        "/oauth/providers",
+		Tags:        tags,
+	}), a.getOauthProvidersHandler)
+
+	huma.Register(apiInstance, m.WithPolicy(m.PolicyOwner, huma.Operation{
+		OperationID: "getLinkedIdentities",
+		Method:      http.MethodGet,
+		Path:        "/auth/identities",
+		Tags:        tags,
+	}), a.getLinkedIdentitiesHandler)
+
+	huma.Register(apiInstance, m.WithPolicy(m.PolicyOwner, huma.Operation{
+		OperationID:   "linkIdentity",
+		Method:        http.MethodPost,
+		Path:          "/auth/identities",
+		Tags:          tags,
+		Summary:       "Link an identity provider account for signing in",
+		DefaultStatus: http.StatusCreated,
+	}), a.linkIdentityHandler)
+
+	huma.Register(apiInstance, m.WithPolicy(m.PolicyOwner, huma.Operation{
+		OperationID: "unlinkIdentity",
+		Method:      http.MethodDelete,
+		Path:        "/auth/identities/{provider}",
+		Tags:        tags,
+		Description: "Refused when it would leave the account with no way to sign in; set a password first.",
+	}), a.unlinkIdentityHandler)
 }
 
 type ExchangeTokenInput struct {
-	Body struct {
-		AccessToken string `json:"accessToken" required:"true" example:"Google ID token"`
+	UserAgent string `header:"User-Agent"`
+	ClientIP  string
+	Body      struct {
+		AccessToken string `json:"accessToken" required:"true" example:"ID token issued by the provider"`
+		Provider    string `json:"provider,omitempty" default:"google" example:"google"`
+		Nonce       string `json:"nonce,omitempty" doc:"Nonce the token must carry, if the client sent one"`
 	}
 }
 
+func (i *ExchangeTokenInput) Resolve(ctx huma.Context) []error {
+	i.ClientIP = m.ClientIP(ctx)
+	return nil
+}
+
 type ExchangeTokenOutput struct {
 	Body struct {
-		JWT   string `json:"jwt" example:"Fresh JWT for frontend authentication"`
-		Email string `json:"email" example:"Email address of the user"`
+		JWT          string `json:"jwt" example:"Fresh JWT for frontend authentication"`
+		RefreshToken string `json:"refreshToken" doc:"Single-use token for POST /auth/refresh"`
+		ExpiresIn    int    `json:"expiresIn" doc:"Seconds until the JWT expires"`
+		Email        string `json:"email" example:"Email address of the user"`
+		// TwoFactorRequired is set, and no tokens are issued, when the user
+		// must complete the login at POST /auth/login/2fa.
+		TwoFactorRequired bool   `json:"twoFactorRequired,omitempty"`
+		ChallengeToken    string `json:"challengeToken,omitempty"`
+	}
+}
+
+type GetOauthProvidersOutput struct {
+	Body struct {
+		Providers []string `json:"providers"`
+	}
+}
+
+type GetLinkedIdentitiesOutput struct {
+	Body struct {
+		Identities  []domain.LinkedIdentity `json:"identities"`
+		Providers   []string                `json:"providers" doc:"Providers that can be linked"`
+		PasswordSet bool                    `json:"passwordSet"`
+	}
+}
+
+type LinkIdentityInput struct {
+	Body struct {
+		Provider string `json:"provider" required:"true" example:"microsoft"`
+		IDToken  string `json:"idToken" required:"true"`
+		Nonce    string `json:"nonce,omitempty"`
+	}
+}
+
+type LinkIdentityOutput struct {
+	Body domain.LinkedIdentity
+}
+
+type UnlinkIdentityInput struct {
+	Provider string `path:"provider" required:"true"`
+}
+
+type UnlinkIdentityOutput struct {
+	Body struct {
+		Message string `json:"message"`
 	}
 }
 
@@ -52,71 +138,325 @@ func generateRandomPassword(length int) (string, error) {
 	return string(bytes), nil
 }
 
-func (a *api) exchangeHandler(ctx context.Context, input *ExchangeTokenInput) (*ExchangeTokenOutput, error) {
-	if input.Body.AccessToken == "" {
-		return nil, huma.Error400BadRequest("accessToken is required") // Match JSON tag
-	}
-
-	googleUserID, email, err := utilities.ExtractGoogleUserID(input.Body.AccessToken)
+// unusablePassword returns the hash of a random password, for accounts that
+// sign in through a provider only.
+func unusablePassword() (string, error) {
+	password, err := generateRandomPassword(32)
 	if err != nil {
-		a.logger.Warn("Invalid Google ID token received", "error", err)
-		return nil, huma.Error401Unauthorized("Invalid Google ID token", err)
+		return "", err
 	}
-	if email == "" {
-		a.logger.Error("Google token verification succeeded but email is missing", "googleUserId", googleUserID)
-		return nil, huma.Error500InternalServerError("Failed to retrieve email from Google token")
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hashed), err
+}
+
+// verifyIdentity checks an ID token and turns failures into API errors.
+func (a *api) verifyIdentity(ctx context.Context, provider, idToken, nonce string) (*oidc.Identity, error) {
+	identity, err := a.identityProviders.Verify(ctx, provider, idToken, nonce)
+	switch {
+	case err == nil:
+		return identity, nil
+	case errors.Is(err, oidc.ErrUnknownProvider):
+		return nil, huma.Error400BadRequest(fmt.Sprintf("Sign-in with %q is not enabled", provider))
+	case errors.Is(err, oidc.ErrInvalidToken):
+		a.logger.Warn("Invalid ID token received", "provider", provider, "error", err)
+		return nil, huma.Error401Unauthorized("Invalid ID token")
+	default:
+		a.logger.Error("Failed to verify ID token", "provider", provider, "error", err)
+		return nil, huma.Error502BadGateway("The identity provider could not be reached")
 	}
+}
 
-	user, err := a.userRepo.GetByEmail(ctx, email)
-	if errors.Is(err, domain.ErrNotFound) || errors.Is(err, sql.ErrNoRows) {
-		a.logger.Info("Creating new user from Google OAuth", "email", email, "googleUserId", googleUserID)
+// inactiveAccount is the error for a sign-in to a deactivated account.
+func (a *api) inactiveAccount(user *domain.User) error {
+	a.logger.Warn("OAuth login attempt for inactive user", "email", user.Email, "user_uuid", user.UUID)
+	return huma.Error403Forbidden("Account is inactive")
+}
 
-		newPassword, passErr := generateRandomPassword(16) // Increase length
-		if passErr != nil {
-			a.logger.Error("Failed to generate random password for OAuth user", "error", passErr)
-			return nil, huma.Error500InternalServerError("User creation failed (password generation)")
+// userForIdentity returns the user a provider account signs in as. The first
+// sign-in links the account to the user with its email address, creating one
+// if there is none. A deactivated account is refused before anything about
+// it changes.
+func (a *api) userForIdentity(ctx context.Context, identity *oidc.Identity) (*domain.User, error) {
+	linked, err := a.identityRepo.GetByProviderSubject(ctx, identity.Provider, identity.Subject)
+	if err == nil {
+		user, err := a.userRepo.GetByUUID(ctx, linked.UserID)
+		if err != nil {
+			a.logger.Error("Failed to get user of linked identity", "identity", linked.UUID, "error", err)
+			return nil, huma.Error500InternalServerError("Failed to process login")
 		}
-
-		hashedPassword, hashErr := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
-		if hashErr != nil {
-			a.logger.Error("Failed to hash generated password for OAuth user", "error", hashErr)
-			return nil, huma.Error500InternalServerError("User creation failed (password hashing)")
+		if !user.IsActive {
+			return nil, a.inactiveAccount(&user)
 		}
+		if err := a.identityRepo.Touch(ctx, linked.UUID); err != nil {
+			a.logger.Error("Failed to record identity use", "identity", linked.UUID, "error", err)
+		}
+		return &user, nil
+	}
+	if !errors.Is(err, domain.ErrNotFound) {
+		a.logger.Error("Failed to look up linked identity", "provider", identity.Provider, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to process login")
+	}
 
-		newUser := &domain.User{
-			Email:    email,
-			Password: string(hashedPassword), // Store hashed random password
-			IsActive: true,                   // Activate user immediately
-			// Username can be initially empty or derived from email if needed
+	if identity.Email == "" {
+		return nil, huma.Error422UnprocessableEntity("The identity provider did not share an email address")
+	}
+
+	user, err := a.userRepo.GetByEmail(ctx, identity.Email)
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		a.logger.Info("Creating new user from identity provider", "provider", identity.Provider, "email", identity.Email)
+		password, err := unusablePassword()
+		if err != nil {
+			a.logger.Error("Failed to generate password for provider user", "error", err)
+			return nil, huma.Error500InternalServerError("Failed to create user account")
+		}
+		user = domain.User{
+			Email:    identity.Email,
+			Password: password,
+			IsActive: true,
 		}
-		if createErr := a.userRepo.CreateOrUpdate(ctx, newUser); createErr != nil {
-			a.logger.Error("Failed to save new OAuth user to database", "email", email, "error", createErr)
+		if identity.EmailVerified {
+			now := time.Now()
+			user.EmailVerifiedAt = &now
+		}
+		if err := a.userRepo.CreateOrUpdate(ctx, &user); err != nil {
+			a.logger.Error("Failed to save new provider user", "email", identity.Email, "error", err)
 			return nil, huma.Error500InternalServerError("Failed to create user account")
 		}
-		user = *newUser
-	} else if err != nil {
-		a.logger.Error("Database error looking up user by email during OAuth", "email", email, "error", err)
+		a.auditAccount(ctx, domain.AuditRegistered, user.UUID, map[string]interface{}{"provider": identity.Provider})
+
+	case err != nil:
+		a.logger.Error("Database error looking up user by email during OAuth", "email", identity.Email, "error", err)
 		return nil, huma.Error500InternalServerError("Failed to process login")
+
+	case !user.IsActive:
+		return nil, a.inactiveAccount(&user)
+
+	case !identity.EmailVerified:
+		// Without proof that the provider account owns the address, it
+		// must be linked from inside the account.
+		return nil, huma.Error409Conflict(fmt.Sprintf("An account with this email already exists; sign in and link %s from your account settings", identity.Provider))
+
+	case !user.IsEmailVerified():
+		// Whoever registered the address never proved it, so their
+		// password may not be the owner's: only the provider signs in now.
+		password, err := unusablePassword()
+		if err != nil {
+			a.logger.Error("Failed to generate password for provider user", "error", err)
+			return nil, huma.Error500InternalServerError("Failed to process login")
+		}
+		now := time.Now()
+		user.Password = password
+		user.PasswordSet = false
+		user.EmailVerifiedAt = &now
+		if err := a.userRepo.CreateOrUpdate(ctx, &user); err != nil {
+			a.logger.Error("Failed to mark provider user's email verified", "user_uuid", user.UUID, "error", err)
+			return nil, huma.Error500InternalServerError("Failed to process login")
+		}
+		if err := a.sessionRepo.RevokeAllForUser(ctx, user.UUID); err != nil {
+			a.logger.Error("Failed to revoke sessions of unverified account", "user_uuid", user.UUID, "error", err)
+		}
 	}
 
-	// Ensure the existing user is active
-	if !user.IsActive {
-		a.logger.Warn("OAuth login attempt for inactive user", "email", email, "user_uuid", user.UUID)
-		return nil, huma.Error403Forbidden("Account is inactive")
+	newIdentity := &domain.LinkedIdentity{
+		UserID:   user.UUID,
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
 	}
+	err = a.identityRepo.Link(ctx, newIdentity)
+	if errors.Is(err, domain.ErrConflict) {
+		return nil, huma.Error409Conflict(fmt.Sprintf("This account is already linked to another %s account", identity.Provider))
+	}
+	if err != nil {
+		a.logger.Error("Failed to link identity", "user_uuid", user.UUID, "provider", identity.Provider, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to process login")
+	}
+	a.auditIdentity(ctx, domain.AuditIdentityLinked, user.UUID, newIdentity.UUID, identity.Provider)
+	return &user, nil
+}
 
-	// Generate JWT for the user (either existing or newly created)
-	token, err := utilities.CreateJwtToken(user.UUID)
+func (a *api) exchangeHandler(ctx context.Context, input *ExchangeTokenInput) (*ExchangeTokenOutput, error) {
+	if input.Body.AccessToken == "" {
+		return nil, huma.Error400BadRequest("accessToken is required") // Match JSON tag
+	}
+
+	attempt := services.LoginAttempt{IP: input.ClientIP}
+	if err := a.loginGuard.Check(ctx, attempt); err != nil {
+		a.logger.Warn("Throttled OAuth exchange", "ip", input.ClientIP, "error", err)
+		return nil, loginThrottled(err)
+	}
+
+	identity, err := a.verifyIdentity(ctx, input.Body.Provider, input.Body.AccessToken, input.Body.Nonce)
+	if err != nil {
+		var statusErr huma.StatusError
+		if errors.As(err, &statusErr) && statusErr.GetStatus() == http.StatusUnauthorized {
+			a.loginGuard.Fail(ctx, attempt)
+		}
+		return nil, err
+	}
+
+	// A locked account stays locked whichever way it signs in.
+	attempt.Email = identity.Email
+	if err := a.loginGuard.Check(ctx, attempt); err != nil {
+		a.logger.Warn("Throttled OAuth exchange", "email", identity.Email, "ip", input.ClientIP, "error", err)
+		return nil, loginThrottled(err)
+	}
+
+	user, err := a.userForIdentity(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := a.completeLogin(ctx, user, input.UserAgent)
 	if err != nil {
-		a.logger.Error("Failed to create JWT token after OAuth exchange", "user_uuid", user.UUID, "error", err)
+		a.logger.Error("Failed to start session after OAuth exchange", "user_uuid", user.UUID, "error", err)
 		return nil, huma.Error500InternalServerError("Failed to generate session token")
 	}
 
+	if !result.TwoFactorRequired {
+		attempt.Email = user.Email
+		a.loginGuard.Succeed(ctx, attempt)
+		a.auditAccount(ctx, domain.AuditLoginSucceeded, user.UUID, map[string]interface{}{"method": identity.Provider})
+	}
+
 	output := &ExchangeTokenOutput{}
-	output.Body.JWT = token
-	output.Body.Email = email // Return the email for frontend context
-	_ = googleUserID          // Maybe log or store this association if needed later
+	output.Body.JWT = result.Token
+	output.Body.RefreshToken = result.RefreshToken
+	output.Body.ExpiresIn = result.ExpiresIn
+	output.Body.TwoFactorRequired = result.TwoFactorRequired
+	output.Body.ChallengeToken = result.ChallengeToken
+	output.Body.Email = user.Email // Return the email for frontend context
 
-	a.logger.Info("OAuth exchange successful", "email", email, "user_uuid", user.UUID)
+	a.logger.Info("OAuth exchange successful", "provider", identity.Provider, "email", user.Email, "user_uuid", user.UUID)
 	return output, nil
 }
+
+func (a *api) getOauthProvidersHandler(_ context.Context, _ *struct{}) (*GetOauthProvidersOutput, error) {
+	resp := &GetOauthProvidersOutput{}
+	resp.Body.Providers = a.identityProviders.Names()
+	return resp, nil
+}
+
+func (a *api) getLinkedIdentitiesHandler(ctx context.Context, _ *struct{}) (*GetLinkedIdentitiesOutput, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("Authentication failed", err)
+	}
+
+	user, err := a.userRepo.GetByUUID(ctx, userID)
+	if err != nil {
+		a.logger.Error("Failed to get user", "user_uuid", userID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to retrieve linked identities")
+	}
+	identities, err := a.identityRepo.ListByUserID(ctx, userID)
+	if err != nil {
+		a.logger.Error("Failed to list linked identities", "user_uuid", userID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to retrieve linked identities")
+	}
+
+	resp := &GetLinkedIdentitiesOutput{}
+	resp.Body.Identities = identities
+	resp.Body.Providers = a.identityProviders.Names()
+	resp.Body.PasswordSet = user.PasswordSet
+	return resp, nil
+}
+
+func (a *api) linkIdentityHandler(ctx context.Context, input *LinkIdentityInput) (*LinkIdentityOutput, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("Authentication failed", err)
+	}
+
+	identity, err := a.verifyIdentity(ctx, input.Body.Provider, input.Body.IDToken, input.Body.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	linked, err := a.identityRepo.GetByProviderSubject(ctx, identity.Provider, identity.Subject)
+	if err == nil {
+		if linked.UserID == userID {
+			return nil, huma.Error409Conflict("This account is already linked")
+		}
+		return nil, huma.Error409Conflict("This account is linked to another user")
+	}
+	if !errors.Is(err, domain.ErrNotFound) {
+		a.logger.Error("Failed to look up linked identity", "provider", identity.Provider, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to link account")
+	}
+
+	newIdentity := &domain.LinkedIdentity{
+		UserID:   userID,
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+	}
+	err = a.identityRepo.Link(ctx, newIdentity)
+	if errors.Is(err, domain.ErrConflict) {
+		return nil, huma.Error409Conflict(fmt.Sprintf("Another %s account is already linked; unlink it first", identity.Provider))
+	}
+	if err != nil {
+		a.logger.Error("Failed to link identity", "user_uuid", userID, "provider", identity.Provider, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to link account")
+	}
+
+	a.logger.Info("Identity linked", "user_uuid", userID, "provider", identity.Provider)
+	a.auditIdentity(ctx, domain.AuditIdentityLinked, userID, newIdentity.UUID, identity.Provider)
+	return &LinkIdentityOutput{Body: *newIdentity}, nil
+}
+
+func (a *api) unlinkIdentityHandler(ctx context.Context, input *UnlinkIdentityInput) (*UnlinkIdentityOutput, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("Authentication failed", err)
+	}
+
+	user, err := a.userRepo.GetByUUID(ctx, userID)
+	if err != nil {
+		a.logger.Error("Failed to get user", "user_uuid", userID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to unlink account")
+	}
+	identities, err := a.identityRepo.ListByUserID(ctx, userID)
+	if err != nil {
+		a.logger.Error("Failed to list linked identities", "user_uuid", userID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to unlink account")
+	}
+
+	var identityID string
+	for _, identity := range identities {
+		if identity.Provider == input.Provider {
+			identityID = identity.UUID
+		}
+	}
+	if identityID == "" {
+		return nil, huma.Error404NotFound("No account is linked for this provider")
+	}
+	if !user.PasswordSet && len(identities) == 1 {
+		return nil, huma.Error409Conflict("This is your only way to sign in; set a password before unlinking it")
+	}
+
+	if err := a.identityRepo.Unlink(ctx, userID, input.Provider); err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return nil, huma.Error404NotFound("No account is linked for this provider")
+		}
+		a.logger.Error("Failed to unlink identity", "user_uuid", userID, "provider", input.Provider, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to unlink account")
+	}
+
+	a.logger.Info("Identity unlinked", "user_uuid", userID, "provider", input.Provider)
+	a.auditIdentity(ctx, domain.AuditIdentityUnlinked, userID, identityID, input.Provider)
+	resp := &UnlinkIdentityOutput{}
+	resp.Body.Message = "Account unlinked"
+	return resp, nil
+}
+
+func (a *api) auditIdentity(ctx context.Context, action, userID, identityID, provider string) {
+	a.audit(ctx, domain.AuditEntry{
+		Action:       action,
+		UserID:       userID,
+		ActorID:      userID,
+		ResourceType: domain.AuditResourceIdentity,
+		ResourceID:   identityID,
+		Details:      auditDetails(map[string]interface{}{"provider": provider}),
+	})
+}
@@ -0,0 +1,334 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/forfarm/backend/internal/domain"
+	"github.com/forfarm/backend/internal/services/oidc"
+	"github.com/forfarm/backend/internal/services/oidc/oidctest"
+)
+
+// memoryUsers is an in-memory domain.UserRepository.
+type memoryUsers map[string]*domain.User
+
+func (m memoryUsers) GetByID(context.Context, int64) (domain.User, error) {
+	return domain.User{}, domain.ErrNotFound
+}
+
+func (m memoryUsers) GetByUUID(_ context.Context, id string) (domain.User, error) {
+	if u, ok := m[id]; ok {
+		return *u, nil
+	}
+	return domain.User{}, domain.ErrNotFound
+}
+
+func (m memoryUsers) GetByUsername(context.Context, string) (domain.User, error) {
+	return domain.User{}, domain.ErrNotFound
+}
+
+func (m memoryUsers) GetByEmail(_ context.Context, email string) (domain.User, error) {
+	for _, u := range m {
+		if u.Email == email {
+			return *u, nil
+		}
+	}
+	return domain.User{}, domain.ErrNotFound
+}
+
+func (m memoryUsers) CreateOrUpdate(_ context.Context, u *domain.User) error {
+	if u.UUID == "" {
+		u.UUID = uuid.New().String()
+		u.ID = int64(len(m) + 1)
+	}
+	stored := *u
+	m[u.UUID] = &stored
+	return nil
+}
+
+func (m memoryUsers) Delete(_ context.Context, id int64) error {
+	for uuid, u := range m {
+		if u.ID == id {
+			delete(m, uuid)
+		}
+	}
+	return nil
+}
+
+func (m memoryUsers) SetActive(_ context.Context, userID string, active bool) error {
+	u, ok := m[userID]
+	if !ok {
+		return domain.ErrNotFound
+	}
+	u.IsActive = active
+	return nil
+}
+
+func (m memoryUsers) SetDeletionScheduledAt(_ context.Context, userID string, at *time.Time) error {
+	u, ok := m[userID]
+	if !ok {
+		return domain.ErrNotFound
+	}
+	u.DeletionScheduledAt = at
+	return nil
+}
+
+func (m memoryUsers) GetDueForDeletion(_ context.Context, now time.Time) ([]domain.User, error) {
+	var due []domain.User
+	for _, u := range m {
+		if u.DeletionScheduledAt != nil && !u.DeletionScheduledAt.After(now) {
+			due = append(due, *u)
+		}
+	}
+	return due, nil
+}
+
+func (m memoryUsers) DeleteIfDue(_ context.Context, userID string, now time.Time, cleanup func(domain.User, domain.AccountDeletionStores) error) (bool, error) {
+	u, ok := m[userID]
+	if !ok || u.DeletionScheduledAt == nil || u.DeletionScheduledAt.After(now) {
+		return false, nil
+	}
+	if err := cleanup(*u, domain.AccountDeletionStores{}); err != nil {
+		return false, err
+	}
+	delete(m, userID)
+	return true, nil
+}
+
+func (m memoryUsers) List(_ context.Context, filter domain.UserFilter) ([]domain.User, error) {
+	users := []domain.User{}
+	for _, u := range m {
+		if (filter.Query != "" && !strings.Contains(u.Email, filter.Query) && !strings.Contains(u.Username, filter.Query)) ||
+			(filter.Role != "" && u.Role != filter.Role) ||
+			(filter.IsActive != nil && u.IsActive != *filter.IsActive) ||
+			(filter.BeforeID > 0 && u.ID >= filter.BeforeID) {
+			continue
+		}
+		users = append(users, *u)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID > users[j].ID })
+	if len(users) > filter.Limit {
+		users = users[:filter.Limit]
+	}
+	return users, nil
+}
+
+// memoryIdentities is an in-memory domain.IdentityRepository.
+type memoryIdentities []domain.LinkedIdentity
+
+func (m *memoryIdentities) GetByProviderSubject(_ context.Context, provider, subject string) (*domain.LinkedIdentity, error) {
+	for _, i := range *m {
+		if i.Provider == provider && i.Subject == subject {
+			return &i, nil
+		}
+	}
+	return nil, domain.ErrNotFound
+}
+
+func (m *memoryIdentities) ListByUserID(_ context.Context, userID string) ([]domain.LinkedIdentity, error) {
+	identities := []domain.LinkedIdentity{}
+	for _, i := range *m {
+		if i.UserID == userID {
+			identities = append(identities, i)
+		}
+	}
+	return identities, nil
+}
+
+func (m *memoryIdentities) Link(_ context.Context, identity *domain.LinkedIdentity) error {
+	for _, i := range *m {
+		if (i.Provider == identity.Provider && i.Subject == identity.Subject) ||
+			(i.Provider == identity.Provider && i.UserID == identity.UserID) {
+			return domain.ErrConflict
+		}
+	}
+	identity.UUID = uuid.New().String()
+	*m = append(*m, *identity)
+	return nil
+}
+
+func (m *memoryIdentities) Unlink(_ context.Context, userID, provider string) error {
+	for n, i := range *m {
+		if i.UserID == userID && i.Provider == provider {
+			*m = append((*m)[:n], (*m)[n+1:]...)
+			return nil
+		}
+	}
+	return domain.ErrNotFound
+}
+
+func (m *memoryIdentities) Touch(context.Context, string) error {
+	return nil
+}
+
+func assertStatus(t *testing.T, err error, status int) {
+	t.Helper()
+	var statusErr huma.StatusError
+	require.True(t, errors.As(err, &statusErr), "got %v", err)
+	assert.Equal(t, status, statusErr.GetStatus(), err.Error())
+}
+
+func TestOauthExchangeAndLinking(t *testing.T) {
+	mockIssuer := oidctest.NewIssuer()
+	defer mockIssuer.Close()
+	otherIssuer := oidctest.NewIssuer()
+	defer otherIssuer.Close()
+
+	users := memoryUsers{}
+	identities := &memoryIdentities{}
+	sessions := newMockSessions()
+	sessions.On("RevokeAllForUser", mock.Anything, mock.Anything).Return(nil)
+	api := &api{
+		userRepo:      users,
+		sessionRepo:   sessions,
+		twoFactorRepo: newMemoryTwoFactor(),
+		identityRepo:  identities,
+		identityProviders: oidc.NewRegistry(mockIssuer.Client(),
+			oidc.ProviderConfig{Name: "mock", Issuer: mockIssuer.URL(), ClientIDs: []string{"web"}},
+			oidc.ProviderConfig{Name: "other", Issuer: otherIssuer.URL(), ClientIDs: []string{"web"}},
+		),
+		loginGuard: newTestLoginGuard(),
+		auditRepo:  &memoryAuditLog{},
+		logger:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	ctx := context.Background()
+	exchange := func(provider, token string) (*ExchangeTokenOutput, error) {
+		input := &ExchangeTokenInput{}
+		input.Body.Provider = provider
+		input.Body.AccessToken = token
+		return api.exchangeHandler(ctx, input)
+	}
+
+	// The first sign-in creates an account without a usable password.
+	verified := jwt.MapClaims{"email": "farmer@example.com", "email_verified": true}
+	out, err := exchange("mock", mockIssuer.Token("web", "mock-1", verified))
+	require.NoError(t, err)
+	assert.NotEmpty(t, out.Body.JWT)
+	require.Len(t, users, 1)
+	user, _ := users.GetByEmail(ctx, "farmer@example.com")
+	assert.False(t, user.PasswordSet)
+	assert.True(t, user.IsEmailVerified())
+
+	// Later sign-ins match the provider subject, not the email address.
+	_, err = exchange("mock", mockIssuer.Token("web", "mock-1", jwt.MapClaims{"email": "renamed@example.com"}))
+	require.NoError(t, err)
+	assert.Len(t, users, 1)
+
+	// An unverified address is not enough to join an existing account.
+	_, err = exchange("other", otherIssuer.Token("web", "other-1", jwt.MapClaims{"email": "farmer@example.com"}))
+	assertStatus(t, err, http.StatusConflict)
+	_, err = exchange("unknown", "token")
+	assertStatus(t, err, http.StatusBadRequest)
+	_, err = exchange("other", mockIssuer.Token("web", "other-1", nil))
+	assertStatus(t, err, http.StatusUnauthorized)
+
+	// Signed in, the user links the second provider and can then drop
+	// either, but not both.
+	userCtx := domain.ContextWithPrincipal(ctx, domain.Principal{UserID: user.UUID, Role: domain.RoleUser})
+	link := &LinkIdentityInput{}
+	link.Body.Provider = "other"
+	link.Body.IDToken = otherIssuer.Token("web", "other-1", jwt.MapClaims{"email": "farmer@example.com"})
+	_, err = api.linkIdentityHandler(userCtx, link)
+	require.NoError(t, err)
+	_, err = api.linkIdentityHandler(userCtx, link)
+	assertStatus(t, err, http.StatusConflict)
+
+	_, err = api.unlinkIdentityHandler(userCtx, &UnlinkIdentityInput{Provider: "mock"})
+	require.NoError(t, err)
+	_, err = api.unlinkIdentityHandler(userCtx, &UnlinkIdentityInput{Provider: "other"})
+	assertStatus(t, err, http.StatusConflict)
+	_, err = api.unlinkIdentityHandler(userCtx, &UnlinkIdentityInput{Provider: "mock"})
+	assertStatus(t, err, http.StatusNotFound)
+
+	listed, err := api.getLinkedIdentitiesHandler(userCtx, nil)
+	require.NoError(t, err)
+	require.Len(t, listed.Body.Identities, 1)
+	assert.Equal(t, "other", listed.Body.Identities[0].Provider)
+	assert.Equal(t, []string{"mock", "other"}, listed.Body.Providers)
+}
+
+func TestOauthExchange_UnverifiedAccountTakeover(t *testing.T) {
+	iss := oidctest.NewIssuer()
+	defer iss.Close()
+
+	// Someone registered the address first but never verified it.
+	squatterPassword, _ := bcrypt.GenerateFromPassword([]byte("Squatter123!"), bcrypt.MinCost)
+	users := memoryUsers{}
+	squatter := &domain.User{Email: "farmer@example.com", Password: string(squatterPassword), IsActive: true, PasswordSet: true}
+	require.NoError(t, users.CreateOrUpdate(context.Background(), squatter))
+
+	sessions := newMockSessions()
+	sessions.On("RevokeAllForUser", mock.Anything, squatter.UUID).Return(nil)
+	api := &api{
+		userRepo:          users,
+		sessionRepo:       sessions,
+		twoFactorRepo:     newMemoryTwoFactor(),
+		identityRepo:      &memoryIdentities{},
+		identityProviders: oidc.NewRegistry(iss.Client(), oidc.ProviderConfig{Name: "mock", Issuer: iss.URL(), ClientIDs: []string{"web"}}),
+		loginGuard:        newTestLoginGuard(),
+		auditRepo:         &memoryAuditLog{},
+		logger:            slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	input := &ExchangeTokenInput{}
+	input.Body.Provider = "mock"
+	input.Body.AccessToken = iss.Token("web", "owner", jwt.MapClaims{"email": "farmer@example.com", "email_verified": true})
+	_, err := api.exchangeHandler(context.Background(), input)
+	require.NoError(t, err)
+
+	// The owner gets the account; the squatter's password and sessions are gone.
+	user := users[squatter.UUID]
+	assert.False(t, user.PasswordSet)
+	assert.Error(t, bcrypt.CompareHashAndPassword([]byte(user.Password), []byte("Squatter123!")))
+	sessions.AssertCalled(t, "RevokeAllForUser", mock.Anything, squatter.UUID)
+}
+
+func TestOauthExchange_InactiveAccount(t *testing.T) {
+	iss := oidctest.NewIssuer()
+	defer iss.Close()
+
+	// An admin deactivated the account before it was ever verified.
+	password, _ := bcrypt.GenerateFromPassword([]byte("Farmer123!"), bcrypt.MinCost)
+	users := memoryUsers{}
+	deactivated := &domain.User{Email: "farmer@example.com", Password: string(password), PasswordSet: true}
+	require.NoError(t, users.CreateOrUpdate(context.Background(), deactivated))
+
+	identities := &memoryIdentities{}
+	sessions := newMockSessions()
+	api := &api{
+		userRepo:          users,
+		sessionRepo:       sessions,
+		twoFactorRepo:     newMemoryTwoFactor(),
+		identityRepo:      identities,
+		identityProviders: oidc.NewRegistry(iss.Client(), oidc.ProviderConfig{Name: "mock", Issuer: iss.URL(), ClientIDs: []string{"web"}}),
+		loginGuard:        newTestLoginGuard(),
+		auditRepo:         &memoryAuditLog{},
+		logger:            slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	input := &ExchangeTokenInput{}
+	input.Body.Provider = "mock"
+	input.Body.AccessToken = iss.Token("web", "owner", jwt.MapClaims{"email": "farmer@example.com", "email_verified": true})
+	_, err := api.exchangeHandler(context.Background(), input)
+	assertStatus(t, err, http.StatusForbidden)
+
+	// The refusal leaves the account exactly as the admin left it.
+	assert.Equal(t, deactivated, users[deactivated.UUID])
+	assert.Empty(t, *identities)
+	sessions.AssertNotCalled(t, "RevokeAllForUser", mock.Anything, mock.Anything)
+}
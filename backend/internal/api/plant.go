@@ -6,6 +6,7 @@ import (
 
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/forfarm/backend/internal/domain"
+	m "github.com/forfarm/backend/internal/middlewares"
 	"github.com/go-chi/chi/v5"
 )
 
@@ -13,12 +14,12 @@ func (a *api) registerPlantRoutes(_ chi.Router, api huma.API) {
 	tags := []string{"plant"}
 	prefix := "/plant"
 
-	huma.Register(api, huma.Operation{
+	huma.Register(api, m.WithPolicy(m.PolicyPublic, huma.Operation{
 		OperationID: "getAllPlant",
 		Method:      http.MethodGet,
 		Path:        prefix,
 		Tags:        tags,
-	}, a.getAllPlantHandler)
+	}), a.getAllPlantHandler)
 }
 
 type GetAllPlantsOutput struct {
@@ -0,0 +1,305 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/go-chi/chi/v5"
+
+	"github.com/forfarm/backend/internal/config"
+	"github.com/forfarm/backend/internal/domain"
+	m "github.com/forfarm/backend/internal/middlewares"
+	"github.com/forfarm/backend/internal/utilities"
+)
+
+const defaultRefreshTokenTTL = 30 * 24 * time.Hour
+
+func (a *api) registerSessionRoutes(_ chi.Router, api huma.API) {
+	tags := []string{"auth"}
+	prefix := "/auth"
+
+	huma.Register(api, m.WithPolicy(m.PolicyPublic, huma.Operation{
+		OperationID: "refreshToken",
+		Method:      http.MethodPost,
+		Path:        prefix + "/refresh",
+		Tags:        tags,
+		Summary:     "Exchange a refresh token for a new token pair",
+		Description: "Refresh tokens are single use: the response carries a new one. " +
+			"Presenting a refresh token that was already used revokes its session.",
+	}), a.refreshHandler)
+
+	huma.Register(api, m.WithPolicy(m.PolicyAuthenticated, huma.Operation{
+		OperationID: "logout",
+		Method:      http.MethodPost,
+		Path:        prefix + "/logout",
+		Tags:        tags,
+		Summary:     "Revoke the current session",
+	}), a.logoutHandler)
+
+	huma.Register(api, m.WithPolicy(m.PolicyOwner, huma.Operation{
+		OperationID: "getSessions",
+		Method:      http.MethodGet,
+		Path:        prefix + "/sessions",
+		Tags:        tags,
+		Summary:     "List the caller's active sessions",
+	}), a.getSessionsHandler)
+
+	huma.Register(api, m.WithPolicy(m.PolicyOwner, huma.Operation{
+		OperationID: "revokeSession",
+		Method:      http.MethodDelete,
+		Path:        prefix + "/sessions/{sessionId}",
+		Tags:        tags,
+	}), a.revokeSessionHandler)
+
+	huma.Register(api, m.WithPolicy(m.PolicyOwner, huma.Operation{
+		OperationID: "revokeAllSessions",
+		Method:      http.MethodDelete,
+		Path:        prefix + "/sessions",
+		Tags:        tags,
+		Summary:     "Revoke every session of the caller, including the current one",
+	}), a.revokeAllSessionsHandler)
+
+	huma.Register(api, m.WithPolicy(m.PolicyPublic, huma.Operation{
+		OperationID: "getJWKS",
+		Method:      http.MethodGet,
+		Path:        "/.well-known/jwks.json",
+		Tags:        tags,
+		Summary:     "Public keys that verify ForFarm access tokens",
+		Description: "Tokens name their key in the `kid` header. The set is empty when tokens are signed with a shared secret.",
+	}), a.jwksHandler)
+}
+
+// TokenPair is the access and refresh token of a session.
+type TokenPair struct {
+	Token        string `json:"token" doc:"Access token"`
+	RefreshToken string `json:"refreshToken" doc:"Single-use token for POST /auth/refresh"`
+	ExpiresIn    int    `json:"expiresIn" doc:"Seconds until the access token expires"`
+}
+
+type RefreshTokenInput struct {
+	Body struct {
+		RefreshToken string `json:"refreshToken" required:"true"`
+	}
+}
+
+type RefreshTokenOutput struct {
+	Body TokenPair
+}
+
+type LogoutOutput struct {
+	Body struct {
+		Message string `json:"message"`
+	}
+}
+
+// SessionResponse is a session as listed to its user.
+type SessionResponse struct {
+	domain.Session
+	Current bool `json:"current"`
+}
+
+type GetSessionsOutput struct {
+	Body []SessionResponse
+}
+
+type RevokeSessionInput struct {
+	SessionID string `path:"sessionId" required:"true" format:"uuid"`
+}
+
+type JWKSOutput struct {
+	CacheControl string `header:"Cache-Control"`
+	Body         struct {
+		Keys []utilities.JWK `json:"keys"`
+	}
+}
+
+func (a *api) refreshHandler(ctx context.Context, input *RefreshTokenInput) (*RefreshTokenOutput, error) {
+	refreshToken, hash, err := utilities.NewRefreshToken()
+	if err != nil {
+		a.logger.Error("Failed to generate refresh token", "error", err)
+		return nil, huma.Error500InternalServerError("Failed to refresh token")
+	}
+
+	session, err := a.sessionRepo.Rotate(ctx, utilities.HashRefreshToken(input.Body.RefreshToken), hash, time.Now().Add(refreshTokenTTL()))
+	if errors.Is(err, domain.ErrRefreshTokenReused) {
+		a.logger.Warn("Refresh token reused, session revoked")
+		return nil, huma.Error401Unauthorized("Invalid refresh token")
+	}
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, huma.Error401Unauthorized("Invalid refresh token")
+	}
+	if err != nil {
+		a.logger.Error("Failed to rotate refresh token", "error", err)
+		return nil, huma.Error500InternalServerError("Failed to refresh token")
+	}
+
+	user, err := a.userRepo.GetByUUID(ctx, session.UserID)
+	if err != nil {
+		a.logger.Error("Failed to load user of session", "session_id", session.UUID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to refresh token")
+	}
+	if !user.IsActive {
+		_ = a.sessionRepo.Revoke(ctx, session.UUID)
+		return nil, huma.Error403Forbidden("Account is inactive")
+	}
+
+	token, err := utilities.IssueAccessToken(sessionClaims(session, user.Role))
+	if err != nil {
+		a.logger.Error("Failed to create access token", "session_id", session.UUID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to refresh token")
+	}
+
+	return &RefreshTokenOutput{Body: TokenPair{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(utilities.AccessTokenTTL().Seconds()),
+	}}, nil
+}
+
+func (a *api) logoutHandler(ctx context.Context, _ *struct{}) (*LogoutOutput, error) {
+	principal, ok := domain.PrincipalFromContext(ctx)
+	if !ok {
+		return nil, huma.Error401Unauthorized("Authentication failed")
+	}
+
+	if err := a.sessionRepo.Revoke(ctx, principal.SessionID); err != nil && !errors.Is(err, domain.ErrNotFound) {
+		a.logger.Error("Failed to revoke session", "session_id", principal.SessionID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to log out")
+	}
+	a.audit(ctx, domain.AuditEntry{
+		Action:       domain.AuditLoggedOut,
+		UserID:       principal.UserID,
+		ResourceType: domain.AuditResourceSession,
+		ResourceID:   principal.SessionID,
+	})
+
+	resp := &LogoutOutput{}
+	resp.Body.Message = "Logged out successfully"
+	return resp, nil
+}
+
+func (a *api) getSessionsHandler(ctx context.Context, _ *struct{}) (*GetSessionsOutput, error) {
+	principal, ok := domain.PrincipalFromContext(ctx)
+	if !ok {
+		return nil, huma.Error401Unauthorized("Authentication failed")
+	}
+
+	sessions, err := a.sessionRepo.GetActiveByUserID(ctx, principal.UserID)
+	if err != nil {
+		a.logger.Error("Failed to list sessions", "user_id", principal.UserID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to retrieve sessions")
+	}
+
+	resp := &GetSessionsOutput{Body: make([]SessionResponse, len(sessions))}
+	for i, s := range sessions {
+		resp.Body[i] = SessionResponse{Session: s, Current: s.UUID == principal.SessionID}
+	}
+	return resp, nil
+}
+
+func (a *api) revokeSessionHandler(ctx context.Context, input *RevokeSessionInput) (*LogoutOutput, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("Authentication failed", err)
+	}
+
+	session, err := a.sessionRepo.GetByID(ctx, input.SessionID)
+	if errors.Is(err, domain.ErrNotFound) || (err == nil && session.UserID != userID) {
+		return nil, huma.Error404NotFound("Session not found")
+	}
+	if err != nil {
+		a.logger.Error("Failed to get session", "session_id", input.SessionID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to revoke session")
+	}
+
+	if err := a.sessionRepo.Revoke(ctx, session.UUID); err != nil {
+		a.logger.Error("Failed to revoke session", "session_id", session.UUID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to revoke session")
+	}
+	a.audit(ctx, domain.AuditEntry{
+		Action:       domain.AuditSessionRevoked,
+		UserID:       userID,
+		ResourceType: domain.AuditResourceSession,
+		ResourceID:   session.UUID,
+	})
+
+	resp := &LogoutOutput{}
+	resp.Body.Message = "Session revoked"
+	return resp, nil
+}
+
+func (a *api) revokeAllSessionsHandler(ctx context.Context, _ *struct{}) (*LogoutOutput, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("Authentication failed", err)
+	}
+
+	if err := a.sessionRepo.RevokeAllForUser(ctx, userID); err != nil {
+		a.logger.Error("Failed to revoke sessions", "user_id", userID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to revoke sessions")
+	}
+	a.audit(ctx, domain.AuditEntry{
+		Action:       domain.AuditSessionRevoked,
+		UserID:       userID,
+		ResourceType: domain.AuditResourceSession,
+		Details:      auditDetails(map[string]interface{}{"all": true}),
+	})
+
+	resp := &LogoutOutput{}
+	resp.Body.Message = "All sessions revoked"
+	return resp, nil
+}
+
+func (a *api) jwksHandler(_ context.Context, _ *struct{}) (*JWKSOutput, error) {
+	resp := &JWKSOutput{CacheControl: "public, max-age=300"}
+	resp.Body.Keys = utilities.PublishedKeys()
+	return resp, nil
+}
+
+// startSession opens a session for a user who just proved who they are and
+// returns its tokens. twoFactor records that they also gave a second factor.
+func (a *api) startSession(ctx context.Context, user *domain.User, userAgent string, twoFactor bool) (TokenPair, error) {
+	refreshToken, hash, err := utilities.NewRefreshToken()
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	session := &domain.Session{
+		UserID:           user.UUID,
+		RefreshTokenHash: hash,
+		UserAgent:        userAgent,
+		TwoFactor:        twoFactor,
+		ExpiresAt:        time.Now().Add(refreshTokenTTL()),
+	}
+	if err := a.sessionRepo.Create(ctx, session); err != nil {
+		return TokenPair{}, err
+	}
+
+	token, err := utilities.IssueAccessToken(sessionClaims(session, user.Role))
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	return TokenPair{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(utilities.AccessTokenTTL().Seconds()),
+	}, nil
+}
+
+// sessionClaims are the claims of access tokens issued for a session. The
+// role is read from the user each time, so a change takes effect on the next
+// refresh.
+func sessionClaims(s *domain.Session, role string) utilities.TokenClaims {
+	return utilities.TokenClaims{UserID: s.UserID, Role: role, SessionID: s.UUID, TwoFactor: s.TwoFactor}
+}
+
+func refreshTokenTTL() time.Duration {
+	if config.REFRESH_TOKEN_TTL > 0 {
+		return config.REFRESH_TOKEN_TTL
+	}
+	return defaultRefreshTokenTTL
+}
@@ -0,0 +1,275 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	m "github.com/forfarm/backend/internal/middlewares"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/forfarm/backend/internal/domain"
+)
+
+const (
+	eventStreamBuffer    = 64
+	eventStreamHeartbeat = 15 * time.Second
+	eventStreamRetry     = 5 * time.Second
+	// eventStreamMaxReplay caps the events replayed on reconnect; a client
+	// that was away longer should reload its data instead.
+	eventStreamMaxReplay = 1000
+)
+
+// StreamEventTypes are the event types pushed to event stream clients.
+var StreamEventTypes = []string{"farm.*", "cropland.*", "weather.updated", "inventory.item.*"}
+
+var errReplayLimit = errors.New("replay limit reached")
+
+// eventListener hands out in-process channels of bus events.
+type eventListener interface {
+	Listen(buffer int) (<-chan domain.Event, func())
+}
+
+// SetEventStream enables /events/stream, fed by listener and resumed from
+// store.
+func (a *api) SetEventStream(listener eventListener, store domain.EventStore) {
+	a.eventListener = listener
+	a.eventStore = store
+}
+
+func (a *api) registerEventRoutes(_ chi.Router, api huma.API) {
+	huma.Register(api, m.WithPolicy(m.PolicyOwner, huma.Operation{
+		OperationID: "streamEvents",
+		Method:      http.MethodGet,
+		Path:        "/events/stream",
+		Tags:        []string{"events"},
+		Summary:     "Stream events of the caller's farms",
+		Description: "Server-Sent Events stream of farm, cropland, weather and inventory events for farms the caller owns or is a member of. " +
+			"Send the Last-Event-ID header to receive the events missed since that event before live ones. " +
+			"A comment line is sent every 15 seconds to keep the connection open.",
+		Responses: map[string]*huma.Response{
+			"200": {
+				Description: "Event stream",
+				Content:     map[string]*huma.MediaType{"text/event-stream": {}},
+			},
+		},
+	}), a.streamEventsHandler)
+}
+
+type StreamEventsInput struct {
+	LastEventID string `header:"Last-Event-ID" doc:"ID of the last event the client received"`
+}
+
+// StreamEvent is the data of an event on the stream.
+type StreamEvent struct {
+	ID            string      `json:"id"`
+	Type          string      `json:"type"`
+	Source        string      `json:"source"`
+	Timestamp     time.Time   `json:"timestamp"`
+	AggregateID   string      `json:"aggregateId"`
+	SchemaVersion int         `json:"schemaVersion"`
+	Payload       interface{} `json:"payload"`
+}
+
+func (a *api) streamEventsHandler(ctx context.Context, input *StreamEventsInput) (*huma.StreamResponse, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("Authentication failed: " + err.Error())
+	}
+	if a.eventListener == nil {
+		return nil, huma.Error503ServiceUnavailable("Event stream is not available")
+	}
+	if input.LastEventID != "" {
+		if _, err := uuid.Parse(input.LastEventID); err != nil {
+			return nil, huma.Error400BadRequest("Invalid Last-Event-ID format.")
+		}
+	}
+
+	farms, err := a.farmRepo.GetByMemberID(ctx, userID)
+	if err != nil {
+		a.logger.Error("Failed to load farms for event stream", "user_id", userID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to open event stream")
+	}
+	farms = accessibleFarms(ctx, farms)
+	filter := &eventStreamFilter{userID: userID, farms: make(map[string]struct{}, len(farms))}
+	filter.principal, _ = domain.PrincipalFromContext(ctx)
+	farmIDs := make([]string, 0, len(farms))
+	for _, f := range farms {
+		filter.farms[f.UUID] = struct{}{}
+		farmIDs = append(farmIDs, f.UUID)
+	}
+
+	// Listen before replaying, so no event falls between the two.
+	events, stop := a.eventListener.Listen(eventStreamBuffer)
+
+	return &huma.StreamResponse{
+		Body: func(hctx huma.Context) {
+			defer stop()
+			a.streamEvents(hctx, userID, input.LastEventID, farmIDs, filter, events)
+		},
+	}, nil
+}
+
+func (a *api) streamEvents(hctx huma.Context, userID, lastEventID string, farmIDs []string, filter *eventStreamFilter, events <-chan domain.Event) {
+	ctx := hctx.Context()
+	hctx.SetHeader("Content-Type", "text/event-stream")
+	hctx.SetHeader("Cache-Control", "no-cache")
+	hctx.SetHeader("Connection", "keep-alive")
+	hctx.SetHeader("X-Accel-Buffering", "no")
+
+	w := hctx.BodyWriter()
+	flush := func() {
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+
+	if _, err := fmt.Fprintf(w, "retry: %d\n\n", eventStreamRetry.Milliseconds()); err != nil {
+		return
+	}
+	flush()
+
+	replayed := make(map[string]struct{})
+	var live []domain.Event
+	if lastEventID != "" && a.eventStore != nil {
+		// Drain live events into live while replaying, so a long replay
+		// does not fill the listener's buffer and get the stream dropped.
+		stopDraining := make(chan struct{})
+		drained := make(chan struct{})
+		go func() {
+			defer close(drained)
+			for {
+				select {
+				case <-stopDraining:
+					return
+				case e, ok := <-events:
+					if !ok {
+						return
+					}
+					live = append(live, e)
+				}
+			}
+		}()
+
+		err := a.eventStore.ForEachAfter(ctx, lastEventID, farmIDs, func(e domain.Event) error {
+			if !filter.allows(e) {
+				return nil
+			}
+			if len(replayed) == eventStreamMaxReplay {
+				return errReplayLimit
+			}
+			replayed[e.ID] = struct{}{}
+			return writeStreamEvent(w, e)
+		})
+		close(stopDraining)
+		<-drained
+		switch {
+		case err == nil:
+		case errors.Is(err, domain.ErrNotFound):
+			a.logger.Info("Last-Event-ID not in event store, streaming live events only", "user_id", userID, "last_event_id", lastEventID)
+		case errors.Is(err, errReplayLimit):
+			a.logger.Warn("Event stream replay truncated", "user_id", userID, "last_event_id", lastEventID, "limit", eventStreamMaxReplay)
+		default:
+			a.logger.Error("Failed to replay events", "user_id", userID, "last_event_id", lastEventID, "error", err)
+			return
+		}
+		flush()
+	}
+
+	send := func(e domain.Event) error {
+		if _, dup := replayed[e.ID]; dup || !filter.allows(e) {
+			return nil
+		}
+		if err := writeStreamEvent(w, e); err != nil {
+			return err
+		}
+		flush()
+		return nil
+	}
+	for _, e := range live {
+		if err := send(e); err != nil {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(eventStreamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-a.shutdown:
+			return
+		case <-heartbeat.C:
+			if _, err := io.WriteString(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flush()
+		case e, ok := <-events:
+			if !ok {
+				// Dropped for falling behind; the client reconnects and resumes.
+				return
+			}
+			if err := send(e); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func writeStreamEvent(w io.Writer, e domain.Event) error {
+	data, err := json.Marshal(StreamEvent{
+		ID:            e.ID,
+		Type:          e.Type,
+		Source:        e.Source,
+		Timestamp:     e.Timestamp,
+		AggregateID:   e.AggregateID,
+		SchemaVersion: e.SchemaVersion,
+		Payload:       e.Payload,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+	return err
+}
+
+// eventStreamFilter passes the events of the farms a user owns or is a
+// member of and of their inventory, following farms the user creates or
+// deletes while streaming.
+type eventStreamFilter struct {
+	userID    string
+	principal domain.Principal
+	farms     map[string]struct{}
+}
+
+func (f *eventStreamFilter) allows(e domain.Event) bool {
+	switch p := e.Payload.(type) {
+	case *domain.FarmPayload:
+		if _, ok := f.farms[p.UUID]; ok {
+			return true
+		}
+		if p.OwnerID != f.userID || !f.principal.CanAccessFarm(p.UUID) {
+			return false
+		}
+		f.farms[p.UUID] = struct{}{}
+		return true
+	case *domain.FarmDeletedPayload:
+		_, ok := f.farms[p.UUID]
+		delete(f.farms, p.UUID)
+		return ok
+	case *domain.InventoryItemPayload:
+		return p.UserID == f.userID
+	case *domain.InventoryItemDeletedPayload:
+		return p.UserID == f.userID
+	}
+	_, ok := f.farms[domain.EventFarmID(e)]
+	return ok
+}
@@ -0,0 +1,423 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/go-chi/chi/v5"
+
+	"github.com/forfarm/backend/internal/domain"
+	m "github.com/forfarm/backend/internal/middlewares"
+	"github.com/forfarm/backend/internal/services"
+	"github.com/forfarm/backend/internal/utilities"
+)
+
+const (
+	tokenPurposeLoginChallenge = "login-2fa"
+	loginChallengeTTL          = 5 * time.Minute
+
+	totpIssuer = "ForFarm"
+)
+
+func (a *api) registerTwoFactorRoutes(_ chi.Router, api huma.API) {
+	tags := []string{"auth"}
+	prefix := "/auth"
+
+	huma.Register(api, m.WithPolicy(m.PolicyPublic, huma.Operation{
+		OperationID: "loginTwoFactor",
+		Method:      http.MethodPost,
+		Path:        prefix + "/login/2fa",
+		Tags:        tags,
+		Summary:     "Complete a login with an authenticator or recovery code",
+	}), a.loginTwoFactorHandler)
+
+	huma.Register(api, m.WithPolicy(m.PolicyOwner, huma.Operation{
+		OperationID: "getTwoFactorStatus",
+		Method:      http.MethodGet,
+		Path:        prefix + "/2fa",
+		Tags:        tags,
+	}), a.getTwoFactorStatusHandler)
+
+	huma.Register(api, m.WithPolicy(m.PolicyOwner, huma.Operation{
+		OperationID: "setupTwoFactor",
+		Method:      http.MethodPost,
+		Path:        prefix + "/2fa/setup",
+		Tags:        tags,
+		Summary:     "Start enrolling an authenticator app",
+		Description: "Returns a new secret and its otpauth:// URI to show as a QR code. It takes effect once confirmed with POST /auth/2fa/enable.",
+	}), a.setupTwoFactorHandler)
+
+	huma.Register(api, m.WithPolicy(m.PolicyOwner, huma.Operation{
+		OperationID: "enableTwoFactor",
+		Method:      http.MethodPost,
+		Path:        prefix + "/2fa/enable",
+		Tags:        tags,
+		Summary:     "Confirm enrolment with a code and receive recovery codes",
+	}), a.enableTwoFactorHandler)
+
+	huma.Register(api, m.WithPolicy(m.PolicyOwner, huma.Operation{
+		OperationID: "disableTwoFactor",
+		Method:      http.MethodPost,
+		Path:        prefix + "/2fa/disable",
+		Tags:        tags,
+	}), a.disableTwoFactorHandler)
+
+	huma.Register(api, m.WithPolicy(m.PolicyOwner, huma.Operation{
+		OperationID: "regenerateRecoveryCodes",
+		Method:      http.MethodPost,
+		Path:        prefix + "/2fa/recovery-codes",
+		Tags:        tags,
+		Summary:     "Replace the recovery codes",
+	}), a.regenerateRecoveryCodesHandler)
+}
+
+//
+// Input and Output types
+//
+
+// LoginResult is the outcome of checking a user's first factor: tokens, or a
+// challenge to complete at POST /auth/login/2fa.
+type LoginResult struct {
+	TokenPair
+	TwoFactorRequired bool   `json:"twoFactorRequired,omitempty"`
+	ChallengeToken    string `json:"challengeToken,omitempty" doc:"Sent with a code to POST /auth/login/2fa"`
+}
+
+type LoginTwoFactorInput struct {
+	UserAgent string `header:"User-Agent"`
+	ClientIP  string
+	Body      struct {
+		ChallengeToken string `json:"challengeToken" required:"true"`
+		Code           string `json:"code" required:"true" doc:"Authenticator code or recovery code"`
+	}
+}
+
+func (i *LoginTwoFactorInput) Resolve(ctx huma.Context) []error {
+	i.ClientIP = m.ClientIP(ctx)
+	return nil
+}
+
+type LoginTwoFactorOutput struct {
+	Body TokenPair
+}
+
+type TwoFactorStatusOutput struct {
+	Body struct {
+		Enabled                bool       `json:"enabled"`
+		EnabledAt              *time.Time `json:"enabledAt,omitempty"`
+		RecoveryCodesRemaining int        `json:"recoveryCodesRemaining"`
+	}
+}
+
+type SetupTwoFactorOutput struct {
+	Body struct {
+		Secret          string `json:"secret" doc:"For entering into the app by hand"`
+		ProvisioningURI string `json:"provisioningUri" doc:"otpauth:// URI to render as a QR code"`
+	}
+}
+
+type TwoFactorCodeInput struct {
+	Body struct {
+		Code string `json:"code" required:"true"`
+	}
+}
+
+type RecoveryCodesOutput struct {
+	Body struct {
+		RecoveryCodes []string `json:"recoveryCodes" doc:"Each works once in place of an authenticator code; shown only now"`
+	}
+}
+
+type TwoFactorMessageOutput struct {
+	Body struct {
+		Message string `json:"message"`
+	}
+}
+
+//
+// Login
+//
+
+func loginChallengeFingerprint(u *domain.User) string {
+	return tokenFingerprint(u.Password)
+}
+
+// completeLogin signs in a user who passed their first factor, or returns a
+// challenge if they have two-factor authentication enabled.
+func (a *api) completeLogin(ctx context.Context, user *domain.User, userAgent string) (LoginResult, error) {
+	tf, err := a.twoFactorRepo.Get(ctx, user.UUID)
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		return LoginResult{}, err
+	}
+	if tf.IsEnabled() {
+		token := utilities.NewActionToken(actionTokenSecret(), tokenPurposeLoginChallenge, user.UUID, loginChallengeFingerprint(user), loginChallengeTTL)
+		return LoginResult{TwoFactorRequired: true, ChallengeToken: token}, nil
+	}
+
+	tokens, err := a.startSession(ctx, user, userAgent, false)
+	if err != nil {
+		return LoginResult{}, err
+	}
+	return LoginResult{TokenPair: tokens}, nil
+}
+
+func (a *api) loginTwoFactorHandler(ctx context.Context, input *LoginTwoFactorInput) (*LoginTwoFactorOutput, error) {
+	user, err := a.userFromActionToken(ctx, input.Body.ChallengeToken, tokenPurposeLoginChallenge, loginChallengeFingerprint)
+	if err != nil {
+		var statusErr huma.StatusError
+		if errors.As(err, &statusErr) && statusErr.GetStatus() < http.StatusInternalServerError {
+			return nil, huma.Error401Unauthorized("Login challenge is invalid or has expired; sign in again")
+		}
+		return nil, err
+	}
+	if !user.IsActive {
+		return nil, huma.Error403Forbidden("Account is inactive")
+	}
+
+	// Codes are guessed against the same counters as passwords.
+	attempt := services.LoginAttempt{Email: user.Email, IP: input.ClientIP, UserID: user.UUID}
+	if err := a.loginGuard.Check(ctx, attempt); err != nil {
+		a.logger.Warn("Throttled two-factor attempt", "user_uuid", user.UUID, "ip", input.ClientIP, "error", err)
+		return nil, loginThrottled(err)
+	}
+
+	tf, err := a.twoFactorRepo.Get(ctx, user.UUID)
+	if errors.Is(err, domain.ErrNotFound) || (err == nil && !tf.IsEnabled()) {
+		return nil, huma.Error401Unauthorized("Login challenge is invalid or has expired; sign in again")
+	}
+	if err != nil {
+		a.logger.Error("Failed to get two-factor enrolment", "user_uuid", user.UUID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to verify code")
+	}
+
+	ok, err := a.verifySecondFactor(ctx, tf, input.Body.Code)
+	if err != nil {
+		a.logger.Error("Failed to verify two-factor code", "user_uuid", user.UUID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to verify code")
+	}
+	if !ok {
+		a.logger.Warn("Incorrect two-factor code", "user_uuid", user.UUID)
+		a.loginGuard.Fail(ctx, attempt)
+		a.auditLoginFailed(ctx, attempt, "wrong_code")
+		return nil, huma.Error401Unauthorized("Invalid code")
+	}
+	a.loginGuard.Succeed(ctx, attempt)
+	a.auditAccount(ctx, domain.AuditLoginSucceeded, user.UUID, map[string]interface{}{"method": "password+2fa"})
+
+	tokens, err := a.startSession(ctx, user, input.UserAgent, true)
+	if err != nil {
+		a.logger.Error("Failed to start session after two-factor login", "user_uuid", user.UUID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to generate login token")
+	}
+	return &LoginTwoFactorOutput{Body: tokens}, nil
+}
+
+// verifySecondFactor accepts an unused authenticator code or recovery code
+// for an enabled enrolment.
+func (a *api) verifySecondFactor(ctx context.Context, tf *domain.TwoFactor, code string) (bool, error) {
+	if step, ok := utilities.ValidateTOTP(tf.Secret, code, time.Now()); ok {
+		return a.twoFactorRepo.UseStep(ctx, tf.UserID, step)
+	}
+	used, err := a.twoFactorRepo.UseRecoveryCode(ctx, tf.UserID, utilities.HashRecoveryCode(code))
+	if used {
+		a.logger.Info("Recovery code used", "user_uuid", tf.UserID)
+	}
+	return used, err
+}
+
+//
+// Enrolment
+//
+
+func (a *api) getTwoFactorStatusHandler(ctx context.Context, _ *struct{}) (*TwoFactorStatusOutput, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("Authentication failed", err)
+	}
+
+	resp := &TwoFactorStatusOutput{}
+	tf, err := a.twoFactorRepo.Get(ctx, userID)
+	if errors.Is(err, domain.ErrNotFound) || (err == nil && !tf.IsEnabled()) {
+		return resp, nil
+	}
+	if err != nil {
+		a.logger.Error("Failed to get two-factor enrolment", "user_uuid", userID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to retrieve two-factor status")
+	}
+
+	remaining, err := a.twoFactorRepo.CountRecoveryCodes(ctx, userID)
+	if err != nil {
+		a.logger.Error("Failed to count recovery codes", "user_uuid", userID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to retrieve two-factor status")
+	}
+
+	resp.Body.Enabled = true
+	resp.Body.EnabledAt = tf.EnabledAt
+	resp.Body.RecoveryCodesRemaining = remaining
+	return resp, nil
+}
+
+func (a *api) setupTwoFactorHandler(ctx context.Context, _ *struct{}) (*SetupTwoFactorOutput, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("Authentication failed", err)
+	}
+	email, err := a.callerEmail(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := utilities.NewTOTPSecret()
+	if err != nil {
+		a.logger.Error("Failed to generate TOTP secret", "error", err)
+		return nil, huma.Error500InternalServerError("Failed to start two-factor setup")
+	}
+	err = a.twoFactorRepo.SavePending(ctx, userID, secret)
+	if errors.Is(err, domain.ErrConflict) {
+		return nil, huma.Error409Conflict("Two-factor authentication is already enabled")
+	}
+	if err != nil {
+		a.logger.Error("Failed to save TOTP secret", "user_uuid", userID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to start two-factor setup")
+	}
+
+	resp := &SetupTwoFactorOutput{}
+	resp.Body.Secret = secret
+	resp.Body.ProvisioningURI = utilities.TOTPProvisioningURI(secret, totpIssuer, email)
+	return resp, nil
+}
+
+func (a *api) enableTwoFactorHandler(ctx context.Context, input *TwoFactorCodeInput) (*RecoveryCodesOutput, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("Authentication failed", err)
+	}
+
+	tf, err := a.twoFactorRepo.Get(ctx, userID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, huma.Error409Conflict("Start two-factor setup first")
+	}
+	if err != nil {
+		a.logger.Error("Failed to get two-factor enrolment", "user_uuid", userID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to enable two-factor authentication")
+	}
+	if tf.IsEnabled() {
+		return nil, huma.Error409Conflict("Two-factor authentication is already enabled")
+	}
+
+	step, ok := utilities.ValidateTOTP(tf.Secret, input.Body.Code, time.Now())
+	if !ok {
+		return nil, huma.Error422UnprocessableEntity("Invalid code")
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		a.logger.Error("Failed to generate recovery codes", "error", err)
+		return nil, huma.Error500InternalServerError("Failed to enable two-factor authentication")
+	}
+	if _, err := a.twoFactorRepo.UseStep(ctx, userID, step); err != nil {
+		a.logger.Error("Failed to record TOTP step", "user_uuid", userID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to enable two-factor authentication")
+	}
+	if err := a.twoFactorRepo.Enable(ctx, userID, hashes); err != nil {
+		a.logger.Error("Failed to enable two-factor authentication", "user_uuid", userID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to enable two-factor authentication")
+	}
+
+	a.logger.Info("Two-factor authentication enabled", "user_uuid", userID)
+	a.auditAccount(ctx, domain.AuditTwoFactorEnabled, userID, nil)
+	resp := &RecoveryCodesOutput{}
+	resp.Body.RecoveryCodes = codes
+	return resp, nil
+}
+
+func (a *api) disableTwoFactorHandler(ctx context.Context, input *TwoFactorCodeInput) (*TwoFactorMessageOutput, error) {
+	principal, ok := domain.PrincipalFromContext(ctx)
+	if !ok {
+		return nil, huma.Error401Unauthorized("Authentication failed")
+	}
+	if principal.IsAdmin() {
+		return nil, huma.Error403Forbidden("Administrators must keep two-factor authentication enabled")
+	}
+
+	tf, err := a.enabledTwoFactor(ctx, principal.UserID, input.Body.Code)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.twoFactorRepo.Disable(ctx, tf.UserID); err != nil {
+		a.logger.Error("Failed to disable two-factor authentication", "user_uuid", tf.UserID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to disable two-factor authentication")
+	}
+
+	a.logger.Info("Two-factor authentication disabled", "user_uuid", tf.UserID)
+	a.auditAccount(ctx, domain.AuditTwoFactorDisabled, tf.UserID, nil)
+	resp := &TwoFactorMessageOutput{}
+	resp.Body.Message = "Two-factor authentication disabled"
+	return resp, nil
+}
+
+func (a *api) regenerateRecoveryCodesHandler(ctx context.Context, input *TwoFactorCodeInput) (*RecoveryCodesOutput, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("Authentication failed", err)
+	}
+
+	tf, err := a.enabledTwoFactor(ctx, userID, input.Body.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		a.logger.Error("Failed to generate recovery codes", "error", err)
+		return nil, huma.Error500InternalServerError("Failed to replace recovery codes")
+	}
+	if err := a.twoFactorRepo.ReplaceRecoveryCodes(ctx, tf.UserID, hashes); err != nil {
+		a.logger.Error("Failed to replace recovery codes", "user_uuid", tf.UserID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to replace recovery codes")
+	}
+	a.auditAccount(ctx, domain.AuditRecoveryCodesRegenerated, tf.UserID, nil)
+
+	resp := &RecoveryCodesOutput{}
+	resp.Body.RecoveryCodes = codes
+	return resp, nil
+}
+
+// enabledTwoFactor returns the user's enabled enrolment after checking code
+// against it, so that changes need the second factor as well as a token.
+func (a *api) enabledTwoFactor(ctx context.Context, userID, code string) (*domain.TwoFactor, error) {
+	tf, err := a.twoFactorRepo.Get(ctx, userID)
+	if errors.Is(err, domain.ErrNotFound) || (err == nil && !tf.IsEnabled()) {
+		return nil, huma.Error409Conflict("Two-factor authentication is not enabled")
+	}
+	if err != nil {
+		a.logger.Error("Failed to get two-factor enrolment", "user_uuid", userID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to verify code")
+	}
+
+	ok, err := a.verifySecondFactor(ctx, tf, code)
+	if err != nil {
+		a.logger.Error("Failed to verify two-factor code", "user_uuid", userID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to verify code")
+	}
+	if !ok {
+		return nil, huma.Error422UnprocessableEntity("Invalid code")
+	}
+	return tf, nil
+}
+
+// newRecoveryCodes returns a fresh set of recovery codes and their hashes.
+func newRecoveryCodes() ([]string, []string, error) {
+	codes, err := utilities.NewRecoveryCodes(domain.RecoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = utilities.HashRecoveryCode(code)
+	}
+	return codes, hashes, nil
+}
@@ -9,7 +9,7 @@ import (
 
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/forfarm/backend/internal/domain"
-	"github.com/forfarm/backend/internal/utilities"
+	m "github.com/forfarm/backend/internal/middlewares"
 	"github.com/go-chi/chi/v5"
 	validation "github.com/go-ozzo/ozzo-validation/v4"
 	"github.com/jackc/pgx/v5"
@@ -19,16 +19,15 @@ func (a *api) registerUserRoutes(_ chi.Router, api huma.API) {
 	tags := []string{"user"}
 	prefix := "/user"
 
-	huma.Register(api, huma.Operation{
+	huma.Register(api, m.WithPolicy(m.PolicyAuthenticated, huma.Operation{
 		OperationID: "getSelfData",
 		Method:      http.MethodGet,
 		Path:        prefix + "/me",
 		Tags:        tags,
-	}, a.getSelfData)
+	}), a.getSelfData)
 }
 
 type getSelfDataInput struct {
-	Authorization string `header:"Authorization" required:"true" example:"Bearer token"`
 }
 
 type getSelfDataOutput struct {
@@ -38,8 +37,7 @@ type getSelfDataOutput struct {
 }
 
 type UpdateSelfDataInput struct {
-	Authorization string `header:"Authorization" required:"true" example:"Bearer token"`
-	Body          struct {
+	Body struct {
 		Username *string `json:"username,omitempty"`
 	}
 }
@@ -53,20 +51,9 @@ type UpdateSelfDataOutput struct {
 func (a *api) getSelfData(ctx context.Context, input *getSelfDataInput) (*getSelfDataOutput, error) {
 	resp := &getSelfDataOutput{}
 
-	authHeader := input.Authorization
-	if authHeader == "" {
-		return nil, huma.Error401Unauthorized("No authorization header provided")
-	}
-
-	authToken := strings.TrimPrefix(authHeader, "Bearer ")
-	if authToken == "" {
-		return nil, huma.Error401Unauthorized("No token provided in Authorization header")
-	}
-
-	uuid, err := utilities.ExtractUUIDFromToken(authToken)
+	uuid, err := userIDFromContext(ctx)
 	if err != nil {
-		a.logger.Warn("Failed to extract UUID from token", "error", err)
-		return nil, huma.Error401Unauthorized("Invalid or expired token", err)
+		return nil, huma.Error401Unauthorized("Authentication failed", err)
 	}
 
 	user, err := a.userRepo.GetByUUID(ctx, uuid)
@@ -87,7 +74,7 @@ func (a *api) getSelfData(ctx context.Context, input *getSelfDataInput) (*getSel
 }
 
 func (a *api) updateSelfData(ctx context.Context, input *UpdateSelfDataInput) (*UpdateSelfDataOutput, error) {
-	userID, err := a.getUserIDFromHeader(input.Authorization)
+	userID, err := userIDFromContext(ctx)
 	if err != nil {
 		return nil, huma.Error401Unauthorized("Authentication failed", err)
 	}
@@ -0,0 +1,310 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+	m "github.com/forfarm/backend/internal/middlewares"
+	"github.com/go-chi/chi/v5"
+
+	"github.com/forfarm/backend/internal/domain"
+)
+
+const (
+	webhookSecretPrefix    = "whsec_"
+	defaultDeliveriesLimit = 50
+	maxDeliveriesLimit     = 500
+)
+
+func (a *api) registerWebhookRoutes(_ chi.Router, api huma.API) {
+	tags := []string{"webhook"}
+	prefix := "/webhooks"
+
+	huma.Register(api, m.WithPolicy(m.PolicyOwner, huma.Operation{
+		OperationID: "getWebhooks",
+		Method:      http.MethodGet,
+		Path:        prefix,
+		Tags:        tags,
+	}), a.getWebhooksHandler)
+
+	huma.Register(api, m.WithPolicy(m.PolicyOwner, huma.Operation{
+		OperationID: "createWebhook",
+		Method:      http.MethodPost,
+		Path:        prefix,
+		Tags:        tags,
+		Summary:     "Register a webhook endpoint",
+		Description: "Events matching the filters are POSTed to the URL with an " +
+			"X-ForFarm-Signature header of sha256=<hex HMAC-SHA256 of \"<X-ForFarm-Timestamp>.<body>\">, " +
+			"keyed with the secret returned here. The secret is only returned on creation and rotation.",
+	}), a.createWebhookHandler)
+
+	huma.Register(api, m.WithPolicy(m.PolicyOwner, huma.Operation{
+		OperationID: "updateWebhook",
+		Method:      http.MethodPut,
+		Path:        prefix + "/{webhookId}",
+		Tags:        tags,
+		Description: "Setting active re-enables a webhook that was disabled after repeated failures.",
+	}), a.updateWebhookHandler)
+
+	huma.Register(api, m.WithPolicy(m.PolicyOwner, huma.Operation{
+		OperationID: "deleteWebhook",
+		Method:      http.MethodDelete,
+		Path:        prefix + "/{webhookId}",
+		Tags:        tags,
+	}), a.deleteWebhookHandler)
+
+	huma.Register(api, m.WithPolicy(m.PolicyOwner, huma.Operation{
+		OperationID: "getWebhookDeliveries",
+		Method:      http.MethodGet,
+		Path:        prefix + "/{webhookId}/deliveries",
+		Tags:        tags,
+	}), a.getWebhookDeliveriesHandler)
+}
+
+//
+// Input and Output types
+//
+
+type GetWebhooksInput struct {
+}
+
+type GetWebhooksOutput struct {
+	Body []domain.Webhook
+}
+
+type CreateWebhookInput struct {
+	Body struct {
+		URL        string   `json:"url" required:"true" example:"https://example.com/forfarm"`
+		EventTypes []string `json:"eventTypes,omitempty" doc:"Event types or patterns such as farm.*; empty for all"`
+		FarmIDs    []string `json:"farmIds,omitempty" doc:"Farms to receive events of; empty for all"`
+	}
+}
+
+// WebhookWithSecret is a webhook together with its signing secret.
+type WebhookWithSecret struct {
+	domain.Webhook
+	Secret string `json:"secret"`
+}
+
+type CreateWebhookOutput struct {
+	Body WebhookWithSecret
+}
+
+type UpdateWebhookInput struct {
+	WebhookID string `path:"webhookId" required:"true"`
+	Body      struct {
+		URL          *string   `json:"url,omitempty"`
+		EventTypes   *[]string `json:"eventTypes,omitempty"`
+		FarmIDs      *[]string `json:"farmIds,omitempty"`
+		Active       *bool     `json:"active,omitempty"`
+		RotateSecret bool      `json:"rotateSecret,omitempty"`
+	}
+}
+
+type UpdateWebhookOutput struct {
+	Body struct {
+		domain.Webhook
+		Secret string `json:"secret,omitempty"`
+	}
+}
+
+type DeleteWebhookInput struct {
+	WebhookID string `path:"webhookId" required:"true"`
+}
+
+type DeleteWebhookOutput struct {
+	Body struct {
+		Message string `json:"message"`
+	}
+}
+
+type GetWebhookDeliveriesInput struct {
+	WebhookID string `path:"webhookId" required:"true"`
+	Limit     int    `query:"limit" minimum:"1" maximum:"500" doc:"Number of most recent deliveries to return (default 50)"`
+}
+
+type GetWebhookDeliveriesOutput struct {
+	Body []domain.WebhookDelivery
+}
+
+//
+// API Handlers
+//
+
+func (a *api) getWebhooksHandler(ctx context.Context, input *GetWebhooksInput) (*GetWebhooksOutput, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("Authentication failed", err)
+	}
+
+	webhooks, err := a.webhookRepo.GetByOwnerID(ctx, userID)
+	if err != nil {
+		a.logger.Error("Failed to get webhooks", "ownerId", userID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to retrieve webhooks")
+	}
+	return &GetWebhooksOutput{Body: webhooks}, nil
+}
+
+func (a *api) createWebhookHandler(ctx context.Context, input *CreateWebhookInput) (*CreateWebhookOutput, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("Authentication failed", err)
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		a.logger.Error("Failed to generate webhook secret", "error", err)
+		return nil, huma.Error500InternalServerError("Failed to create webhook")
+	}
+
+	webhook := &domain.Webhook{
+		OwnerID:    userID,
+		URL:        input.Body.URL,
+		Secret:     secret,
+		EventTypes: input.Body.EventTypes,
+		FarmIDs:    input.Body.FarmIDs,
+		Active:     true,
+	}
+	if err := a.validateWebhook(ctx, webhook); err != nil {
+		return nil, err
+	}
+
+	if err := a.webhookRepo.CreateOrUpdate(ctx, webhook); err != nil {
+		a.logger.Error("Failed to create webhook", "ownerId", userID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to create webhook")
+	}
+
+	a.logger.Info("Webhook created", "webhookId", webhook.UUID, "ownerId", userID)
+	return &CreateWebhookOutput{Body: WebhookWithSecret{Webhook: *webhook, Secret: secret}}, nil
+}
+
+func (a *api) updateWebhookHandler(ctx context.Context, input *UpdateWebhookInput) (*UpdateWebhookOutput, error) {
+	webhook, err := a.getOwnedWebhook(ctx, input.WebhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.Body.URL != nil {
+		webhook.URL = *input.Body.URL
+	}
+	if input.Body.EventTypes != nil {
+		webhook.EventTypes = *input.Body.EventTypes
+	}
+	if input.Body.FarmIDs != nil {
+		webhook.FarmIDs = *input.Body.FarmIDs
+	}
+	if input.Body.Active != nil {
+		webhook.Active = *input.Body.Active
+	}
+	var secret string
+	if input.Body.RotateSecret {
+		if secret, err = newWebhookSecret(); err != nil {
+			a.logger.Error("Failed to generate webhook secret", "error", err)
+			return nil, huma.Error500InternalServerError("Failed to update webhook")
+		}
+		webhook.Secret = secret
+	}
+	if err := a.validateWebhook(ctx, webhook); err != nil {
+		return nil, err
+	}
+
+	if err := a.webhookRepo.CreateOrUpdate(ctx, webhook); err != nil {
+		a.logger.Error("Failed to update webhook", "webhookId", webhook.UUID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to update webhook")
+	}
+
+	resp := &UpdateWebhookOutput{}
+	resp.Body.Webhook = *webhook
+	resp.Body.Secret = secret
+	return resp, nil
+}
+
+func (a *api) deleteWebhookHandler(ctx context.Context, input *DeleteWebhookInput) (*DeleteWebhookOutput, error) {
+	webhook, err := a.getOwnedWebhook(ctx, input.WebhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.webhookRepo.Delete(ctx, webhook.UUID); err != nil && !errors.Is(err, domain.ErrNotFound) {
+		a.logger.Error("Failed to delete webhook", "webhookId", webhook.UUID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to delete webhook")
+	}
+
+	resp := &DeleteWebhookOutput{}
+	resp.Body.Message = "Webhook deleted successfully"
+	return resp, nil
+}
+
+func (a *api) getWebhookDeliveriesHandler(ctx context.Context, input *GetWebhookDeliveriesInput) (*GetWebhookDeliveriesOutput, error) {
+	webhook, err := a.getOwnedWebhook(ctx, input.WebhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := input.Limit
+	if limit <= 0 {
+		limit = defaultDeliveriesLimit
+	}
+	limit = min(limit, maxDeliveriesLimit)
+
+	deliveries, err := a.webhookRepo.ListDeliveries(ctx, webhook.UUID, limit)
+	if err != nil {
+		a.logger.Error("Failed to list webhook deliveries", "webhookId", webhook.UUID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to retrieve webhook deliveries")
+	}
+	return &GetWebhookDeliveriesOutput{Body: deliveries}, nil
+}
+
+// getOwnedWebhook loads a webhook of the authenticated user, returning the
+// error response to send otherwise.
+func (a *api) getOwnedWebhook(ctx context.Context, webhookID string) (*domain.Webhook, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("Authentication failed", err)
+	}
+
+	webhook, err := a.webhookRepo.GetByID(ctx, webhookID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, huma.Error404NotFound("Webhook not found")
+	}
+	if err != nil {
+		a.logger.Error("Failed to get webhook", "webhookId", webhookID, "error", err)
+		return nil, huma.Error500InternalServerError("Failed to retrieve webhook")
+	}
+	if webhook.OwnerID != userID {
+		// Not revealing that the webhook exists.
+		return nil, huma.Error404NotFound("Webhook not found")
+	}
+	return webhook, nil
+}
+
+// validateWebhook checks a webhook's fields and that the farms it filters on
+// belong to its owner.
+func (a *api) validateWebhook(ctx context.Context, webhook *domain.Webhook) error {
+	if err := webhook.Validate(); err != nil {
+		return huma.Error422UnprocessableEntity("Validation failed", err)
+	}
+	for _, farmID := range webhook.FarmIDs {
+		farm, err := a.farmRepo.GetByID(ctx, farmID)
+		if errors.Is(err, domain.ErrNotFound) || (err == nil && farm.OwnerID != webhook.OwnerID) {
+			return huma.Error422UnprocessableEntity("Unknown farm: " + farmID)
+		}
+		if err != nil {
+			a.logger.Error("Failed to get farm for webhook", "farmId", farmID, "error", err)
+			return huma.Error500InternalServerError("Failed to validate webhook")
+		}
+	}
+	return nil
+}
+
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return webhookSecretPrefix + hex.EncodeToString(b), nil
+}
@@ -3,7 +3,6 @@ package cmd
 
 import (
 	"context"
-	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
@@ -12,11 +11,13 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/forfarm/backend/internal/api"
+	"github.com/forfarm/backend/internal/cache"
 	"github.com/forfarm/backend/internal/cmdutil"
 	"github.com/forfarm/backend/internal/config"
 	"github.com/forfarm/backend/internal/event"
 	"github.com/forfarm/backend/internal/repository"
 	"github.com/forfarm/backend/internal/services"
+	"github.com/forfarm/backend/internal/utilities"
 	"github.com/forfarm/backend/internal/workers"
 )
 
@@ -30,6 +31,18 @@ func APICmd(ctx context.Context) *cobra.Command {
 		RunE: func(cmd *cobra.Command, args []string) error {
 			logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 
+			if config.JWT_KEYS_DIR != "" {
+				keys, err := utilities.LoadSigningKeys(config.JWT_KEYS_DIR, config.JWT_ACTIVE_KEY_ID)
+				if err != nil {
+					logger.Error("failed to load JWT signing keys", "dir", config.JWT_KEYS_DIR, "error", err)
+					return err
+				}
+				utilities.SetSigningKeys(keys)
+				logger.Info("loaded JWT signing keys", "active_kid", keys.ActiveKeyID())
+			} else {
+				logger.Warn("JWT_KEYS_DIR is not set, signing tokens with HS256 and JWT_SECRET_KEY")
+			}
+
 			pool, err := cmdutil.NewDatabasePool(ctx, 16)
 			if err != nil {
 				logger.Error("failed to create database pool", "error", err)
@@ -39,13 +52,15 @@ func APICmd(ctx context.Context) *cobra.Command {
 			logger.Info("connected to database")
 
 			// --- Event Bus ---
-			eventBus, err := event.NewRabbitMQEventBus(config.RABBITMQ_URL, logger)
+			eventBus, err := newEventBus(ctx, pool, logger)
 			if err != nil {
-				logger.Error("failed to connect to event bus", "url", config.RABBITMQ_URL, "error", err)
-				return fmt.Errorf("event bus connection failed: %w", err)
+				return err
 			}
 			defer eventBus.Close()
-			logger.Info("connected to event bus", "url", config.RABBITMQ_URL)
+
+			// Every event is appended to the event store before it is published.
+			eventStore := repository.NewPostgresEventStore(pool)
+			eventPublisher := event.NewStoringPublisher(eventStore, eventBus)
 
 			logger.Info("starting AnalyticService worker for farm-crop analytics")
 			analyticService := services.NewAnalyticsService()
@@ -53,7 +68,8 @@ func APICmd(ctx context.Context) *cobra.Command {
 			analyticsRepo := repository.NewPostgresFarmAnalyticsRepository(pool, logger, analyticService)
 
 			farmRepo := repository.NewPostgresFarm(pool)
-			farmRepo.SetEventPublisher(eventBus)
+			croplandRepo := repository.NewPostgresCropland(pool)
+			inventoryRepo := repository.NewPostgresInventory(pool, eventPublisher, cache.NewMemoryCache(time.Hour, 2*time.Hour))
 
 			projection := event.NewFarmAnalyticsProjection(eventBus, analyticsRepo, logger)
 			go func() {
@@ -63,7 +79,28 @@ func APICmd(ctx context.Context) *cobra.Command {
 			}()
 			logger.Info("Farm Analytics Projection started")
 
-			apiInstance := api.NewAPI(ctx, logger, pool, eventBus, analyticsRepo, farmRepo)
+			aggregator := event.NewEventAggregator(eventBus, eventPublisher, analyticsRepo, croplandRepo, inventoryRepo, logger)
+			if err := aggregator.Start(ctx); err != nil {
+				logger.Error("EventAggregator failed to start", "error", err)
+			} else {
+				logger.Info("Farm status aggregator started")
+			}
+
+			webhookRepo := repository.NewPostgresWebhook(pool)
+			webhookDispatcher := event.NewWebhookDispatcher(eventBus, webhookRepo, farmRepo, logger)
+			if err := webhookDispatcher.Start(ctx); err != nil {
+				logger.Error("WebhookDispatcher failed to start", "error", err)
+			}
+
+			apiInstance := api.NewAPI(ctx, logger, pool, eventPublisher, analyticsRepo, farmRepo)
+			apiInstance.SetEventBusHealthCheck(eventBus.HealthCheck)
+
+			broadcaster := event.NewBroadcaster(eventBus, api.StreamEventTypes, logger)
+			if err := broadcaster.Start(ctx); err != nil {
+				logger.Error("failed to start event stream broadcaster", "error", err)
+			} else {
+				apiInstance.SetEventStream(broadcaster, eventStore)
+			}
 
 			weatherFetcher := apiInstance.GetWeatherFetcher()
 			weatherInterval, err := time.ParseDuration(config.WEATHER_FETCH_INTERVAL)
@@ -71,13 +108,64 @@ func APICmd(ctx context.Context) *cobra.Command {
 				logger.Warn("Invalid WEATHER_FETCH_INTERVAL, using default 15m", "value", config.WEATHER_FETCH_INTERVAL, "error", err)
 				weatherInterval = 15 * time.Minute
 			}
-			weatherUpdater, err := workers.NewWeatherUpdater(farmRepo, weatherFetcher, eventBus, logger, weatherInterval)
+			weatherUpdater, err := workers.NewWeatherUpdater(farmRepo, weatherFetcher, eventPublisher, logger, weatherInterval)
 			if err != nil {
 				logger.Error("failed to create WeatherUpdater", "error", err)
 			}
 			weatherUpdater.Start(ctx)
 			logger.Info("Weather Updater worker started", "interval", weatherInterval)
 
+			outboxInterval, err := time.ParseDuration(config.OUTBOX_POLL_INTERVAL)
+			if err != nil {
+				logger.Warn("Invalid OUTBOX_POLL_INTERVAL, using default 1s", "value", config.OUTBOX_POLL_INTERVAL, "error", err)
+				outboxInterval = time.Second
+			}
+			outboxRelay, err := workers.NewOutboxRelay(repository.NewPostgresOutbox(pool), eventPublisher, logger, outboxInterval)
+			if err != nil {
+				logger.Error("failed to create OutboxRelay", "error", err)
+				return err
+			}
+			outboxRelay.Start(ctx)
+
+			webhookInterval, err := time.ParseDuration(config.WEBHOOK_POLL_INTERVAL)
+			if err != nil {
+				logger.Warn("Invalid WEBHOOK_POLL_INTERVAL, using default 2s", "value", config.WEBHOOK_POLL_INTERVAL, "error", err)
+				webhookInterval = 2 * time.Second
+			}
+			webhookDelivery, err := workers.NewWebhookDelivery(webhookRepo, logger, webhookInterval, config.WEBHOOK_ALLOW_PRIVATE)
+			if err != nil {
+				logger.Error("failed to create WebhookDelivery", "error", err)
+				return err
+			}
+			webhookDelivery.Start(ctx)
+
+			accountJobsInterval, err := time.ParseDuration(config.ACCOUNT_JOBS_INTERVAL)
+			if err != nil {
+				logger.Warn("Invalid ACCOUNT_JOBS_INTERVAL, using default 1m", "value", config.ACCOUNT_JOBS_INTERVAL, "error", err)
+				accountJobsInterval = time.Minute
+			}
+			userRepo := repository.NewPostgresUser(pool)
+			auditRepo := repository.NewPostgresAuditLog(pool)
+			accountService := services.NewAccountService(
+				logger,
+				userRepo,
+				farmRepo,
+				repository.NewPostgresFarmMember(pool),
+				croplandRepo,
+				inventoryRepo,
+				repository.NewPostgresIdentity(pool),
+				repository.NewPostgresAPIKey(pool),
+				repository.NewPostgresSession(pool),
+				auditRepo,
+				services.NewLoginGuard(logger, repository.NewPostgresLoginThrottle(pool), auditRepo),
+			)
+			accountJobs, err := workers.NewAccountJobs(repository.NewPostgresDataExport(pool), userRepo, accountService, logger, accountJobsInterval, config.DATA_EXPORT_TTL)
+			if err != nil {
+				logger.Error("failed to create AccountJobs", "error", err)
+				return err
+			}
+			accountJobs.Start(ctx)
+
 			server := apiInstance.Server(port)
 
 			serverErrChan := make(chan error, 1)
@@ -100,6 +188,9 @@ func APICmd(ctx context.Context) *cobra.Command {
 				defer cancel()
 
 				weatherUpdater.Stop()
+				outboxRelay.Stop()
+				webhookDelivery.Stop()
+				accountJobs.Stop()
 				if err := server.Shutdown(shutdownCtx); err != nil {
 					logger.Error("HTTP server graceful shutdown failed", "error", err)
 				} else {
@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/spf13/cobra"
+
+	"github.com/forfarm/backend/internal/cmdutil"
+	"github.com/forfarm/backend/internal/config"
+	"github.com/forfarm/backend/internal/event"
+)
+
+func DeadLetterCmd(ctx context.Context) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "deadletter",
+		Short: "Inspect and replay events dead-lettered by durable consumers",
+	}
+
+	cmd.AddCommand(deadLetterListCmd(ctx))
+	cmd.AddCommand(deadLetterReplayCmd(ctx))
+
+	return cmd
+}
+
+func deadLetterListCmd(ctx context.Context) *cobra.Command {
+	var limit int
+
+	cmd := &cobra.Command{
+		Use:   "list [consumer]",
+		Short: "List dead-lettered events of a consumer without removing them",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			bus, err := newDeadLetterBus(ctx)
+			if err != nil {
+				return err
+			}
+			defer bus.Close()
+
+			letters, err := bus.DeadLetters(ctx, args[0], limit)
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "EVENT ID\tTYPE\tAGGREGATE\tRETRIES\tDEAD-LETTERED AT\tERROR")
+			for _, l := range letters {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n",
+					l.Event.ID, l.Event.Type, l.Event.AggregateID, l.Retries,
+					l.DeadLetteredAt.Format(time.RFC3339), l.Error)
+			}
+			return w.Flush()
+		},
+	}
+
+	cmd.Flags().IntVarP(&limit, "limit", "n", 50, "Maximum number of events to list (0 lists all)")
+	return cmd
+}
+
+func deadLetterReplayCmd(ctx context.Context) *cobra.Command {
+	var limit int
+
+	cmd := &cobra.Command{
+		Use:   "replay [consumer]",
+		Short: "Move dead-lettered events back to the consumer queue",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+			bus, err := newDeadLetterBus(ctx)
+			if err != nil {
+				return err
+			}
+			defer bus.Close()
+
+			replayed, err := bus.ReplayDeadLetters(ctx, args[0], limit)
+			logger.Info("Replayed dead-lettered events", "consumer", args[0], "count", replayed)
+			return err
+		},
+	}
+
+	cmd.Flags().IntVarP(&limit, "limit", "n", 0, "Maximum number of events to replay (0 replays all)")
+	return cmd
+}
+
+// newDeadLetterBus connects to the event bus selected by EVENT_BUS_DRIVER for
+// inspecting dead letters.
+func newDeadLetterBus(ctx context.Context) (deadLetterBus, error) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+
+	switch strings.ToLower(config.EVENT_BUS_DRIVER) {
+	case "", "rabbitmq":
+		bus, err := event.NewRabbitMQEventBus(config.RABBITMQ_URL, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to RabbitMQ at %s: %w", config.RABBITMQ_URL, err)
+		}
+		return bus, nil
+	case "postgres":
+		pool, err := cmdutil.NewDatabasePool(ctx, 2)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to database: %w", err)
+		}
+		bus, err := event.NewPostgresEventBus(ctx, pool, logger)
+		if err != nil {
+			pool.Close()
+			return nil, err
+		}
+		return &poolClosingBus{PostgresEventBus: bus, pool: pool}, nil
+	default:
+		return nil, fmt.Errorf("EVENT_BUS_DRIVER %q does not keep dead letters", config.EVENT_BUS_DRIVER)
+	}
+}
+
+// poolClosingBus closes the pool it was created with after the bus.
+type poolClosingBus struct {
+	*event.PostgresEventBus
+	pool *pgxpool.Pool
+}
+
+func (b *poolClosingBus) Close() error {
+	err := b.PostgresEventBus.Close()
+	b.pool.Close()
+	return err
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/forfarm/backend/internal/config"
+	"github.com/forfarm/backend/internal/domain"
+	"github.com/forfarm/backend/internal/event"
+)
+
+type closableEventBus interface {
+	domain.EventBus
+	HealthCheck(ctx context.Context) error
+	Close() error
+}
+
+// newEventBus builds the event bus selected by EVENT_BUS_DRIVER. The
+// postgres driver runs on pool, which must outlive the bus.
+func newEventBus(ctx context.Context, pool *pgxpool.Pool, logger *slog.Logger) (closableEventBus, error) {
+	switch strings.ToLower(config.EVENT_BUS_DRIVER) {
+	case "", "rabbitmq":
+		bus, err := event.NewRabbitMQEventBus(config.RABBITMQ_URL, logger)
+		if err != nil {
+			logger.Error("failed to connect to event bus", "url", config.RABBITMQ_URL, "error", err)
+			return nil, fmt.Errorf("event bus connection failed: %w", err)
+		}
+		logger.Info("connected to event bus", "driver", "rabbitmq", "url", config.RABBITMQ_URL)
+		return bus, nil
+	case "postgres":
+		bus, err := event.NewPostgresEventBus(ctx, pool, logger)
+		if err != nil {
+			logger.Error("failed to start postgres event bus", "error", err)
+			return nil, fmt.Errorf("event bus connection failed: %w", err)
+		}
+		logger.Info("connected to event bus", "driver", "postgres")
+		return bus, nil
+	case "memory":
+		logger.Warn("using in-memory event bus, events are not shared between processes and are lost on restart")
+		return event.NewInMemoryEventBus(logger), nil
+	default:
+		return nil, fmt.Errorf("unknown EVENT_BUS_DRIVER %q", config.EVENT_BUS_DRIVER)
+	}
+}
+
+// deadLetterBus is an event bus whose dead letters outlive the process.
+type deadLetterBus interface {
+	DeadLetters(ctx context.Context, consumer string, limit int) ([]event.DeadLetter, error)
+	ReplayDeadLetters(ctx context.Context, consumer string, limit int) (int, error)
+	Close() error
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"context"
+	"log/slog"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/forfarm/backend/internal/cmdutil"
+	"github.com/forfarm/backend/internal/event"
+	"github.com/forfarm/backend/internal/repository"
+	"github.com/forfarm/backend/internal/services"
+)
+
+func ReplayCmd(ctx context.Context) *cobra.Command {
+	var farmID string
+
+	cmd := &cobra.Command{
+		Use:   "replay",
+		Args:  cobra.ExactArgs(0),
+		Short: "Rebuild farm analytics by replaying the event store",
+		Long: "Rebuild farm analytics by replaying the event store.\n\n" +
+			"Without --farm the farm_analytics table is truncated and rebuilt for every farm. " +
+			"Base rows and crop counts are seeded from the farms and croplands tables first. " +
+			"The API keeps running; it applies live events once the rebuild has committed.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+			pool, err := cmdutil.NewDatabasePool(ctx, 4)
+			if err != nil {
+				logger.Error("failed to create database pool", "error", err)
+				return err
+			}
+			defer pool.Close()
+
+			analyticsRepo := repository.NewPostgresFarmAnalyticsRepository(pool, logger, services.NewAnalyticsService())
+			eventStore := repository.NewPostgresEventStore(pool)
+			projection := event.NewFarmAnalyticsProjection(nil, analyticsRepo, logger)
+
+			logger.Info("Replaying event store into farm analytics", "farm_id", farmID)
+			replayed, failed, err := projection.Rebuild(ctx, eventStore, farmID)
+			if err != nil {
+				logger.Error("Replay failed", "replayed", replayed, "failed", failed, "error", err)
+				return err
+			}
+
+			logger.Info("Replay complete", "replayed", replayed, "failed", failed)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&farmID, "farm", "", "Only rebuild the analytics of this farm")
+	return cmd
+}
@@ -20,6 +20,9 @@ func Execute(ctx context.Context) int {
 	rootCmd.AddCommand(APICmd(ctx))
 	rootCmd.AddCommand(MigrateCmd(ctx, "pgx", config.DATABASE_URL))
 	rootCmd.AddCommand(RollbackCmd(ctx, "pgx", config.DATABASE_URL))
+	rootCmd.AddCommand(ReplayCmd(ctx))
+	rootCmd.AddCommand(DeadLetterCmd(ctx))
+	rootCmd.AddCommand(UserCmd(ctx))
 
 	if err := rootCmd.Execute(); err != nil {
 		return 1
@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/forfarm/backend/internal/cmdutil"
+	"github.com/forfarm/backend/internal/domain"
+	"github.com/forfarm/backend/internal/repository"
+)
+
+func UserCmd(ctx context.Context) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "user",
+		Short: "Manage user accounts",
+	}
+
+	cmd.AddCommand(userCreateAdminCmd(ctx))
+	cmd.AddCommand(userDeactivateCmd(ctx))
+
+	return cmd
+}
+
+func userCreateAdminCmd(ctx context.Context) *cobra.Command {
+	var password string
+
+	cmd := &cobra.Command{
+		Use:   "create-admin [email]",
+		Short: "Create an administrator, or make an existing user one",
+		Long: "Create an administrator, or make an existing user one.\n\n" +
+			"A new account needs a password, taken from --password or else read from standard input. " +
+			"Administrators must enable two-factor authentication before they can use the admin API.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+			email := strings.ToLower(strings.TrimSpace(args[0]))
+
+			pool, err := cmdutil.NewDatabasePool(ctx, 2)
+			if err != nil {
+				logger.Error("failed to create database pool", "error", err)
+				return err
+			}
+			defer pool.Close()
+			userRepo := repository.NewPostgresUser(pool)
+			auditRepo := repository.NewPostgresAuditLog(pool)
+
+			user, err := userRepo.GetByEmail(ctx, email)
+			if err == nil {
+				if user.IsAdmin() {
+					logger.Info("User is already an administrator", "email", email, "user_uuid", user.UUID)
+					return nil
+				}
+				user.Role = domain.RoleAdmin
+				if err := userRepo.CreateOrUpdate(ctx, &user); err != nil {
+					return fmt.Errorf("failed to update user: %w", err)
+				}
+				recordCLIAudit(ctx, logger, auditRepo, domain.AuditRoleChanged, user.UUID, map[string]interface{}{"role": domain.RoleAdmin})
+				logger.Info("User is now an administrator; they must sign in again to use it", "email", email, "user_uuid", user.UUID)
+				return nil
+			}
+			if !errors.Is(err, domain.ErrNotFound) {
+				return fmt.Errorf("failed to look up user: %w", err)
+			}
+
+			if password == "" {
+				fmt.Fprint(cmd.ErrOrStderr(), "Password: ")
+				line, err := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+				if err != nil && line == "" {
+					return fmt.Errorf("failed to read password: %w", err)
+				}
+				password = strings.TrimRight(line, "\r\n")
+			}
+			if len(password) < 8 {
+				return errors.New("password must be at least 8 characters long")
+			}
+			hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+			if err != nil {
+				return fmt.Errorf("failed to hash password: %w", err)
+			}
+
+			now := time.Now()
+			user = domain.User{
+				Email:           email,
+				Password:        string(hashed),
+				IsActive:        true,
+				PasswordSet:     true,
+				EmailVerifiedAt: &now,
+				Role:            domain.RoleAdmin,
+			}
+			if err := userRepo.CreateOrUpdate(ctx, &user); err != nil {
+				return fmt.Errorf("failed to create user: %w", err)
+			}
+			recordCLIAudit(ctx, logger, auditRepo, domain.AuditRegistered, user.UUID, map[string]interface{}{"role": domain.RoleAdmin})
+			logger.Info("Administrator created", "email", email, "user_uuid", user.UUID)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&password, "password", "", "Password of a new account (read from standard input if empty)")
+	return cmd
+}
+
+func userDeactivateCmd(ctx context.Context) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "deactivate [email]",
+		Short: "Deactivate a user account and sign it out everywhere",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+			email := strings.ToLower(strings.TrimSpace(args[0]))
+
+			pool, err := cmdutil.NewDatabasePool(ctx, 2)
+			if err != nil {
+				logger.Error("failed to create database pool", "error", err)
+				return err
+			}
+			defer pool.Close()
+			userRepo := repository.NewPostgresUser(pool)
+
+			user, err := userRepo.GetByEmail(ctx, email)
+			if errors.Is(err, domain.ErrNotFound) {
+				return fmt.Errorf("no user has the email address %s", email)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to look up user: %w", err)
+			}
+
+			if user.IsActive {
+				user.IsActive = false
+				if err := userRepo.CreateOrUpdate(ctx, &user); err != nil {
+					return fmt.Errorf("failed to update user: %w", err)
+				}
+				recordCLIAudit(ctx, logger, repository.NewPostgresAuditLog(pool), domain.AuditAccountDeactivated, user.UUID, nil)
+			}
+			// Sessions are revoked even if the account was already inactive,
+			// in case an earlier run stopped half way.
+			if err := repository.NewPostgresSession(pool).RevokeAllForUser(ctx, user.UUID); err != nil {
+				return fmt.Errorf("failed to revoke sessions: %w", err)
+			}
+
+			logger.Info("User deactivated", "email", email, "user_uuid", user.UUID)
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+// recordCLIAudit records an action taken from the command line, which has
+// no actor.
+func recordCLIAudit(ctx context.Context, logger *slog.Logger, auditRepo domain.AuditLogRepository, action, userID string, details map[string]interface{}) {
+	if details == nil {
+		details = map[string]interface{}{}
+	}
+	details["via"] = "cli"
+	data, _ := json.Marshal(details)
+
+	if err := auditRepo.Record(ctx, &domain.AuditEntry{
+		Action:       action,
+		UserID:       userID,
+		ResourceType: domain.AuditResourceUser,
+		ResourceID:   userID,
+		Details:      data,
+	}); err != nil {
+		logger.Error("Failed to record audit entry", "action", action, "error", err)
+	}
+}
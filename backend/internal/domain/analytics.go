@@ -5,6 +5,13 @@ import (
 	"time"
 )
 
+// Overall farm statuses, from best to worst.
+const (
+	FarmStatusOK       = "ok"
+	FarmStatusWarning  = "warning"
+	FarmStatusCritical = "critical"
+)
+
 type FarmAnalytics struct {
 	FarmID        string  `json:"farmId"`
 	FarmName      string  `json:"farmName"`
@@ -63,5 +70,18 @@ type AnalyticsRepository interface {
 	UpdateFarmAnalyticsCropStats(ctx context.Context, farmID string) error
 	UpdateFarmAnalyticsInventoryStats(ctx context.Context, farmID string) error
 	DeleteFarmAnalytics(ctx context.Context, farmID string) error
+	// ResetFarmAnalytics removes the analytics of one farm, or of every farm
+	// when farmID is empty, ahead of a rebuild from the event store. The base
+	// rows and crop counts are seeded again from the farms and croplands
+	// tables, since farms from before the event store have no history.
+	ResetFarmAnalytics(ctx context.Context, farmID string) error
+	// RunExclusive runs fn in a transaction that ApplyEventOnce waits for, so
+	// no live event is applied while farm analytics are being rebuilt.
+	RunExclusive(ctx context.Context, fn func(AnalyticsRepository) error) error
 	UpdateFarmOverallStatus(ctx context.Context, farmID string, status string) error
+	// ApplyEventOnce runs apply in a transaction together with recording the
+	// event as processed by consumer. It returns false without calling apply
+	// if the consumer already processed the event, or if it already applied a
+	// newer event of the same stream for the event's aggregate.
+	ApplyEventOnce(ctx context.Context, consumer string, event Event, stream string, apply func(AnalyticsRepository) error) (bool, error)
 }
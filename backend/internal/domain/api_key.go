@@ -0,0 +1,79 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+)
+
+// API key scopes. Each operation open to API keys requires one of them.
+const (
+	ScopeFarmsRead      = "farms:read"
+	ScopeFarmsWrite     = "farms:write"
+	ScopeCropsRead      = "crops:read"
+	ScopeCropsWrite     = "crops:write"
+	ScopeInventoryRead  = "inventory:read"
+	ScopeInventoryWrite = "inventory:write"
+	ScopeAnalyticsRead  = "analytics:read"
+)
+
+// APIKeyScopes lists every scope a key can be granted.
+var APIKeyScopes = []string{
+	ScopeFarmsRead,
+	ScopeFarmsWrite,
+	ScopeCropsRead,
+	ScopeCropsWrite,
+	ScopeInventoryRead,
+	ScopeInventoryWrite,
+	ScopeAnalyticsRead,
+}
+
+// APIKey lets a machine client act for a user within the key's scopes. Only
+// the hash of the key is stored; Prefix identifies it in listings.
+type APIKey struct {
+	UUID       string     `json:"uuid"`
+	UserID     string     `json:"userId"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	FarmID     *string    `json:"farmId,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+}
+
+func (k *APIKey) Validate() error {
+	scopes := make([]interface{}, len(APIKeyScopes))
+	for i, s := range APIKeyScopes {
+		scopes[i] = s
+	}
+	return validation.ValidateStruct(k,
+		validation.Field(&k.UserID, validation.Required),
+		validation.Field(&k.Name, validation.Required, validation.Length(1, 100)),
+		validation.Field(&k.KeyHash, validation.Required),
+		validation.Field(&k.Scopes, validation.Required, validation.Each(validation.In(scopes...))),
+		validation.Field(&k.FarmID, validation.NilOrNotEmpty, is.UUID),
+		validation.Field(&k.ExpiresAt, validation.By(func(value interface{}) error {
+			if t, _ := value.(*time.Time); t != nil && !t.After(time.Now()) {
+				return validation.NewError("validation_expires_at_past", "must be in the future")
+			}
+			return nil
+		})),
+	)
+}
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, k *APIKey) error
+	GetByID(ctx context.Context, uuid string) (*APIKey, error)
+	// GetByUserID returns the user's keys that are not revoked, newest first.
+	GetByUserID(ctx context.Context, userID string) ([]APIKey, error)
+	Revoke(ctx context.Context, uuid string) error
+	// Authenticate returns the usable key whose hash is keyHash and records
+	// that it was used. Revoked and expired keys, and keys of deactivated
+	// users, are not found.
+	Authenticate(ctx context.Context, keyHash string) (*APIKey, error)
+}
@@ -0,0 +1,172 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"time"
+)
+
+// Audit actions are "<resource type>.<verb>".
+const (
+	AuditAccountLocked   = "auth.account_locked"
+	AuditAccountUnlocked = "auth.account_unlocked"
+	AuditIPLocked        = "auth.ip_locked"
+
+	AuditRegistered               = "auth.registered"
+	AuditLoginSucceeded           = "auth.login_succeeded"
+	AuditLoginFailed              = "auth.login_failed"
+	AuditLoggedOut                = "auth.logged_out"
+	AuditSessionRevoked           = "auth.session_revoked"
+	AuditEmailVerified            = "auth.email_verified"
+	AuditPasswordReset            = "auth.password_reset"
+	AuditTwoFactorEnabled         = "auth.two_factor_enabled"
+	AuditTwoFactorDisabled        = "auth.two_factor_disabled"
+	AuditRecoveryCodesRegenerated = "auth.recovery_codes_regenerated"
+	AuditAPIKeyCreated            = "auth.api_key_created"
+	AuditAPIKeyRevoked            = "auth.api_key_revoked"
+	AuditIdentityLinked           = "auth.identity_linked"
+	AuditIdentityUnlinked         = "auth.identity_unlinked"
+	AuditDataExportRequested      = "auth.data_export_requested"
+	AuditDeletionScheduled        = "auth.deletion_scheduled"
+	AuditDeletionCancelled        = "auth.deletion_cancelled"
+	AuditAccountDeleted           = "auth.account_deleted"
+	AuditAccountDeactivated       = "auth.account_deactivated"
+	AuditAccountReactivated       = "auth.account_reactivated"
+	AuditRoleChanged              = "auth.role_changed"
+
+	AuditFarmCreated = "farm.created"
+	AuditFarmUpdated = "farm.updated"
+	AuditFarmDeleted = "farm.deleted"
+
+	AuditFarmMemberUpdated      = "farm_member.updated"
+	AuditFarmMemberRemoved      = "farm_member.removed"
+	AuditFarmInvitationCreated  = "farm_invitation.created"
+	AuditFarmInvitationRevoked  = "farm_invitation.revoked"
+	AuditFarmInvitationAccepted = "farm_invitation.accepted"
+	AuditFarmInvitationDeclined = "farm_invitation.declined"
+	AuditCroplandCreated        = "cropland.created"
+	AuditCroplandUpdated        = "cropland.updated"
+	AuditInventoryItemCreated   = "inventory_item.created"
+	AuditInventoryItemUpdated   = "inventory_item.updated"
+	AuditInventoryItemDeleted   = "inventory_item.deleted"
+
+	AuditArticleCreated = "knowledge_article.created"
+	AuditArticleUpdated = "knowledge_article.updated"
+)
+
+// Audited resource types.
+const (
+	AuditResourceUser           = "user"
+	AuditResourceSession        = "session"
+	AuditResourceAPIKey         = "api_key"
+	AuditResourceIdentity       = "identity"
+	AuditResourceFarm           = "farm"
+	AuditResourceFarmMember     = "farm_member"
+	AuditResourceFarmInvitation = "farm_invitation"
+	AuditResourceCropland       = "cropland"
+	AuditResourceInventoryItem  = "inventory_item"
+	AuditResourceArticle        = "knowledge_article"
+)
+
+// AuditEntry records a security-relevant or data-changing action. UserID is
+// the account it concerns and ActorID who performed it; either is empty when
+// unknown or when the system acted on its own. FarmID is set for actions on a
+// farm's data, which the farm's owner can review. Changes is made by
+// AuditChanges.
+type AuditEntry struct {
+	UUID         string          `json:"uuid"`
+	Action       string          `json:"action"`
+	UserID       string          `json:"userId,omitempty"`
+	ActorID      string          `json:"actorId,omitempty"`
+	APIKeyID     string          `json:"apiKeyId,omitempty"`
+	ResourceType string          `json:"resourceType,omitempty"`
+	ResourceID   string          `json:"resourceId,omitempty"`
+	FarmID       string          `json:"farmId,omitempty"`
+	IPAddress    string          `json:"ipAddress,omitempty"`
+	UserAgent    string          `json:"userAgent,omitempty"`
+	Changes      json.RawMessage `json:"changes,omitempty"`
+	Details      json.RawMessage `json:"details,omitempty"`
+	CreatedAt    time.Time       `json:"createdAt"`
+}
+
+// AuditLogFilter selects audit entries. Empty fields match everything.
+type AuditLogFilter struct {
+	FarmID       string
+	UserID       string
+	ActorID      string
+	Action       string
+	ResourceType string
+	ResourceID   string
+	Since        *time.Time
+	Until        *time.Time
+	// Before continues a listing after the last entry of the previous page.
+	Before *AuditCursor
+	Limit  int
+}
+
+// AuditCursor is the position of an entry in the log, which is listed
+// newest first.
+type AuditCursor struct {
+	CreatedAt time.Time
+	UUID      string
+}
+
+type AuditLogRepository interface {
+	Record(ctx context.Context, e *AuditEntry) error
+	// List returns the entries matching filter, newest first.
+	List(ctx context.Context, filter AuditLogFilter) ([]AuditEntry, error)
+	// Anonymize removes what identifies a user from the log before their
+	// account is deleted. Entries about the user or the farms they own lose
+	// their changes and details; every entry loses the user's ids and the
+	// address and user agent of their requests. The actions themselves stay.
+	Anonymize(ctx context.Context, userID string, farmIDs []string) error
+}
+
+// auditIgnoredFields change on every write and say nothing about the change.
+var auditIgnoredFields = map[string]bool{"createdAt": true, "updatedAt": true}
+
+// AuditChanges describes how a resource changed between two JSON-encodable
+// states: {"field": {"before": x, "after": y}} for every top-level field that
+// differs. before is nil for a creation and after for a deletion. It returns
+// nil when nothing changed.
+func AuditChanges(before, after interface{}) json.RawMessage {
+	from, to := auditFields(before), auditFields(after)
+
+	changes := map[string]map[string]interface{}{}
+	for field, value := range from {
+		if other, ok := to[field]; !ok || !reflect.DeepEqual(value, other) {
+			changes[field] = map[string]interface{}{"before": value, "after": to[field]}
+		}
+	}
+	for field, value := range to {
+		if _, ok := from[field]; !ok {
+			changes[field] = map[string]interface{}{"before": nil, "after": value}
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+func auditFields(v interface{}) map[string]interface{} {
+	fields := map[string]interface{}{}
+	if v == nil || (reflect.ValueOf(v).Kind() == reflect.Pointer && reflect.ValueOf(v).IsNil()) {
+		return fields
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fields
+	}
+	_ = json.Unmarshal(data, &fields)
+	for field := range auditIgnoredFields {
+		delete(fields, field)
+	}
+	return fields
+}
@@ -0,0 +1,25 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditChanges(t *testing.T) {
+	before := &Farm{UUID: "f1", Name: "North field", Lat: 13.7, OwnerID: "u1", CreatedAt: time.Now()}
+	after := *before
+	after.Name = "South field"
+	after.UpdatedAt = time.Now()
+
+	assert.JSONEq(t, `{"name": {"before": "North field", "after": "South field"}}`, string(AuditChanges(before, &after)))
+	assert.Nil(t, AuditChanges(before, before), "timestamps alone are no change")
+
+	created := AuditChanges(nil, &User{UUID: "u1", Email: "a@example.com", Password: "secret"})
+	assert.Contains(t, string(created), `"email":{"after":"a@example.com","before":null}`)
+	assert.NotContains(t, string(created), "secret", "fields hidden from JSON stay out of the log")
+
+	var deleted *InventoryItem
+	assert.NotNil(t, AuditChanges(&InventoryItem{ID: "i1", Name: "Seeds"}, deleted))
+}
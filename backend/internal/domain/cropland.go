@@ -9,17 +9,21 @@ import (
 )
 
 type Cropland struct {
-	UUID        string          `json:"uuid"`
-	Name        string          `json:"name"`
-	Status      string          `json:"status"`
-	Priority    int             `json:"priority"`
-	LandSize    float64         `json:"landSize"`
-	GrowthStage string          `json:"growthStage"`
-	PlantID     string          `json:"plantId"`
-	FarmID      string          `json:"farmId"`
-	GeoFeature  json.RawMessage `json:"geoFeature,omitempty"`
-	CreatedAt   time.Time       `json:"createdAt"`
-	UpdatedAt   time.Time       `json:"updatedAt"`
+	UUID     string `json:"uuid"`
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Priority int    `json:"priority"`
+	// LandSize is in hectares. It is computed from GeoFeature when that is
+	// a boundary rather than a marker.
+	LandSize    float64 `json:"landSize"`
+	GrowthStage string  `json:"growthStage"`
+	PlantID     string  `json:"plantId"`
+	FarmID      string  `json:"farmId"`
+	// GeoFeature is the cropland's location as a GeoJSON Point, Polygon or
+	// MultiPolygon.
+	GeoFeature json.RawMessage `json:"geoFeature,omitempty"`
+	CreatedAt  time.Time       `json:"createdAt"`
+	UpdatedAt  time.Time       `json:"updatedAt"`
 }
 
 func (c *Cropland) Validate() error {
@@ -31,11 +35,31 @@ func (c *Cropland) Validate() error {
 	)
 }
 
+// SetGeoFeature validates a GeoJSON geometry, or a Feature holding one, and
+// stores the geometry as the cropland's location. A Polygon or MultiPolygon
+// also sets LandSize to the area it encloses.
+func (c *Cropland) SetGeoFeature(data json.RawMessage) error {
+	if len(data) == 0 || string(data) == "null" {
+		c.GeoFeature = nil
+		return nil
+	}
+	g, err := ParseGeometry(data)
+	if err != nil {
+		return err
+	}
+	if c.GeoFeature, err = json.Marshal(g); err != nil {
+		return err
+	}
+	if g.Type != GeometryPoint {
+		c.LandSize = g.Hectares()
+	}
+	return nil
+}
+
 type CroplandRepository interface {
 	GetByID(context.Context, string) (Cropland, error)
 	GetByFarmID(ctx context.Context, farmID string) ([]Cropland, error)
 	GetAll(ctx context.Context) ([]Cropland, error)
 	CreateOrUpdate(context.Context, *Cropland) error
 	Delete(context.Context, string) error
-	SetEventPublisher(EventPublisher)
 }
@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	DataExportPending = "pending"
+	DataExportRunning = "running"
+	DataExportReady   = "ready"
+	DataExportFailed  = "failed"
+)
+
+// DataExport is a user's request for an archive of their data. It is built
+// in the background; the archive can be downloaded once it is ready and
+// until it expires.
+type DataExport struct {
+	UUID        string     `json:"uuid"`
+	UserID      string     `json:"userId"`
+	Status      string     `json:"status"`
+	Size        int64      `json:"size,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty"`
+}
+
+// IsDownloadable reports whether the archive is ready and has not expired.
+func (e *DataExport) IsDownloadable(now time.Time) bool {
+	return e.Status == DataExportReady && e.ExpiresAt != nil && now.Before(*e.ExpiresAt)
+}
+
+type DataExportRepository interface {
+	Create(ctx context.Context, e *DataExport) error
+	GetByID(ctx context.Context, uuid string) (*DataExport, error)
+	// ListByUserID returns the user's exports, newest first.
+	ListByUserID(ctx context.Context, userID string) ([]DataExport, error)
+	// Claim marks the oldest pending export as running for the lease
+	// duration and returns it, or ErrNotFound when there is none. An export
+	// whose lease ran out, because its worker stopped, is claimed again.
+	Claim(ctx context.Context, lease time.Duration) (*DataExport, error)
+	Complete(ctx context.Context, uuid string, archive []byte, expiresAt time.Time) error
+	Fail(ctx context.Context, uuid, reason string) error
+	GetArchive(ctx context.Context, uuid string) ([]byte, error)
+	// DeleteExpired removes exports whose archive expired before now and
+	// returns how many there were.
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
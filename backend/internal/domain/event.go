@@ -2,9 +2,14 @@ package domain
 
 import (
 	"context"
+	"errors"
 	"time"
 )
 
+// ErrEventUnroutable is returned by a publisher when the broker accepted an
+// event but no queue was bound to receive it.
+var ErrEventUnroutable = errors.New("event was not routed to any queue")
+
 type Event struct {
 	ID          string
 	Type        string
@@ -12,17 +17,48 @@ type Event struct {
 	Timestamp   time.Time
 	Payload     interface{}
 	AggregateID string
+	// SchemaVersion is the version of the payload schema registered for Type.
+	SchemaVersion int
 }
 
 type EventPublisher interface {
+	// Publish returns once the event has been handed to the bus. Brokers that
+	// confirm publishes report rejected and unroutable events as errors.
 	Publish(ctx context.Context, event Event) error
 }
 
 type EventSubscriber interface {
+	// Subscribe delivers events to a transient queue that only exists while
+	// the subscriber is connected. A handler error drops the event; the
+	// RabbitMQ bus requeues it a few times first.
 	Subscribe(ctx context.Context, eventType string, handler func(Event) error) error
+	// SubscribeDurable delivers events to a named queue shared by every
+	// instance of the consumer, so events published while it is down are kept.
+	// A handler error schedules a delayed retry; once the retries are used up
+	// the event is dead-lettered.
+	SubscribeDurable(ctx context.Context, consumer string, eventTypes []string, handler func(Event) error) error
 }
 
 type EventBus interface {
 	EventPublisher
 	EventSubscriber
 }
+
+// EventStore is an append-only log of every published event.
+type EventStore interface {
+	// Append stores an event under the farm it belongs to; farmID may be
+	// empty for events that are not tied to a farm. Appending an event ID
+	// that is already stored is a no-op.
+	Append(ctx context.Context, event Event, farmID string) error
+	// ForEach calls fn for each stored event in append order, restricted to
+	// one farm unless farmID is empty, and stops at the first error.
+	ForEach(ctx context.Context, farmID string, fn func(Event) error) error
+	// ForEachAfter calls fn for each event stored after the given event, in
+	// append order, restricted to the given farms and events not tied to a
+	// farm. It returns ErrNotFound if that event is not stored.
+	ForEachAfter(ctx context.Context, afterEventID string, farmIDs []string, fn func(Event) error) error
+	// Forget removes the events of the given farms and those whose payload
+	// names the user, when the user's account is deleted. It is the only
+	// way events leave the store.
+	Forget(ctx context.Context, userID string, farmIDs []string) error
+}
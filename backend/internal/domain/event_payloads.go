@@ -0,0 +1,209 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// FarmPayload is the payload of farm.created and farm.updated.
+type FarmPayload struct {
+	UUID      string    `json:"uuid"`
+	Name      string    `json:"name"`
+	Lat       float64   `json:"lat"`
+	Lon       float64   `json:"lon"`
+	FarmType  string    `json:"farmType,omitempty"`
+	TotalSize string    `json:"totalSize,omitempty"`
+	OwnerID   string    `json:"ownerId"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func NewFarmPayload(f *Farm) *FarmPayload {
+	return &FarmPayload{
+		UUID:      f.UUID,
+		Name:      f.Name,
+		Lat:       f.Lat,
+		Lon:       f.Lon,
+		FarmType:  f.FarmType,
+		TotalSize: f.TotalSize,
+		OwnerID:   f.OwnerID,
+		CreatedAt: f.CreatedAt,
+		UpdatedAt: f.UpdatedAt,
+	}
+}
+
+func (p *FarmPayload) Farm() *Farm {
+	return &Farm{
+		UUID:      p.UUID,
+		Name:      p.Name,
+		Lat:       p.Lat,
+		Lon:       p.Lon,
+		FarmType:  p.FarmType,
+		TotalSize: p.TotalSize,
+		OwnerID:   p.OwnerID,
+		CreatedAt: p.CreatedAt,
+		UpdatedAt: p.UpdatedAt,
+	}
+}
+
+func (p FarmPayload) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.UUID, validation.Required),
+		validation.Field(&p.Name, validation.Required),
+		validation.Field(&p.OwnerID, validation.Required),
+	)
+}
+
+func (p FarmPayload) eventFarmID() string { return p.UUID }
+
+// FarmDeletedPayload is the payload of farm.deleted.
+type FarmDeletedPayload struct {
+	UUID    string `json:"uuid"`
+	OwnerID string `json:"ownerId"`
+}
+
+func (p FarmDeletedPayload) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.UUID, validation.Required),
+	)
+}
+
+func (p FarmDeletedPayload) eventFarmID() string { return p.UUID }
+
+// FarmStatusChangedPayload is the payload of farm.status_changed.
+type FarmStatusChangedPayload struct {
+	FarmID         string   `json:"farm_id"`
+	Status         string   `json:"status"`
+	PreviousStatus string   `json:"previousStatus,omitempty"`
+	Reasons        []string `json:"reasons,omitempty"`
+}
+
+func (p FarmStatusChangedPayload) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.FarmID, validation.Required),
+		validation.Field(&p.Status, validation.Required, validation.In(FarmStatusOK, FarmStatusWarning, FarmStatusCritical)),
+	)
+}
+
+func (p FarmStatusChangedPayload) eventFarmID() string { return p.FarmID }
+
+// CroplandPayload is the payload of cropland.created and cropland.updated.
+type CroplandPayload struct {
+	UUID        string          `json:"uuid"`
+	Name        string          `json:"name"`
+	Status      string          `json:"status"`
+	Priority    int             `json:"priority"`
+	LandSize    float64         `json:"landSize"`
+	GrowthStage string          `json:"growthStage"`
+	PlantID     string          `json:"plantId"`
+	FarmID      string          `json:"farm_id"`
+	GeoFeature  json.RawMessage `json:"geoFeature,omitempty"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	UpdatedAt   time.Time       `json:"updatedAt"`
+}
+
+func NewCroplandPayload(c *Cropland) *CroplandPayload {
+	return &CroplandPayload{
+		UUID:        c.UUID,
+		Name:        c.Name,
+		Status:      c.Status,
+		Priority:    c.Priority,
+		LandSize:    c.LandSize,
+		GrowthStage: c.GrowthStage,
+		PlantID:     c.PlantID,
+		FarmID:      c.FarmID,
+		GeoFeature:  c.GeoFeature,
+		CreatedAt:   c.CreatedAt,
+		UpdatedAt:   c.UpdatedAt,
+	}
+}
+
+func (p CroplandPayload) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.UUID, validation.Required),
+		validation.Field(&p.FarmID, validation.Required),
+	)
+}
+
+func (p CroplandPayload) eventFarmID() string { return p.FarmID }
+
+// CroplandDeletedPayload is the payload of cropland.deleted.
+type CroplandDeletedPayload struct {
+	UUID   string `json:"uuid"`
+	FarmID string `json:"farm_id"`
+}
+
+func (p CroplandDeletedPayload) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.UUID, validation.Required),
+		validation.Field(&p.FarmID, validation.Required),
+	)
+}
+
+func (p CroplandDeletedPayload) eventFarmID() string { return p.FarmID }
+
+// WeatherUpdatedPayload is the payload of weather.updated.
+type WeatherUpdatedPayload struct {
+	FarmID string  `json:"farm_id"`
+	Lat    float64 `json:"lat"`
+	Lon    float64 `json:"lon"`
+	WeatherData
+}
+
+func (p WeatherUpdatedPayload) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.FarmID, validation.Required),
+	)
+}
+
+func (p WeatherUpdatedPayload) eventFarmID() string { return p.FarmID }
+
+// InventoryItemPayload is the payload of inventory.item.created and
+// inventory.item.updated.
+type InventoryItemPayload struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"userId"`
+	Name       string    `json:"name"`
+	CategoryID int       `json:"categoryId"`
+	Quantity   float64   `json:"quantity"`
+	UnitID     int       `json:"unitId"`
+	StatusID   int       `json:"statusId"`
+	DateAdded  time.Time `json:"dateAdded"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+func NewInventoryItemPayload(item *InventoryItem) *InventoryItemPayload {
+	return &InventoryItemPayload{
+		ID:         item.ID,
+		UserID:     item.UserID,
+		Name:       item.Name,
+		CategoryID: item.CategoryID,
+		Quantity:   item.Quantity,
+		UnitID:     item.UnitID,
+		StatusID:   item.StatusID,
+		DateAdded:  item.DateAdded,
+		UpdatedAt:  item.UpdatedAt,
+	}
+}
+
+func (p InventoryItemPayload) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.ID, validation.Required),
+		validation.Field(&p.UserID, validation.Required),
+	)
+}
+
+// InventoryItemDeletedPayload is the payload of inventory.item.deleted.
+type InventoryItemDeletedPayload struct {
+	ItemID string `json:"item_id"`
+	UserID string `json:"user_id"`
+}
+
+func (p InventoryItemDeletedPayload) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.ItemID, validation.Required),
+		validation.Field(&p.UserID, validation.Required),
+	)
+}
@@ -0,0 +1,210 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrUnknownEventType    = errors.New("unknown event type")
+	ErrInvalidEventPayload = errors.New("invalid event payload")
+)
+
+// payloadUpcaster rewrites a payload decoded at one schema version into the
+// shape of the next version.
+type payloadUpcaster func(map[string]interface{}) map[string]interface{}
+
+type eventSchema struct {
+	version     int
+	payloadType reflect.Type
+	// upcasters[v] upgrades a payload from version v to v+1.
+	upcasters map[int]payloadUpcaster
+}
+
+// eventSchemas registers the payload type and current schema version of every
+// event type. Publishing an unregistered type is rejected.
+var eventSchemas = map[string]eventSchema{
+	"farm.created": {version: 1, payloadType: reflect.TypeOf(FarmPayload{})},
+	"farm.updated": {version: 1, payloadType: reflect.TypeOf(FarmPayload{})},
+	"farm.deleted": {version: 1, payloadType: reflect.TypeOf(FarmDeletedPayload{})},
+
+	"farm.status_changed": {version: 1, payloadType: reflect.TypeOf(FarmStatusChangedPayload{})},
+
+	"cropland.created": {version: 2, payloadType: reflect.TypeOf(CroplandPayload{}), upcasters: map[int]payloadUpcaster{1: upcastCroplandV1}},
+	"cropland.updated": {version: 2, payloadType: reflect.TypeOf(CroplandPayload{}), upcasters: map[int]payloadUpcaster{1: upcastCroplandV1}},
+	"cropland.deleted": {version: 2, payloadType: reflect.TypeOf(CroplandDeletedPayload{}), upcasters: map[int]payloadUpcaster{1: upcastCroplandDeletedV1}},
+
+	"weather.updated": {version: 1, payloadType: reflect.TypeOf(WeatherUpdatedPayload{})},
+
+	"inventory.item.created": {version: 1, payloadType: reflect.TypeOf(InventoryItemPayload{})},
+	"inventory.item.updated": {version: 1, payloadType: reflect.TypeOf(InventoryItemPayload{})},
+	"inventory.item.deleted": {version: 1, payloadType: reflect.TypeOf(InventoryItemDeletedPayload{})},
+}
+
+// NewEvent builds an event of the given type at its current schema version
+// and validates its payload.
+func NewEvent(eventType, source, aggregateID string, payload interface{}) (Event, error) {
+	event := Event{
+		ID:          uuid.NewString(),
+		Type:        eventType,
+		Source:      source,
+		Timestamp:   time.Now().UTC(),
+		Payload:     payload,
+		AggregateID: aggregateID,
+	}
+	if schema, ok := eventSchemas[eventType]; ok {
+		event.SchemaVersion = schema.version
+	}
+	if err := ValidateEvent(event); err != nil {
+		return Event{}, err
+	}
+	return event, nil
+}
+
+// ValidateEvent checks that an event is of a registered type, at the current
+// schema version, and carries a valid payload of the registered type.
+func ValidateEvent(e Event) error {
+	schema, ok := eventSchemas[e.Type]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownEventType, e.Type)
+	}
+	if e.ID == "" {
+		return fmt.Errorf("%w: %s event has no ID", ErrInvalidEventPayload, e.Type)
+	}
+	if e.SchemaVersion != schema.version {
+		return fmt.Errorf("%w: %s event has schema version %d, want %d", ErrInvalidEventPayload, e.Type, e.SchemaVersion, schema.version)
+	}
+
+	v := reflect.ValueOf(e.Payload)
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return fmt.Errorf("%w: %s event has a nil payload", ErrInvalidEventPayload, e.Type)
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() || v.Type() != schema.payloadType {
+		return fmt.Errorf("%w: %s event expects a %s payload, got %T", ErrInvalidEventPayload, e.Type, schema.payloadType.Name(), e.Payload)
+	}
+
+	if p, ok := e.Payload.(validation.Validatable); ok {
+		if err := p.Validate(); err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrInvalidEventPayload, e.Type, err)
+		}
+	}
+	return nil
+}
+
+// DecodeEventPayload decodes a JSON payload written at the given schema
+// version into a pointer to the registered payload type, upcasting it to the
+// current version first. It returns the payload and its version.
+func DecodeEventPayload(eventType string, version int, data []byte) (interface{}, int, error) {
+	schema, ok := eventSchemas[eventType]
+	if !ok {
+		return nil, 0, fmt.Errorf("%w: %q", ErrUnknownEventType, eventType)
+	}
+	if version == 0 {
+		// Published before payloads were versioned.
+		version = 1
+	}
+	if version > schema.version {
+		return nil, 0, fmt.Errorf("%w: %s event has schema version %d, newest known is %d", ErrInvalidEventPayload, eventType, version, schema.version)
+	}
+
+	if version < schema.version {
+		var fields map[string]interface{}
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, 0, fmt.Errorf("%w: %s: %v", ErrInvalidEventPayload, eventType, err)
+		}
+		for ; version < schema.version; version++ {
+			upcast, ok := schema.upcasters[version]
+			if !ok {
+				return nil, 0, fmt.Errorf("%w: no upcaster for %s schema version %d", ErrInvalidEventPayload, eventType, version)
+			}
+			fields = upcast(fields)
+		}
+		var err error
+		if data, err = json.Marshal(fields); err != nil {
+			return nil, 0, fmt.Errorf("%w: %s: %v", ErrInvalidEventPayload, eventType, err)
+		}
+	}
+
+	payload := reflect.New(schema.payloadType).Interface()
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, payload); err != nil {
+			return nil, 0, fmt.Errorf("%w: %s: %v", ErrInvalidEventPayload, eventType, err)
+		}
+	}
+	return payload, schema.version, nil
+}
+
+// UnmarshalJSON decodes the payload into its registered type, so subscribers
+// receive typed payloads at the current schema version.
+func (e *Event) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		ID            string
+		Type          string
+		Source        string
+		Timestamp     time.Time
+		Payload       json.RawMessage
+		AggregateID   string
+		SchemaVersion int
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	payload, version, err := DecodeEventPayload(raw.Type, raw.SchemaVersion, raw.Payload)
+	if err != nil {
+		return err
+	}
+
+	*e = Event{
+		ID:            raw.ID,
+		Type:          raw.Type,
+		Source:        raw.Source,
+		Timestamp:     raw.Timestamp,
+		Payload:       payload,
+		AggregateID:   raw.AggregateID,
+		SchemaVersion: version,
+	}
+	return nil
+}
+
+type farmScopedPayload interface {
+	eventFarmID() string
+}
+
+// EventFarmID returns the farm an event belongs to, or "" for events that
+// are not tied to a farm.
+func EventFarmID(e Event) string {
+	if p, ok := e.Payload.(farmScopedPayload); ok {
+		return p.eventFarmID()
+	}
+	return ""
+}
+
+// upcastCroplandV1 renames farmId to farm_id; version 1 sent farmId while the
+// projection read farm_id, so crop stats were never updated.
+func upcastCroplandV1(fields map[string]interface{}) map[string]interface{} {
+	if _, ok := fields["farm_id"]; !ok {
+		fields["farm_id"] = fields["farmId"]
+	}
+	delete(fields, "farmId")
+	delete(fields, "event_type")
+	return fields
+}
+
+// upcastCroplandDeletedV1 renames crop_id to uuid. Version 1 carried no farm,
+// so farm_id stays empty.
+func upcastCroplandDeletedV1(fields map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"uuid":    fields["crop_id"],
+		"farm_id": fields["farm_id"],
+	}
+}
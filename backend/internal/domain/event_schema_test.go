@@ -0,0 +1,57 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewEvent_RejectsInvalidPayloads(t *testing.T) {
+	_, err := NewEvent("farm.renamed", "test", "farm-1", &FarmPayload{UUID: "farm-1"})
+	assert.ErrorIs(t, err, ErrUnknownEventType)
+
+	_, err = NewEvent("farm.created", "test", "farm-1", map[string]interface{}{"uuid": "farm-1"})
+	assert.ErrorIs(t, err, ErrInvalidEventPayload)
+
+	_, err = NewEvent("cropland.deleted", "test", "crop-1", &CroplandDeletedPayload{UUID: "crop-1"})
+	assert.ErrorIs(t, err, ErrInvalidEventPayload, "farm_id is required")
+
+	event, err := NewEvent("cropland.deleted", "test", "crop-1", CroplandDeletedPayload{UUID: "crop-1", FarmID: "farm-1"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, event.SchemaVersion)
+	assert.Equal(t, "farm-1", EventFarmID(event))
+}
+
+func TestEvent_UnmarshalJSONUpcastsCroplandV1(t *testing.T) {
+	data := []byte(`{
+		"ID": "evt-1",
+		"Type": "cropland.created",
+		"AggregateID": "crop-1",
+		"Payload": {"uuid": "crop-1", "name": "Rice", "farmId": "farm-1", "event_type": "cropland.created"}
+	}`)
+
+	var event Event
+	require.NoError(t, json.Unmarshal(data, &event))
+
+	payload, ok := event.Payload.(*CroplandPayload)
+	require.True(t, ok)
+	assert.Equal(t, 2, event.SchemaVersion)
+	assert.Equal(t, "farm-1", payload.FarmID)
+	assert.Equal(t, "Rice", payload.Name)
+}
+
+func TestEvent_JSONRoundTrip(t *testing.T) {
+	event, err := NewEvent("weather.updated", "test", "farm-1", &WeatherUpdatedPayload{FarmID: "farm-1", Lat: 13.7})
+	require.NoError(t, err)
+
+	data, err := json.Marshal(event)
+	require.NoError(t, err)
+
+	var decoded Event
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, event.ID, decoded.ID)
+	assert.Equal(t, event.Payload, decoded.Payload)
+	assert.NoError(t, ValidateEvent(decoded))
+}
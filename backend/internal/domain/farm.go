@@ -8,16 +8,18 @@ import (
 )
 
 type Farm struct {
-	UUID      string     `json:"uuid"`
-	Name      string     `json:"name"`
-	Lat       float64    `json:"lat"`
-	Lon       float64    `json:"lon"`
-	FarmType  string     `json:"farmType,omitempty"`
-	TotalSize string     `json:"totalSize,omitempty"`
-	CreatedAt time.Time  `json:"createdAt"`
-	UpdatedAt time.Time  `json:"updatedAt"`
-	OwnerID   string     `json:"ownerId"`
-	Crops     []Cropland `json:"crops,omitempty"`
+	UUID      string    `json:"uuid"`
+	Name      string    `json:"name"`
+	Lat       float64   `json:"lat"`
+	Lon       float64   `json:"lon"`
+	FarmType  string    `json:"farmType,omitempty"`
+	TotalSize string    `json:"totalSize,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	OwnerID   string    `json:"ownerId"`
+	// Role is the caller's role on the farm, where known.
+	Role  string     `json:"role,omitempty"`
+	Crops []Cropland `json:"crops,omitempty"`
 }
 
 func (f *Farm) Validate() error {
@@ -32,8 +34,10 @@ func (f *Farm) Validate() error {
 type FarmRepository interface {
 	GetByID(context.Context, string) (*Farm, error)
 	GetByOwnerID(context.Context, string) ([]Farm, error)
+	// GetByMemberID returns the farms the user owns or is a member of, with
+	// Role set to the user's role on each.
+	GetByMemberID(context.Context, string) ([]Farm, error)
 	GetAll(context.Context) ([]Farm, error)
 	CreateOrUpdate(context.Context, *Farm) error
 	Delete(context.Context, string) error
-	SetEventPublisher(EventPublisher)
 }
@@ -0,0 +1,129 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+)
+
+// Farm roles, from most to least privileged. The owner is the farm's OwnerID;
+// the other roles are granted through membership.
+const (
+	FarmRoleOwner   = "owner"
+	FarmRoleManager = "manager"
+	FarmRoleWorker  = "worker"
+	FarmRoleViewer  = "viewer"
+)
+
+// FarmPermission is an action on a farm that some roles may take.
+type FarmPermission int
+
+const (
+	// FarmPermView allows reading the farm, its croplands and analytics, and
+	// asking the assistant about them.
+	FarmPermView FarmPermission = iota
+	// FarmPermEditCrops allows creating and updating croplands.
+	FarmPermEditCrops
+	// FarmPermEditFarm allows changing the farm's details.
+	FarmPermEditFarm
+	// FarmPermManageMembers allows inviting, changing and removing members of
+	// a lower role.
+	FarmPermManageMembers
+	// FarmPermDelete allows deleting the farm.
+	FarmPermDelete
+	// FarmPermViewAuditLog allows reading who changed the farm and its data.
+	FarmPermViewAuditLog
+)
+
+var farmRoleRank = map[string]int{
+	FarmRoleViewer:  1,
+	FarmRoleWorker:  2,
+	FarmRoleManager: 3,
+	FarmRoleOwner:   4,
+}
+
+// farmPermissionMinRole is the least privileged role holding each permission.
+var farmPermissionMinRole = map[FarmPermission]string{
+	FarmPermView:          FarmRoleViewer,
+	FarmPermEditCrops:     FarmRoleWorker,
+	FarmPermEditFarm:      FarmRoleManager,
+	FarmPermManageMembers: FarmRoleManager,
+	FarmPermDelete:        FarmRoleOwner,
+	FarmPermViewAuditLog:  FarmRoleOwner,
+}
+
+// FarmRoleAllows reports whether role grants perm. Unknown roles grant nothing.
+func FarmRoleAllows(role string, perm FarmPermission) bool {
+	rank, ok := farmRoleRank[role]
+	return ok && rank >= farmRoleRank[farmPermissionMinRole[perm]]
+}
+
+// CanAssignFarmRole reports whether a member with role actor may grant,
+// change or revoke target. Members manage only roles below their own, and
+// ownership cannot be assigned.
+func CanAssignFarmRole(actor, target string) bool {
+	if target == FarmRoleOwner || !FarmRoleAllows(actor, FarmPermManageMembers) {
+		return false
+	}
+	rank, ok := farmRoleRank[target]
+	return ok && rank < farmRoleRank[actor]
+}
+
+// FarmMember is a user with access to a farm.
+type FarmMember struct {
+	FarmID    string    `json:"farmId"`
+	UserID    string    `json:"userId"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// FarmInvitation offers a role on a farm to whoever holds an email address.
+type FarmInvitation struct {
+	UUID       string     `json:"uuid"`
+	FarmID     string     `json:"farmId"`
+	FarmName   string     `json:"farmName,omitempty"`
+	Email      string     `json:"email"`
+	Role       string     `json:"role"`
+	InvitedBy  string     `json:"invitedBy"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+	AcceptedAt *time.Time `json:"acceptedAt,omitempty"`
+}
+
+func (i *FarmInvitation) Validate() error {
+	return validation.ValidateStruct(i,
+		validation.Field(&i.FarmID, validation.Required, is.UUID),
+		validation.Field(&i.Email, validation.Required, is.Email),
+		validation.Field(&i.Role, validation.Required, validation.In(FarmRoleManager, FarmRoleWorker, FarmRoleViewer)),
+		validation.Field(&i.InvitedBy, validation.Required),
+	)
+}
+
+type FarmMemberRepository interface {
+	// GetRole returns the user's role on the farm, FarmRoleOwner for its
+	// owner, or ErrNotFound if the user has no access.
+	GetRole(ctx context.Context, farmID, userID string) (string, error)
+	// ListMembers returns everyone with access to the farm, owner first.
+	ListMembers(ctx context.Context, farmID string) ([]FarmMember, error)
+	SetRole(ctx context.Context, farmID, userID, role string) error
+	RemoveMember(ctx context.Context, farmID, userID string) error
+
+	CreateInvitation(ctx context.Context, inv *FarmInvitation) error
+	GetInvitation(ctx context.Context, uuid string) (*FarmInvitation, error)
+	// ListPendingInvitations returns the farm's unaccepted, unexpired invitations.
+	ListPendingInvitations(ctx context.Context, farmID string) ([]FarmInvitation, error)
+	// ListInvitationsForEmail returns the unaccepted, unexpired invitations
+	// sent to email, matched case-insensitively.
+	ListInvitationsForEmail(ctx context.Context, email string) ([]FarmInvitation, error)
+	// AcceptInvitation makes userID a member with the invitation's role and
+	// marks it accepted, in one transaction. It returns ErrNotFound if the
+	// invitation is no longer pending.
+	AcceptInvitation(ctx context.Context, invitationID, userID string) error
+	DeleteInvitation(ctx context.Context, uuid string) error
+	// DeleteInvitationsForEmail removes every invitation sent to email,
+	// pending or not.
+	DeleteInvitationsForEmail(ctx context.Context, email string) error
+}
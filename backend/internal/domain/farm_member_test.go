@@ -0,0 +1,26 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFarmRolePermissions(t *testing.T) {
+	assert.True(t, FarmRoleAllows(FarmRoleViewer, FarmPermView))
+	assert.False(t, FarmRoleAllows(FarmRoleViewer, FarmPermEditCrops))
+	assert.True(t, FarmRoleAllows(FarmRoleWorker, FarmPermEditCrops))
+	assert.False(t, FarmRoleAllows(FarmRoleWorker, FarmPermEditFarm))
+	assert.True(t, FarmRoleAllows(FarmRoleManager, FarmPermManageMembers))
+	assert.False(t, FarmRoleAllows(FarmRoleManager, FarmPermDelete))
+	assert.True(t, FarmRoleAllows(FarmRoleOwner, FarmPermDelete))
+	assert.False(t, FarmRoleAllows(FarmRoleManager, FarmPermViewAuditLog))
+	assert.True(t, FarmRoleAllows(FarmRoleOwner, FarmPermViewAuditLog))
+	assert.False(t, FarmRoleAllows("", FarmPermView))
+
+	assert.True(t, CanAssignFarmRole(FarmRoleOwner, FarmRoleManager))
+	assert.True(t, CanAssignFarmRole(FarmRoleManager, FarmRoleWorker))
+	assert.False(t, CanAssignFarmRole(FarmRoleManager, FarmRoleManager))
+	assert.False(t, CanAssignFarmRole(FarmRoleOwner, FarmRoleOwner))
+	assert.False(t, CanAssignFarmRole(FarmRoleWorker, FarmRoleViewer))
+}
@@ -0,0 +1,439 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Geometry types a cropland's GeoFeature may hold.
+const (
+	GeometryPoint        = "Point"
+	GeometryPolygon      = "Polygon"
+	GeometryMultiPolygon = "MultiPolygon"
+)
+
+// maxGeometryPositions bounds the size of a geometry, since checking rings
+// for self-intersection takes time quadratic in their length.
+const maxGeometryPositions = 5000
+
+// minRingArea is the area, in square metres, below which a ring is taken to
+// be a line folded back on itself.
+const minRingArea = 0.01
+
+// earthRadius is the radius, in metres, the area of geometries is computed
+// on; it matches the one map clients measure with.
+const earthRadius = 6378137.0
+
+// Position is a GeoJSON position: longitude, then latitude, in degrees.
+type Position [2]float64
+
+func (p Position) Lng() float64 { return p[0] }
+func (p Position) Lat() float64 { return p[1] }
+
+// Geometry is a GeoJSON Point, Polygon or MultiPolygon (RFC 7946). A Polygon
+// is a list of linear rings, the first its outer boundary and the rest holes;
+// each ring is closed, its last position repeating the first.
+type Geometry struct {
+	Type string
+	// Point is set for a Point.
+	Point Position
+	// Polygons holds the one polygon of a Polygon, or those of a
+	// MultiPolygon.
+	Polygons [][][]Position
+}
+
+type geometryJSON struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+	// Geometry is set when the input is a GeoJSON Feature.
+	Geometry json.RawMessage `json:"geometry"`
+}
+
+// ParseGeometry reads and validates a GeoJSON geometry, or a Feature holding
+// one. Positions may carry an altitude, which is dropped.
+func ParseGeometry(data []byte) (*Geometry, error) {
+	var raw geometryJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, errors.New("geometry must be a GeoJSON object")
+	}
+	if raw.Type == "Feature" {
+		if len(raw.Geometry) == 0 || string(raw.Geometry) == "null" {
+			return nil, errors.New("feature has no geometry")
+		}
+		return ParseGeometry(raw.Geometry)
+	}
+
+	g := &Geometry{Type: raw.Type}
+	var err error
+	switch raw.Type {
+	case GeometryPoint:
+		var coords []float64
+		if err := json.Unmarshal(raw.Coordinates, &coords); err != nil {
+			return nil, errors.New("point coordinates must be a position")
+		}
+		g.Point, err = toPosition(coords)
+	case GeometryPolygon:
+		var coords [][][]float64
+		if err := json.Unmarshal(raw.Coordinates, &coords); err != nil {
+			return nil, errors.New("polygon coordinates must be a list of rings")
+		}
+		var polygon [][]Position
+		polygon, err = toPolygon(coords)
+		g.Polygons = [][][]Position{polygon}
+	case GeometryMultiPolygon:
+		var coords [][][][]float64
+		if err := json.Unmarshal(raw.Coordinates, &coords); err != nil {
+			return nil, errors.New("multipolygon coordinates must be a list of polygons")
+		}
+		if len(coords) == 0 {
+			return nil, errors.New("multipolygon has no polygons")
+		}
+		for i, c := range coords {
+			polygon, perr := toPolygon(c)
+			if perr != nil {
+				return nil, fmt.Errorf("polygon %d: %w", i+1, perr)
+			}
+			g.Polygons = append(g.Polygons, polygon)
+		}
+	case "":
+		return nil, errors.New("geometry has no type")
+	default:
+		return nil, fmt.Errorf("geometry type %q is not supported; use Point, Polygon or MultiPolygon", raw.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return g, g.Validate()
+}
+
+func toPosition(coords []float64) (Position, error) {
+	if len(coords) < 2 || len(coords) > 3 {
+		return Position{}, errors.New("a position must have a longitude and a latitude")
+	}
+	return Position{coords[0], coords[1]}, nil
+}
+
+func toPolygon(coords [][][]float64) ([][]Position, error) {
+	if len(coords) == 0 {
+		return nil, errors.New("polygon has no rings")
+	}
+	polygon := make([][]Position, len(coords))
+	for i, ring := range coords {
+		for _, c := range ring {
+			p, err := toPosition(c)
+			if err != nil {
+				return nil, fmt.Errorf("ring %d: %w", i+1, err)
+			}
+			// Repeated positions, as drawing tools leave on a double
+			// click, would otherwise read as the ring touching itself.
+			if n := len(polygon[i]); n > 0 && polygon[i][n-1] == p {
+				continue
+			}
+			polygon[i] = append(polygon[i], p)
+		}
+	}
+	return polygon, nil
+}
+
+// Validate checks that positions are within range and that every ring is
+// closed and does not cross itself or the other rings of its polygon, that
+// holes lie inside their outer ring and not inside each other, and that the
+// parts of a MultiPolygon do not overlap. Crossings are found treating degrees as planar coordinates, which is
+// exact enough at the size of a field.
+func (g *Geometry) Validate() error {
+	switch g.Type {
+	case GeometryPoint:
+		return validatePosition(g.Point)
+	case GeometryPolygon, GeometryMultiPolygon:
+	default:
+		return fmt.Errorf("geometry type %q is not supported", g.Type)
+	}
+
+	count := 0
+	for _, polygon := range g.Polygons {
+		for _, ring := range polygon {
+			count += len(ring)
+		}
+	}
+	if count > maxGeometryPositions {
+		return fmt.Errorf("geometry has %d positions; at most %d are allowed", count, maxGeometryPositions)
+	}
+
+	for i, polygon := range g.Polygons {
+		if err := validatePolygon(polygon); err != nil {
+			if g.Type == GeometryMultiPolygon {
+				return fmt.Errorf("polygon %d: %w", i+1, err)
+			}
+			return err
+		}
+	}
+	for i := range g.Polygons {
+		for j := i + 1; j < len(g.Polygons); j++ {
+			if polygonsOverlap(g.Polygons[i], g.Polygons[j]) {
+				return fmt.Errorf("polygons %d and %d overlap", i+1, j+1)
+			}
+		}
+	}
+	return nil
+}
+
+func validatePosition(p Position) error {
+	if math.IsNaN(p.Lng()) || p.Lng() < -180 || p.Lng() > 180 {
+		return fmt.Errorf("longitude %v is out of range", p.Lng())
+	}
+	if math.IsNaN(p.Lat()) || p.Lat() < -90 || p.Lat() > 90 {
+		return fmt.Errorf("latitude %v is out of range", p.Lat())
+	}
+	return nil
+}
+
+func validatePolygon(polygon [][]Position) error {
+	if len(polygon) == 0 {
+		return errors.New("polygon has no rings")
+	}
+	for i, ring := range polygon {
+		if len(ring) < 4 {
+			return fmt.Errorf("ring %d needs at least 4 positions", i+1)
+		}
+		if ring[0] != ring[len(ring)-1] {
+			return fmt.Errorf("ring %d is not closed; its last position must repeat the first", i+1)
+		}
+		for _, p := range ring {
+			if err := validatePosition(p); err != nil {
+				return fmt.Errorf("ring %d: %w", i+1, err)
+			}
+		}
+		if math.Abs(ringArea(ring)) < minRingArea {
+			return fmt.Errorf("ring %d encloses no area", i+1)
+		}
+	}
+
+	for i, ring := range polygon {
+		for a := 0; a < len(ring)-1; a++ {
+			// Against the rest of its own ring, skipping the edges it
+			// shares a corner with.
+			for b := a + 2; b < len(ring)-1; b++ {
+				if a == 0 && b == len(ring)-2 {
+					continue
+				}
+				if segmentsIntersect(ring[a], ring[a+1], ring[b], ring[b+1]) {
+					return fmt.Errorf("ring %d intersects itself", i+1)
+				}
+			}
+			// Against the later rings.
+			for j := i + 1; j < len(polygon); j++ {
+				other := polygon[j]
+				for b := 0; b < len(other)-1; b++ {
+					if segmentsIntersect(ring[a], ring[a+1], other[b], other[b+1]) {
+						return fmt.Errorf("rings %d and %d intersect", i+1, j+1)
+					}
+				}
+			}
+		}
+	}
+
+	// Rings no longer cross, so a ring lies inside another exactly when its
+	// first position does.
+	holes := polygon[1:]
+	for i, hole := range holes {
+		if !ringContains(polygon[0], hole[0]) {
+			return fmt.Errorf("ring %d is outside the outer ring", i+2)
+		}
+		for j, other := range holes {
+			if i != j && ringContains(other, hole[0]) {
+				return fmt.Errorf("ring %d is inside ring %d", i+2, j+2)
+			}
+		}
+	}
+	return nil
+}
+
+// polygonsOverlap reports whether two valid polygons share any ground. An
+// island inside the other's hole does not; touching boundaries count as
+// overlapping, as they do between the rings of one polygon.
+func polygonsOverlap(a, b [][]Position) bool {
+	if ringCrossesPolygon(a[0], b) || ringCrossesPolygon(b[0], a) {
+		return true
+	}
+	return polygonCovers(a, b[0][0]) || polygonCovers(b, a[0][0])
+}
+
+// ringCrossesPolygon reports whether an edge of ring meets an edge of any
+// of polygon's rings.
+func ringCrossesPolygon(ring []Position, polygon [][]Position) bool {
+	for _, other := range polygon {
+		for a := 0; a < len(ring)-1; a++ {
+			for b := 0; b < len(other)-1; b++ {
+				if segmentsIntersect(ring[a], ring[a+1], other[b], other[b+1]) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// polygonCovers reports whether p lies inside the polygon's outer ring and
+// outside all of its holes.
+func polygonCovers(polygon [][]Position, p Position) bool {
+	if !ringContains(polygon[0], p) {
+		return false
+	}
+	for _, hole := range polygon[1:] {
+		if ringContains(hole, p) {
+			return false
+		}
+	}
+	return true
+}
+
+// ringContains reports whether p lies inside a closed ring, by casting a ray
+// east from it and counting the edges it crosses.
+func ringContains(ring []Position, p Position) bool {
+	inside := false
+	for i := 0; i < len(ring)-1; i++ {
+		a, b := ring[i], ring[i+1]
+		if (a.Lat() > p.Lat()) != (b.Lat() > p.Lat()) &&
+			p.Lng() < a.Lng()+(p.Lat()-a.Lat())*(b.Lng()-a.Lng())/(b.Lat()-a.Lat()) {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// segmentsIntersect reports whether segments p1p2 and q1q2 share a point.
+func segmentsIntersect(p1, p2, q1, q2 Position) bool {
+	d1 := orientation(q1, q2, p1)
+	d2 := orientation(q1, q2, p2)
+	d3 := orientation(p1, p2, q1)
+	d4 := orientation(p1, p2, q2)
+	if ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) && ((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0)) {
+		return true
+	}
+	return (d1 == 0 && onSegment(q1, q2, p1)) ||
+		(d2 == 0 && onSegment(q1, q2, p2)) ||
+		(d3 == 0 && onSegment(p1, p2, q1)) ||
+		(d4 == 0 && onSegment(p1, p2, q2))
+}
+
+func orientation(a, b, c Position) float64 {
+	return (b.Lng()-a.Lng())*(c.Lat()-a.Lat()) - (b.Lat()-a.Lat())*(c.Lng()-a.Lng())
+}
+
+// onSegment reports whether c, collinear with a and b, lies between them.
+func onSegment(a, b, c Position) bool {
+	return math.Min(a.Lng(), b.Lng()) <= c.Lng() && c.Lng() <= math.Max(a.Lng(), b.Lng()) &&
+		math.Min(a.Lat(), b.Lat()) <= c.Lat() && c.Lat() <= math.Max(a.Lat(), b.Lat())
+}
+
+// Area returns the geodesic area of the geometry in square metres, less that
+// of its holes. A Point has no area.
+func (g *Geometry) Area() float64 {
+	total := 0.0
+	for _, polygon := range g.Polygons {
+		area := math.Abs(ringArea(polygon[0]))
+		for _, hole := range polygon[1:] {
+			area -= math.Abs(ringArea(hole))
+		}
+		total += area
+	}
+	return total
+}
+
+// ringArea returns the signed area of a closed ring on a sphere, after
+// Chamberlain and Duquette, "Some Algorithms for Polygons on a Sphere".
+func ringArea(ring []Position) float64 {
+	n := len(ring) - 1 // the closing position repeats the first
+	if n < 3 {
+		return 0
+	}
+	total := 0.0
+	for i := 0; i < n; i++ {
+		prev := ring[(i+n-1)%n]
+		next := ring[(i+1)%n]
+		total += (radians(next.Lng()) - radians(prev.Lng())) * math.Sin(radians(ring[i].Lat()))
+	}
+	return total * earthRadius * earthRadius / 2
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+// Hectares returns the area in hectares, rounded to the nearest square metre.
+func (g *Geometry) Hectares() float64 {
+	return math.Round(g.Area()) / 10000
+}
+
+func (g Geometry) MarshalJSON() ([]byte, error) {
+	var coords interface{}
+	switch g.Type {
+	case GeometryPoint:
+		coords = g.Point
+	case GeometryPolygon:
+		coords = g.Polygons[0]
+	default:
+		coords = g.Polygons
+	}
+	return json.Marshal(struct {
+		Type        string      `json:"type"`
+		Coordinates interface{} `json:"coordinates"`
+	}{g.Type, coords})
+}
+
+// legacyGeoFeature is the format croplands stored their location in before
+// GeoJSON: a map marker, or a polygon or polyline drawn as a path.
+type legacyGeoFeature struct {
+	Type     string `json:"type"`
+	Position *struct {
+		Lat float64 `json:"lat"`
+		Lng float64 `json:"lng"`
+	} `json:"position"`
+	Path []struct {
+		Lat float64 `json:"lat"`
+		Lng float64 `json:"lng"`
+	} `json:"path"`
+}
+
+// ConvertLegacyGeoFeature turns a location stored in the pre-GeoJSON format
+// into a geometry. A marker becomes a Point. A polygon, or a polyline drawn
+// around a field, becomes a Polygon, closed if it was left open; a path of
+// fewer than three distinct positions becomes a Point at its first one. It
+// returns false if data is not in the legacy format.
+func ConvertLegacyGeoFeature(data []byte) (*Geometry, bool) {
+	var legacy legacyGeoFeature
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return nil, false
+	}
+
+	switch legacy.Type {
+	case "marker":
+		if legacy.Position == nil {
+			return nil, false
+		}
+		return &Geometry{Type: GeometryPoint, Point: Position{legacy.Position.Lng, legacy.Position.Lat}}, true
+	case "polygon", "polyline":
+		var ring []Position
+		for _, p := range legacy.Path {
+			pos := Position{p.Lng, p.Lat}
+			if len(ring) == 0 || ring[len(ring)-1] != pos {
+				ring = append(ring, pos)
+			}
+		}
+		if len(ring) > 1 && ring[0] == ring[len(ring)-1] {
+			ring = ring[:len(ring)-1]
+		}
+		switch {
+		case len(ring) == 0:
+			return nil, false
+		case len(ring) < 3:
+			return &Geometry{Type: GeometryPoint, Point: ring[0]}, true
+		}
+		ring = append(ring, ring[0])
+		return &Geometry{Type: GeometryPolygon, Polygons: [][][]Position{{ring}}}, true
+	default:
+		return nil, false
+	}
+}
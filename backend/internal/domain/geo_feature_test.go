@@ -0,0 +1,136 @@
+package domain
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseGeometry(t *testing.T) {
+	square := `{"type":"Polygon","coordinates":[[[100,13],[100.01,13],[100.01,13.01],[100,13.01],[100,13]]]}`
+
+	g, err := ParseGeometry([]byte(square))
+	require.NoError(t, err)
+	// A cell of a lat/lng grid on a sphere: R² Δλ (sin φ2 − sin φ1).
+	want := earthRadius * earthRadius * radians(0.01) * (math.Sin(radians(13.01)) - math.Sin(radians(13)))
+	assert.InDelta(t, want, g.Area(), 1)
+	assert.InDelta(t, want/10000, g.Hectares(), 0.0001)
+
+	data, err := json.Marshal(g)
+	require.NoError(t, err)
+	assert.JSONEq(t, square, string(data))
+
+	feature, err := ParseGeometry([]byte(`{"type":"Feature","properties":{},"geometry":` + square + `}`))
+	require.NoError(t, err)
+	assert.Equal(t, g, feature)
+
+	holed, err := ParseGeometry([]byte(`{"type":"Polygon","coordinates":[
+		[[100,13],[100.01,13],[100.01,13.01],[100,13.01],[100,13]],
+		[[100.002,13.002],[100.002,13.004],[100.004,13.004],[100.004,13.002],[100.002,13.002]]]}`))
+	require.NoError(t, err)
+	assert.Less(t, holed.Area(), g.Area())
+
+	multi, err := ParseGeometry([]byte(`{"type":"MultiPolygon","coordinates":[
+		[[[100,13],[100.01,13],[100.01,13.01],[100,13.01],[100,13]]],
+		[[[101,13],[101.01,13],[101.01,13.01],[101,13.01],[101,13]]]]}`))
+	require.NoError(t, err)
+	assert.InDelta(t, 2*g.Area(), multi.Area(), 1)
+
+	// An island in a hole of another part is not an overlap.
+	island, err := ParseGeometry([]byte(`{"type":"MultiPolygon","coordinates":[
+		[[[100,13],[100.01,13],[100.01,13.01],[100,13.01],[100,13]],
+		 [[100.002,13.002],[100.002,13.008],[100.008,13.008],[100.008,13.002],[100.002,13.002]]],
+		[[[100.004,13.004],[100.006,13.004],[100.006,13.006],[100.004,13.006],[100.004,13.004]]]]}`))
+	require.NoError(t, err)
+	assert.Less(t, island.Area(), g.Area())
+
+	point, err := ParseGeometry([]byte(`{"type":"Point","coordinates":[100.5,13.7,4]}`))
+	require.NoError(t, err)
+	assert.Equal(t, Position{100.5, 13.7}, point.Point)
+	assert.Zero(t, point.Area())
+
+	// A repeated vertex is not a self-intersection.
+	_, err = ParseGeometry([]byte(`{"type":"Polygon","coordinates":[[[100,13],[100.01,13],[100.01,13],[100.01,13.01],[100,13.01],[100,13]]]}`))
+	assert.NoError(t, err)
+}
+
+func TestParseGeometry_Invalid(t *testing.T) {
+	for name, input := range map[string]string{
+		"not an object":    `[1,2]`,
+		"no type":          `{"coordinates":[1,2]}`,
+		"unsupported type": `{"type":"LineString","coordinates":[[100,13],[100.01,13]]}`,
+		"legacy marker":    `{"type":"marker","position":{"lat":13.8,"lng":100.4}}`,
+		"latitude range":   `{"type":"Point","coordinates":[100,91]}`,
+		"longitude range":  `{"type":"Point","coordinates":[181,13]}`,
+		"short position":   `{"type":"Point","coordinates":[100]}`,
+		"open ring":        `{"type":"Polygon","coordinates":[[[100,13],[100.01,13],[100.01,13.01],[100,13.01]]]}`,
+		"too few":          `{"type":"Polygon","coordinates":[[[100,13],[100.01,13],[100,13]]]}`,
+		"bow tie":          `{"type":"Polygon","coordinates":[[[100,13],[100.01,13.01],[100.01,13],[100,13.01],[100,13]]]}`,
+		"collinear":        `{"type":"Polygon","coordinates":[[[100,13],[100.01,13],[100.02,13],[100,13]]]}`,
+		"crossing hole": `{"type":"Polygon","coordinates":[
+			[[100,13],[100.01,13],[100.01,13.01],[100,13.01],[100,13]],
+			[[100.005,13.005],[100.02,13.005],[100.02,13.006],[100.005,13.006],[100.005,13.005]]]}`,
+		"hole outside shell": `{"type":"Polygon","coordinates":[
+			[[100,13],[100.01,13],[100.01,13.01],[100,13.01],[100,13]],
+			[[100.02,13.002],[100.02,13.004],[100.04,13.004],[100.04,13.002],[100.02,13.002]]]}`,
+		"hole around shell": `{"type":"Polygon","coordinates":[
+			[[100.002,13.002],[100.002,13.004],[100.004,13.004],[100.004,13.002],[100.002,13.002]],
+			[[100,13],[100.01,13],[100.01,13.01],[100,13.01],[100,13]]]}`,
+		"nested holes": `{"type":"Polygon","coordinates":[
+			[[100,13],[100.01,13],[100.01,13.01],[100,13.01],[100,13]],
+			[[100.002,13.002],[100.002,13.008],[100.008,13.008],[100.008,13.002],[100.002,13.002]],
+			[[100.004,13.004],[100.004,13.006],[100.006,13.006],[100.006,13.004],[100.004,13.004]]]}`,
+		"overlapping parts": `{"type":"MultiPolygon","coordinates":[
+			[[[100,13],[100.01,13],[100.01,13.01],[100,13.01],[100,13]]],
+			[[[100.005,13.005],[100.015,13.005],[100.015,13.015],[100.005,13.015],[100.005,13.005]]]]}`,
+		"part inside part": `{"type":"MultiPolygon","coordinates":[
+			[[[100,13],[100.01,13],[100.01,13.01],[100,13.01],[100,13]]],
+			[[[100.002,13.002],[100.002,13.004],[100.004,13.004],[100.004,13.002],[100.002,13.002]]]]}`,
+		"empty multipolygon": `{"type":"MultiPolygon","coordinates":[]}`,
+		"feature without":    `{"type":"Feature","geometry":null}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseGeometry([]byte(input))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestCroplandSetGeoFeature(t *testing.T) {
+	c := Cropland{LandSize: 5}
+	require.NoError(t, c.SetGeoFeature(json.RawMessage(`{"type":"Point","coordinates":[100.5,13.7]}`)))
+	assert.Equal(t, 5.0, c.LandSize)
+	assert.JSONEq(t, `{"type":"Point","coordinates":[100.5,13.7]}`, string(c.GeoFeature))
+
+	require.NoError(t, c.SetGeoFeature(json.RawMessage(`{"type":"Polygon","coordinates":[[[100,13],[100.01,13],[100.01,13.01],[100,13.01],[100,13]]]}`)))
+	assert.InDelta(t, 120.7, c.LandSize, 0.1)
+
+	require.Error(t, c.SetGeoFeature(json.RawMessage(`{"type":"polygon","path":[]}`)))
+
+	require.NoError(t, c.SetGeoFeature(nil))
+	assert.Nil(t, c.GeoFeature)
+}
+
+func TestConvertLegacyGeoFeature(t *testing.T) {
+	g, ok := ConvertLegacyGeoFeature([]byte(`{"type":"marker","position":{"lat":13.84,"lng":100.48}}`))
+	require.True(t, ok)
+	assert.Equal(t, &Geometry{Type: GeometryPoint, Point: Position{100.48, 13.84}}, g)
+
+	g, ok = ConvertLegacyGeoFeature([]byte(`{"type":"polygon","path":[{"lat":13,"lng":100},{"lat":13,"lng":100.01},{"lat":13.01,"lng":100.01}]}`))
+	require.True(t, ok)
+	assert.Equal(t, GeometryPolygon, g.Type)
+	assert.Equal(t, []Position{{100, 13}, {100.01, 13}, {100.01, 13.01}, {100, 13}}, g.Polygons[0][0])
+	assert.NoError(t, g.Validate())
+
+	g, ok = ConvertLegacyGeoFeature([]byte(`{"type":"polyline","path":[{"lat":13,"lng":100},{"lat":13,"lng":100.01}]}`))
+	require.True(t, ok)
+	assert.Equal(t, &Geometry{Type: GeometryPoint, Point: Position{100, 13}}, g)
+
+	_, ok = ConvertLegacyGeoFeature([]byte(`{"type":"Point","coordinates":[100,13]}`))
+	assert.False(t, ok)
+	_, ok = ConvertLegacyGeoFeature([]byte(`{"type":"polygon","path":[]}`))
+	assert.False(t, ok)
+}
@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// LinkedIdentity ties an account at an OpenID Connect provider to a user.
+// Subject is the provider's stable ID for the account; Email is only what the
+// provider reported when the identity was linked.
+type LinkedIdentity struct {
+	UUID       string     `json:"uuid"`
+	UserID     string     `json:"userId"`
+	Provider   string     `json:"provider"`
+	Subject    string     `json:"-"`
+	Email      string     `json:"email,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+}
+
+func (i *LinkedIdentity) Validate() error {
+	return validation.ValidateStruct(i,
+		validation.Field(&i.UserID, validation.Required),
+		validation.Field(&i.Provider, validation.Required),
+		validation.Field(&i.Subject, validation.Required),
+	)
+}
+
+type IdentityRepository interface {
+	// GetByProviderSubject returns the identity a provider account is linked
+	// as, or ErrNotFound.
+	GetByProviderSubject(ctx context.Context, provider, subject string) (*LinkedIdentity, error)
+	ListByUserID(ctx context.Context, userID string) ([]LinkedIdentity, error)
+	// Link stores a new identity. It returns ErrConflict if the provider
+	// account is linked to any user, or the user already has an identity at
+	// the provider.
+	Link(ctx context.Context, identity *LinkedIdentity) error
+	// Unlink removes the user's identity at provider, or returns ErrNotFound.
+	Unlink(ctx context.Context, userID, provider string) error
+	// Touch records that the identity was just used to sign in.
+	Touch(ctx context.Context, uuid string) error
+}
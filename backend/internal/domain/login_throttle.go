@@ -0,0 +1,30 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// LoginThrottle counts the recent failed sign-ins of one account or client
+// address.
+type LoginThrottle struct {
+	Key          string
+	Failures     int
+	LastFailedAt time.Time
+	LockedUntil  *time.Time
+}
+
+// IsLocked reports whether the key is locked out at now.
+func (t *LoginThrottle) IsLocked(now time.Time) bool {
+	return t.LockedUntil != nil && now.Before(*t.LockedUntil)
+}
+
+type LoginThrottleRepository interface {
+	// Get returns the key's record, or ErrNotFound if it has none.
+	Get(ctx context.Context, key string) (*LoginThrottle, error)
+	// RecordFailure counts a failure for key and returns the updated record.
+	// Failures before windowStart are forgotten.
+	RecordFailure(ctx context.Context, key string, windowStart time.Time) (*LoginThrottle, error)
+	Lock(ctx context.Context, key string, until time.Time) error
+	Delete(ctx context.Context, key string) error
+}
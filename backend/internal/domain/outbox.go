@@ -0,0 +1,25 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// OutboxMessage is an event waiting in the transactional outbox to be
+// published to the event bus.
+type OutboxMessage struct {
+	Event     Event
+	Attempts  int
+	CreatedAt time.Time
+}
+
+type OutboxRepository interface {
+	// ClaimPending locks up to limit undelivered messages for the lease
+	// duration so that concurrent relays do not publish the same rows. A
+	// message whose payload cannot be decoded is not returned; it is set
+	// aside with the error recorded and is not claimed again.
+	ClaimPending(ctx context.Context, limit int, lease time.Duration) ([]OutboxMessage, error)
+	MarkDelivered(ctx context.Context, eventID string) error
+	MarkFailed(ctx context.Context, eventID string, cause error, nextAttemptAt time.Time) error
+	DeleteDelivered(ctx context.Context, olderThan time.Time) (int64, error)
+}
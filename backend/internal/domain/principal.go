@@ -0,0 +1,82 @@
+package domain
+
+import "context"
+
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// Principal is the authenticated caller of a request: a user signed in with
+// an access token, or a machine client using one of their API keys.
+type Principal struct {
+	UserID    string
+	Role      string
+	SessionID string
+	// TwoFactor is set when the session was opened with a second factor.
+	TwoFactor bool
+
+	// APIKeyID is set when the caller authenticated with an API key, which
+	// limits it to Scopes and, if FarmID is set, to that farm.
+	APIKeyID string
+	Scopes   []string
+	FarmID   string
+}
+
+func (p Principal) IsAdmin() bool {
+	return p.Role == RoleAdmin
+}
+
+// HasScope reports whether the caller may use operations requiring scope.
+// Users signed in with an access token hold every scope.
+func (p Principal) HasScope(scope string) bool {
+	if p.APIKeyID == "" {
+		return true
+	}
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// CanAccessFarm reports whether the caller's credentials reach farmID. It
+// does not check the user's role on the farm.
+func (p Principal) CanAccessFarm(farmID string) bool {
+	return p.FarmID == "" || p.FarmID == farmID
+}
+
+type principalContextKey struct{}
+
+// ContextWithPrincipal returns a copy of ctx carrying the caller.
+func ContextWithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, p)
+}
+
+// PrincipalFromContext returns the caller the auth middleware stored in ctx,
+// if the request was authenticated.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalContextKey{}).(Principal)
+	return p, ok
+}
+
+// RequestInfo describes where a request came from, for the audit log.
+type RequestInfo struct {
+	IPAddress string
+	UserAgent string
+}
+
+type requestInfoContextKey struct{}
+
+// ContextWithRequestInfo returns a copy of ctx carrying info.
+func ContextWithRequestInfo(ctx context.Context, info RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoContextKey{}, info)
+}
+
+// RequestInfoFromContext returns the RequestInfo stored in ctx, or the zero
+// value outside a request.
+func RequestInfoFromContext(ctx context.Context) RequestInfo {
+	info, _ := ctx.Value(requestInfoContextKey{}).(RequestInfo)
+	return info
+}
@@ -0,0 +1,45 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrRefreshTokenReused is returned when a refresh token that was already
+// rotated is presented again; the session it belonged to is revoked, since
+// either the client or an attacker holds a stolen copy.
+var ErrRefreshTokenReused = errors.New("refresh token was already used")
+
+// Session is a login on one device. Access tokens carry its ID, and its
+// refresh token is exchanged for new access tokens until the session expires
+// or is revoked.
+type Session struct {
+	UUID             string     `json:"uuid"`
+	UserID           string     `json:"userId"`
+	RefreshTokenHash string     `json:"-"`
+	UserAgent        string     `json:"userAgent"`
+	TwoFactor        bool       `json:"twoFactor"`
+	CreatedAt        time.Time  `json:"createdAt"`
+	LastUsedAt       time.Time  `json:"lastUsedAt"`
+	ExpiresAt        time.Time  `json:"expiresAt"`
+	RevokedAt        *time.Time `json:"revokedAt,omitempty"`
+}
+
+type SessionRepository interface {
+	Create(ctx context.Context, s *Session) error
+	GetByID(ctx context.Context, uuid string) (*Session, error)
+	// GetActiveByUserID returns the user's sessions that are neither revoked
+	// nor expired, most recently used first.
+	GetActiveByUserID(ctx context.Context, userID string) ([]Session, error)
+	// Rotate replaces the refresh token whose hash is tokenHash with newHash
+	// and extends the session to expiresAt. It returns ErrNotFound if no
+	// active session holds the token, and ErrRefreshTokenReused, after
+	// revoking the session, if the token was already rotated.
+	Rotate(ctx context.Context, tokenHash, newHash string, expiresAt time.Time) (*Session, error)
+	// IsActive reports whether the session exists and is neither revoked nor
+	// expired.
+	IsActive(ctx context.Context, uuid string) (bool, error)
+	Revoke(ctx context.Context, uuid string) error
+	RevokeAllForUser(ctx context.Context, userID string) error
+}
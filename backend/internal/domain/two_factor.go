@@ -0,0 +1,43 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// RecoveryCodeCount is how many recovery codes a user is given at a time.
+const RecoveryCodeCount = 10
+
+// TwoFactor is a user's TOTP enrolment. It is pending until EnabledAt is set.
+type TwoFactor struct {
+	UserID       string     `json:"-"`
+	Secret       string     `json:"-"`
+	EnabledAt    *time.Time `json:"enabledAt,omitempty"`
+	LastUsedStep int64      `json:"-"`
+	CreatedAt    time.Time  `json:"createdAt"`
+}
+
+func (t *TwoFactor) IsEnabled() bool {
+	return t != nil && t.EnabledAt != nil
+}
+
+type TwoFactorRepository interface {
+	// Get returns the user's enrolment, or ErrNotFound if they have none.
+	Get(ctx context.Context, userID string) (*TwoFactor, error)
+	// SavePending starts a new enrolment with secret, replacing any pending
+	// one. It returns ErrConflict if two-factor authentication is enabled.
+	SavePending(ctx context.Context, userID, secret string) error
+	// Enable turns on the pending enrolment and replaces the user's recovery
+	// codes with codeHashes, in one transaction.
+	Enable(ctx context.Context, userID string, codeHashes []string) error
+	// Disable removes the enrolment and its recovery codes.
+	Disable(ctx context.Context, userID string) error
+	// UseStep records that a code for the time step was accepted. It returns
+	// false if that step or a later one was already used.
+	UseStep(ctx context.Context, userID string, step int64) (bool, error)
+	ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes []string) error
+	// UseRecoveryCode marks an unused recovery code as used and reports
+	// whether there was one.
+	UseRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error)
+	CountRecoveryCodes(ctx context.Context, userID string) (int, error)
+}
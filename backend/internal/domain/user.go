@@ -19,6 +19,24 @@ type User struct {
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
 	IsActive  bool      `json:"isActive"`
+	// Role is RoleUser or RoleAdmin. Access tokens carry it from sign-in.
+	Role string `json:"role"`
+	// EmailVerifiedAt is when the user proved they own Email, if they have.
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt,omitempty"`
+	// PasswordSet is false for users created by an identity provider sign-in,
+	// whose password is random, until they choose one.
+	PasswordSet bool `json:"passwordSet"`
+	// DeletionScheduledAt is when the account is due to be deleted, if the
+	// user asked for that. Until then they can sign in and cancel.
+	DeletionScheduledAt *time.Time `json:"deletionScheduledAt,omitempty"`
+}
+
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
 }
 
 func (u *User) NormalizedUsername() string {
@@ -57,9 +75,30 @@ func (u *User) Validate() error {
 		})),
 		validation.Field(&u.Password, validation.Required, validation.Length(6, 100)),
 		validation.Field(&u.Email, validation.Required, is.Email),
+		validation.Field(&u.Role, validation.In(RoleUser, RoleAdmin)),
 	)
 }
 
+// UserFilter selects users for the admin listing. Users are listed newest
+// first; BeforeID continues a listing after the last user of a page.
+type UserFilter struct {
+	// Query matches part of the email address or username.
+	Query    string
+	Role     string
+	IsActive *bool
+	BeforeID int64
+	Limit    int
+}
+
+// AccountDeletionStores are the repositories an account deletion cleans up,
+// bound to the transaction that deletes the user.
+type AccountDeletionStores struct {
+	Farms   FarmRepository
+	Members FarmMemberRepository
+	Audit   AuditLogRepository
+	Events  EventStore
+}
+
 type UserRepository interface {
 	GetByID(context.Context, int64) (User, error)
 	GetByUUID(context.Context, string) (User, error)
@@ -67,4 +106,20 @@ type UserRepository interface {
 	GetByEmail(context.Context, string) (User, error)
 	CreateOrUpdate(context.Context, *User) error
 	Delete(context.Context, int64) error
+	// SetActive deactivates or reactivates the user, leaving the rest of
+	// the row as it is.
+	SetActive(ctx context.Context, userID string, active bool) error
+	// SetDeletionScheduledAt schedules the user's deletion, or cancels it if
+	// at is nil, leaving the rest of the row as it is. CreateOrUpdate only
+	// sets the schedule of a new user.
+	SetDeletionScheduledAt(ctx context.Context, userID string, at *time.Time) error
+	// GetDueForDeletion returns the users whose scheduled deletion is due
+	// by now.
+	GetDueForDeletion(ctx context.Context, now time.Time) ([]User, error)
+	// DeleteIfDue deletes the user if their deletion is still scheduled at
+	// or before now, running cleanup first in the same transaction. The row
+	// is locked, skipping users another caller is deleting or updating. It
+	// returns false if the user was not deleted for any of those reasons.
+	DeleteIfDue(ctx context.Context, userID string, now time.Time, cleanup func(User, AccountDeletionStores) error) (bool, error)
+	List(ctx context.Context, filter UserFilter) ([]User, error)
 }
@@ -0,0 +1,100 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"regexp"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+)
+
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// Webhook is an endpoint a user registered to receive events. Empty
+// EventTypes or FarmIDs match every event type or every farm of the owner.
+type Webhook struct {
+	UUID                string     `json:"uuid"`
+	OwnerID             string     `json:"ownerId"`
+	URL                 string     `json:"url"`
+	Secret              string     `json:"-"`
+	EventTypes          []string   `json:"eventTypes"`
+	FarmIDs             []string   `json:"farmIds"`
+	Active              bool       `json:"active"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	DisabledAt          *time.Time `json:"disabledAt,omitempty"`
+	CreatedAt           time.Time  `json:"createdAt"`
+	UpdatedAt           time.Time  `json:"updatedAt"`
+}
+
+// webhookEventTypePattern accepts event types and topic patterns such as
+// "farm.*" or "#".
+var webhookEventTypePattern = regexp.MustCompile(`^([a-z_]+|\*|#)(\.([a-z_]+|\*|#))*$`)
+
+func (w *Webhook) Validate() error {
+	return validation.ValidateStruct(w,
+		validation.Field(&w.OwnerID, validation.Required),
+		validation.Field(&w.URL, validation.Required, is.URL, validation.By(httpURL)),
+		validation.Field(&w.Secret, validation.Required),
+		validation.Field(&w.EventTypes, validation.Each(validation.Match(webhookEventTypePattern))),
+		validation.Field(&w.FarmIDs, validation.Each(is.UUID)),
+	)
+}
+
+func httpURL(value interface{}) error {
+	u, err := url.Parse(value.(string))
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.New("must be an http or https URL")
+	}
+	return nil
+}
+
+// WebhookDelivery is one event queued for, or delivered to, a webhook.
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	WebhookID      string          `json:"webhookId"`
+	EventID        string          `json:"eventId"`
+	EventType      string          `json:"eventType"`
+	Payload        json.RawMessage `json:"-"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"nextAttemptAt"`
+	LastStatusCode *int            `json:"lastStatusCode,omitempty"`
+	LastError      *string         `json:"lastError,omitempty"`
+	CreatedAt      time.Time       `json:"createdAt"`
+	DeliveredAt    *time.Time      `json:"deliveredAt,omitempty"`
+}
+
+type WebhookRepository interface {
+	GetByID(ctx context.Context, uuid string) (*Webhook, error)
+	GetByOwnerID(ctx context.Context, ownerID string) ([]Webhook, error)
+	// CreateOrUpdate saves a webhook. Re-activating a disabled webhook
+	// resets its failure count.
+	CreateOrUpdate(ctx context.Context, w *Webhook) error
+	Delete(ctx context.Context, uuid string) error
+
+	// EnqueueDelivery queues an event for a webhook; an event already queued
+	// for that webhook is ignored.
+	EnqueueDelivery(ctx context.Context, d *WebhookDelivery) error
+	// ClaimDueDeliveries locks up to limit pending deliveries of active
+	// webhooks that are due, for the lease duration.
+	ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]WebhookDelivery, error)
+	// MarkDelivered records a successful delivery and resets the webhook's
+	// failure count.
+	MarkDelivered(ctx context.Context, deliveryID int64, statusCode int) error
+	// MarkFailed records a failed attempt. A nil nextAttemptAt gives up on
+	// the delivery. The webhook is disabled once it has failed
+	// disableAfter times in a row; the returned flag reports that.
+	MarkFailed(ctx context.Context, deliveryID int64, statusCode *int, cause string, nextAttemptAt *time.Time, disableAfter int) (bool, error)
+	ListDeliveries(ctx context.Context, webhookID string, limit int) ([]WebhookDelivery, error)
+}
@@ -2,35 +2,137 @@ package event
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/forfarm/backend/internal/domain"
 )
 
+const (
+	aggregatorSource = "event-aggregator"
+
+	// statusWeatherWindow is how long a weather observation counts towards
+	// the farm status; the weather updater refreshes it well within that.
+	statusWeatherWindow = 3 * time.Hour
+	// statusSweepInterval is how often farms are re-evaluated so expired
+	// weather stops affecting their status without a new event.
+	statusSweepInterval = 5 * time.Minute
+)
+
+// Weather thresholds, in °C, m/s and mm of rain in the last hour.
+const (
+	heatWarningCelsius   = 35.0
+	heatCriticalCelsius  = 40.0
+	frostWarningCelsius  = 2.0
+	frostCriticalCelsius = -2.0
+	windWarningSpeed     = 10.0
+	windCriticalSpeed    = 20.0
+	rainWarningVolume    = 10.0
+	rainCriticalVolume   = 30.0
+)
+
+// Inventory status IDs seeded by the inventory_status migration.
+const (
+	lowStockStatusID   = 2
+	outOfStockStatusID = 3
+)
+
+// problemCropStatuses maps cropland statuses that need attention to the farm
+// status they cause.
+var problemCropStatuses = map[string]string{
+	"diseased": domain.FarmStatusWarning,
+	"damaged":  domain.FarmStatusWarning,
+	"failed":   domain.FarmStatusCritical,
+}
+
+var statusSeverity = map[string]int{
+	domain.FarmStatusOK:       0,
+	domain.FarmStatusWarning:  1,
+	domain.FarmStatusCritical: 2,
+}
+
+// EventAggregator derives an overall status for each farm from recent
+// weather, crop problems and the owner's stock levels, and publishes
+// farm.status_changed whenever that status changes. Its state lives in
+// memory; the first time a farm is seen it is seeded from the status last
+// persisted in farm analytics, the farm's croplands and the owner's
+// inventory, then kept up to date by events.
 type EventAggregator struct {
 	sourceSubscriber domain.EventSubscriber
 	targetPublisher  domain.EventPublisher
+	analyticsRepo    domain.AnalyticsRepository
+	croplandRepo     domain.CroplandRepository
+	inventoryRepo    domain.InventoryRepository
 	logger           *slog.Logger
+
+	// mu guards the state below. It is never held across repository calls
+	// or publishing.
+	mu    sync.Mutex
+	farms map[string]*farmSignals
+	// stock holds the low and out of stock items of each user whose
+	// inventory has been loaded.
+	stock map[string]map[string]statusSignal
+	now   func() time.Time
+}
+
+type farmSignals struct {
+	// publishMu serializes evaluating and publishing the farm's status, so
+	// its changes are published in order. It is taken before mu.
+	publishMu sync.Mutex
+
+	ownerID string
+	status  string // last published or persisted status, "" if unknown
+	weather *weatherSignal
+	crops   map[string]statusSignal
+}
+
+type statusSignal struct {
+	status string
+	reason string
+}
+
+type weatherSignal struct {
+	statusSignal
+	observedAt time.Time
 }
 
 func NewEventAggregator(
 	sourceSubscriber domain.EventSubscriber,
 	targetPublisher domain.EventPublisher,
+	analyticsRepo domain.AnalyticsRepository,
+	croplandRepo domain.CroplandRepository,
+	inventoryRepo domain.InventoryRepository,
 	logger *slog.Logger,
 ) *EventAggregator {
+	if logger == nil {
+		logger = slog.Default()
+	}
 	return &EventAggregator{
 		sourceSubscriber: sourceSubscriber,
 		targetPublisher:  targetPublisher,
+		analyticsRepo:    analyticsRepo,
+		croplandRepo:     croplandRepo,
+		inventoryRepo:    inventoryRepo,
 		logger:           logger,
+		farms:            make(map[string]*farmSignals),
+		stock:            make(map[string]map[string]statusSignal),
+		now:              time.Now,
 	}
 }
 
+// Start subscribes to the source events and re-evaluates farms periodically
+// until ctx is done. Every instance keeps its own state, so it uses
+// transient subscriptions to see every event rather than share a queue.
 func (a *EventAggregator) Start(ctx context.Context) error {
-	// Subscribe to fine-grained events
 	eventTypes := []string{
 		"farm.created", "farm.updated", "farm.deleted",
-		"weather.updated", "inventory.changed", "marketplace.transaction",
+		"weather.updated",
+		"cropland.created", "cropland.updated", "cropland.deleted",
+		"inventory.item.created", "inventory.item.updated", "inventory.item.deleted",
 	}
 
 	for _, eventType := range eventTypes {
@@ -39,26 +141,344 @@ func (a *EventAggregator) Start(ctx context.Context) error {
 		}
 	}
 
+	go func() {
+		ticker := time.NewTicker(statusSweepInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				a.sweep(ctx)
+			}
+		}
+	}()
+
 	return nil
 }
 
 func (a *EventAggregator) handleEvent(event domain.Event) error {
-	// Logic to aggregate events
-	// For example, combine farm and weather events into a farm status event
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	switch payload := event.Payload.(type) {
+	case *domain.FarmPayload:
+		a.load(ctx, payload.UUID)
+		a.mu.Lock()
+		if farm, ok := a.farms[payload.UUID]; ok {
+			farm.ownerID = payload.OwnerID
+		}
+		a.mu.Unlock()
+		a.loadStock(ctx, payload.OwnerID)
+		return a.evaluate(ctx, payload.UUID)
+
+	case *domain.FarmDeletedPayload:
+		a.mu.Lock()
+		delete(a.farms, payload.UUID)
+		a.mu.Unlock()
+		return nil
+
+	case *domain.WeatherUpdatedPayload:
+		observedAt := event.Timestamp
+		if payload.ObservedAt != nil {
+			observedAt = *payload.ObservedAt
+		}
+		a.load(ctx, payload.FarmID)
+		a.mu.Lock()
+		if farm, ok := a.farms[payload.FarmID]; ok {
+			farm.weather = &weatherSignal{
+				statusSignal: weatherStatus(&payload.WeatherData),
+				observedAt:   observedAt,
+			}
+		}
+		a.mu.Unlock()
+		return a.evaluate(ctx, payload.FarmID)
+
+	case *domain.CroplandPayload:
+		a.load(ctx, payload.FarmID)
+		a.mu.Lock()
+		if farm, ok := a.farms[payload.FarmID]; ok {
+			setCropSignal(farm.crops, payload.UUID, payload.Name, payload.Status)
+		}
+		a.mu.Unlock()
+		return a.evaluate(ctx, payload.FarmID)
+
+	case *domain.CroplandDeletedPayload:
+		a.mu.Lock()
+		farm, ok := a.farms[payload.FarmID]
+		if ok {
+			delete(farm.crops, payload.UUID)
+		}
+		a.mu.Unlock()
+		if !ok {
+			return nil
+		}
+		return a.evaluate(ctx, payload.FarmID)
+
+	case *domain.InventoryItemPayload:
+		a.loadStock(ctx, payload.UserID)
+		a.mu.Lock()
+		if items, ok := a.stock[payload.UserID]; ok {
+			setStockSignal(items, payload.ID, payload.Name, payload.StatusID, payload.Quantity)
+		}
+		a.mu.Unlock()
+		return a.evaluateOwner(ctx, payload.UserID)
+
+	case *domain.InventoryItemDeletedPayload:
+		a.loadStock(ctx, payload.UserID)
+		a.mu.Lock()
+		delete(a.stock[payload.UserID], payload.ItemID)
+		a.mu.Unlock()
+		return a.evaluateOwner(ctx, payload.UserID)
+	}
+	return nil
+}
+
+// setCropSignal records whether a cropland's status needs attention.
+func setCropSignal(crops map[string]statusSignal, id, name, cropStatus string) {
+	if status, ok := problemCropStatuses[strings.ToLower(cropStatus)]; ok {
+		crops[id] = statusSignal{status: status, reason: fmt.Sprintf("crop %q is %s", name, strings.ToLower(cropStatus))}
+	} else {
+		delete(crops, id)
+	}
+}
+
+// setStockSignal records whether an inventory item is low or out of stock.
+func setStockSignal(items map[string]statusSignal, id, name string, statusID int, quantity float64) {
+	switch {
+	case statusID == outOfStockStatusID || quantity <= 0:
+		items[id] = statusSignal{status: domain.FarmStatusCritical, reason: fmt.Sprintf("%s is out of stock", name)}
+	case statusID == lowStockStatusID:
+		items[id] = statusSignal{status: domain.FarmStatusWarning, reason: fmt.Sprintf("%s is low on stock", name)}
+	default:
+		delete(items, id)
+	}
+}
+
+// load seeds the state of a farm the first time it is seen, from the
+// persisted farm analytics and the farm's croplands, and then the owner's
+// stock. The repositories are read without holding mu; if another event
+// seeded the farm meanwhile, that state is kept.
+func (a *EventAggregator) load(ctx context.Context, farmID string) {
+	a.mu.Lock()
+	_, ok := a.farms[farmID]
+	a.mu.Unlock()
+	if ok {
+		return
+	}
+
+	farm := &farmSignals{crops: make(map[string]statusSignal)}
+	if a.analyticsRepo != nil {
+		analytics, err := a.analyticsRepo.GetFarmAnalytics(ctx, farmID)
+		switch {
+		case errors.Is(err, domain.ErrNotFound):
+		case err != nil:
+			a.logger.Warn("Failed to load farm analytics, status is unknown until re-evaluated", "farm_id", farmID, "error", err)
+		default:
+			farm.ownerID = analytics.OwnerID
+			if analytics.OverallStatus != nil {
+				farm.status = *analytics.OverallStatus
+			}
+		}
+	}
+	if a.croplandRepo != nil {
+		croplands, err := a.croplandRepo.GetByFarmID(ctx, farmID)
+		if err != nil {
+			a.logger.Warn("Failed to load croplands, crop problems are unknown until they change", "farm_id", farmID, "error", err)
+		}
+		for _, c := range croplands {
+			setCropSignal(farm.crops, c.UUID, c.Name, c.Status)
+		}
+	}
+
+	a.mu.Lock()
+	if _, ok := a.farms[farmID]; !ok {
+		a.farms[farmID] = farm
+	}
+	a.mu.Unlock()
+
+	a.loadStock(ctx, farm.ownerID)
+}
+
+// loadStock seeds the stock signals of a user from their inventory the first
+// time the user is seen. Like load, it reads without holding mu.
+func (a *EventAggregator) loadStock(ctx context.Context, userID string) {
+	if userID == "" {
+		return
+	}
+	a.mu.Lock()
+	_, ok := a.stock[userID]
+	a.mu.Unlock()
+	if ok {
+		return
+	}
+
+	items := make(map[string]statusSignal)
+	if a.inventoryRepo != nil {
+		inventory, err := a.inventoryRepo.GetByUserID(ctx, userID, domain.InventoryFilter{}, domain.InventorySort{})
+		if err != nil {
+			a.logger.Warn("Failed to load inventory, stock levels are unknown until they change", "user_id", userID, "error", err)
+		}
+		for _, i := range inventory {
+			setStockSignal(items, i.ID, i.Name, i.StatusID, i.Quantity)
+		}
+	}
+
+	a.mu.Lock()
+	if _, ok := a.stock[userID]; !ok {
+		a.stock[userID] = items
+	}
+	a.mu.Unlock()
+}
 
-	if event.Type == "farm.created" || event.Type == "farm.updated" {
-		// Create a coarse-grained event
-		aggregatedEvent := domain.Event{
-			ID:          event.ID,
-			Type:        "farm.status_changed",
-			Source:      "event-aggregator",
-			Timestamp:   time.Now(),
-			Payload:     event.Payload,
-			AggregateID: event.AggregateID,
+// evaluateOwner re-evaluates every known farm of a user.
+func (a *EventAggregator) evaluateOwner(ctx context.Context, ownerID string) error {
+	a.mu.Lock()
+	var farmIDs []string
+	for farmID, farm := range a.farms {
+		if farm.ownerID == ownerID {
+			farmIDs = append(farmIDs, farmID)
 		}
+	}
+	a.mu.Unlock()
 
-		return a.targetPublisher.Publish(context.Background(), aggregatedEvent)
+	var errs []error
+	for _, farmID := range farmIDs {
+		errs = append(errs, a.evaluate(ctx, farmID))
+	}
+	return errors.Join(errs...)
+}
+
+// evaluate computes the status of a farm and publishes farm.status_changed
+// if it differs from the last one. Evaluations of one farm are serialized by
+// its publishMu, and mu is released while publishing.
+func (a *EventAggregator) evaluate(ctx context.Context, farmID string) error {
+	a.mu.Lock()
+	farm, ok := a.farms[farmID]
+	a.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	farm.publishMu.Lock()
+	defer farm.publishMu.Unlock()
+
+	a.mu.Lock()
+	if a.farms[farmID] != farm {
+		// Deleted, or deleted and seen again, while waiting.
+		a.mu.Unlock()
+		return nil
+	}
+	previous := farm.status
+	status, reasons := a.computeStatus(farm)
+	a.mu.Unlock()
+	if status == previous {
+		return nil
+	}
+
+	event, err := domain.NewEvent("farm.status_changed", aggregatorSource, farmID, &domain.FarmStatusChangedPayload{
+		FarmID:         farmID,
+		Status:         status,
+		PreviousStatus: previous,
+		Reasons:        reasons,
+	})
+	if err != nil {
+		return err
+	}
+	if err := a.targetPublisher.Publish(ctx, event); err != nil {
+		// The status stays unchanged, so the next evaluation retries.
+		a.logger.Error("Failed to publish farm status change", "farm_id", farmID, "status", status, "error", err)
+		return fmt.Errorf("failed to publish farm.status_changed for farm %s: %w", farmID, err)
 	}
 
+	a.logger.Info("Farm status changed", "farm_id", farmID, "from", previous, "to", status, "reasons", reasons)
+	a.mu.Lock()
+	farm.status = status
+	a.mu.Unlock()
 	return nil
 }
+
+// computeStatus returns the worst status among the signals of a farm and
+// the reasons for it. Callers hold mu.
+func (a *EventAggregator) computeStatus(farm *farmSignals) (string, []string) {
+	signals := make([]statusSignal, 0, len(farm.crops)+1)
+	if farm.weather != nil && a.now().Sub(farm.weather.observedAt) <= statusWeatherWindow {
+		signals = append(signals, farm.weather.statusSignal)
+	}
+	for _, s := range farm.crops {
+		signals = append(signals, s)
+	}
+	if farm.ownerID != "" {
+		for _, s := range a.stock[farm.ownerID] {
+			signals = append(signals, s)
+		}
+	}
+
+	status := domain.FarmStatusOK
+	for _, s := range signals {
+		if statusSeverity[s.status] > statusSeverity[status] {
+			status = s.status
+		}
+	}
+	var reasons []string
+	for _, s := range signals {
+		if s.status != domain.FarmStatusOK {
+			reasons = append(reasons, s.reason)
+		}
+	}
+	return status, reasons
+}
+
+// sweep re-evaluates farms with weather signals, so statuses caused by
+// weather that left the window are cleared.
+func (a *EventAggregator) sweep(ctx context.Context) {
+	a.mu.Lock()
+	var farmIDs []string
+	for farmID, farm := range a.farms {
+		if farm.weather != nil {
+			farmIDs = append(farmIDs, farmID)
+		}
+	}
+	a.mu.Unlock()
+
+	for _, farmID := range farmIDs {
+		if err := a.evaluate(ctx, farmID); err != nil {
+			a.logger.Warn("Failed to re-evaluate farm status", "farm_id", farmID, "error", err)
+		}
+	}
+}
+
+// weatherStatus rates a weather observation against the heat, frost, wind
+// and rain thresholds.
+func weatherStatus(w *domain.WeatherData) statusSignal {
+	signal := statusSignal{status: domain.FarmStatusOK}
+	var reasons []string
+	check := func(value *float64, exceeds func(v, limit float64) bool, warning, critical float64, format string) {
+		if value == nil {
+			return
+		}
+		status := ""
+		switch {
+		case exceeds(*value, critical):
+			status = domain.FarmStatusCritical
+		case exceeds(*value, warning):
+			status = domain.FarmStatusWarning
+		default:
+			return
+		}
+		if statusSeverity[status] > statusSeverity[signal.status] {
+			signal.status = status
+		}
+		reasons = append(reasons, fmt.Sprintf(format, *value))
+	}
+	above := func(v, limit float64) bool { return v >= limit }
+	below := func(v, limit float64) bool { return v <= limit }
+
+	check(w.TempCelsius, above, heatWarningCelsius, heatCriticalCelsius, "heat: %.1f°C")
+	check(w.TempCelsius, below, frostWarningCelsius, frostCriticalCelsius, "frost risk: %.1f°C")
+	check(w.WindSpeed, above, windWarningSpeed, windCriticalSpeed, "strong wind: %.1f m/s")
+	check(w.RainVolume1h, above, rainWarningVolume, rainCriticalVolume, "heavy rain: %.1f mm in the last hour")
+
+	signal.reason = strings.Join(reasons, ", ")
+	return signal
+}
@@ -0,0 +1,81 @@
+package event
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/forfarm/backend/internal/domain"
+)
+
+// Broadcaster fans events out to listeners inside the process, such as
+// connected event stream clients, so that each of them does not need its
+// own subscription on the bus. A listener that falls behind is dropped and
+// its channel closed; clients resume from the event store.
+type Broadcaster struct {
+	subscriber domain.EventSubscriber
+	eventTypes []string
+	logger     *slog.Logger
+
+	mu        sync.Mutex
+	listeners map[chan domain.Event]struct{}
+}
+
+func NewBroadcaster(subscriber domain.EventSubscriber, eventTypes []string, logger *slog.Logger) *Broadcaster {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Broadcaster{
+		subscriber: subscriber,
+		eventTypes: eventTypes,
+		logger:     logger,
+		listeners:  make(map[chan domain.Event]struct{}),
+	}
+}
+
+// Start subscribes to the event types until ctx is done.
+func (b *Broadcaster) Start(ctx context.Context) error {
+	for _, eventType := range b.eventTypes {
+		if err := b.subscriber.Subscribe(ctx, eventType, b.broadcast); err != nil {
+			return fmt.Errorf("failed to subscribe broadcaster to %s: %w", eventType, err)
+		}
+	}
+	return nil
+}
+
+// Listen registers a listener with room for buffer pending events. The
+// returned function unregisters it.
+func (b *Broadcaster) Listen(buffer int) (<-chan domain.Event, func()) {
+	ch := make(chan domain.Event, buffer)
+
+	b.mu.Lock()
+	b.listeners[ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() { b.remove(ch) }
+}
+
+func (b *Broadcaster) remove(ch chan domain.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.listeners[ch]; ok {
+		delete(b.listeners, ch)
+		close(ch)
+	}
+}
+
+func (b *Broadcaster) broadcast(event domain.Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.listeners {
+		select {
+		case ch <- event:
+		default:
+			b.logger.Warn("Event listener is too slow, dropping it", "event_id", event.ID)
+			delete(b.listeners, ch)
+			close(ch)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package event
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/forfarm/backend/internal/domain"
+)
+
+const (
+	deadLetterExchange = "events.dlx"
+
+	// maxDeliveryRetries is how many times a durable consumer retries an event
+	// before it is dead-lettered. Retry n waits retryBaseDelay * 2^(n-1).
+	maxDeliveryRetries = 5
+	retryBaseDelay     = 5 * time.Second
+
+	headerRetryCount     = "x-retry-count"
+	headerLastError      = "x-last-error"
+	headerRoutingKey     = "x-original-routing-key"
+	headerDeadLetteredAt = "x-dead-lettered-at"
+)
+
+// DeadLetter is an event a durable consumer gave up on, together with the
+// reason it was dead-lettered.
+type DeadLetter struct {
+	Event          domain.Event
+	Consumer       string
+	RoutingKey     string
+	Retries        int
+	Error          string
+	DeadLetteredAt time.Time
+}
+
+// retryDelay returns how long the given retry attempt (starting at 1) waits.
+func retryDelay(base time.Duration, attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	return base << (attempt - 1)
+}
+
+func retryQueueName(consumer string, attempt int) string {
+	return fmt.Sprintf("%s.retry.%d", consumer, attempt)
+}
+
+func deadLetterQueueName(consumer string) string {
+	return consumer + ".dead"
+}
@@ -3,137 +3,550 @@ package event
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
+	"sync"
+	"time"
 
 	"github.com/forfarm/backend/internal/domain"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
+const (
+	reconnectMinDelay = 1 * time.Second
+	reconnectMaxDelay = 30 * time.Second
+	publishBufferSize = 1000
+
+	// transientDeliveryAttempts is how many times a transient subscriber
+	// is handed an event it fails to handle before the event is dropped.
+	transientDeliveryAttempts = 3
+)
+
+var (
+	ErrPublishBufferFull = errors.New("event bus is disconnected and its publish buffer is full")
+	ErrPublishNacked     = errors.New("broker rejected the published event")
+)
+
+// RabbitMQEventBus publishes and consumes events through a RabbitMQ topic
+// exchange. When the connection or channel closes it reconnects with
+// backoff, re-declares the exchange and queues, and re-subscribes every
+// registered handler. Events published while disconnected are buffered and
+// sent in order once the connection is back.
+//
+// Publishes go through a dedicated channel in confirm mode, one at a time,
+// so Publish returns only after the broker has accepted the message and
+// reports nacks and unroutable messages to the caller.
 type RabbitMQEventBus struct {
-	conn    *amqp.Connection
-	channel *amqp.Channel
-	logger  *slog.Logger
+	url    string
+	logger *slog.Logger
+
+	// pubMu serializes publishes on pubChannel so each one can wait for
+	// its own confirm. It is taken before mu, never after.
+	pubMu sync.Mutex
+
+	mu            sync.Mutex
+	conn          *amqp.Connection
+	channel       *amqp.Channel
+	pubChannel    *amqp.Channel
+	returns       chan amqp.Return
+	connected     bool
+	lastErr       error
+	subscriptions []*rabbitSubscription
+	buffer        []bufferedPublish
+	closed        bool
+
+	// subsMu serializes starting consumers, so a subscription registered
+	// during a reconnect is consumed exactly once on the new channel.
+	subsMu sync.Mutex
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+type rabbitSubscription struct {
+	ctx        context.Context
+	consumer   string // empty for transient subscriptions
+	eventTypes []string
+	handler    func(domain.Event) error
+	channel    *amqp.Channel // channel currently consumed from
+}
+
+type bufferedPublish struct {
+	exchange string
+	key      string
+	msg      amqp.Publishing
+	result   chan error // receives the confirm outcome once flushed
+}
+
+// closeNotifications are the channels notified when the connection or one
+// of the bus channels closes.
+type closeNotifications struct {
+	conn      chan *amqp.Error
+	channel   chan *amqp.Error
+	publisher chan *amqp.Error
 }
 
 func NewRabbitMQEventBus(url string, logger *slog.Logger) (*RabbitMQEventBus, error) {
-	conn, err := amqp.Dial(url)
+	if logger == nil {
+		logger = slog.Default()
+	}
+	r := &RabbitMQEventBus{
+		url:    url,
+		logger: logger,
+		done:   make(chan struct{}),
+	}
+
+	closed, err := r.connect()
 	if err != nil {
 		return nil, err
 	}
+	r.mu.Lock()
+	r.connected = true
+	r.mu.Unlock()
+
+	r.wg.Add(1)
+	go r.watch(closed)
+
+	return r, nil
+}
+
+// connect dials the broker, opens the consumer channel and the confirming
+// publisher channel, and declares the exchange.
+func (r *RabbitMQEventBus) connect() (closeNotifications, error) {
+	conn, err := amqp.Dial(r.url)
+	if err != nil {
+		return closeNotifications{}, err
+	}
 
 	ch, err := conn.Channel()
 	if err != nil {
 		conn.Close()
-		return nil, err
+		return closeNotifications{}, err
 	}
 
 	// Declare the exchange
 	err = ch.ExchangeDeclare(
-		"events", // name
-		"topic",  // type
-		true,     // durable
-		false,    // auto-deleted
-		false,    // internal
-		false,    // no-wait
-		nil,      // arguments
+		exchangeName, // name
+		"topic",      // type
+		true,         // durable
+		false,        // auto-deleted
+		false,        // internal
+		false,        // no-wait
+		nil,          // arguments
 	)
 	if err != nil {
-		ch.Close()
 		conn.Close()
-		return nil, err
+		return closeNotifications{}, err
+	}
+
+	pub, err := conn.Channel()
+	if err == nil {
+		err = pub.Confirm(false)
+	}
+	if err != nil {
+		conn.Close()
+		return closeNotifications{}, fmt.Errorf("failed to open publisher channel: %w", err)
+	}
+	// Mandatory publishes that match no queue come back on returns. The
+	// buffer leaves room for returns of publishes whose caller gave up.
+	returns := pub.NotifyReturn(make(chan amqp.Return, 16))
+
+	closed := closeNotifications{
+		conn:      conn.NotifyClose(make(chan *amqp.Error, 1)),
+		channel:   ch.NotifyClose(make(chan *amqp.Error, 1)),
+		publisher: pub.NotifyClose(make(chan *amqp.Error, 1)),
+	}
+
+	r.mu.Lock()
+	r.conn = conn
+	r.channel = ch
+	r.pubChannel = pub
+	r.returns = returns
+	r.mu.Unlock()
+
+	return closed, nil
+}
+
+// watch waits for the connection or one of its channels to close and
+// reconnects.
+func (r *RabbitMQEventBus) watch(closed closeNotifications) {
+	defer r.wg.Done()
+	for {
+		var reason *amqp.Error
+		select {
+		case <-r.done:
+			return
+		case reason = <-closed.conn:
+		case reason = <-closed.channel:
+		case reason = <-closed.publisher:
+		}
+
+		r.markDisconnected(reason)
+
+		var ok bool
+		if closed, ok = r.reconnect(); !ok {
+			return
+		}
+	}
+}
+
+func (r *RabbitMQEventBus) markDisconnected(reason *amqp.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.connected = false
+	if reason != nil {
+		r.lastErr = reason
+	} else {
+		r.lastErr = amqp.ErrClosed
+	}
+	if r.conn != nil && !r.conn.IsClosed() {
+		// Only the channel failed; drop the connection too and start over.
+		r.conn.Close()
+	}
+	r.logger.Error("Lost connection to RabbitMQ, reconnecting", "error", r.lastErr, "buffered", len(r.buffer))
+}
+
+// reconnect retries connect with exponential backoff until it succeeds or
+// the bus is closed, then restores subscriptions and flushes the buffer.
+// It returns false if the bus was closed.
+func (r *RabbitMQEventBus) reconnect() (closeNotifications, bool) {
+	delay := reconnectMinDelay
+	for {
+		timer := time.NewTimer(delay)
+		select {
+		case <-r.done:
+			timer.Stop()
+			return closeNotifications{}, false
+		case <-timer.C:
+		}
+
+		closed, err := r.connect()
+		if err != nil {
+			r.logger.Warn("Failed to reconnect to RabbitMQ", "retry_in", delay, "error", err)
+			r.mu.Lock()
+			r.lastErr = err
+			r.mu.Unlock()
+			delay *= 2
+			if delay > reconnectMaxDelay {
+				delay = reconnectMaxDelay
+			}
+			continue
+		}
+
+		r.resubscribe()
+		if err := r.flushBuffer(); err != nil {
+			r.logger.Error("Failed to flush buffered events after reconnecting", "error", err)
+		} else {
+			r.logger.Info("Reconnected to RabbitMQ")
+		}
+		return closed, true
+	}
+}
+
+func (r *RabbitMQEventBus) resubscribe() {
+	r.subsMu.Lock()
+	defer r.subsMu.Unlock()
+
+	r.mu.Lock()
+	active := r.subscriptions[:0]
+	for _, sub := range r.subscriptions {
+		if sub.ctx.Err() == nil {
+			active = append(active, sub)
+		}
+	}
+	r.subscriptions = active
+	subs := append([]*rabbitSubscription(nil), active...)
+	r.mu.Unlock()
+
+	for _, sub := range subs {
+		if err := r.consume(sub); err != nil {
+			r.logger.Error("Failed to re-subscribe after reconnecting", "consumer", sub.consumer, "types", sub.eventTypes, "error", err)
+		}
 	}
+}
+
+// flushBuffer publishes buffered events in order, hands each confirm outcome
+// to the waiting publisher and marks the bus connected once the buffer is
+// empty. It stops at the first event lost to a closed channel, leaving it
+// buffered for the next reconnect.
+func (r *RabbitMQEventBus) flushBuffer() error {
+	r.pubMu.Lock()
+	defer r.pubMu.Unlock()
 
-	return &RabbitMQEventBus{
-		conn:    conn,
-		channel: ch,
-		logger:  logger,
-	}, nil
+	for {
+		r.mu.Lock()
+		if r.closed {
+			r.mu.Unlock()
+			return ErrEventBusClosed
+		}
+		if len(r.buffer) == 0 {
+			r.buffer = nil
+			r.connected = true
+			r.mu.Unlock()
+			return nil
+		}
+		p := r.buffer[0]
+		ch, returns := r.pubChannel, r.returns
+		r.mu.Unlock()
+
+		err := r.publishConfirmed(context.Background(), ch, returns, p.exchange, p.key, p.msg)
+		if errors.Is(err, amqp.ErrClosed) {
+			return err
+		}
+
+		r.mu.Lock()
+		if r.closed {
+			// Close already failed every buffered publish.
+			r.mu.Unlock()
+			return ErrEventBusClosed
+		}
+		r.buffer = r.buffer[1:]
+		r.mu.Unlock()
+		p.result <- err
+	}
+}
+
+// HealthCheck reports whether the bus is connected to the broker.
+func (r *RabbitMQEventBus) HealthCheck(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return ErrEventBusClosed
+	}
+	if !r.connected {
+		return fmt.Errorf("disconnected from RabbitMQ, %d events buffered: %w", len(r.buffer), r.lastErr)
+	}
+	return nil
 }
 
 func (r *RabbitMQEventBus) Publish(ctx context.Context, event domain.Event) error {
+	if err := domain.ValidateEvent(event); err != nil {
+		return err
+	}
 	data, err := json.Marshal(event)
 	if err != nil {
 		return err
 	}
 
-	return r.channel.PublishWithContext(
-		ctx,
-		"events",             // exchange
-		"events."+event.Type, // routing key
-		false,                // mandatory
-		false,                // immediate
-		amqp.Publishing{
-			ContentType:  "application/json",
-			Body:         data,
-			DeliveryMode: amqp.Persistent,
-			MessageId:    event.ID,
-			Timestamp:    event.Timestamp,
-		},
-	)
+	return r.publish(ctx, exchangeName, routingKey(event.Type), amqp.Publishing{
+		ContentType:  "application/json",
+		Body:         data,
+		DeliveryMode: amqp.Persistent,
+		MessageId:    event.ID,
+		Timestamp:    event.Timestamp,
+	}, true)
 }
 
-func (r *RabbitMQEventBus) Subscribe(ctx context.Context, eventType string, handler func(domain.Event) error) error {
-	// Declare a queue for this consumer
-	q, err := r.channel.QueueDeclare(
-		"",    // name (empty = auto-generated)
-		false, // durable
-		true,  // delete when unused
-		true,  // exclusive
-		false, // no-wait
-		nil,   // arguments
-	)
-	if err != nil {
+// publish sends a message on the publisher channel and waits for the broker
+// to confirm it. If the bus is disconnected the message is buffered when
+// buffer is set, and publish waits for it to be flushed and confirmed after
+// reconnecting, or for ctx to end. Without buffer it is rejected.
+func (r *RabbitMQEventBus) publish(ctx context.Context, exchange, key string, msg amqp.Publishing, buffer bool) error {
+	r.pubMu.Lock()
+
+	r.mu.Lock()
+	closed, connected := r.closed, r.connected
+	ch, returns := r.pubChannel, r.returns
+	r.mu.Unlock()
+
+	if closed {
+		r.pubMu.Unlock()
+		return ErrEventBusClosed
+	}
+	if connected {
+		err := r.publishConfirmed(ctx, ch, returns, exchange, key, msg)
+		if !errors.Is(err, amqp.ErrClosed) {
+			r.pubMu.Unlock()
+			return err
+		}
+		r.mu.Lock()
+		r.connected = false
+		r.mu.Unlock()
+	}
+
+	if !buffer {
+		r.pubMu.Unlock()
+		return fmt.Errorf("event bus is disconnected: %w", amqp.ErrClosed)
+	}
+
+	r.mu.Lock()
+	if len(r.buffer) >= publishBufferSize {
+		r.mu.Unlock()
+		r.pubMu.Unlock()
+		return ErrPublishBufferFull
+	}
+	p := bufferedPublish{exchange: exchange, key: key, msg: msg, result: make(chan error, 1)}
+	r.buffer = append(r.buffer, p)
+	buffered := len(r.buffer)
+	r.mu.Unlock()
+	r.pubMu.Unlock()
+
+	r.logger.Warn("Event bus disconnected, buffering event", "message_id", msg.MessageId, "buffered", buffered)
+
+	select {
+	case err := <-p.result:
 		return err
+	case <-ctx.Done():
+		// The event stays buffered and may still be delivered.
+		return fmt.Errorf("event %s buffered but not confirmed: %w", msg.MessageId, ctx.Err())
 	}
+}
 
-	// Bind the queue to the exchange
-	err = r.channel.QueueBind(
-		q.Name,              // queue name
-		"events."+eventType, // routing key
-		"events",            // exchange
-		false,               // no-wait
-		nil,                 // arguments
-	)
+// publishConfirmed publishes a mandatory message on a channel in confirm
+// mode and waits for the broker's ack. A message that matched no queue is
+// reported as domain.ErrEventUnroutable, a nack as ErrPublishNacked, and a
+// channel that closed before confirming as amqp.ErrClosed. Callers must not
+// publish on ch concurrently, so that a return belongs to this message.
+func (r *RabbitMQEventBus) publishConfirmed(ctx context.Context, ch *amqp.Channel, returns <-chan amqp.Return, exchange, key string, msg amqp.Publishing) error {
+	dc, err := ch.PublishWithDeferredConfirmWithContext(ctx, exchange, key, true, false, msg)
+	if err != nil {
+		return err
+	}
+	acked, err := dc.WaitContext(ctx)
 	if err != nil {
+		return fmt.Errorf("event %s not confirmed: %w", msg.MessageId, err)
+	}
+
+	// The broker sends basic.return before the ack of the same message, so
+	// any return for it is already queued.
+	returned := false
+	for drained := false; !drained; {
+		select {
+		case ret, ok := <-returns:
+			if !ok {
+				drained = true
+			} else if ret.MessageId == msg.MessageId {
+				returned = true
+			} else {
+				r.logger.Warn("Event was unroutable", "message_id", ret.MessageId, "routing_key", ret.RoutingKey)
+			}
+		default:
+			drained = true
+		}
+	}
+
+	switch {
+	case returned:
+		return fmt.Errorf("event %s with routing key %s: %w", msg.MessageId, key, domain.ErrEventUnroutable)
+	case !acked && ch.IsClosed():
+		return fmt.Errorf("event %s not confirmed before the channel closed: %w", msg.MessageId, amqp.ErrClosed)
+	case !acked:
+		return fmt.Errorf("event %s: %w", msg.MessageId, ErrPublishNacked)
+	}
+	return nil
+}
+
+func (r *RabbitMQEventBus) Subscribe(ctx context.Context, eventType string, handler func(domain.Event) error) error {
+	return r.register(&rabbitSubscription{
+		ctx:        ctx,
+		eventTypes: []string{eventType},
+		handler:    handler,
+	})
+}
+
+func (r *RabbitMQEventBus) SubscribeDurable(ctx context.Context, consumer string, eventTypes []string, handler func(domain.Event) error) error {
+	if consumer == "" {
+		return fmt.Errorf("consumer name is required for a durable subscription")
+	}
+	return r.register(&rabbitSubscription{
+		ctx:        ctx,
+		consumer:   consumer,
+		eventTypes: eventTypes,
+		handler:    handler,
+	})
+}
+
+// register records a subscription so it is restored after a reconnect, and
+// starts consuming right away if a channel is open.
+func (r *RabbitMQEventBus) register(sub *rabbitSubscription) error {
+	r.subsMu.Lock()
+	defer r.subsMu.Unlock()
+
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return ErrEventBusClosed
+	}
+	r.subscriptions = append(r.subscriptions, sub)
+	r.mu.Unlock()
+
+	if err := r.consume(sub); err != nil {
+		r.mu.Lock()
+		r.subscriptions = r.subscriptions[:len(r.subscriptions)-1]
+		r.mu.Unlock()
 		return err
 	}
+	return nil
+}
+
+// consume declares the queues of a subscription on the current channel and
+// starts its consumer goroutine, unless it already consumes from that
+// channel or no channel is open. The goroutine exits when the channel closes;
+// resubscribe starts a new one after reconnecting. Callers hold subsMu.
+func (r *RabbitMQEventBus) consume(sub *rabbitSubscription) error {
+	r.mu.Lock()
+	ch := r.channel
+	r.mu.Unlock()
+
+	if ch == nil || ch.IsClosed() || sub.channel == ch {
+		return nil
+	}
+
+	queue := sub.consumer
+	if sub.consumer == "" {
+		// Declare a transient queue for this subscriber
+		q, err := ch.QueueDeclare(
+			"",    // name (empty = auto-generated)
+			false, // durable
+			true,  // delete when unused
+			true,  // exclusive
+			false, // no-wait
+			nil,   // arguments
+		)
+		if err != nil {
+			return err
+		}
+		for _, eventType := range sub.eventTypes {
+			if err := ch.QueueBind(q.Name, routingKey(eventType), exchangeName, false, nil); err != nil {
+				return err
+			}
+		}
+		queue = q.Name
+	} else if err := declareConsumerTopology(ch, sub.consumer, sub.eventTypes); err != nil {
+		return fmt.Errorf("failed to declare queues for consumer %s: %w", sub.consumer, err)
+	}
 
-	// Start consuming
-	msgs, err := r.channel.Consume(
-		q.Name, // queue
-		"",     // consumer
-		false,  // auto-ack
-		false,  // exclusive
-		false,  // no-local
-		false,  // no-wait
-		nil,    // args
+	msgs, err := ch.Consume(
+		queue, // queue
+		"",    // consumer
+		false, // auto-ack
+		false, // exclusive
+		false, // no-local
+		false, // no-wait
+		nil,   // args
 	)
 	if err != nil {
 		return err
 	}
+	sub.channel = ch
 
 	go func() {
+		// Failed attempts per event, for transient subscriptions.
+		attempts := map[string]int{}
 		for {
 			select {
-			case <-ctx.Done():
+			case <-sub.ctx.Done():
 				return
 			case msg, ok := <-msgs:
 				if !ok {
 					return
 				}
-
-				var event domain.Event
-				if err := json.Unmarshal(msg.Body, &event); err != nil {
-					r.logger.Error("Failed to unmarshal event", "error", err)
-					msg.Nack(false, false)
-					continue
-				}
-
-				if err := handler(event); err != nil {
-					r.logger.Error("Failed to handle event", "error", err)
-					msg.Nack(false, true) // requeue
+				if sub.consumer == "" {
+					r.handleTransientDelivery(msg, sub.handler, attempts)
 				} else {
-					msg.Ack(false)
+					r.handleDurableDelivery(sub.ctx, sub.consumer, msg, sub.handler)
 				}
 			}
 		}
@@ -142,9 +555,308 @@ func (r *RabbitMQEventBus) Subscribe(ctx context.Context, eventType string, hand
 	return nil
 }
 
+// handleTransientDelivery requeues an event the handler fails on, up to
+// transientDeliveryAttempts times, counting attempts in attempts. Transient
+// subscribers have no retry queue; use SubscribeDurable for consumers that
+// must see every event.
+func (r *RabbitMQEventBus) handleTransientDelivery(msg amqp.Delivery, handler func(domain.Event) error, attempts map[string]int) {
+	var event domain.Event
+	if err := json.Unmarshal(msg.Body, &event); err != nil {
+		r.logger.Error("Failed to unmarshal event", "error", err)
+		msg.Nack(false, false)
+		return
+	}
+
+	if err := handler(event); err != nil {
+		attempts[event.ID]++
+		if attempts[event.ID] < transientDeliveryAttempts {
+			r.logger.Warn("Failed to handle event, requeueing it", "error", err, "event_id", event.ID, "type", event.Type, "attempt", attempts[event.ID])
+			msg.Nack(false, true)
+			return
+		}
+		delete(attempts, event.ID)
+		r.logger.Error("Failed to handle event, dropping it", "error", err, "event_id", event.ID, "type", event.Type, "attempts", transientDeliveryAttempts)
+		msg.Nack(false, false)
+		return
+	}
+	delete(attempts, event.ID)
+	msg.Ack(false)
+}
+
+// declareConsumerTopology declares the durable queue of a consumer bound to
+// its event types, one delayed retry queue per attempt, and its dead-letter
+// queue. A retry queue holds a message for its TTL and then dead-letters it
+// back into the consumer queue through the default exchange.
+func declareConsumerTopology(ch *amqp.Channel, consumer string, eventTypes []string) error {
+	err := ch.ExchangeDeclare(
+		deadLetterExchange, // name
+		"direct",           // type
+		true,               // durable
+		false,              // auto-deleted
+		false,              // internal
+		false,              // no-wait
+		nil,                // arguments
+	)
+	if err != nil {
+		return err
+	}
+
+	if _, err := ch.QueueDeclare(consumer, true, false, false, false, nil); err != nil {
+		return err
+	}
+	for _, eventType := range eventTypes {
+		if err := ch.QueueBind(consumer, routingKey(eventType), exchangeName, false, nil); err != nil {
+			return err
+		}
+	}
+
+	for attempt := 1; attempt <= maxDeliveryRetries; attempt++ {
+		args := amqp.Table{
+			"x-message-ttl":             retryDelay(retryBaseDelay, attempt).Milliseconds(),
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": consumer,
+		}
+		if _, err := ch.QueueDeclare(retryQueueName(consumer, attempt), true, false, false, false, args); err != nil {
+			return err
+		}
+	}
+
+	deadQueue := deadLetterQueueName(consumer)
+	if _, err := ch.QueueDeclare(deadQueue, true, false, false, false, nil); err != nil {
+		return err
+	}
+	return ch.QueueBind(deadQueue, consumer, deadLetterExchange, false, nil)
+}
+
+func (r *RabbitMQEventBus) handleDurableDelivery(ctx context.Context, consumer string, msg amqp.Delivery, handler func(domain.Event) error) {
+	retries := headerInt(msg.Headers, headerRetryCount)
+
+	var event domain.Event
+	if err := json.Unmarshal(msg.Body, &event); err != nil {
+		r.logger.Error("Failed to unmarshal event, dead-lettering it", "consumer", consumer, "error", err)
+		r.forward(ctx, msg, deadLetterExchange, consumer, retries, err)
+		return
+	}
+
+	err := handler(event)
+	if err == nil {
+		msg.Ack(false)
+		return
+	}
+
+	if retries < maxDeliveryRetries {
+		r.logger.Warn("Failed to handle event, scheduling retry", "consumer", consumer, "event_id", event.ID, "type", event.Type, "retry", retries+1, "error", err)
+		r.forward(ctx, msg, "", retryQueueName(consumer, retries+1), retries+1, err)
+		return
+	}
+
+	r.logger.Error("Failed to handle event, retries exhausted, dead-lettering it", "consumer", consumer, "event_id", event.ID, "type", event.Type, "retries", retries, "error", err)
+	r.forward(ctx, msg, deadLetterExchange, consumer, retries, err)
+}
+
+// forward republishes a delivery with updated retry headers and acks the
+// original. If the republish fails the delivery is requeued instead, so the
+// event is never lost.
+func (r *RabbitMQEventBus) forward(ctx context.Context, msg amqp.Delivery, exchange, key string, retries int, cause error) {
+	headers := amqp.Table{}
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	headers[headerRetryCount] = int32(retries)
+	headers[headerLastError] = cause.Error()
+	if _, ok := headers[headerRoutingKey]; !ok {
+		headers[headerRoutingKey] = msg.RoutingKey
+	}
+	if exchange == deadLetterExchange {
+		headers[headerDeadLetteredAt] = time.Now().UTC()
+	}
+
+	err := r.publish(ctx, exchange, key, amqp.Publishing{
+		Headers:      headers,
+		ContentType:  msg.ContentType,
+		Body:         msg.Body,
+		DeliveryMode: amqp.Persistent,
+		MessageId:    msg.MessageId,
+		Timestamp:    msg.Timestamp,
+	}, false)
+	if err != nil {
+		r.logger.Error("Failed to forward event, requeueing it", "exchange", exchange, "routing_key", key, "error", err)
+		msg.Nack(false, true)
+		return
+	}
+	msg.Ack(false)
+}
+
+// DeadLetters returns up to limit dead-lettered events of a consumer without
+// removing them from its dead-letter queue.
+func (r *RabbitMQEventBus) DeadLetters(ctx context.Context, consumer string, limit int) ([]DeadLetter, error) {
+	ch, err := r.openChannel()
+	if err != nil {
+		return nil, err
+	}
+	// Closing the channel returns every unacknowledged message to the queue.
+	defer ch.Close()
+
+	q, err := ch.QueueDeclarePassive(deadLetterQueueName(consumer), true, false, false, false, nil)
+	if err != nil {
+		return nil, fmt.Errorf("no dead-letter queue for consumer %s: %w", consumer, err)
+	}
+	if limit <= 0 || limit > q.Messages {
+		limit = q.Messages
+	}
+
+	var letters []DeadLetter
+	for len(letters) < limit {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		msg, ok, err := ch.Get(q.Name, false)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		letters = append(letters, toDeadLetter(consumer, msg))
+	}
+	return letters, nil
+}
+
+// ReplayDeadLetters moves up to limit dead-lettered events back to the
+// consumer queue with a fresh retry budget. A limit of zero replays every
+// event that was dead-lettered when the call started.
+func (r *RabbitMQEventBus) ReplayDeadLetters(ctx context.Context, consumer string, limit int) (int, error) {
+	ch, err := r.openChannel()
+	if err != nil {
+		return 0, err
+	}
+	defer ch.Close()
+
+	q, err := ch.QueueDeclarePassive(deadLetterQueueName(consumer), true, false, false, false, nil)
+	if err != nil {
+		return 0, fmt.Errorf("no dead-letter queue for consumer %s: %w", consumer, err)
+	}
+	if limit <= 0 || limit > q.Messages {
+		limit = q.Messages
+	}
+
+	// Ack a dead letter only once the broker confirmed its replay.
+	if err := ch.Confirm(false); err != nil {
+		return 0, err
+	}
+	returns := ch.NotifyReturn(make(chan amqp.Return, 1))
+
+	replayed := 0
+	for replayed < limit {
+		if err := ctx.Err(); err != nil {
+			return replayed, err
+		}
+		msg, ok, err := ch.Get(q.Name, false)
+		if err != nil {
+			return replayed, err
+		}
+		if !ok {
+			break
+		}
+
+		headers := amqp.Table{}
+		for k, v := range msg.Headers {
+			headers[k] = v
+		}
+		delete(headers, headerRetryCount)
+		delete(headers, headerDeadLetteredAt)
+
+		err = r.publishConfirmed(ctx, ch, returns, "", consumer, amqp.Publishing{
+			Headers:      headers,
+			ContentType:  msg.ContentType,
+			Body:         msg.Body,
+			DeliveryMode: amqp.Persistent,
+			MessageId:    msg.MessageId,
+			Timestamp:    msg.Timestamp,
+		})
+		if err != nil {
+			return replayed, fmt.Errorf("failed to replay message %s: %w", msg.MessageId, err)
+		}
+		if err := msg.Ack(false); err != nil {
+			return replayed, err
+		}
+		replayed++
+	}
+	return replayed, nil
+}
+
+func toDeadLetter(consumer string, msg amqp.Delivery) DeadLetter {
+	letter := DeadLetter{
+		Consumer: consumer,
+		Retries:  headerInt(msg.Headers, headerRetryCount),
+	}
+	if err := json.Unmarshal(msg.Body, &letter.Event); err != nil {
+		letter.Event.ID = msg.MessageId
+	}
+	letter.RoutingKey, _ = msg.Headers[headerRoutingKey].(string)
+	letter.Error, _ = msg.Headers[headerLastError].(string)
+	letter.DeadLetteredAt, _ = msg.Headers[headerDeadLetteredAt].(time.Time)
+	return letter
+}
+
+// headerInt reads an integer header, whichever integer type the AMQP table
+// decoded it as.
+func headerInt(headers amqp.Table, key string) int {
+	switch v := headers[key].(type) {
+	case int:
+		return v
+	case int16:
+		return int(v)
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	default:
+		return 0
+	}
+}
+
+// openChannel opens a dedicated channel on the current connection.
+func (r *RabbitMQEventBus) openChannel() (*amqp.Channel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil, ErrEventBusClosed
+	}
+	if !r.connected {
+		return nil, fmt.Errorf("event bus is disconnected: %w", r.lastErr)
+	}
+	return r.conn.Channel()
+}
+
 func (r *RabbitMQEventBus) Close() error {
-	if err := r.channel.Close(); err != nil {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil
+	}
+	r.closed = true
+	close(r.done)
+	if len(r.buffer) > 0 {
+		r.logger.Warn("Closing event bus with undelivered buffered events", "count", len(r.buffer))
+	}
+	for _, p := range r.buffer {
+		p.result <- ErrEventBusClosed
+	}
+	r.buffer = nil
+	ch, pub, conn := r.channel, r.pubChannel, r.conn
+	r.mu.Unlock()
+
+	r.wg.Wait()
+
+	if err := pub.Close(); err != nil && !errors.Is(err, amqp.ErrClosed) {
+		return err
+	}
+	if err := ch.Close(); err != nil && !errors.Is(err, amqp.ErrClosed) {
 		return err
 	}
-	return r.conn.Close()
+	if err := conn.Close(); err != nil && !errors.Is(err, amqp.ErrClosed) {
+		return err
+	}
+	return nil
 }
@@ -0,0 +1,292 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/forfarm/backend/internal/domain"
+)
+
+var ErrEventBusClosed = errors.New("event bus is closed")
+
+const defaultMemoryQueueSize = 256
+
+// InMemoryEventBus is a process-local domain.EventBus. It mirrors the
+// behaviour of RabbitMQEventBus closely enough to run the API and its
+// projections without a broker: events are serialized on publish, routed with
+// topic exchange semantics, and handled asynchronously per subscription.
+// Durable consumers retry failed events with backoff and then dead-letter
+// them; transient subscribers drop them. Nothing survives a restart.
+type InMemoryEventBus struct {
+	mu            sync.RWMutex
+	subscriptions map[*memorySubscription]struct{}
+	consumers     map[string]*memorySubscription
+	deadLetters   map[string][]DeadLetter
+	closed        bool
+	done          chan struct{}
+	wg            sync.WaitGroup
+
+	logger         *slog.Logger
+	queueSize      int
+	retryBaseDelay time.Duration
+}
+
+type memorySubscription struct {
+	consumer string // empty for transient subscriptions
+	patterns []string
+	handler  func(domain.Event) error
+	queue    chan memoryDelivery
+	stopped  chan struct{}
+}
+
+type memoryDelivery struct {
+	data       []byte
+	routingKey string
+	retries    int
+}
+
+func NewInMemoryEventBus(logger *slog.Logger) *InMemoryEventBus {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &InMemoryEventBus{
+		subscriptions:  make(map[*memorySubscription]struct{}),
+		consumers:      make(map[string]*memorySubscription),
+		deadLetters:    make(map[string][]DeadLetter),
+		done:           make(chan struct{}),
+		logger:         logger,
+		queueSize:      defaultMemoryQueueSize,
+		retryBaseDelay: retryBaseDelay,
+	}
+}
+
+// SetRetryDelay changes the delay before the first retry of a failed durable
+// delivery; later retries double it. Tests use a short delay to keep retries fast.
+func (b *InMemoryEventBus) SetRetryDelay(d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.retryBaseDelay = d
+}
+
+func (b *InMemoryEventBus) Publish(ctx context.Context, event domain.Event) error {
+	if err := domain.ValidateEvent(event); err != nil {
+		return err
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	key := routingKey(event.Type)
+
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return ErrEventBusClosed
+	}
+	var targets []*memorySubscription
+	for sub := range b.subscriptions {
+		if sub.matches(key) {
+			targets = append(targets, sub)
+		}
+	}
+	b.mu.RUnlock()
+
+	for _, sub := range targets {
+		select {
+		case sub.queue <- memoryDelivery{data: data, routingKey: key}:
+		case <-sub.stopped:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-b.done:
+			return ErrEventBusClosed
+		}
+	}
+	return nil
+}
+
+func (b *InMemoryEventBus) Subscribe(ctx context.Context, eventType string, handler func(domain.Event) error) error {
+	return b.subscribe(ctx, "", []string{eventType}, handler)
+}
+
+// SubscribeDurable registers a named consumer. Unlike RabbitMQ, a consumer
+// name can only be subscribed once per bus.
+func (b *InMemoryEventBus) SubscribeDurable(ctx context.Context, consumer string, eventTypes []string, handler func(domain.Event) error) error {
+	if consumer == "" {
+		return fmt.Errorf("consumer name is required for a durable subscription")
+	}
+	return b.subscribe(ctx, consumer, eventTypes, handler)
+}
+
+func (b *InMemoryEventBus) subscribe(ctx context.Context, consumer string, eventTypes []string, handler func(domain.Event) error) error {
+	sub := &memorySubscription{
+		consumer: consumer,
+		handler:  handler,
+		queue:    make(chan memoryDelivery, b.queueSize),
+		stopped:  make(chan struct{}),
+	}
+	for _, eventType := range eventTypes {
+		sub.patterns = append(sub.patterns, routingKey(eventType))
+	}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return ErrEventBusClosed
+	}
+	if consumer != "" {
+		if _, exists := b.consumers[consumer]; exists {
+			b.mu.Unlock()
+			return fmt.Errorf("consumer %s is already subscribed", consumer)
+		}
+		b.consumers[consumer] = sub
+	}
+	b.subscriptions[sub] = struct{}{}
+	b.wg.Add(1)
+	b.mu.Unlock()
+
+	go func() {
+		defer b.wg.Done()
+		defer b.unsubscribe(sub)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-b.done:
+				return
+			case d := <-sub.queue:
+				b.deliver(ctx, sub, d)
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (s *memorySubscription) matches(key string) bool {
+	for _, pattern := range s.patterns {
+		if topicMatches(pattern, key) {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *InMemoryEventBus) deliver(ctx context.Context, sub *memorySubscription, d memoryDelivery) {
+	var event domain.Event
+	if err := json.Unmarshal(d.data, &event); err != nil {
+		b.logger.Error("Failed to unmarshal event", "error", err)
+		if sub.consumer != "" {
+			b.deadLetter(sub, d, event, err)
+		}
+		return
+	}
+
+	err := sub.handler(event)
+	if err == nil {
+		return
+	}
+
+	switch {
+	case sub.consumer == "":
+		b.logger.Error("Failed to handle event, dropping it", "error", err, "event_id", event.ID, "type", event.Type)
+	case d.retries < maxDeliveryRetries:
+		b.logger.Warn("Failed to handle event, scheduling retry", "consumer", sub.consumer, "event_id", event.ID, "type", event.Type, "retry", d.retries+1, "error", err)
+		d.retries++
+		b.retry(ctx, sub, d)
+	default:
+		b.logger.Error("Failed to handle event, retries exhausted, dead-lettering it", "consumer", sub.consumer, "event_id", event.ID, "type", event.Type, "retries", d.retries, "error", err)
+		b.deadLetter(sub, d, event, err)
+	}
+}
+
+// retry puts a failed delivery back on the subscription queue after the
+// backoff for its attempt, like the delayed retry queues on RabbitMQ.
+func (b *InMemoryEventBus) retry(ctx context.Context, sub *memorySubscription, d memoryDelivery) {
+	b.mu.RLock()
+	delay := retryDelay(b.retryBaseDelay, d.retries)
+	b.mu.RUnlock()
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return
+		case <-b.done:
+			return
+		}
+		select {
+		case sub.queue <- d:
+		case <-sub.stopped:
+		case <-b.done:
+		}
+	}()
+}
+
+func (b *InMemoryEventBus) deadLetter(sub *memorySubscription, d memoryDelivery, event domain.Event, cause error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.deadLetters[sub.consumer] = append(b.deadLetters[sub.consumer], DeadLetter{
+		Event:          event,
+		Consumer:       sub.consumer,
+		RoutingKey:     d.routingKey,
+		Retries:        d.retries,
+		Error:          cause.Error(),
+		DeadLetteredAt: time.Now().UTC(),
+	})
+}
+
+// DeadLetters returns up to limit dead-lettered events of a consumer.
+func (b *InMemoryEventBus) DeadLetters(ctx context.Context, consumer string, limit int) ([]DeadLetter, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	letters := b.deadLetters[consumer]
+	if limit > 0 && limit < len(letters) {
+		letters = letters[:limit]
+	}
+	return append([]DeadLetter(nil), letters...), nil
+}
+
+func (b *InMemoryEventBus) unsubscribe(sub *memorySubscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subscriptions, sub)
+	if sub.consumer != "" {
+		delete(b.consumers, sub.consumer)
+	}
+	close(sub.stopped)
+}
+
+// HealthCheck reports whether the bus still accepts events.
+func (b *InMemoryEventBus) HealthCheck(ctx context.Context) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return ErrEventBusClosed
+	}
+	return nil
+}
+
+// Close stops all subscription goroutines and rejects further publishes.
+func (b *InMemoryEventBus) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	close(b.done)
+	b.mu.Unlock()
+
+	b.wg.Wait()
+	return nil
+}
@@ -0,0 +1,481 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/forfarm/backend/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockAnalyticsRepository struct {
+	mock.Mock
+}
+
+func (m *MockAnalyticsRepository) GetFarmAnalytics(ctx context.Context, farmID string) (*domain.FarmAnalytics, error) {
+	args := m.Called(ctx, farmID)
+	analytics, _ := args.Get(0).(*domain.FarmAnalytics)
+	return analytics, args.Error(1)
+}
+
+func (m *MockAnalyticsRepository) GetCropAnalytics(ctx context.Context, cropID string) (*domain.CropAnalytics, error) {
+	args := m.Called(ctx, cropID)
+	analytics, _ := args.Get(0).(*domain.CropAnalytics)
+	return analytics, args.Error(1)
+}
+
+func (m *MockAnalyticsRepository) CreateOrUpdateFarmBaseData(ctx context.Context, farm *domain.Farm) error {
+	return m.Called(ctx, farm).Error(0)
+}
+
+func (m *MockAnalyticsRepository) UpdateFarmAnalyticsWeather(ctx context.Context, farmID string, weatherData *domain.WeatherData) error {
+	return m.Called(ctx, farmID, weatherData).Error(0)
+}
+
+func (m *MockAnalyticsRepository) UpdateFarmAnalyticsCropStats(ctx context.Context, farmID string) error {
+	return m.Called(ctx, farmID).Error(0)
+}
+
+func (m *MockAnalyticsRepository) UpdateFarmAnalyticsInventoryStats(ctx context.Context, farmID string) error {
+	return m.Called(ctx, farmID).Error(0)
+}
+
+func (m *MockAnalyticsRepository) DeleteFarmAnalytics(ctx context.Context, farmID string) error {
+	return m.Called(ctx, farmID).Error(0)
+}
+
+func (m *MockAnalyticsRepository) ResetFarmAnalytics(ctx context.Context, farmID string) error {
+	return m.Called(ctx, farmID).Error(0)
+}
+
+func (m *MockAnalyticsRepository) RunExclusive(ctx context.Context, fn func(domain.AnalyticsRepository) error) error {
+	return fn(m)
+}
+
+// ApplyEventOnce applies every event; deduplication is covered by the
+// Postgres repository.
+func (m *MockAnalyticsRepository) ApplyEventOnce(ctx context.Context, consumer string, event domain.Event, stream string, apply func(domain.AnalyticsRepository) error) (bool, error) {
+	if err := apply(m); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (m *MockAnalyticsRepository) UpdateFarmOverallStatus(ctx context.Context, farmID string, status string) error {
+	return m.Called(ctx, farmID, status).Error(0)
+}
+
+func newTestEvent(t *testing.T, eventType, aggregateID string, payload interface{}) domain.Event {
+	t.Helper()
+	event, err := domain.NewEvent(eventType, "test", aggregateID, payload)
+	require.NoError(t, err)
+	return event
+}
+
+func newFarmEvent(t *testing.T, eventType, farmID, name string) domain.Event {
+	t.Helper()
+	return newTestEvent(t, eventType, farmID, &domain.FarmPayload{UUID: farmID, Name: name, OwnerID: "owner-1"})
+}
+
+func TestTopicMatches(t *testing.T) {
+	tests := []struct {
+		pattern string
+		key     string
+		want    bool
+	}{
+		{"events.farm.created", "events.farm.created", true},
+		{"events.farm.created", "events.farm.updated", false},
+		{"events.farm.*", "events.farm.updated", true},
+		{"events.farm.*", "events.farm", false},
+		{"events.*", "events.inventory.item.created", false},
+		{"events.#", "events.inventory.item.created", true},
+		{"events.#", "events", true},
+		{"events.inventory.#", "events.inventory.item.deleted", true},
+		{"events.#.deleted", "events.inventory.item.deleted", true},
+		{"events.#.deleted", "events.inventory.item.created", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+"->"+tt.key, func(t *testing.T) {
+			assert.Equal(t, tt.want, topicMatches(tt.pattern, tt.key))
+		})
+	}
+}
+
+func TestWebhookMatches(t *testing.T) {
+	farmID := "farm-1"
+	tests := []struct {
+		name      string
+		webhook   domain.Webhook
+		eventType string
+		farmID    string
+		want      bool
+	}{
+		{"no filters", domain.Webhook{}, "farm.updated", farmID, true},
+		{"type pattern", domain.Webhook{EventTypes: []string{"farm.*"}}, "farm.updated", farmID, true},
+		{"type mismatch", domain.Webhook{EventTypes: []string{"farm.*"}}, "cropland.updated", farmID, false},
+		{"farm filter", domain.Webhook{FarmIDs: []string{farmID}}, "cropland.updated", farmID, true},
+		{"other farm", domain.Webhook{FarmIDs: []string{"farm-2"}}, "cropland.updated", farmID, false},
+		{"event without farm", domain.Webhook{FarmIDs: []string{"farm-2"}}, "inventory.item.created", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, webhookMatches(tt.webhook, tt.eventType, tt.farmID))
+		})
+	}
+}
+
+func TestInMemoryEventBus_PublishSubscribe(t *testing.T) {
+	bus := NewInMemoryEventBus(nil)
+	defer bus.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	received := make(chan domain.Event, 2)
+	require.NoError(t, bus.Subscribe(ctx, "farm.*", func(e domain.Event) error {
+		received <- e
+		return nil
+	}))
+
+	require.NoError(t, bus.Publish(ctx, newTestEvent(t, "weather.updated", "farm-1", &domain.WeatherUpdatedPayload{FarmID: "farm-1"})))
+	farmCreated := newFarmEvent(t, "farm.created", "farm-1", "North field")
+	require.NoError(t, bus.Publish(ctx, farmCreated))
+
+	select {
+	case e := <-received:
+		assert.Equal(t, farmCreated.ID, e.ID)
+		assert.Equal(t, 1, e.SchemaVersion)
+		payload, ok := e.Payload.(*domain.FarmPayload)
+		require.True(t, ok, "payload should arrive decoded as its registered type")
+		assert.Equal(t, "North field", payload.Name)
+	case <-time.After(time.Second):
+		t.Fatal("event was not delivered")
+	}
+
+	select {
+	case e := <-received:
+		t.Fatalf("unexpected event delivered: %s", e.Type)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestInMemoryEventBus_SubscribeDurableRetries(t *testing.T) {
+	bus := NewInMemoryEventBus(nil)
+	bus.SetRetryDelay(time.Millisecond)
+	defer bus.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var attempts atomic.Int32
+	handled := make(chan struct{})
+	require.NoError(t, bus.SubscribeDurable(ctx, "test-consumer", []string{"farm.updated"}, func(e domain.Event) error {
+		if attempts.Add(1) < 3 {
+			return errors.New("transient failure")
+		}
+		close(handled)
+		return nil
+	}))
+
+	require.NoError(t, bus.Publish(ctx, newFarmEvent(t, "farm.updated", "farm-1", "North field")))
+
+	select {
+	case <-handled:
+		assert.Equal(t, int32(3), attempts.Load())
+	case <-time.After(time.Second):
+		t.Fatalf("event was not redelivered, attempts=%d", attempts.Load())
+	}
+}
+
+func TestInMemoryEventBus_SubscribeDurableDeadLetters(t *testing.T) {
+	bus := NewInMemoryEventBus(nil)
+	bus.SetRetryDelay(time.Millisecond)
+	defer bus.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var attempts atomic.Int32
+	require.NoError(t, bus.SubscribeDurable(ctx, "test-consumer", []string{"farm.*"}, func(e domain.Event) error {
+		attempts.Add(1)
+		return errors.New("poison")
+	}))
+
+	farmDeleted := newTestEvent(t, "farm.deleted", "farm-1", &domain.FarmDeletedPayload{UUID: "farm-1"})
+	require.NoError(t, bus.Publish(ctx, farmDeleted))
+
+	require.Eventually(t, func() bool {
+		letters, _ := bus.DeadLetters(ctx, "test-consumer", 0)
+		return len(letters) == 1
+	}, 2*time.Second, 5*time.Millisecond)
+
+	letters, err := bus.DeadLetters(ctx, "test-consumer", 0)
+	require.NoError(t, err)
+	assert.Equal(t, farmDeleted.ID, letters[0].Event.ID)
+	assert.Equal(t, "events.farm.deleted", letters[0].RoutingKey)
+	assert.Equal(t, maxDeliveryRetries, letters[0].Retries)
+	assert.Equal(t, "poison", letters[0].Error)
+	assert.Equal(t, int32(maxDeliveryRetries+1), attempts.Load())
+
+	err = bus.SubscribeDurable(ctx, "test-consumer", []string{"farm.*"}, func(domain.Event) error { return nil })
+	assert.Error(t, err, "a consumer name can only be subscribed once")
+}
+
+func TestInMemoryEventBus_SubscribeDropsOnHandlerError(t *testing.T) {
+	bus := NewInMemoryEventBus(nil)
+	bus.SetRetryDelay(time.Millisecond)
+	defer bus.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var attempts atomic.Int32
+	require.NoError(t, bus.Subscribe(ctx, "farm.updated", func(e domain.Event) error {
+		attempts.Add(1)
+		return errors.New("failure")
+	}))
+
+	require.NoError(t, bus.Publish(ctx, newFarmEvent(t, "farm.updated", "farm-1", "North field")))
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(1), attempts.Load())
+}
+
+func TestInMemoryEventBus_Close(t *testing.T) {
+	bus := NewInMemoryEventBus(nil)
+	require.NoError(t, bus.Subscribe(context.Background(), "farm.created", func(domain.Event) error { return nil }))
+	require.NoError(t, bus.Close())
+
+	err := bus.Publish(context.Background(), newFarmEvent(t, "farm.created", "farm-1", "North field"))
+	assert.ErrorIs(t, err, ErrEventBusClosed)
+}
+
+func TestFarmAnalyticsProjection_InMemoryBus(t *testing.T) {
+	bus := NewInMemoryEventBus(nil)
+	defer bus.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	repo := &MockAnalyticsRepository{}
+	done := make(chan struct{})
+	repo.On("CreateOrUpdateFarmBaseData", mock.Anything, mock.MatchedBy(func(f *domain.Farm) bool {
+		return f.UUID == "farm-1" && f.Name == "North field"
+	})).Return(nil).Run(func(mock.Arguments) { close(done) })
+
+	projection := NewFarmAnalyticsProjection(bus, repo, nil)
+	require.NoError(t, projection.Start(ctx))
+
+	require.NoError(t, bus.Publish(ctx, newFarmEvent(t, "farm.created", "farm-1", "North field")))
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("projection did not handle farm.created")
+	}
+	repo.AssertExpectations(t)
+}
+
+type recordingEventStore struct {
+	events  []domain.Event
+	farmIDs []string
+}
+
+func (s *recordingEventStore) Append(ctx context.Context, event domain.Event, farmID string) error {
+	s.events = append(s.events, event)
+	s.farmIDs = append(s.farmIDs, farmID)
+	return nil
+}
+
+func (s *recordingEventStore) ForEach(ctx context.Context, farmID string, fn func(domain.Event) error) error {
+	for i, e := range s.events {
+		if farmID != "" && s.farmIDs[i] != farmID {
+			continue
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *recordingEventStore) ForEachAfter(ctx context.Context, afterEventID string, farmIDs []string, fn func(domain.Event) error) error {
+	for i, e := range s.events {
+		if e.ID != afterEventID {
+			continue
+		}
+		for j, e := range s.events[i+1:] {
+			if farmID := s.farmIDs[i+1+j]; farmID != "" && !slices.Contains(farmIDs, farmID) {
+				continue
+			}
+			if err := fn(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return domain.ErrNotFound
+}
+
+func (s *recordingEventStore) Forget(context.Context, string, []string) error {
+	return nil
+}
+
+func TestFarmAnalyticsProjection_Rebuild(t *testing.T) {
+	bus := NewInMemoryEventBus(nil)
+	defer bus.Close()
+
+	store := &recordingEventStore{}
+	publisher := NewStoringPublisher(store, bus)
+	ctx := context.Background()
+
+	require.NoError(t, publisher.Publish(ctx, newFarmEvent(t, "farm.created", "farm-1", "North field")))
+	require.NoError(t, publisher.Publish(ctx, newTestEvent(t, "weather.updated", "farm-2", &domain.WeatherUpdatedPayload{FarmID: "farm-2"})))
+	assert.ErrorIs(t, publisher.Publish(ctx, domain.Event{ID: "evt-3", Type: "farm.created", Payload: map[string]interface{}{}}), domain.ErrInvalidEventPayload)
+	assert.Equal(t, []string{"farm-1", "farm-2"}, store.farmIDs)
+
+	repo := &MockAnalyticsRepository{}
+	repo.On("ResetFarmAnalytics", mock.Anything, "farm-1").Return(nil)
+	repo.On("CreateOrUpdateFarmBaseData", mock.Anything, mock.MatchedBy(func(f *domain.Farm) bool {
+		return f.UUID == "farm-1"
+	})).Return(nil)
+
+	projection := NewFarmAnalyticsProjection(bus, repo, nil)
+	replayed, failed, err := projection.Rebuild(ctx, store, "farm-1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, replayed)
+	assert.Equal(t, 0, failed)
+	repo.AssertExpectations(t)
+}
+
+type recordingPublisher struct {
+	events chan domain.Event
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, event domain.Event) error {
+	p.events <- event
+	return nil
+}
+
+func TestEventAggregator_PublishesStatusChanges(t *testing.T) {
+	bus := NewInMemoryEventBus(nil)
+	defer bus.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	repo := &MockAnalyticsRepository{}
+	repo.On("GetFarmAnalytics", mock.Anything, "farm-1").Return(nil, domain.ErrNotFound)
+
+	target := &recordingPublisher{events: make(chan domain.Event, 10)}
+	aggregator := NewEventAggregator(bus, target, repo, nil, nil, nil)
+	require.NoError(t, aggregator.Start(ctx))
+
+	nextStatus := func() *domain.FarmStatusChangedPayload {
+		t.Helper()
+		select {
+		case e := <-target.events:
+			assert.Equal(t, "farm.status_changed", e.Type)
+			payload, ok := e.Payload.(*domain.FarmStatusChangedPayload)
+			require.True(t, ok)
+			return payload
+		case <-time.After(time.Second):
+			t.Fatal("farm.status_changed was not published")
+			return nil
+		}
+	}
+	weather := func(wind float64) domain.Event {
+		return newTestEvent(t, "weather.updated", "farm-1", &domain.WeatherUpdatedPayload{
+			FarmID:      "farm-1",
+			WeatherData: domain.WeatherData{WindSpeed: &wind},
+		})
+	}
+
+	require.NoError(t, bus.Publish(ctx, newFarmEvent(t, "farm.created", "farm-1", "North field")))
+	assert.Equal(t, domain.FarmStatusOK, nextStatus().Status)
+
+	require.NoError(t, bus.Publish(ctx, weather(12)))
+	status := nextStatus()
+	assert.Equal(t, domain.FarmStatusWarning, status.Status)
+	assert.Equal(t, domain.FarmStatusOK, status.PreviousStatus)
+	assert.Equal(t, []string{"strong wind: 12.0 m/s"}, status.Reasons)
+
+	// Each event type has its own subscription, so only events of one type
+	// are delivered in order.
+	require.NoError(t, bus.Publish(ctx, weather(14)))
+	require.NoError(t, bus.Publish(ctx, newTestEvent(t, "inventory.item.updated", "item-1", &domain.InventoryItemPayload{
+		ID: "item-1", UserID: "owner-1", Name: "Fertilizer", Quantity: 0, StatusID: outOfStockStatusID,
+	})))
+	status = nextStatus()
+	assert.Equal(t, domain.FarmStatusCritical, status.Status, "an unchanged status is not republished")
+	assert.Contains(t, status.Reasons, "Fertilizer is out of stock")
+
+	require.NoError(t, bus.Publish(ctx, newTestEvent(t, "inventory.item.deleted", "item-1", &domain.InventoryItemDeletedPayload{ItemID: "item-1", UserID: "owner-1"})))
+	assert.Equal(t, domain.FarmStatusWarning, nextStatus().Status)
+	require.NoError(t, bus.Publish(ctx, weather(3)))
+	assert.Equal(t, domain.FarmStatusOK, nextStatus().Status)
+}
+
+type stubCroplands struct {
+	domain.CroplandRepository
+	croplands []domain.Cropland
+}
+
+func (s stubCroplands) GetByFarmID(ctx context.Context, farmID string) ([]domain.Cropland, error) {
+	return s.croplands, nil
+}
+
+type stubInventory struct {
+	domain.InventoryRepository
+	items []domain.InventoryItem
+}
+
+func (s stubInventory) GetByUserID(ctx context.Context, userID string, filter domain.InventoryFilter, sort domain.InventorySort) ([]domain.InventoryItem, error) {
+	return s.items, nil
+}
+
+func TestEventAggregator_SeedsStateOnFirstLoad(t *testing.T) {
+	bus := NewInMemoryEventBus(nil)
+	defer bus.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	persisted := domain.FarmStatusCritical
+	repo := &MockAnalyticsRepository{}
+	repo.On("GetFarmAnalytics", mock.Anything, "farm-1").Return(&domain.FarmAnalytics{FarmID: "farm-1", OwnerID: "owner-1", OverallStatus: &persisted}, nil)
+	croplands := stubCroplands{croplands: []domain.Cropland{
+		{UUID: "crop-1", Name: "Rice", Status: "Failed", FarmID: "farm-1"},
+		{UUID: "crop-2", Name: "Corn", Status: "growing", FarmID: "farm-1"},
+	}}
+	inventory := stubInventory{items: []domain.InventoryItem{
+		{ID: "item-1", UserID: "owner-1", Name: "Seeds", Quantity: 3, StatusID: lowStockStatusID},
+	}}
+
+	target := &recordingPublisher{events: make(chan domain.Event, 10)}
+	aggregator := NewEventAggregator(bus, target, repo, croplands, inventory, nil)
+	require.NoError(t, aggregator.Start(ctx))
+
+	// Fixing the failed crop leaves the low stock loaded at startup.
+	require.NoError(t, bus.Publish(ctx, newTestEvent(t, "cropland.updated", "crop-1", &domain.CroplandPayload{
+		UUID: "crop-1", Name: "Rice", Status: "growing", FarmID: "farm-1",
+	})))
+	select {
+	case e := <-target.events:
+		payload, ok := e.Payload.(*domain.FarmStatusChangedPayload)
+		require.True(t, ok)
+		assert.Equal(t, domain.FarmStatusWarning, payload.Status)
+		assert.Equal(t, domain.FarmStatusCritical, payload.PreviousStatus)
+		assert.Equal(t, []string{"Seeds is low on stock"}, payload.Reasons)
+	case <-time.After(time.Second):
+		t.Fatal("farm.status_changed was not published")
+	}
+}
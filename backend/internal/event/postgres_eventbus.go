@@ -0,0 +1,769 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/forfarm/backend/internal/domain"
+)
+
+const (
+	eventNotifyChannel      = "event_bus"
+	postgresPollInterval    = 5 * time.Second
+	postgresBatchSize       = 100
+	eventLogRetention       = 7 * 24 * time.Hour
+	eventLogCleanupInterval = 1 * time.Hour
+	// eventLogLockID is the advisory lock that serializes appends to the log.
+	eventLogLockID = 0x666f72666172 // "forfar"
+)
+
+// PostgresEventBus is a domain.EventBus on top of the application database,
+// for deployments without a broker. Published events are appended to the
+// event_log table and a NOTIFY wakes subscribers, which also poll in case a
+// notification is missed. Routing follows topic exchange semantics.
+//
+// Durable consumers track their position in event_consumer_offsets. An
+// instance locks the offset row while it handles an event and advances it
+// past that event, so instances of one consumer share its events like
+// consumers of a RabbitMQ queue. Failed events are moved to event_retries and
+// retried there with backoff, then dead-lettered, without holding back later
+// events of that consumer. Transient subscribers keep their position in
+// memory and start at the end of the log.
+type PostgresEventBus struct {
+	pool   *pgxpool.Pool
+	logger *slog.Logger
+
+	mu             sync.Mutex
+	subscriptions  map[*postgresSubscription]struct{}
+	listening      bool
+	lastErr        error
+	closed         bool
+	retryBaseDelay time.Duration
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+type postgresSubscription struct {
+	consumer string // empty for transient subscriptions
+	patterns []string
+	handler  func(domain.Event) error
+	position int64 // last handled position of a transient subscription
+	wake     chan struct{}
+}
+
+type logRecord struct {
+	position       int64
+	eventID        string
+	routingKey     string
+	body           []byte
+	targetConsumer *string
+}
+
+func NewPostgresEventBus(ctx context.Context, pool *pgxpool.Pool, logger *slog.Logger) (*PostgresEventBus, error) {
+	if pool == nil {
+		return nil, fmt.Errorf("database pool cannot be nil")
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	conn, err := listenConn(ctx, pool)
+	if err != nil {
+		return nil, err
+	}
+
+	busCtx, cancel := context.WithCancel(context.Background())
+	b := &PostgresEventBus{
+		pool:           pool,
+		logger:         logger,
+		subscriptions:  make(map[*postgresSubscription]struct{}),
+		listening:      true,
+		retryBaseDelay: retryBaseDelay,
+		ctx:            busCtx,
+		cancel:         cancel,
+	}
+
+	b.wg.Add(2)
+	go b.listen(conn)
+	go b.cleanup()
+
+	return b, nil
+}
+
+// SetRetryDelay changes the delay before the first retry of a failed durable
+// delivery; later retries double it.
+func (b *PostgresEventBus) SetRetryDelay(d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.retryBaseDelay = d
+}
+
+// listenConn acquires a connection dedicated to receiving notifications.
+func listenConn(ctx context.Context, pool *pgxpool.Pool) (*pgxpool.Conn, error) {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire listener connection: %w", err)
+	}
+	if _, err := conn.Exec(ctx, "LISTEN "+eventNotifyChannel); err != nil {
+		conn.Release()
+		return nil, fmt.Errorf("failed to listen on %s: %w", eventNotifyChannel, err)
+	}
+	return conn, nil
+}
+
+// listen wakes every subscription on each notification. If the listener
+// connection fails it reconnects with backoff and wakes every subscription,
+// since notifications sent meanwhile are lost.
+func (b *PostgresEventBus) listen(conn *pgxpool.Conn) {
+	defer b.wg.Done()
+
+	delay := reconnectMinDelay
+	for {
+		if conn == nil {
+			select {
+			case <-b.ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+			var err error
+			if conn, err = listenConn(b.ctx, b.pool); err != nil {
+				b.logger.Warn("Failed to restore event bus listener", "retry_in", delay, "error", err)
+				b.setListening(false, err)
+				delay = min(delay*2, reconnectMaxDelay)
+				continue
+			}
+			delay = reconnectMinDelay
+			b.setListening(true, nil)
+			b.logger.Info("Restored event bus listener")
+			b.wakeAll()
+		}
+
+		_, err := conn.Conn().WaitForNotification(b.ctx)
+		if err != nil {
+			if b.ctx.Err() != nil {
+				conn.Release()
+				return
+			}
+			b.logger.Error("Event bus listener failed, reconnecting", "error", err)
+			b.setListening(false, err)
+			// Close the connection so the pool discards it.
+			conn.Conn().Close(context.Background())
+			conn.Release()
+			conn = nil
+			continue
+		}
+		b.wakeAll()
+	}
+}
+
+func (b *PostgresEventBus) setListening(listening bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.listening = listening
+	b.lastErr = err
+}
+
+func (b *PostgresEventBus) wakeAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subscriptions {
+		select {
+		case sub.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// cleanup periodically deletes events past the retention period that every
+// durable consumer has handled.
+func (b *PostgresEventBus) cleanup() {
+	defer b.wg.Done()
+	ticker := time.NewTicker(eventLogCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		query := `
+			DELETE FROM event_log l
+			WHERE l.published_at < $1
+			  AND NOT EXISTS (SELECT 1 FROM event_consumer_offsets o WHERE o.position < l.position)`
+		tag, err := b.pool.Exec(b.ctx, query, time.Now().Add(-eventLogRetention))
+		if err != nil {
+			b.logger.Error("Failed to clean up event log", "error", err)
+			continue
+		}
+		if n := tag.RowsAffected(); n > 0 {
+			b.logger.Info("Cleaned up event log", "deleted", n)
+		}
+	}
+}
+
+func (b *PostgresEventBus) Publish(ctx context.Context, event domain.Event) error {
+	if err := domain.ValidateEvent(event); err != nil {
+		return err
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	closed := b.closed
+	b.mu.Unlock()
+	if closed {
+		return ErrEventBusClosed
+	}
+
+	tx, err := b.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := appendEvent(ctx, tx, event.ID, routingKey(event.Type), data, nil); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// appendEvent adds an event to the log and notifies subscribers on commit.
+// The advisory lock is held until the transaction ends, so positions become
+// visible in order and a subscriber never skips a position that commits
+// after a later one.
+func appendEvent(ctx context.Context, tx pgx.Tx, eventID, key string, body []byte, targetConsumer *string) error {
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, eventLogLockID); err != nil {
+		return fmt.Errorf("failed to lock event log: %w", err)
+	}
+
+	var position int64
+	query := `
+		INSERT INTO event_log (event_id, routing_key, body, target_consumer)
+		VALUES ($1, $2, $3, $4)
+		RETURNING position`
+	if err := tx.QueryRow(ctx, query, eventID, key, json.RawMessage(body), targetConsumer).Scan(&position); err != nil {
+		return fmt.Errorf("failed to append event %s to event log: %w", eventID, err)
+	}
+
+	if _, err := tx.Exec(ctx, `SELECT pg_notify($1, $2)`, eventNotifyChannel, strconv.FormatInt(position, 10)); err != nil {
+		return fmt.Errorf("failed to notify event subscribers: %w", err)
+	}
+	return nil
+}
+
+func (b *PostgresEventBus) Subscribe(ctx context.Context, eventType string, handler func(domain.Event) error) error {
+	var position int64
+	if err := b.pool.QueryRow(ctx, `SELECT COALESCE(MAX(position), 0) FROM event_log`).Scan(&position); err != nil {
+		return fmt.Errorf("failed to read event log position: %w", err)
+	}
+	return b.subscribe(ctx, &postgresSubscription{
+		patterns: []string{routingKey(eventType)},
+		handler:  handler,
+		position: position,
+	})
+}
+
+// SubscribeDurable registers a named consumer. A new consumer starts at the
+// end of the log, like a newly declared queue.
+func (b *PostgresEventBus) SubscribeDurable(ctx context.Context, consumer string, eventTypes []string, handler func(domain.Event) error) error {
+	if consumer == "" {
+		return fmt.Errorf("consumer name is required for a durable subscription")
+	}
+
+	query := `
+		INSERT INTO event_consumer_offsets (consumer, position)
+		SELECT $1, COALESCE(MAX(position), 0) FROM event_log
+		ON CONFLICT (consumer) DO NOTHING`
+	if _, err := b.pool.Exec(ctx, query, consumer); err != nil {
+		return fmt.Errorf("failed to register consumer %s: %w", consumer, err)
+	}
+
+	sub := &postgresSubscription{consumer: consumer, handler: handler}
+	for _, eventType := range eventTypes {
+		sub.patterns = append(sub.patterns, routingKey(eventType))
+	}
+	return b.subscribe(ctx, sub)
+}
+
+func (b *PostgresEventBus) subscribe(ctx context.Context, sub *postgresSubscription) error {
+	sub.wake = make(chan struct{}, 1)
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return ErrEventBusClosed
+	}
+	b.subscriptions[sub] = struct{}{}
+	b.wg.Add(1)
+	b.mu.Unlock()
+
+	go b.run(ctx, sub)
+	return nil
+}
+
+// run handles new events of a subscription until ctx is done or the bus is
+// closed.
+func (b *PostgresEventBus) run(ctx context.Context, sub *postgresSubscription) {
+	defer b.wg.Done()
+	defer func() {
+		b.mu.Lock()
+		delete(b.subscriptions, sub)
+		b.mu.Unlock()
+	}()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(b.ctx, cancel)
+	defer stop()
+
+	ticker := time.NewTicker(postgresPollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			var n int
+			var err error
+			if sub.consumer == "" {
+				n, err = b.pollTransient(ctx, sub)
+			} else {
+				n, err = b.pollDurable(ctx, sub)
+			}
+			if err != nil {
+				if ctx.Err() == nil {
+					b.logger.Error("Failed to read event log", "consumer", sub.consumer, "error", err)
+				}
+				break
+			}
+			if n < postgresBatchSize {
+				break
+			}
+		}
+
+		var retryTimer *time.Timer
+		var retryDue <-chan time.Time
+		if sub.consumer != "" {
+			wait, pending, err := b.pollRetries(ctx, sub)
+			if err != nil {
+				if ctx.Err() == nil {
+					b.logger.Error("Failed to retry events", "consumer", sub.consumer, "error", err)
+				}
+			} else if pending {
+				retryTimer = time.NewTimer(wait)
+				retryDue = retryTimer.C
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-sub.wake:
+		case <-ticker.C:
+		case <-retryDue:
+		}
+		if retryTimer != nil {
+			retryTimer.Stop()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+func (s *postgresSubscription) matches(key string) bool {
+	for _, pattern := range s.patterns {
+		if topicMatches(pattern, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// logQuerier is satisfied by both the pool and a transaction.
+type logQuerier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// readLog reads the next batch of events after position that are either
+// broadcast or targeted at consumer.
+func readLog(ctx context.Context, q logQuerier, position int64, consumer string) ([]logRecord, error) {
+	query := `
+		SELECT position, event_id, routing_key, body, target_consumer
+		FROM event_log
+		WHERE position > $1 AND (target_consumer IS NULL OR target_consumer = $2)
+		ORDER BY position
+		LIMIT $3`
+
+	rows, err := q.Query(ctx, query, position, consumer, postgresBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []logRecord
+	for rows.Next() {
+		var r logRecord
+		if err := rows.Scan(&r.position, &r.eventID, &r.routingKey, &r.body, &r.targetConsumer); err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+func (b *PostgresEventBus) pollTransient(ctx context.Context, sub *postgresSubscription) (int, error) {
+	records, err := readLog(ctx, b.pool, sub.position, "")
+	if err != nil {
+		return 0, err
+	}
+
+	for _, r := range records {
+		sub.position = r.position
+		if !sub.matches(r.routingKey) {
+			continue
+		}
+		var event domain.Event
+		if err := json.Unmarshal(r.body, &event); err != nil {
+			b.logger.Error("Failed to unmarshal event", "position", r.position, "error", err)
+			continue
+		}
+		if err := sub.handler(event); err != nil {
+			b.logger.Error("Failed to handle event, dropping it", "error", err, "event_id", event.ID, "type", event.Type)
+		}
+	}
+	return len(records), nil
+}
+
+// pollDurable handles up to a batch of events of a durable consumer. Each
+// delivery is its own transaction, so a failure or a cancelled ctx only
+// affects the event being handled.
+func (b *PostgresEventBus) pollDurable(ctx context.Context, sub *postgresSubscription) (int, error) {
+	total := 0
+	for total < postgresBatchSize {
+		n, err := b.deliverNext(ctx, sub)
+		if err != nil {
+			return total, err
+		}
+		if n == 0 {
+			break
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// deliverNext hands the next event of a durable consumer to its handler
+// while holding the consumer's offset row, and advances the offset past it
+// and any events before it that the consumer is not bound to. It returns how
+// many log positions it moved past; if another instance holds the row it
+// returns without doing anything.
+func (b *PostgresEventBus) deliverNext(ctx context.Context, sub *postgresSubscription) (int, error) {
+	tx, err := b.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(context.Background())
+
+	var position int64
+	err = tx.QueryRow(ctx, `SELECT position FROM event_consumer_offsets WHERE consumer = $1 FOR UPDATE SKIP LOCKED`, sub.consumer).Scan(&position)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	records, err := readLog(ctx, tx, position, sub.consumer)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, r := range records {
+		position = r.position
+		n++
+		// Replayed dead letters are delivered whatever the bindings.
+		if r.targetConsumer != nil || sub.matches(r.routingKey) {
+			if err := b.deliverDurable(ctx, tx, sub, r); err != nil {
+				return 0, err
+			}
+			break
+		}
+	}
+
+	if n > 0 {
+		_, err = tx.Exec(ctx, `UPDATE event_consumer_offsets SET position = $2, updated_at = NOW() WHERE consumer = $1`, sub.consumer, position)
+		if err != nil {
+			return 0, fmt.Errorf("failed to advance offset of consumer %s: %w", sub.consumer, err)
+		}
+	}
+	return n, tx.Commit(ctx)
+}
+
+// deliverDurable hands an event to a durable consumer once. If the handler
+// fails the event is scheduled for a retry, and if it cannot be decoded it is
+// dead-lettered. It only returns an error if either could not be written.
+func (b *PostgresEventBus) deliverDurable(ctx context.Context, tx pgx.Tx, sub *postgresSubscription, r logRecord) error {
+	var event domain.Event
+	if err := json.Unmarshal(r.body, &event); err != nil {
+		b.logger.Error("Failed to unmarshal event, dead-lettering it", "consumer", sub.consumer, "position", r.position, "error", err)
+		return deadLetter(ctx, tx, sub.consumer, r.eventID, r.routingKey, r.body, 0, err)
+	}
+
+	err := sub.handler(event)
+	if err == nil {
+		return nil
+	}
+	if maxDeliveryRetries == 0 {
+		b.logger.Error("Failed to handle event, dead-lettering it", "consumer", sub.consumer, "event_id", event.ID, "type", event.Type, "error", err)
+		return deadLetter(ctx, tx, sub.consumer, r.eventID, r.routingKey, r.body, 0, err)
+	}
+
+	delay := retryDelay(b.retryDelay(), 1)
+	b.logger.Warn("Failed to handle event, scheduling retry", "consumer", sub.consumer, "event_id", event.ID, "type", event.Type, "retry", 1, "retry_in", delay, "error", err)
+	query := `
+		INSERT INTO event_retries (consumer, event_id, routing_key, body, retries, last_error, next_attempt_at)
+		VALUES ($1, $2, $3, $4, 1, $5, NOW() + make_interval(secs => $6))`
+	if _, err := tx.Exec(ctx, query, sub.consumer, r.eventID, r.routingKey, json.RawMessage(r.body), err.Error(), delay.Seconds()); err != nil {
+		return fmt.Errorf("failed to schedule retry of event %s: %w", event.ID, err)
+	}
+	return nil
+}
+
+func (b *PostgresEventBus) retryDelay() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.retryBaseDelay
+}
+
+// pollRetries retries up to a batch of due events of a durable consumer,
+// outside its offset transaction. It reports how long until the next retry
+// is due, if one is pending.
+func (b *PostgresEventBus) pollRetries(ctx context.Context, sub *postgresSubscription) (time.Duration, bool, error) {
+	for i := 0; i < postgresBatchSize; i++ {
+		retried, err := b.retryNext(ctx, sub)
+		if err != nil {
+			return 0, false, err
+		}
+		if !retried {
+			break
+		}
+	}
+
+	var seconds *float64
+	query := `SELECT EXTRACT(EPOCH FROM MIN(next_attempt_at) - NOW())::float8 FROM event_retries WHERE consumer = $1`
+	if err := b.pool.QueryRow(ctx, query, sub.consumer).Scan(&seconds); err != nil {
+		return 0, false, err
+	}
+	if seconds == nil {
+		return 0, false, nil
+	}
+	return max(time.Duration(*seconds*float64(time.Second)), time.Millisecond), true, nil
+}
+
+// retryNext hands the most overdue retry of a durable consumer to its
+// handler while holding its row, then removes it, schedules the next retry
+// with backoff, or dead-letters it once the retries are used up. It reports
+// whether a retry was due.
+func (b *PostgresEventBus) retryNext(ctx context.Context, sub *postgresSubscription) (bool, error) {
+	tx, err := b.pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(context.Background())
+
+	var id int64
+	var eventID, key string
+	var body []byte
+	var retries int
+	query := `
+		SELECT id, event_id, routing_key, body, retries
+		FROM event_retries
+		WHERE consumer = $1 AND next_attempt_at <= NOW()
+		ORDER BY next_attempt_at, id
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED`
+	err = tx.QueryRow(ctx, query, sub.consumer).Scan(&id, &eventID, &key, &body, &retries)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	var event domain.Event
+	if err = json.Unmarshal(body, &event); err == nil {
+		err = sub.handler(event)
+	}
+
+	switch {
+	case err == nil:
+	case retries >= maxDeliveryRetries:
+		b.logger.Error("Failed to handle event, retries exhausted, dead-lettering it", "consumer", sub.consumer, "event_id", eventID, "type", event.Type, "retries", retries, "error", err)
+		if err := deadLetter(ctx, tx, sub.consumer, eventID, key, body, retries, err); err != nil {
+			return false, err
+		}
+	default:
+		delay := retryDelay(b.retryDelay(), retries+1)
+		b.logger.Warn("Failed to handle event, scheduling retry", "consumer", sub.consumer, "event_id", eventID, "type", event.Type, "retry", retries+1, "retry_in", delay, "error", err)
+		query := `
+			UPDATE event_retries
+			SET retries = retries + 1, last_error = $2, next_attempt_at = NOW() + make_interval(secs => $3)
+			WHERE id = $1`
+		if _, err := tx.Exec(ctx, query, id, err.Error(), delay.Seconds()); err != nil {
+			return false, fmt.Errorf("failed to schedule retry of event %s: %w", eventID, err)
+		}
+		return true, tx.Commit(ctx)
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM event_retries WHERE id = $1`, id); err != nil {
+		return false, fmt.Errorf("failed to remove retry of event %s: %w", eventID, err)
+	}
+	return true, tx.Commit(ctx)
+}
+
+func deadLetter(ctx context.Context, tx pgx.Tx, consumer, eventID, key string, body []byte, retries int, cause error) error {
+	query := `
+		INSERT INTO event_dead_letters (consumer, event_id, routing_key, body, retries, last_error)
+		VALUES ($1, $2, $3, $4, $5, $6)`
+	if _, err := tx.Exec(ctx, query, consumer, eventID, key, json.RawMessage(body), retries, cause.Error()); err != nil {
+		return fmt.Errorf("failed to dead-letter event %s: %w", eventID, err)
+	}
+	return nil
+}
+
+// DeadLetters returns up to limit dead-lettered events of a consumer without
+// removing them.
+func (b *PostgresEventBus) DeadLetters(ctx context.Context, consumer string, limit int) ([]DeadLetter, error) {
+	query := `
+		SELECT event_id, routing_key, body, retries, last_error, dead_lettered_at
+		FROM event_dead_letters
+		WHERE consumer = $1
+		ORDER BY id
+		LIMIT NULLIF($2, 0)`
+
+	rows, err := b.pool.Query(ctx, query, consumer, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var letters []DeadLetter
+	for rows.Next() {
+		letter := DeadLetter{Consumer: consumer}
+		var eventID string
+		var body []byte
+		if err := rows.Scan(&eventID, &letter.RoutingKey, &body, &letter.Retries, &letter.Error, &letter.DeadLetteredAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(body, &letter.Event); err != nil {
+			letter.Event.ID = eventID
+		}
+		letters = append(letters, letter)
+	}
+	return letters, rows.Err()
+}
+
+// ReplayDeadLetters moves up to limit dead-lettered events back to the
+// consumer with a fresh retry budget. A limit of zero replays all of them.
+func (b *PostgresEventBus) ReplayDeadLetters(ctx context.Context, consumer string, limit int) (int, error) {
+	tx, err := b.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		DELETE FROM event_dead_letters
+		WHERE id IN (
+			SELECT id FROM event_dead_letters
+			WHERE consumer = $1
+			ORDER BY id
+			LIMIT NULLIF($2, 0)
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, event_id, routing_key, body`
+
+	rows, err := tx.Query(ctx, query, consumer, limit)
+	if err != nil {
+		return 0, err
+	}
+	type replay struct {
+		id      int64
+		eventID string
+		key     string
+		body    []byte
+	}
+	var replays []replay
+	for rows.Next() {
+		var r replay
+		if err := rows.Scan(&r.id, &r.eventID, &r.key, &r.body); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		replays = append(replays, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	// RETURNING does not preserve the subquery order.
+	sort.Slice(replays, func(i, j int) bool { return replays[i].id < replays[j].id })
+
+	for _, r := range replays {
+		if err := appendEvent(ctx, tx, r.eventID, r.key, r.body, &consumer); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return len(replays), nil
+}
+
+// HealthCheck reports whether the database is reachable and notifications
+// are being received.
+func (b *PostgresEventBus) HealthCheck(ctx context.Context) error {
+	b.mu.Lock()
+	closed, listening, lastErr := b.closed, b.listening, b.lastErr
+	b.mu.Unlock()
+
+	if closed {
+		return ErrEventBusClosed
+	}
+	if !listening {
+		return fmt.Errorf("event bus listener is disconnected: %w", lastErr)
+	}
+	return b.pool.Ping(ctx)
+}
+
+// Close stops all subscriptions. It does not close the pool, which belongs
+// to the caller.
+func (b *PostgresEventBus) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	b.mu.Unlock()
+
+	b.cancel()
+	b.wg.Wait()
+	return nil
+}
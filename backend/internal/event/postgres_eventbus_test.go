@@ -0,0 +1,166 @@
+package event
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/pressly/goose/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/forfarm/backend/internal/domain"
+	"github.com/forfarm/backend/migrations"
+)
+
+// newTestPostgresBus connects to the database in TEST_DATABASE_URL, migrates
+// it and returns a bus with a short retry delay and a fresh consumer name.
+// Tests using it are skipped without a database.
+func newTestPostgresBus(t *testing.T) (*PostgresEventBus, *pgxpool.Pool, string) {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	ctx := context.Background()
+
+	db, err := sql.Open("pgx", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+	goose.SetBaseFS(migrations.EmbedMigrations)
+	require.NoError(t, goose.UpContext(ctx, db, "."))
+
+	pool, err := pgxpool.New(ctx, dsn)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+
+	bus, err := NewPostgresEventBus(ctx, pool, nil)
+	require.NoError(t, err)
+	bus.SetRetryDelay(10 * time.Millisecond)
+	t.Cleanup(func() { bus.Close() })
+
+	consumer := "test-" + uuid.NewString()
+	t.Cleanup(func() {
+		for _, table := range []string{"event_consumer_offsets", "event_retries", "event_dead_letters"} {
+			pool.Exec(ctx, "DELETE FROM "+table+" WHERE consumer = $1", consumer)
+		}
+	})
+	return bus, pool, consumer
+}
+
+func TestPostgresEventBus_SubscribeDurableRetriesWithoutBlocking(t *testing.T) {
+	bus, pool, consumer := newTestPostgresBus(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	first := newFarmEvent(t, "farm.updated", "farm-1", "North field")
+	second := newFarmEvent(t, "farm.updated", "farm-2", "South field")
+
+	var mu sync.Mutex
+	var handled []string
+	var attempts atomic.Int32
+	require.NoError(t, bus.SubscribeDurable(ctx, consumer, []string{"farm.updated"}, func(e domain.Event) error {
+		if e.ID == first.ID && attempts.Add(1) < 3 {
+			return errors.New("transient failure")
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		handled = append(handled, e.ID)
+		return nil
+	}))
+
+	require.NoError(t, bus.Publish(ctx, first))
+	require.NoError(t, bus.Publish(ctx, second))
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(handled) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, []string{second.ID, first.ID}, handled, "a failing event does not hold back later ones")
+	assert.Equal(t, int32(3), attempts.Load())
+
+	var pending int
+	require.NoError(t, pool.QueryRow(ctx, `SELECT COUNT(*) FROM event_retries WHERE consumer = $1`, consumer).Scan(&pending))
+	assert.Zero(t, pending)
+}
+
+func TestPostgresEventBus_SubscribeDurableDeadLetters(t *testing.T) {
+	bus, _, consumer := newTestPostgresBus(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var attempts atomic.Int32
+	var fixed atomic.Bool
+	replayed := make(chan struct{})
+	require.NoError(t, bus.SubscribeDurable(ctx, consumer, []string{"farm.*"}, func(e domain.Event) error {
+		if fixed.Load() {
+			close(replayed)
+			return nil
+		}
+		attempts.Add(1)
+		return errors.New("poison")
+	}))
+
+	farmDeleted := newTestEvent(t, "farm.deleted", "farm-1", &domain.FarmDeletedPayload{UUID: "farm-1"})
+	require.NoError(t, bus.Publish(ctx, farmDeleted))
+
+	require.Eventually(t, func() bool {
+		letters, _ := bus.DeadLetters(ctx, consumer, 0)
+		return len(letters) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	letters, err := bus.DeadLetters(ctx, consumer, 0)
+	require.NoError(t, err)
+	assert.Equal(t, farmDeleted.ID, letters[0].Event.ID)
+	assert.Equal(t, "events.farm.deleted", letters[0].RoutingKey)
+	assert.Equal(t, maxDeliveryRetries, letters[0].Retries)
+	assert.Equal(t, "poison", letters[0].Error)
+	assert.Equal(t, int32(maxDeliveryRetries+1), attempts.Load())
+
+	fixed.Store(true)
+	n, err := bus.ReplayDeadLetters(ctx, consumer, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	select {
+	case <-replayed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("replayed dead letter was not delivered")
+	}
+}
+
+func TestPostgresEventBus_SubscribeDurableResumesFromOffset(t *testing.T) {
+	bus, _, consumer := newTestPostgresBus(t)
+	ctx := context.Background()
+
+	subCtx, cancel := context.WithCancel(ctx)
+	require.NoError(t, bus.SubscribeDurable(subCtx, consumer, []string{"farm.updated"}, func(domain.Event) error { return nil }))
+	cancel()
+
+	// Published while no instance of the consumer is running.
+	missed := newFarmEvent(t, "farm.updated", "farm-1", "North field")
+	require.NoError(t, bus.Publish(ctx, missed))
+
+	handled := make(chan string, 1)
+	require.NoError(t, bus.SubscribeDurable(ctx, consumer, []string{"farm.updated"}, func(e domain.Event) error {
+		handled <- e.ID
+		return nil
+	}))
+
+	select {
+	case id := <-handled:
+		assert.Equal(t, missed.ID, id)
+	case <-time.After(5 * time.Second):
+		t.Fatal("event published while the consumer was stopped was not delivered")
+	}
+}
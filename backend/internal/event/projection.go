@@ -2,15 +2,19 @@ package event
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
+	"strings"
 	"time"
 
 	"github.com/forfarm/backend/internal/domain"
 )
 
+// FarmAnalyticsConsumer is the durable queue the farm analytics projection
+// consumes from.
+const FarmAnalyticsConsumer = "farm-analytics-projection"
+
 type FarmAnalyticsProjection struct {
 	eventSubscriber domain.EventSubscriber
 	repository      domain.AnalyticsRepository
@@ -34,124 +38,127 @@ func NewFarmAnalyticsProjection(
 
 func (p *FarmAnalyticsProjection) Start(ctx context.Context) error {
 	eventTypes := []string{
-		"farm.created", "farm.updated", "farm.deleted",
+		"farm.created", "farm.updated", "farm.deleted", "farm.status_changed",
 		"weather.updated",
 		"cropland.created", "cropland.updated", "cropland.deleted",
 		"inventory.item.created", "inventory.item.updated", "inventory.item.deleted",
 	}
 
-	p.logger.Info("FarmAnalyticsProjection starting, subscribing to events", "types", eventTypes)
-
-	var errs []error
-	for _, eventType := range eventTypes {
-		if err := p.eventSubscriber.Subscribe(ctx, eventType, p.handleEvent); err != nil {
-			p.logger.Error("Failed to subscribe to event type", "type", eventType, "error", err)
-			errs = append(errs, fmt.Errorf("failed to subscribe to %s: %w", eventType, err))
-		} else {
-			p.logger.Info("Successfully subscribed to event type", "type", eventType)
-		}
-	}
+	p.logger.Info("FarmAnalyticsProjection starting, subscribing to events", "consumer", FarmAnalyticsConsumer, "types", eventTypes)
 
-	if len(errs) > 0 {
-		return errors.Join(errs...)
+	if err := p.eventSubscriber.SubscribeDurable(ctx, FarmAnalyticsConsumer, eventTypes, p.handleEvent); err != nil {
+		p.logger.Error("Failed to subscribe to events", "consumer", FarmAnalyticsConsumer, "error", err)
+		return fmt.Errorf("failed to subscribe %s: %w", FarmAnalyticsConsumer, err)
 	}
 
 	p.logger.Info("FarmAnalyticsProjection started successfully")
 	return nil
 }
 
+// Rebuild resets farm analytics, for one farm or for all farms when farmID is
+// empty, and replays the stored events through the projection. It runs in one
+// transaction that live event handling waits for, so nothing applied live is
+// lost to the reset. Events that fail to apply are logged and counted rather
+// than aborting the rebuild. Replayed events bypass the processed-events
+// registry, since the live consumer has already recorded them.
+func (p *FarmAnalyticsProjection) Rebuild(ctx context.Context, store domain.EventStore, farmID string) (replayed int, failed int, err error) {
+	err = p.repository.RunExclusive(ctx, func(repo domain.AnalyticsRepository) error {
+		if err := repo.ResetFarmAnalytics(ctx, farmID); err != nil {
+			return err
+		}
+
+		err := store.ForEach(ctx, farmID, func(event domain.Event) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := p.applyEvent(ctx, repo, event); err != nil {
+				p.logger.Warn("Failed to replay event", "event_id", event.ID, "type", event.Type, "error", err)
+				failed++
+				return nil
+			}
+			replayed++
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to read event store: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, failed, err
+	}
+	return replayed, failed, nil
+}
+
 func (p *FarmAnalyticsProjection) handleEvent(event domain.Event) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
 	p.logger.Debug("Handling event in FarmAnalyticsProjection", "type", event.Type, "aggregate_id", event.AggregateID, "event_id", event.ID)
 
-	farmID := event.AggregateID
+	_, err := p.repository.ApplyEventOnce(ctx, FarmAnalyticsConsumer, event, eventStream(event.Type), func(repo domain.AnalyticsRepository) error {
+		return p.applyEvent(ctx, repo, event)
+	})
+	return err
+}
 
-	// Try to get farmID from payload if AggregateID is empty or potentially not the farmID (e.g., user events)
-	if farmID == "" || event.Type == "inventory.item.created" || event.Type == "inventory.item.updated" || event.Type == "inventory.item.deleted" || event.Type == "cropland.created" || event.Type == "cropland.updated" || event.Type == "cropland.deleted" {
-		payloadMap, ok := event.Payload.(map[string]interface{})
-		if ok {
-			if idVal, ok := payloadMap["farm_id"].(string); ok && idVal != "" {
-				farmID = idVal
-			} else if event.Type != "farm.deleted" && event.Type != "farm.created" {
-				p.logger.Warn("Could not determine farm_id from event payload or AggregateID", "event_type", event.Type, "event_id", event.ID, "aggregate_id", event.AggregateID)
-				return nil
-			}
-		} else if event.Type != "farm.deleted" && event.Type != "farm.created" {
-			p.logger.Error("Event payload is not a map, cannot extract farm_id", "event_type", event.Type, "event_id", event.ID)
-			return nil
-		}
+// eventStream groups event types whose order matters relative to each other,
+// e.g. "farm.created" and "farm.updated" are both in the "farm" stream.
+// Status changes are derived from other events and only ordered among
+// themselves.
+func eventStream(eventType string) string {
+	if eventType == "farm.status_changed" {
+		return "farm.status"
 	}
+	stream, _, _ := strings.Cut(eventType, ".")
+	return stream
+}
 
-	if farmID == "" && event.Type != "farm.deleted" {
-		p.logger.Error("Cannot process event, missing farm_id", "event_type", event.Type, "event_id", event.ID, "aggregate_id", event.AggregateID)
-		return nil
-	}
+func (p *FarmAnalyticsProjection) applyEvent(ctx context.Context, repo domain.AnalyticsRepository, event domain.Event) error {
+	farmID := domain.EventFarmID(event)
 
 	var err error
-	switch event.Type {
-	case "farm.created", "farm.updated":
-		var farmData domain.Farm
-		jsonData, _ := json.Marshal(event.Payload)
-		if err = json.Unmarshal(jsonData, &farmData); err != nil {
-			p.logger.Error("Failed to unmarshal farm data from event payload", "event_id", event.ID, "error", err)
-			return nil
-		}
-		if farmData.UUID == "" {
-			farmData.UUID = event.AggregateID
-		}
+	switch payload := event.Payload.(type) {
+	case *domain.FarmPayload:
+		p.logger.Info("Processing farm event", "event_type", event.Type, "farm_id", payload.UUID, "owner_id", payload.OwnerID)
+		err = repo.CreateOrUpdateFarmBaseData(ctx, payload.Farm())
 
-		p.logger.Info("Processing farm event", "event_type", event.Type, "farm_id", farmData.UUID, "owner_id", farmData.OwnerID)
-		err = p.repository.CreateOrUpdateFarmBaseData(ctx, &farmData)
+	case *domain.FarmDeletedPayload:
+		err = repo.DeleteFarmAnalytics(ctx, payload.UUID)
 
-	case "farm.deleted":
-		farmID = event.AggregateID
-		if farmID == "" {
-			p.logger.Error("Cannot process farm.deleted event, missing farm_id in AggregateID", "event_id", event.ID)
-			return nil
-		}
-		err = p.repository.DeleteFarmAnalytics(ctx, farmID)
+	case *domain.FarmStatusChangedPayload:
+		err = repo.UpdateFarmOverallStatus(ctx, payload.FarmID, payload.Status)
 
-	case "weather.updated":
-		var weatherData domain.WeatherData
-		jsonData, _ := json.Marshal(event.Payload)
-		if err = json.Unmarshal(jsonData, &weatherData); err != nil {
-			p.logger.Error("Failed to unmarshal weather data from event payload", "event_id", event.ID, "error", err)
-			return nil
-		}
-		err = p.repository.UpdateFarmAnalyticsWeather(ctx, farmID, &weatherData)
+	case *domain.WeatherUpdatedPayload:
+		err = repo.UpdateFarmAnalyticsWeather(ctx, payload.FarmID, &payload.WeatherData)
 
-	case "cropland.created", "cropland.updated", "cropland.deleted":
-		payloadMap, ok := event.Payload.(map[string]interface{})
-		if !ok {
-			p.logger.Error("Failed to cast cropland event payload to map", "event_id", event.ID)
-			return nil
-		}
-		idVal, ok := payloadMap["farm_id"].(string)
-		if !ok || idVal == "" {
-			p.logger.Error("Missing farm_id in cropland event payload", "event_id", event.ID, "event_type", event.Type)
-			return nil
-		}
-		farmID = idVal
-		err = p.repository.UpdateFarmAnalyticsCropStats(ctx, farmID)
-
-	case "inventory.item.created", "inventory.item.updated", "inventory.item.deleted":
-		if farmID != "" {
-			err = p.repository.UpdateFarmAnalyticsInventoryStats(ctx, farmID)
-		} else {
-			p.logger.Warn("Skipping inventory stats update due to missing farm_id", "event_id", event.ID)
+	case *domain.CroplandPayload, *domain.CroplandDeletedPayload:
+		if farmID == "" {
+			// Only cropland.deleted events from before schema version 2 lack a farm.
+			p.logger.Warn("Skipping cropland event without farm_id", "event_id", event.ID, "event_type", event.Type)
 			return nil
 		}
+		err = repo.UpdateFarmAnalyticsCropStats(ctx, farmID)
+
+	case *domain.InventoryItemPayload, *domain.InventoryItemDeletedPayload:
+		// Inventory items belong to a user rather than a farm.
+		p.logger.Debug("Skipping inventory event, no farm to update", "event_id", event.ID, "event_type", event.Type)
+		return nil
 
 	default:
-		p.logger.Warn("Received unhandled event type", "type", event.Type, "event_id", event.ID)
+		p.logger.Warn("Received unhandled event type", "type", event.Type, "event_id", event.ID, "payload", fmt.Sprintf("%T", event.Payload))
 		return nil
 	}
 
+	if errors.Is(err, domain.ErrNotFound) {
+		p.logger.Warn("Farm analytics not found, skipping event", "event_type", event.Type, "farm_id", farmID, "event_id", event.ID)
+		return nil
+	}
 	if err != nil {
+		// Returning the error lets the bus retry the event and eventually
+		// dead-letter it instead of silently losing the update.
 		p.logger.Error("Failed to update farm analytics", "event_type", event.Type, "farm_id", farmID, "error", err)
-		return nil
+		return fmt.Errorf("failed to update farm analytics for %s: %w", event.Type, err)
 	}
 
 	p.logger.Debug("Successfully processed event and updated farm analytics", "event_type", event.Type, "farm_id", farmID)
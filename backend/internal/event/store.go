@@ -0,0 +1,29 @@
+package event
+
+import (
+	"context"
+
+	"github.com/forfarm/backend/internal/domain"
+)
+
+// StoringPublisher appends every event to the event store before handing it
+// to the wrapped publisher. Appending is idempotent, so a publish retried by
+// the outbox relay is stored once.
+type StoringPublisher struct {
+	store     domain.EventStore
+	publisher domain.EventPublisher
+}
+
+func NewStoringPublisher(store domain.EventStore, publisher domain.EventPublisher) *StoringPublisher {
+	return &StoringPublisher{store: store, publisher: publisher}
+}
+
+func (s *StoringPublisher) Publish(ctx context.Context, event domain.Event) error {
+	if err := domain.ValidateEvent(event); err != nil {
+		return err
+	}
+	if err := s.store.Append(ctx, event, domain.EventFarmID(event)); err != nil {
+		return err
+	}
+	return s.publisher.Publish(ctx, event)
+}
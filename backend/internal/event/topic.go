@@ -0,0 +1,51 @@
+package event
+
+import "strings"
+
+const (
+	exchangeName      = "events"
+	routingKeyPrefix  = "events."
+	topicWordSingle   = "*"
+	topicWordMultiple = "#"
+)
+
+// routingKey returns the routing key an event type is published under.
+func routingKey(eventType string) string {
+	return routingKeyPrefix + eventType
+}
+
+// topicMatches reports whether a routing key matches a binding pattern using
+// the same rules as a RabbitMQ topic exchange: words are separated by dots,
+// "*" matches exactly one word and "#" matches zero or more words.
+func topicMatches(pattern, key string) bool {
+	return matchWords(strings.Split(pattern, "."), strings.Split(key, "."))
+}
+
+func matchWords(pattern, key []string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case topicWordMultiple:
+			rest := pattern[1:]
+			if len(rest) == 0 {
+				return true
+			}
+			for i := 0; i <= len(key); i++ {
+				if matchWords(rest, key[i:]) {
+					return true
+				}
+			}
+			return false
+		case topicWordSingle:
+			if len(key) == 0 {
+				return false
+			}
+		default:
+			if len(key) == 0 || pattern[0] != key[0] {
+				return false
+			}
+		}
+		pattern = pattern[1:]
+		key = key[1:]
+	}
+	return len(key) == 0
+}
@@ -0,0 +1,173 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/forfarm/backend/internal/domain"
+)
+
+// WebhookDispatcherConsumer is the durable queue the webhook dispatcher
+// consumes from.
+const WebhookDispatcherConsumer = "webhook-dispatcher"
+
+// WebhookEventTypes are the event types webhooks can subscribe to.
+var WebhookEventTypes = []string{"farm.*", "cropland.*", "weather.updated", "inventory.item.*"}
+
+// WebhookEvent is the body POSTed to a webhook endpoint.
+type WebhookEvent struct {
+	ID            string      `json:"id"`
+	Type          string      `json:"type"`
+	Source        string      `json:"source"`
+	Timestamp     time.Time   `json:"timestamp"`
+	AggregateID   string      `json:"aggregateId"`
+	SchemaVersion int         `json:"schemaVersion"`
+	Payload       interface{} `json:"payload"`
+}
+
+// WebhookDispatcher queues a delivery for every active webhook whose filters
+// match an event of its owner. Deliveries are sent by the webhook delivery
+// worker, so a slow endpoint never holds up the bus.
+type WebhookDispatcher struct {
+	eventSubscriber domain.EventSubscriber
+	webhookRepo     domain.WebhookRepository
+	farmRepo        domain.FarmRepository
+	logger          *slog.Logger
+}
+
+func NewWebhookDispatcher(
+	subscriber domain.EventSubscriber,
+	webhookRepo domain.WebhookRepository,
+	farmRepo domain.FarmRepository,
+	logger *slog.Logger,
+) *WebhookDispatcher {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &WebhookDispatcher{
+		eventSubscriber: subscriber,
+		webhookRepo:     webhookRepo,
+		farmRepo:        farmRepo,
+		logger:          logger,
+	}
+}
+
+func (d *WebhookDispatcher) Start(ctx context.Context) error {
+	if err := d.eventSubscriber.SubscribeDurable(ctx, WebhookDispatcherConsumer, WebhookEventTypes, d.handleEvent); err != nil {
+		return fmt.Errorf("failed to subscribe %s: %w", WebhookDispatcherConsumer, err)
+	}
+	d.logger.Info("WebhookDispatcher started", "consumer", WebhookDispatcherConsumer, "types", WebhookEventTypes)
+	return nil
+}
+
+func (d *WebhookDispatcher) handleEvent(event domain.Event) error {
+	ctx := context.Background()
+
+	ownerID, farmID, err := d.resolveOwner(ctx, event)
+	if err != nil {
+		return err
+	}
+	if ownerID == "" {
+		d.logger.Debug("No owner for event, skipping webhooks", "event_id", event.ID, "type", event.Type)
+		return nil
+	}
+
+	webhooks, err := d.webhookRepo.GetByOwnerID(ctx, ownerID)
+	if err != nil {
+		return fmt.Errorf("failed to load webhooks of user %s: %w", ownerID, err)
+	}
+
+	var body []byte
+	for _, w := range webhooks {
+		if !w.Active || !webhookMatches(w, event.Type, farmID) {
+			continue
+		}
+		if body == nil {
+			body, err = json.Marshal(WebhookEvent{
+				ID:            event.ID,
+				Type:          event.Type,
+				Source:        event.Source,
+				Timestamp:     event.Timestamp,
+				AggregateID:   event.AggregateID,
+				SchemaVersion: event.SchemaVersion,
+				Payload:       event.Payload,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to marshal webhook body for event %s: %w", event.ID, err)
+			}
+		}
+		err := d.webhookRepo.EnqueueDelivery(ctx, &domain.WebhookDelivery{
+			WebhookID: w.UUID,
+			EventID:   event.ID,
+			EventType: event.Type,
+			Payload:   body,
+		})
+		if err != nil {
+			// Deliveries already queued are ignored on redelivery.
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveOwner returns the user an event belongs to and the farm it concerns,
+// if any.
+func (d *WebhookDispatcher) resolveOwner(ctx context.Context, event domain.Event) (ownerID, farmID string, err error) {
+	switch p := event.Payload.(type) {
+	case *domain.FarmPayload:
+		return p.OwnerID, p.UUID, nil
+	case *domain.FarmDeletedPayload:
+		if p.OwnerID != "" {
+			return p.OwnerID, p.UUID, nil
+		}
+	case *domain.InventoryItemPayload:
+		return p.UserID, "", nil
+	case *domain.InventoryItemDeletedPayload:
+		return p.UserID, "", nil
+	}
+
+	farmID = domain.EventFarmID(event)
+	if farmID == "" {
+		return "", "", nil
+	}
+	farm, err := d.farmRepo.GetByID(ctx, farmID)
+	if errors.Is(err, domain.ErrNotFound) {
+		// The farm is gone, and so are its webhooks' reasons to care.
+		return "", farmID, nil
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("failed to load farm %s: %w", farmID, err)
+	}
+	return farm.OwnerID, farmID, nil
+}
+
+// webhookMatches reports whether a webhook's filters accept an event. Event
+// type filters use the bus's topic patterns, so "farm.*" matches every farm
+// event. Events that concern no farm pass any farm filter.
+func webhookMatches(w domain.Webhook, eventType, farmID string) bool {
+	if len(w.EventTypes) > 0 {
+		matched := false
+		for _, pattern := range w.EventTypes {
+			if topicMatches(pattern, eventType) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(w.FarmIDs) > 0 && farmID != "" {
+		for _, id := range w.FarmIDs {
+			if id == farmID {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
@@ -1,35 +1,197 @@
 package middlewares
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"strings"
 
 	"github.com/danielgtaylor/huma/v2"
+	"github.com/forfarm/backend/internal/domain"
 	"github.com/forfarm/backend/internal/utilities"
 )
 
-func AuthMiddleware(api huma.API) func(ctx huma.Context, next func(huma.Context)) {
+// Policy decides who may call an operation.
+type Policy string
+
+const (
+	// PolicyPublic lets anyone call the operation. A valid token still puts
+	// the caller into the context.
+	PolicyPublic Policy = "public"
+	// PolicyAuthenticated requires a valid token.
+	PolicyAuthenticated Policy = "authenticated"
+	// PolicyOwner requires a valid token; the handler only acts on resources
+	// the caller owns.
+	PolicyOwner Policy = "owner"
+	// PolicyAdmin requires a valid token with the admin role, issued for a
+	// session opened with two-factor authentication.
+	PolicyAdmin Policy = "admin"
+)
+
+// BearerAuthScheme is the OpenAPI security scheme of operations that need a
+// token.
+const BearerAuthScheme = "bearer"
+
+const (
+	policyMetadataKey = "authPolicy"
+	scopeMetadataKey  = "authScope"
+)
+
+// SessionChecker reports whether a login session is still active.
+type SessionChecker interface {
+	IsActive(ctx context.Context, sessionID string) (bool, error)
+}
+
+// APIKeyAuthenticator looks up a usable API key by its hash.
+type APIKeyAuthenticator interface {
+	Authenticate(ctx context.Context, keyHash string) (*domain.APIKey, error)
+}
+
+// WithPolicy sets the policy AuthMiddleware enforces for op.
+func WithPolicy(policy Policy, op huma.Operation) huma.Operation {
+	if op.Metadata == nil {
+		op.Metadata = map[string]any{}
+	}
+	op.Metadata[policyMetadataKey] = policy
+	if policy != PolicyPublic {
+		op.Security = []map[string][]string{{BearerAuthScheme: {}}}
+	}
+	return op
+}
+
+// WithScope opens op to API keys granted scope. Operations without a scope
+// can only be called with an access token.
+func WithScope(scope string, op huma.Operation) huma.Operation {
+	if op.Metadata == nil {
+		op.Metadata = map[string]any{}
+	}
+	op.Metadata[scopeMetadataKey] = scope
+	for _, requirement := range op.Security {
+		if scopes, ok := requirement[BearerAuthScheme]; ok {
+			requirement[BearerAuthScheme] = append(scopes, scope)
+		}
+	}
+	return op
+}
+
+// AuthMiddleware authenticates the bearer token or API key, puts the caller
+// into the request context and enforces the operation's policy. Tokens of
+// revoked or expired sessions are rejected, and API keys only reach
+// operations that require a scope they were granted. Operations registered
+// without a policy are refused, so a route cannot be exposed by forgetting
+// one.
+func AuthMiddleware(api huma.API, sessions SessionChecker, keys APIKeyAuthenticator) func(ctx huma.Context, next func(huma.Context)) {
 	return func(ctx huma.Context, next func(huma.Context)) {
-		authHeader := ctx.Header("Authorization")
-		if authHeader == "" {
-			huma.WriteErr(api, ctx, http.StatusUnauthorized, "No token provided")
+		policy, ok := ctx.Operation().Metadata[policyMetadataKey].(Policy)
+		if !ok {
+			huma.WriteErr(api, ctx, http.StatusForbidden, "No access policy for this operation")
 			return
 		}
 
-		tokenStr := strings.TrimPrefix(authHeader, "Bearer ")
+		authHeader := ctx.Header("Authorization")
+		tokenStr := strings.TrimSpace(strings.TrimPrefix(authHeader, "Bearer "))
+
 		if tokenStr == "" {
+			if policy == PolicyPublic {
+				next(ctx)
+				return
+			}
 			huma.WriteErr(api, ctx, http.StatusUnauthorized, "No token provided")
 			return
 		}
 
-		err := utilities.VerifyJwtToken(tokenStr)
-
+		var principal domain.Principal
+		var err error
+		if utilities.IsAPIKey(tokenStr) {
+			principal, err = apiKeyPrincipal(ctx.Context(), keys, tokenStr)
+		} else {
+			principal, err = tokenPrincipal(ctx.Context(), sessions, tokenStr)
+		}
+		if err != nil && !errors.Is(err, errInvalidCredential) {
+			huma.WriteErr(api, ctx, http.StatusInternalServerError, "Failed to verify credentials")
+			return
+		}
 		if err != nil {
+			if policy == PolicyPublic {
+				next(ctx)
+				return
+			}
 			huma.WriteErr(api, ctx, http.StatusUnauthorized, "Invalid token")
 			return
 		}
 
-		next(ctx)
+		if policy == PolicyAdmin && !principal.IsAdmin() {
+			huma.WriteErr(api, ctx, http.StatusForbidden, "Administrator role required")
+			return
+		}
+		if policy == PolicyAdmin && !principal.TwoFactor {
+			huma.WriteErr(api, ctx, http.StatusForbidden, "Administrators must sign in with two-factor authentication")
+			return
+		}
+		if principal.APIKeyID != "" && policy != PolicyPublic {
+			scope, _ := ctx.Operation().Metadata[scopeMetadataKey].(string)
+			if scope == "" {
+				huma.WriteErr(api, ctx, http.StatusForbidden, "This operation cannot be called with an API key")
+				return
+			}
+			if !principal.HasScope(scope) {
+				huma.WriteErr(api, ctx, http.StatusForbidden, "API key lacks the "+scope+" scope")
+				return
+			}
+		}
+
+		next(huma.WithContext(ctx, domain.ContextWithPrincipal(ctx.Context(), principal)))
+	}
+}
+
+// errInvalidCredential marks a credential that is wrong, as opposed to one
+// that could not be checked.
+var errInvalidCredential = errors.New("invalid credential")
+
+func tokenPrincipal(ctx context.Context, sessions SessionChecker, tokenStr string) (domain.Principal, error) {
+	claims, err := utilities.ParseJwtToken(tokenStr)
+	if err != nil || claims.SessionID == "" {
+		return domain.Principal{}, errInvalidCredential
+	}
+	active, err := sessions.IsActive(ctx, claims.SessionID)
+	if err != nil {
+		return domain.Principal{}, err
+	}
+	if !active {
+		return domain.Principal{}, errInvalidCredential
+	}
+
+	principal := domain.Principal{
+		UserID:    claims.UserID,
+		Role:      claims.Role,
+		SessionID: claims.SessionID,
+		TwoFactor: claims.TwoFactor,
+	}
+	if principal.Role == "" {
+		principal.Role = domain.RoleUser
+	}
+	return principal, nil
+}
+
+// apiKeyPrincipal authenticates an API key. Keys act as a regular user even
+// for administrators.
+func apiKeyPrincipal(ctx context.Context, keys APIKeyAuthenticator, key string) (domain.Principal, error) {
+	apiKey, err := keys.Authenticate(ctx, utilities.HashAPIKey(key))
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.Principal{}, errInvalidCredential
+	}
+	if err != nil {
+		return domain.Principal{}, err
 	}
 
+	principal := domain.Principal{
+		UserID:   apiKey.UserID,
+		Role:     domain.RoleUser,
+		APIKeyID: apiKey.UUID,
+		Scopes:   apiKey.Scopes,
+	}
+	if apiKey.FarmID != nil {
+		principal.FarmID = *apiKey.FarmID
+	}
+	return principal, nil
 }
@@ -0,0 +1,139 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/humatest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/forfarm/backend/internal/domain"
+	"github.com/forfarm/backend/internal/utilities"
+)
+
+type whoAmIOutput struct {
+	Body struct {
+		UserID string `json:"userId"`
+	}
+}
+
+func whoAmI(ctx context.Context, _ *struct{}) (*whoAmIOutput, error) {
+	resp := &whoAmIOutput{}
+	if p, ok := domain.PrincipalFromContext(ctx); ok {
+		resp.Body.UserID = p.UserID
+	}
+	return resp, nil
+}
+
+type fakeSessions map[string]bool
+
+func (f fakeSessions) IsActive(_ context.Context, sessionID string) (bool, error) {
+	return f[sessionID], nil
+}
+
+type fakeAPIKeys map[string]*domain.APIKey
+
+func (f fakeAPIKeys) Authenticate(_ context.Context, keyHash string) (*domain.APIKey, error) {
+	if k, ok := f[keyHash]; ok {
+		return k, nil
+	}
+	return nil, domain.ErrNotFound
+}
+
+func TestAuthMiddleware_Policies(t *testing.T) {
+	userID := "123e4567-e89b-12d3-a456-426614174000"
+	apiKey, _, apiKeyHash, err := utilities.NewAPIKey()
+	require.NoError(t, err)
+
+	_, api := humatest.New(t)
+	api.UseMiddleware(AuthMiddleware(api,
+		fakeSessions{"active": true, "revoked": false},
+		fakeAPIKeys{apiKeyHash: {UUID: "key", UserID: userID, Scopes: []string{domain.ScopeFarmsRead}}},
+	))
+
+	for path, policy := range map[string]Policy{
+		"/public": PolicyPublic,
+		"/authn":  PolicyAuthenticated,
+		"/admin":  PolicyAdmin,
+	} {
+		huma.Register(api, WithPolicy(policy, huma.Operation{
+			OperationID: path,
+			Method:      http.MethodGet,
+			Path:        path,
+		}), whoAmI)
+	}
+	huma.Register(api, huma.Operation{OperationID: "none", Method: http.MethodGet, Path: "/none"}, whoAmI)
+	for path, scope := range map[string]string{
+		"/farms": domain.ScopeFarmsRead,
+		"/crops": domain.ScopeCropsWrite,
+	} {
+		huma.Register(api, WithScope(scope, WithPolicy(PolicyOwner, huma.Operation{
+			OperationID: path,
+			Method:      http.MethodGet,
+			Path:        path,
+		})), whoAmI)
+	}
+
+	userToken, err := utilities.CreateJwtToken(userID, "active")
+	require.NoError(t, err)
+	revokedToken, err := utilities.CreateJwtToken(userID, "revoked")
+	require.NoError(t, err)
+	noSessionToken, err := utilities.CreateJwtToken(userID, "")
+	require.NoError(t, err)
+	adminToken, err := utilities.IssueAccessToken(utilities.TokenClaims{
+		UserID:    userID,
+		SessionID: "active",
+		Role:      domain.RoleAdmin,
+		TwoFactor: true,
+	})
+	require.NoError(t, err)
+	adminWithout2FAToken, err := utilities.IssueAccessToken(utilities.TokenClaims{
+		UserID:    userID,
+		SessionID: "active",
+		Role:      domain.RoleAdmin,
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		path   string
+		token  string
+		status int
+	}{
+		{"public anonymous", "/public", "", http.StatusOK},
+		{"public with bad token", "/public", "garbage", http.StatusOK},
+		{"authenticated anonymous", "/authn", "", http.StatusUnauthorized},
+		{"authenticated bad token", "/authn", "garbage", http.StatusUnauthorized},
+		{"authenticated", "/authn", userToken, http.StatusOK},
+		{"revoked session", "/authn", revokedToken, http.StatusUnauthorized},
+		{"token without session", "/authn", noSessionToken, http.StatusUnauthorized},
+		{"public with revoked session", "/public", revokedToken, http.StatusOK},
+		{"admin as user", "/admin", userToken, http.StatusForbidden},
+		{"admin", "/admin", adminToken, http.StatusOK},
+		{"admin without two-factor", "/admin", adminWithout2FAToken, http.StatusForbidden},
+		{"no policy", "/none", adminToken, http.StatusForbidden},
+		{"api key with scope", "/farms", apiKey, http.StatusOK},
+		{"api key without scope", "/crops", apiKey, http.StatusForbidden},
+		{"api key on unscoped operation", "/authn", apiKey, http.StatusForbidden},
+		{"api key on public operation", "/public", apiKey, http.StatusOK},
+		{"unknown api key", "/farms", utilities.APIKeyPrefix + "unknown", http.StatusUnauthorized},
+		{"token on scoped operation", "/crops", userToken, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var args []any
+			if tt.token != "" {
+				args = append(args, "Authorization: Bearer "+tt.token)
+			}
+			resp := api.Get(tt.path, args...)
+			assert.Equal(t, tt.status, resp.Code, resp.Body.String())
+			if tt.status == http.StatusOK && (tt.token == userToken || tt.token == apiKey) {
+				assert.Contains(t, resp.Body.String(), userID)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package middlewares
+
+import (
+	"net"
+
+	"github.com/danielgtaylor/huma/v2"
+
+	"github.com/forfarm/backend/internal/domain"
+)
+
+// ClientIP is the address a request came from. Behind a proxy it is only the
+// client's own when the router trusts forwarding headers.
+func ClientIP(ctx huma.Context) string {
+	host, _, err := net.SplitHostPort(ctx.RemoteAddr())
+	if err != nil {
+		return ctx.RemoteAddr()
+	}
+	return host
+}
+
+// RequestInfoMiddleware stores the client address and user agent in the
+// context of every operation.
+func RequestInfoMiddleware(ctx huma.Context, next func(huma.Context)) {
+	info := domain.RequestInfo{
+		IPAddress: ClientIP(ctx),
+		UserAgent: ctx.Header("User-Agent"),
+	}
+	next(huma.WithContext(ctx, domain.ContextWithRequestInfo(ctx.Context(), info)))
+}
@@ -13,3 +13,13 @@ type Connection interface {
 	QueryRow(context.Context, string, ...interface{}) pgx.Row
 	BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error)
 }
+
+// txConnection runs repository code written against Connection inside an
+// existing transaction. BeginTx opens a savepoint in that transaction.
+type txConnection struct {
+	pgx.Tx
+}
+
+func (c txConnection) BeginTx(ctx context.Context, _ pgx.TxOptions) (pgx.Tx, error) {
+	return c.Tx.Begin(ctx)
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/forfarm/backend/internal/domain"
+)
+
+type postgresAPIKeyRepository struct {
+	conn Connection
+}
+
+func NewPostgresAPIKey(conn Connection) domain.APIKeyRepository {
+	return &postgresAPIKeyRepository{conn: conn}
+}
+
+const apiKeyColumns = `k.uuid, k.user_id, k.name, k.prefix, k.key_hash, k.scopes, k.farm_id::text, k.created_at, k.expires_at, k.last_used_at, k.revoked_at`
+
+func scanAPIKey(row pgx.Row) (*domain.APIKey, error) {
+	var k domain.APIKey
+	if err := row.Scan(
+		&k.UUID,
+		&k.UserID,
+		&k.Name,
+		&k.Prefix,
+		&k.KeyHash,
+		&k.Scopes,
+		&k.FarmID,
+		&k.CreatedAt,
+		&k.ExpiresAt,
+		&k.LastUsedAt,
+		&k.RevokedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+func (p *postgresAPIKeyRepository) Create(ctx context.Context, k *domain.APIKey) error {
+	if strings.TrimSpace(k.UUID) == "" {
+		k.UUID = uuid.New().String()
+	}
+
+	query := `
+		INSERT INTO api_keys (uuid, user_id, name, prefix, key_hash, scopes, farm_id, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING created_at`
+
+	return p.conn.QueryRow(ctx, query, k.UUID, k.UserID, k.Name, k.Prefix, k.KeyHash, k.Scopes, k.FarmID, k.ExpiresAt).
+		Scan(&k.CreatedAt)
+}
+
+func (p *postgresAPIKeyRepository) GetByID(ctx context.Context, id string) (*domain.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys k WHERE k.uuid = $1`
+	k, err := scanAPIKey(p.conn.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	return k, err
+}
+
+func (p *postgresAPIKeyRepository) GetByUserID(ctx context.Context, userID string) ([]domain.APIKey, error) {
+	query := `
+		SELECT ` + apiKeyColumns + `
+		FROM api_keys k
+		WHERE k.user_id = $1 AND k.revoked_at IS NULL
+		ORDER BY k.created_at DESC`
+
+	rows, err := p.conn.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []domain.APIKey{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *k)
+	}
+	return keys, rows.Err()
+}
+
+func (p *postgresAPIKeyRepository) Revoke(ctx context.Context, id string) error {
+	tag, err := p.conn.Exec(ctx, `UPDATE api_keys SET revoked_at = COALESCE(revoked_at, NOW()) WHERE uuid = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (p *postgresAPIKeyRepository) Authenticate(ctx context.Context, keyHash string) (*domain.APIKey, error) {
+	query := `
+		SELECT ` + apiKeyColumns + `
+		FROM api_keys k
+		JOIN users u ON u.uuid = k.user_id
+		WHERE k.key_hash = $1
+		  AND k.revoked_at IS NULL
+		  AND (k.expires_at IS NULL OR k.expires_at > NOW())
+		  AND u.is_active`
+
+	k, err := scanAPIKey(p.conn.QueryRow(ctx, query, keyHash))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Busy clients call many times a minute; a coarser timestamp spares a
+	// write on most of those requests.
+	if k.LastUsedAt == nil || time.Since(*k.LastUsedAt) > time.Minute {
+		if _, err := p.conn.Exec(ctx, `UPDATE api_keys SET last_used_at = NOW() WHERE uuid = $1`, k.UUID); err != nil {
+			return nil, fmt.Errorf("failed to record api key use: %w", err)
+		}
+	}
+	return k, nil
+}
@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/forfarm/backend/internal/domain"
+)
+
+type postgresAuditLogRepository struct {
+	conn Connection
+}
+
+func NewPostgresAuditLog(conn Connection) domain.AuditLogRepository {
+	return &postgresAuditLogRepository{conn: conn}
+}
+
+func (p *postgresAuditLogRepository) Record(ctx context.Context, e *domain.AuditEntry) error {
+	if strings.TrimSpace(e.UUID) == "" {
+		e.UUID = uuid.New().String()
+	}
+	details := e.Details
+	if len(details) == 0 {
+		details = json.RawMessage(`{}`)
+	}
+	var changes interface{}
+	if len(e.Changes) > 0 {
+		changes = e.Changes
+	}
+
+	query := `
+		INSERT INTO audit_log (uuid, action, user_id, actor_id, api_key_id, resource_type, resource_id, farm_id,
+		                       ip_address, user_agent, changes, details)
+		VALUES ($1, $2, NULLIF($3, '')::uuid, NULLIF($4, '')::uuid, NULLIF($5, '')::uuid, $6, $7, NULLIF($8, '')::uuid,
+		        $9, $10, $11, $12)
+		RETURNING created_at`
+
+	return p.conn.QueryRow(ctx, query,
+		e.UUID, e.Action, e.UserID, e.ActorID, e.APIKeyID, e.ResourceType, e.ResourceID, e.FarmID,
+		e.IPAddress, e.UserAgent, changes, details,
+	).Scan(&e.CreatedAt)
+}
+
+func (p *postgresAuditLogRepository) List(ctx context.Context, filter domain.AuditLogFilter) ([]domain.AuditEntry, error) {
+	var conditions []string
+	var args []interface{}
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.FarmID != "" {
+		where("farm_id = $%d", filter.FarmID)
+	}
+	if filter.UserID != "" {
+		where("user_id = $%d", filter.UserID)
+	}
+	if filter.ActorID != "" {
+		where("actor_id = $%d", filter.ActorID)
+	}
+	if filter.Action != "" {
+		where("action = $%d", filter.Action)
+	}
+	if filter.ResourceType != "" {
+		where("resource_type = $%d", filter.ResourceType)
+	}
+	if filter.ResourceID != "" {
+		where("resource_id = $%d", filter.ResourceID)
+	}
+	if filter.Since != nil {
+		where("created_at >= $%d", *filter.Since)
+	}
+	if filter.Until != nil {
+		where("created_at < $%d", *filter.Until)
+	}
+	if filter.Before != nil {
+		args = append(args, filter.Before.CreatedAt, filter.Before.UUID)
+		conditions = append(conditions, fmt.Sprintf("(created_at, uuid) < ($%d, $%d::uuid)", len(args)-1, len(args)))
+	}
+
+	query := `
+		SELECT uuid, action, COALESCE(user_id::text, ''), COALESCE(actor_id::text, ''), COALESCE(api_key_id::text, ''),
+		       resource_type, resource_id, COALESCE(farm_id::text, ''), ip_address, user_agent, changes, details, created_at
+		FROM audit_log`
+	if len(conditions) > 0 {
+		query += "\n\t\tWHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf("\n\t\tORDER BY created_at DESC, uuid DESC\n\t\tLIMIT $%d", len(args))
+
+	rows, err := p.conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []domain.AuditEntry{}
+	for rows.Next() {
+		var e domain.AuditEntry
+		if err := rows.Scan(
+			&e.UUID,
+			&e.Action,
+			&e.UserID,
+			&e.ActorID,
+			&e.APIKeyID,
+			&e.ResourceType,
+			&e.ResourceID,
+			&e.FarmID,
+			&e.IPAddress,
+			&e.UserAgent,
+			&e.Changes,
+			&e.Details,
+			&e.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func (p *postgresAuditLogRepository) Anonymize(ctx context.Context, userID string, farmIDs []string) error {
+	tx, err := p.conn.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	about := `
+		UPDATE audit_log
+		SET user_id = CASE WHEN user_id = $1 THEN NULL ELSE user_id END, changes = NULL, details = '{}'
+		WHERE user_id = $1 OR farm_id = ANY($2::uuid[])`
+	if _, err := tx.Exec(ctx, about, userID, farmIDs); err != nil {
+		return err
+	}
+
+	by := `
+		UPDATE audit_log
+		SET actor_id = NULL, api_key_id = NULL, ip_address = '', user_agent = ''
+		WHERE actor_id = $1`
+	if _, err := tx.Exec(ctx, by, userID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
@@ -2,29 +2,24 @@ package repository
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
-	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 
 	"github.com/forfarm/backend/internal/domain"
 )
 
 type postgresCroplandRepository struct {
-	conn           Connection
-	eventPublisher domain.EventPublisher
+	conn Connection
 }
 
 func NewPostgresCropland(conn Connection) domain.CroplandRepository {
 	return &postgresCroplandRepository{conn: conn}
 }
 
-func (p *postgresCroplandRepository) SetEventPublisher(publisher domain.EventPublisher) {
-	p.eventPublisher = publisher
-}
-
 func (p *postgresCroplandRepository) fetch(ctx context.Context, query string, args ...interface{}) ([]domain.Cropland, error) {
 	rows, err := p.conn.Query(ctx, query, args...)
 	if err != nil {
@@ -90,6 +85,12 @@ func (p *postgresCroplandRepository) CreateOrUpdate(ctx context.Context, c *doma
 		c.GeoFeature = nil
 	}
 
+	tx, err := p.conn.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
 	query := `
 	INSERT INTO croplands (
 		uuid, name, status, priority, land_size, growth_stage,
@@ -103,95 +104,63 @@ func (p *postgresCroplandRepository) CreateOrUpdate(ctx context.Context, c *doma
 		geo_feature = EXCLUDED.geo_feature, updated_at = NOW()
 	RETURNING uuid, created_at, updated_at`
 
-	err := p.conn.QueryRow(
+	err = tx.QueryRow(
 		ctx, query,
 		c.UUID, c.Name, c.Status, c.Priority, c.LandSize, c.GrowthStage,
 		c.PlantID, c.FarmID, c.GeoFeature,
 	).Scan(&c.UUID, &c.CreatedAt, &c.UpdatedAt)
-
 	if err != nil {
 		return err
 	}
 
-	if p.eventPublisher != nil {
-		eventType := "cropland.updated"
-		if isNew {
-			eventType = "cropland.created"
-		}
-
-		// Avoid sending raw json.RawMessage directly if possible
-		var geoFeatureMap interface{}
-		if c.GeoFeature != nil {
-			_ = json.Unmarshal(c.GeoFeature, &geoFeatureMap)
-		}
-		payload := map[string]interface{}{
-			"uuid":        c.UUID,
-			"name":        c.Name,
-			"status":      c.Status,
-			"priority":    c.Priority,
-			"landSize":    c.LandSize,
-			"growthStage": c.GrowthStage,
-			"plantId":     c.PlantID,
-			"farmId":      c.FarmID,
-			"geoFeature":  geoFeatureMap,
-			"createdAt":   c.CreatedAt,
-			"updatedAt":   c.UpdatedAt,
-			"event_type":  eventType,
-		}
+	eventType := "cropland.updated"
+	if isNew {
+		eventType = "cropland.created"
+	}
 
-		event := domain.Event{
-			ID:          uuid.NewString(),
-			Type:        eventType,
-			Source:      "cropland-repository",
-			Timestamp:   time.Now().UTC(),
-			AggregateID: c.UUID,
-			Payload:     payload,
-		}
-		go func() {
-			bgCtx := context.Background()
-			if errPub := p.eventPublisher.Publish(bgCtx, event); errPub != nil {
-				fmt.Printf("Error publishing %s event: %v\n", eventType, errPub) // Replace with proper logging
-			}
-		}()
+	event, err := domain.NewEvent(eventType, "cropland-repository", c.UUID, domain.NewCroplandPayload(c))
+	if err != nil {
+		return err
+	}
+	if err := writeOutboxEvent(ctx, tx, event); err != nil {
+		return err
 	}
 
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
 	return nil
 }
 
-func (p *postgresCroplandRepository) Delete(ctx context.Context, uuid string) error {
-	// Optional: Fetch details before deleting if needed for event payload
-	// cropland, err := p.GetByID(ctx, uuid) // Might fail if already deleted, handle carefully
-	// if err != nil && !errors.Is(err, domain.ErrNotFound){ return err } // Return actual errors
-
-	query := `DELETE FROM croplands WHERE uuid = $1`
-	_, err := p.conn.Exec(ctx, query, uuid)
+func (p *postgresCroplandRepository) Delete(ctx context.Context, id string) error {
+	tx, err := p.conn.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback(ctx)
 
-	if p.eventPublisher != nil {
-		eventType := "cropland.deleted"
-		payload := map[string]interface{}{
-			"crop_id": uuid,
-			// Include farm_id if easily available or fetched before delete
-			// "farm_id": cropland.FarmID
-			"event_type": eventType,
-		}
-		event := domain.Event{
-			ID:          uuid,
-			Type:        eventType,
-			Source:      "cropland-repository",
-			Timestamp:   time.Now().UTC(),
-			AggregateID: uuid,
-			Payload:     payload,
+	var farmID string
+	query := `DELETE FROM croplands WHERE uuid = $1 RETURNING farm_id`
+	if err := tx.QueryRow(ctx, query, id).Scan(&farmID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.ErrNotFound
 		}
-		go func() {
-			bgCtx := context.Background()
-			if errPub := p.eventPublisher.Publish(bgCtx, event); errPub != nil {
-				fmt.Printf("Error publishing %s event: %v\n", eventType, errPub)
-			}
-		}()
+		return err
 	}
 
+	event, err := domain.NewEvent("cropland.deleted", "cropland-repository", id, &domain.CroplandDeletedPayload{
+		UUID:   id,
+		FarmID: farmID,
+	})
+	if err != nil {
+		return err
+	}
+	if err := writeOutboxEvent(ctx, tx, event); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
 	return nil
 }
@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/forfarm/backend/internal/domain"
+)
+
+type postgresDataExportRepository struct {
+	conn Connection
+}
+
+func NewPostgresDataExport(conn Connection) domain.DataExportRepository {
+	return &postgresDataExportRepository{conn: conn}
+}
+
+const dataExportColumns = `uuid, user_id, status, size, error, created_at, completed_at, expires_at`
+
+func scanDataExport(row pgx.Row) (*domain.DataExport, error) {
+	var e domain.DataExport
+	if err := row.Scan(
+		&e.UUID,
+		&e.UserID,
+		&e.Status,
+		&e.Size,
+		&e.Error,
+		&e.CreatedAt,
+		&e.CompletedAt,
+		&e.ExpiresAt,
+	); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (p *postgresDataExportRepository) Create(ctx context.Context, e *domain.DataExport) error {
+	if strings.TrimSpace(e.UUID) == "" {
+		e.UUID = uuid.New().String()
+	}
+	e.Status = domain.DataExportPending
+
+	query := `
+		INSERT INTO data_exports (uuid, user_id, status)
+		VALUES ($1, $2, $3)
+		RETURNING created_at`
+	return p.conn.QueryRow(ctx, query, e.UUID, e.UserID, e.Status).Scan(&e.CreatedAt)
+}
+
+func (p *postgresDataExportRepository) GetByID(ctx context.Context, id string) (*domain.DataExport, error) {
+	query := `SELECT ` + dataExportColumns + ` FROM data_exports WHERE uuid = $1`
+	e, err := scanDataExport(p.conn.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	return e, err
+}
+
+func (p *postgresDataExportRepository) ListByUserID(ctx context.Context, userID string) ([]domain.DataExport, error) {
+	query := `SELECT ` + dataExportColumns + ` FROM data_exports WHERE user_id = $1 ORDER BY created_at DESC`
+
+	rows, err := p.conn.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exports := []domain.DataExport{}
+	for rows.Next() {
+		e, err := scanDataExport(rows)
+		if err != nil {
+			return nil, err
+		}
+		exports = append(exports, *e)
+	}
+	return exports, rows.Err()
+}
+
+func (p *postgresDataExportRepository) Claim(ctx context.Context, lease time.Duration) (*domain.DataExport, error) {
+	query := `
+		UPDATE data_exports
+		SET status = 'running', lease_expires_at = NOW() + make_interval(secs => $1)
+		WHERE uuid = (
+			SELECT uuid FROM data_exports
+			WHERE status = 'pending' OR (status = 'running' AND lease_expires_at < NOW())
+			ORDER BY created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + dataExportColumns
+
+	e, err := scanDataExport(p.conn.QueryRow(ctx, query, lease.Seconds()))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	return e, err
+}
+
+func (p *postgresDataExportRepository) Complete(ctx context.Context, id string, archive []byte, expiresAt time.Time) error {
+	query := `
+		UPDATE data_exports
+		SET status = 'ready', archive = $2, size = $3, completed_at = NOW(), expires_at = $4, lease_expires_at = NULL
+		WHERE uuid = $1`
+	tag, err := p.conn.Exec(ctx, query, id, archive, len(archive), expiresAt)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (p *postgresDataExportRepository) Fail(ctx context.Context, id, reason string) error {
+	query := `
+		UPDATE data_exports
+		SET status = 'failed', error = $2, completed_at = NOW(), lease_expires_at = NULL
+		WHERE uuid = $1`
+	tag, err := p.conn.Exec(ctx, query, id, reason)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (p *postgresDataExportRepository) GetArchive(ctx context.Context, id string) ([]byte, error) {
+	var archive []byte
+	err := p.conn.QueryRow(ctx, `SELECT archive FROM data_exports WHERE uuid = $1 AND archive IS NOT NULL`, id).Scan(&archive)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	return archive, err
+}
+
+func (p *postgresDataExportRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	tag, err := p.conn.Exec(ctx, `DELETE FROM data_exports WHERE expires_at < $1`, now)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/forfarm/backend/internal/domain"
+)
+
+type postgresEventStore struct {
+	conn Connection
+}
+
+func NewPostgresEventStore(conn Connection) domain.EventStore {
+	return &postgresEventStore{conn: conn}
+}
+
+func (p *postgresEventStore) Append(ctx context.Context, event domain.Event, farmID string) error {
+	payload, err := json.Marshal(event.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s event payload: %w", event.Type, err)
+	}
+
+	var farm *string
+	if farmID != "" {
+		farm = &farmID
+	}
+
+	query := `
+		INSERT INTO analytics_events (event_id, farm_id, event_type, source, aggregate_id, event_data, schema_version, occurred_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (event_id) DO NOTHING`
+
+	_, err = p.conn.Exec(ctx, query, event.ID, farm, event.Type, event.Source, event.AggregateID, json.RawMessage(payload), event.SchemaVersion, event.Timestamp)
+	if err != nil {
+		return fmt.Errorf("failed to append %s event to event store: %w", event.Type, err)
+	}
+	return nil
+}
+
+func (p *postgresEventStore) ForEach(ctx context.Context, farmID string, fn func(domain.Event) error) error {
+	query := `
+		SELECT event_id, event_type, COALESCE(source, ''), COALESCE(aggregate_id, ''), event_data, schema_version, occurred_at
+		FROM analytics_events
+		WHERE event_id IS NOT NULL AND ($1 = '' OR farm_id::text = $1)
+		ORDER BY id`
+
+	return p.forEach(ctx, fn, query, farmID)
+}
+
+func (p *postgresEventStore) ForEachAfter(ctx context.Context, afterEventID string, farmIDs []string, fn func(domain.Event) error) error {
+	var position int64
+	err := p.conn.QueryRow(ctx, `SELECT id FROM analytics_events WHERE event_id = $1`, afterEventID).Scan(&position)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	query := `
+		SELECT event_id, event_type, COALESCE(source, ''), COALESCE(aggregate_id, ''), event_data, schema_version, occurred_at
+		FROM analytics_events
+		WHERE event_id IS NOT NULL AND id > $1 AND (farm_id IS NULL OR farm_id = ANY($2::uuid[]))
+		ORDER BY id`
+
+	return p.forEach(ctx, fn, query, position, farmIDs)
+}
+
+func (p *postgresEventStore) Forget(ctx context.Context, userID string, farmIDs []string) error {
+	query := `
+		DELETE FROM analytics_events
+		WHERE farm_id = ANY($2::uuid[]) OR event_data->>'userId' = $1 OR event_data->>'user_id' = $1`
+	_, err := p.conn.Exec(ctx, query, userID, farmIDs)
+	return err
+}
+
+func (p *postgresEventStore) forEach(ctx context.Context, fn func(domain.Event) error, query string, args ...interface{}) error {
+	rows, err := p.conn.Query(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e domain.Event
+		var payload []byte
+		if err := rows.Scan(&e.ID, &e.Type, &e.Source, &e.AggregateID, &payload, &e.SchemaVersion, &e.Timestamp); err != nil {
+			return err
+		}
+		e.Payload, e.SchemaVersion, err = domain.DecodeEventPayload(e.Type, e.SchemaVersion, payload)
+		if err != nil {
+			return fmt.Errorf("failed to decode stored payload for event %s: %w", e.ID, err)
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -3,8 +3,8 @@ package repository
 import (
 	"context"
 	"errors"
+	"fmt"
 	"strings"
-	"time"
 
 	"github.com/forfarm/backend/internal/domain"
 	"github.com/google/uuid"
@@ -12,18 +12,13 @@ import (
 )
 
 type postgresFarmRepository struct {
-	conn           Connection
-	eventPublisher domain.EventPublisher
+	conn Connection
 }
 
 func NewPostgresFarm(conn Connection) domain.FarmRepository {
 	return &postgresFarmRepository{conn: conn}
 }
 
-func (p *postgresFarmRepository) SetEventPublisher(publisher domain.EventPublisher) {
-	p.eventPublisher = publisher
-}
-
 func (p *postgresFarmRepository) fetch(ctx context.Context, query string, args ...interface{}) ([]domain.Farm, error) {
 	rows, err := p.conn.Query(ctx, query, args...)
 	if err != nil {
@@ -183,8 +178,54 @@ func (p *postgresFarmRepository) GetByOwnerID(ctx context.Context, ownerID strin
 	if err != nil {
 		return nil, err
 	}
+	return p.attachCroplands(ctx, farms), nil
+}
+
+func (p *postgresFarmRepository) GetByMemberID(ctx context.Context, userID string) ([]domain.Farm, error) {
+	query := `
+		SELECT f.uuid, f.name, f.lat, f.lon, f.farm_type, f.total_size, f.created_at, f.updated_at, f.owner_id,
+		       CASE WHEN f.owner_id = $1 THEN 'owner' ELSE m.role END
+		FROM farms f
+		LEFT JOIN farm_members m ON m.farm_id = f.uuid AND m.user_id = $1
+		WHERE f.owner_id = $1 OR m.user_id IS NOT NULL
+		ORDER BY f.created_at`
+
+	rows, err := p.conn.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var farms []domain.Farm
+	for rows.Next() {
+		var f domain.Farm
+		if err := rows.Scan(
+			&f.UUID,
+			&f.Name,
+			&f.Lat,
+			&f.Lon,
+			&f.FarmType,
+			&f.TotalSize,
+			&f.CreatedAt,
+			&f.UpdatedAt,
+			&f.OwnerID,
+			&f.Role,
+		); err != nil {
+			return nil, err
+		}
+		farms = append(farms, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return p.attachCroplands(ctx, farms), nil
+}
+
+// attachCroplands fills in the croplands of farms. Farms are returned without
+// croplands if they cannot be fetched.
+func (p *postgresFarmRepository) attachCroplands(ctx context.Context, farms []domain.Farm) []domain.Farm {
 	if len(farms) == 0 {
-		return []domain.Farm{}, nil
+		return []domain.Farm{}
 	}
 
 	farmIDs := make([]string, 0, len(farms))
@@ -197,7 +238,7 @@ func (p *postgresFarmRepository) GetByOwnerID(ctx context.Context, ownerID strin
 	croplandsByFarmID, err := p.fetchCroplandsByFarmIDs(ctx, farmIDs)
 	if err != nil {
 		println("Warning: Failed to fetch croplands for farms:", err.Error())
-		return farms, nil
+		return farms
 	}
 
 	for farmID, croplands := range croplandsByFarmID {
@@ -206,7 +247,7 @@ func (p *postgresFarmRepository) GetByOwnerID(ctx context.Context, ownerID strin
 		}
 	}
 
-	return farms, nil
+	return farms
 }
 
 func (p *postgresFarmRepository) CreateOrUpdate(ctx context.Context, f *domain.Farm) error {
@@ -216,6 +257,12 @@ func (p *postgresFarmRepository) CreateOrUpdate(ctx context.Context, f *domain.F
 		f.UUID = uuid.New().String()
 	}
 
+	tx, err := p.conn.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
 	query := `
 		INSERT INTO farms (uuid, name, lat, lon, farm_type, total_size, created_at, updated_at, owner_id)
 		VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW(), $7)
@@ -228,52 +275,60 @@ func (p *postgresFarmRepository) CreateOrUpdate(ctx context.Context, f *domain.F
 		    updated_at = NOW(),
 		    owner_id = EXCLUDED.owner_id
 		RETURNING uuid, created_at, updated_at`
-	err := p.conn.QueryRow(ctx, query, f.UUID, f.Name, f.Lat, f.Lon, f.FarmType, f.TotalSize, f.OwnerID).
+	err = tx.QueryRow(ctx, query, f.UUID, f.Name, f.Lat, f.Lon, f.FarmType, f.TotalSize, f.OwnerID).
 		Scan(&f.UUID, &f.CreatedAt, &f.UpdatedAt)
+	if err != nil {
+		return err
+	}
 
+	eventType := "farm.updated"
+	if isNew {
+		eventType = "farm.created"
+	}
+
+	event, err := domain.NewEvent(eventType, "farm-repository", f.UUID, domain.NewFarmPayload(f))
 	if err != nil {
 		return err
 	}
+	if err := writeOutboxEvent(ctx, tx, event); err != nil {
+		return err
+	}
 
-	if p.eventPublisher != nil {
-		eventType := "farm.updated"
-		if isNew {
-			eventType = "farm.created"
-		}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (p *postgresFarmRepository) Delete(ctx context.Context, farmID string) error {
+	tx, err := p.conn.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
 
-		event := domain.Event{
-			ID:          uuid.New().String(),
-			Type:        eventType,
-			Source:      "farm-repository",
-			Timestamp:   time.Now(),
-			AggregateID: f.UUID,
-			Payload: map[string]interface{}{
-				"uuid":      f.UUID,
-				"name":      f.Name,
-				"lat":       f.Lat,
-				"lon":       f.Lon,
-				"location":  map[string]float64{"lat": f.Lat, "lon": f.Lon},
-				"farmType":  f.FarmType,
-				"totalSize": f.TotalSize,
-				"ownerId":   f.OwnerID,
-				"createdAt": f.CreatedAt,
-				"updatedAt": f.UpdatedAt,
-			},
+	var ownerID string
+	query := `DELETE FROM farms WHERE uuid = $1 RETURNING owner_id`
+	if err := tx.QueryRow(ctx, query, farmID).Scan(&ownerID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.ErrNotFound
 		}
+		return err
+	}
 
-		go func() {
-			bgCtx := context.Background()
-			if err := p.eventPublisher.Publish(bgCtx, event); err != nil {
-				println("Failed to publish event", err.Error())
-			}
-		}()
+	event, err := domain.NewEvent("farm.deleted", "farm-repository", farmID, &domain.FarmDeletedPayload{
+		UUID:    farmID,
+		OwnerID: ownerID,
+	})
+	if err != nil {
+		return err
+	}
+	if err := writeOutboxEvent(ctx, tx, event); err != nil {
+		return err
 	}
 
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
 	return nil
 }
-
-func (p *postgresFarmRepository) Delete(ctx context.Context, uuid string) error {
-	query := `DELETE FROM farms WHERE uuid = $1`
-	_, err := p.conn.Exec(ctx, query, uuid)
-	return err
-}
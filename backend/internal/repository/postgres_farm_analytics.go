@@ -372,6 +372,60 @@ func (r *postgresFarmAnalyticsRepository) DeleteFarmAnalytics(ctx context.Contex
 	return nil
 }
 
+func (r *postgresFarmAnalyticsRepository) ResetFarmAnalytics(ctx context.Context, farmID string) error {
+	if farmID == "" {
+		if _, err := r.conn.Exec(ctx, `TRUNCATE TABLE public.farm_analytics`); err != nil {
+			return fmt.Errorf("failed to truncate farm analytics: %w", err)
+		}
+	} else if _, err := r.conn.Exec(ctx, `DELETE FROM public.farm_analytics WHERE farm_id = $1`, farmID); err != nil {
+		return fmt.Errorf("failed to reset farm analytics for farm %s: %w", farmID, err)
+	}
+
+	seed := `
+		INSERT INTO public.farm_analytics (farm_id, farm_name, owner_id, farm_type, total_size, latitude, longitude,
+			crop_total_count, crop_growing_count, crop_last_updated, analytics_last_updated)
+		SELECT f.uuid, f.name, f.owner_id, f.farm_type, f.total_size, f.lat, f.lon,
+			COUNT(c.uuid), COUNT(c.uuid) FILTER (WHERE lower(c.status) = 'growing'), NOW(), NOW()
+		FROM public.farms f
+		LEFT JOIN public.croplands c ON c.farm_id = f.uuid
+		WHERE $1 = '' OR f.uuid::text = $1
+		GROUP BY f.uuid`
+	cmdTag, err := r.conn.Exec(ctx, seed, farmID)
+	if err != nil {
+		return fmt.Errorf("failed to seed farm analytics: %w", err)
+	}
+	r.logger.Info("Reset farm analytics", "farm_id", farmID, "seeded", cmdTag.RowsAffected())
+	return nil
+}
+
+// farmAnalyticsLock is held shared while a live event is applied and
+// exclusively during a rebuild.
+const farmAnalyticsLock = `hashtext('farm_analytics')`
+
+func (r *postgresFarmAnalyticsRepository) RunExclusive(ctx context.Context, fn func(domain.AnalyticsRepository) error) error {
+	tx, err := r.conn.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(`+farmAnalyticsLock+`)`); err != nil {
+		return fmt.Errorf("failed to lock farm analytics: %w", err)
+	}
+	txRepo := &postgresFarmAnalyticsRepository{
+		conn:             txConnection{tx},
+		logger:           r.logger,
+		analyticsService: r.analyticsService,
+	}
+	if err := fn(txRepo); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
 func (r *postgresFarmAnalyticsRepository) UpdateFarmOverallStatus(ctx context.Context, farmID string, status string) error {
 	query := `
 		UPDATE public.farm_analytics SET
@@ -390,3 +444,70 @@ func (r *postgresFarmAnalyticsRepository) UpdateFarmOverallStatus(ctx context.Co
 	r.logger.Debug("Updated farm overall status", "farm_id", farmID, "status", status)
 	return nil
 }
+
+func (r *postgresFarmAnalyticsRepository) ApplyEventOnce(
+	ctx context.Context,
+	consumer string,
+	event domain.Event,
+	stream string,
+	apply func(domain.AnalyticsRepository) error,
+) (bool, error) {
+	tx, err := r.conn.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Waits for a rebuild in progress; see RunExclusive.
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock_shared(`+farmAnalyticsLock+`)`); err != nil {
+		return false, fmt.Errorf("failed to lock farm analytics: %w", err)
+	}
+
+	cmdTag, err := tx.Exec(ctx, `
+		INSERT INTO public.processed_events (consumer, event_id)
+		VALUES ($1, $2)
+		ON CONFLICT (consumer, event_id) DO NOTHING`,
+		consumer, event.ID)
+	if err != nil {
+		return false, fmt.Errorf("failed to record processed event %s: %w", event.ID, err)
+	}
+	if cmdTag.RowsAffected() == 0 {
+		r.logger.Debug("Skipping already processed event", "consumer", consumer, "event_id", event.ID, "type", event.Type)
+		return false, nil
+	}
+
+	if event.AggregateID != "" && stream != "" {
+		cmdTag, err = tx.Exec(ctx, `
+			INSERT INTO public.consumer_aggregate_positions (consumer, aggregate_id, stream, last_event_at)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (consumer, aggregate_id, stream) DO UPDATE
+			SET last_event_at = EXCLUDED.last_event_at
+			WHERE consumer_aggregate_positions.last_event_at <= EXCLUDED.last_event_at`,
+			consumer, event.AggregateID, stream, event.Timestamp)
+		if err != nil {
+			return false, fmt.Errorf("failed to update aggregate position for event %s: %w", event.ID, err)
+		}
+		if cmdTag.RowsAffected() == 0 {
+			// Keep the processed marker so the stale event is not retried.
+			r.logger.Info("Skipping stale event", "consumer", consumer, "event_id", event.ID, "type", event.Type, "aggregate_id", event.AggregateID, "occurred_at", event.Timestamp)
+			if err := tx.Commit(ctx); err != nil {
+				return false, fmt.Errorf("failed to commit transaction: %w", err)
+			}
+			return false, nil
+		}
+	}
+
+	txRepo := &postgresFarmAnalyticsRepository{
+		conn:             txConnection{tx},
+		logger:           r.logger,
+		analyticsService: r.analyticsService,
+	}
+	if err := apply(txRepo); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,223 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/forfarm/backend/internal/domain"
+)
+
+type postgresFarmMemberRepository struct {
+	conn Connection
+}
+
+func NewPostgresFarmMember(conn Connection) domain.FarmMemberRepository {
+	return &postgresFarmMemberRepository{conn: conn}
+}
+
+func (p *postgresFarmMemberRepository) GetRole(ctx context.Context, farmID, userID string) (string, error) {
+	query := `
+		SELECT CASE WHEN f.owner_id = $2 THEN 'owner' ELSE m.role END
+		FROM farms f
+		LEFT JOIN farm_members m ON m.farm_id = f.uuid AND m.user_id = $2
+		WHERE f.uuid = $1 AND (f.owner_id = $2 OR m.user_id IS NOT NULL)`
+
+	var role string
+	err := p.conn.QueryRow(ctx, query, farmID, userID).Scan(&role)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", domain.ErrNotFound
+	}
+	return role, err
+}
+
+func (p *postgresFarmMemberRepository) ListMembers(ctx context.Context, farmID string) ([]domain.FarmMember, error) {
+	query := `
+		SELECT f.uuid, u.uuid, u.email, 'owner', f.created_at, 0 AS rank
+		FROM farms f
+		JOIN users u ON u.uuid = f.owner_id
+		WHERE f.uuid = $1
+		UNION ALL
+		SELECT m.farm_id, u.uuid, u.email, m.role, m.created_at, 1 AS rank
+		FROM farm_members m
+		JOIN users u ON u.uuid = m.user_id
+		WHERE m.farm_id = $1
+		ORDER BY rank, created_at`
+
+	rows, err := p.conn.Query(ctx, query, farmID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []domain.FarmMember{}
+	for rows.Next() {
+		var m domain.FarmMember
+		var rank int
+		if err := rows.Scan(&m.FarmID, &m.UserID, &m.Email, &m.Role, &m.CreatedAt, &rank); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+func (p *postgresFarmMemberRepository) SetRole(ctx context.Context, farmID, userID, role string) error {
+	tag, err := p.conn.Exec(ctx, `UPDATE farm_members SET role = $3 WHERE farm_id = $1 AND user_id = $2`, farmID, userID, role)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (p *postgresFarmMemberRepository) RemoveMember(ctx context.Context, farmID, userID string) error {
+	tag, err := p.conn.Exec(ctx, `DELETE FROM farm_members WHERE farm_id = $1 AND user_id = $2`, farmID, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+const invitationColumns = `i.uuid, i.farm_id, f.name, i.email, i.role, i.invited_by, i.created_at, i.expires_at, i.accepted_at`
+
+func (p *postgresFarmMemberRepository) fetchInvitations(ctx context.Context, query string, args ...interface{}) ([]domain.FarmInvitation, error) {
+	rows, err := p.conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invitations := []domain.FarmInvitation{}
+	for rows.Next() {
+		var i domain.FarmInvitation
+		if err := rows.Scan(
+			&i.UUID,
+			&i.FarmID,
+			&i.FarmName,
+			&i.Email,
+			&i.Role,
+			&i.InvitedBy,
+			&i.CreatedAt,
+			&i.ExpiresAt,
+			&i.AcceptedAt,
+		); err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, i)
+	}
+	return invitations, rows.Err()
+}
+
+func (p *postgresFarmMemberRepository) CreateInvitation(ctx context.Context, inv *domain.FarmInvitation) error {
+	if strings.TrimSpace(inv.UUID) == "" {
+		inv.UUID = uuid.New().String()
+	}
+
+	query := `
+		INSERT INTO farm_invitations (uuid, farm_id, email, role, invited_by, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING created_at`
+
+	return p.conn.QueryRow(ctx, query, inv.UUID, inv.FarmID, inv.Email, inv.Role, inv.InvitedBy, inv.ExpiresAt).
+		Scan(&inv.CreatedAt)
+}
+
+func (p *postgresFarmMemberRepository) GetInvitation(ctx context.Context, id string) (*domain.FarmInvitation, error) {
+	query := `
+		SELECT ` + invitationColumns + `
+		FROM farm_invitations i
+		JOIN farms f ON f.uuid = i.farm_id
+		WHERE i.uuid = $1`
+
+	invitations, err := p.fetchInvitations(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(invitations) == 0 {
+		return nil, domain.ErrNotFound
+	}
+	return &invitations[0], nil
+}
+
+func (p *postgresFarmMemberRepository) ListPendingInvitations(ctx context.Context, farmID string) ([]domain.FarmInvitation, error) {
+	query := `
+		SELECT ` + invitationColumns + `
+		FROM farm_invitations i
+		JOIN farms f ON f.uuid = i.farm_id
+		WHERE i.farm_id = $1 AND i.accepted_at IS NULL AND i.expires_at > NOW()
+		ORDER BY i.created_at DESC`
+
+	return p.fetchInvitations(ctx, query, farmID)
+}
+
+func (p *postgresFarmMemberRepository) ListInvitationsForEmail(ctx context.Context, email string) ([]domain.FarmInvitation, error) {
+	query := `
+		SELECT ` + invitationColumns + `
+		FROM farm_invitations i
+		JOIN farms f ON f.uuid = i.farm_id
+		WHERE LOWER(i.email) = LOWER($1) AND i.accepted_at IS NULL AND i.expires_at > NOW()
+		ORDER BY i.created_at DESC`
+
+	return p.fetchInvitations(ctx, query, email)
+}
+
+func (p *postgresFarmMemberRepository) AcceptInvitation(ctx context.Context, invitationID, userID string) error {
+	tx, err := p.conn.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var farmID, role string
+	err = tx.QueryRow(ctx, `
+		UPDATE farm_invitations SET accepted_at = NOW()
+		WHERE uuid = $1 AND accepted_at IS NULL AND expires_at > NOW()
+		RETURNING farm_id, role`, invitationID).Scan(&farmID, &role)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to accept invitation: %w", err)
+	}
+
+	// Accepting never lowers an existing role, and the owner needs no row.
+	_, err = tx.Exec(ctx, `
+		INSERT INTO farm_members (farm_id, user_id, role)
+		SELECT $1, $2, $3
+		WHERE NOT EXISTS (SELECT 1 FROM farms WHERE uuid = $1 AND owner_id = $2)
+		ON CONFLICT (farm_id, user_id) DO UPDATE SET role = EXCLUDED.role
+		WHERE array_position(ARRAY['viewer', 'worker', 'manager'], farm_members.role)
+		    < array_position(ARRAY['viewer', 'worker', 'manager'], EXCLUDED.role)`,
+		farmID, userID, role)
+	if err != nil {
+		return fmt.Errorf("failed to add farm member: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (p *postgresFarmMemberRepository) DeleteInvitation(ctx context.Context, id string) error {
+	tag, err := p.conn.Exec(ctx, `DELETE FROM farm_invitations WHERE uuid = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (p *postgresFarmMemberRepository) DeleteInvitationsForEmail(ctx context.Context, email string) error {
+	_, err := p.conn.Exec(ctx, `DELETE FROM farm_invitations WHERE LOWER(email) = LOWER($1)`, email)
+	return err
+}
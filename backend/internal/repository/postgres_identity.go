@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/forfarm/backend/internal/domain"
+)
+
+type postgresIdentityRepository struct {
+	conn Connection
+}
+
+func NewPostgresIdentity(conn Connection) domain.IdentityRepository {
+	return &postgresIdentityRepository{conn: conn}
+}
+
+const identityColumns = `uuid, user_id, provider, subject, COALESCE(email, ''), created_at, last_used_at`
+
+func scanIdentity(row pgx.Row) (*domain.LinkedIdentity, error) {
+	var i domain.LinkedIdentity
+	if err := row.Scan(
+		&i.UUID,
+		&i.UserID,
+		&i.Provider,
+		&i.Subject,
+		&i.Email,
+		&i.CreatedAt,
+		&i.LastUsedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &i, nil
+}
+
+func (p *postgresIdentityRepository) GetByProviderSubject(ctx context.Context, provider, subject string) (*domain.LinkedIdentity, error) {
+	query := `SELECT ` + identityColumns + ` FROM user_identities WHERE provider = $1 AND subject = $2`
+	i, err := scanIdentity(p.conn.QueryRow(ctx, query, provider, subject))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	return i, err
+}
+
+func (p *postgresIdentityRepository) ListByUserID(ctx context.Context, userID string) ([]domain.LinkedIdentity, error) {
+	query := `SELECT ` + identityColumns + ` FROM user_identities WHERE user_id = $1 ORDER BY created_at`
+
+	rows, err := p.conn.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	identities := []domain.LinkedIdentity{}
+	for rows.Next() {
+		i, err := scanIdentity(rows)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, *i)
+	}
+	return identities, rows.Err()
+}
+
+func (p *postgresIdentityRepository) Link(ctx context.Context, i *domain.LinkedIdentity) error {
+	if err := i.Validate(); err != nil {
+		return err
+	}
+	if strings.TrimSpace(i.UUID) == "" {
+		i.UUID = uuid.New().String()
+	}
+
+	query := `
+		INSERT INTO user_identities (uuid, user_id, provider, subject, email)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''))
+		ON CONFLICT DO NOTHING
+		RETURNING created_at`
+
+	err := p.conn.QueryRow(ctx, query, i.UUID, i.UserID, i.Provider, i.Subject, i.Email).Scan(&i.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ErrConflict
+	}
+	return err
+}
+
+func (p *postgresIdentityRepository) Unlink(ctx context.Context, userID, provider string) error {
+	tag, err := p.conn.Exec(ctx, `DELETE FROM user_identities WHERE user_id = $1 AND provider = $2`, userID, provider)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (p *postgresIdentityRepository) Touch(ctx context.Context, id string) error {
+	_, err := p.conn.Exec(ctx, `UPDATE user_identities SET last_used_at = NOW() WHERE uuid = $1`, id)
+	return err
+}
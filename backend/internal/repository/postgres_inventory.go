@@ -9,7 +9,6 @@ import (
 
 	"github.com/forfarm/backend/internal/cache"
 	"github.com/forfarm/backend/internal/domain"
-	"github.com/google/uuid"
 )
 
 const (
@@ -275,29 +274,10 @@ func (p *postgresInventoryRepository) CreateOrUpdate(ctx context.Context, item *
 			eventType = "inventory.item.created"
 		}
 
-		payload := map[string]interface{}{
-			"id":         item.ID,
-			"userId":     item.UserID, // Include user ID for potential farm lookup in projection
-			"name":       item.Name,
-			"categoryId": item.CategoryID,
-			"quantity":   item.Quantity,
-			"unitId":     item.UnitID,
-			"statusId":   item.StatusID,
-			"dateAdded":  item.DateAdded,
-			"updatedAt":  item.UpdatedAt,
-			// NO farm_id easily available here without extra lookup
-		}
-
-		event := domain.Event{
-			ID:          uuid.NewString(),
-			Type:        eventType,
-			Source:      "inventory-repository",
-			Timestamp:   time.Now().UTC(),
-			AggregateID: item.UserID, // Use UserID as AggregateID for inventory? Or item.ID? Let's use item.ID.
-			Payload:     payload,
+		event, err := domain.NewEvent(eventType, "inventory-repository", item.ID, domain.NewInventoryItemPayload(item))
+		if err != nil {
+			return err
 		}
-		// Use AggregateID = item.ID for consistency if item is the aggregate root
-		event.AggregateID = item.ID
 
 		go func() {
 			bgCtx := context.Background()
@@ -325,17 +305,12 @@ func (p *postgresInventoryRepository) Delete(ctx context.Context, id, userID str
 	// --- Publish Event ---
 	if p.eventPublisher != nil {
 		eventType := "inventory.item.deleted"
-		payload := map[string]interface{}{
-			"item_id": id,
-			"user_id": userID, // Include user ID
-		}
-		event := domain.Event{
-			ID:          uuid.NewString(),
-			Type:        eventType,
-			Source:      "inventory-repository",
-			Timestamp:   time.Now().UTC(),
-			AggregateID: id, // Use item ID as aggregate ID
-			Payload:     payload,
+		event, err := domain.NewEvent(eventType, "inventory-repository", id, &domain.InventoryItemDeletedPayload{
+			ItemID: id,
+			UserID: userID,
+		})
+		if err != nil {
+			return err
 		}
 		go func() {
 			bgCtx := context.Background()
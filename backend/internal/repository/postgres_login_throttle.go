@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/forfarm/backend/internal/domain"
+)
+
+type postgresLoginThrottleRepository struct {
+	conn Connection
+}
+
+func NewPostgresLoginThrottle(conn Connection) domain.LoginThrottleRepository {
+	return &postgresLoginThrottleRepository{conn: conn}
+}
+
+func scanLoginThrottle(row pgx.Row) (*domain.LoginThrottle, error) {
+	var t domain.LoginThrottle
+	if err := row.Scan(&t.Key, &t.Failures, &t.LastFailedAt, &t.LockedUntil); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (p *postgresLoginThrottleRepository) Get(ctx context.Context, key string) (*domain.LoginThrottle, error) {
+	query := `SELECT key, failures, last_failed_at, locked_until FROM login_throttles WHERE key = $1`
+	t, err := scanLoginThrottle(p.conn.QueryRow(ctx, query, key))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	return t, err
+}
+
+func (p *postgresLoginThrottleRepository) RecordFailure(ctx context.Context, key string, windowStart time.Time) (*domain.LoginThrottle, error) {
+	query := `
+		INSERT INTO login_throttles (key, failures, last_failed_at)
+		VALUES ($1, 1, NOW())
+		ON CONFLICT (key) DO UPDATE
+		SET failures = CASE WHEN login_throttles.last_failed_at < $2 THEN 1 ELSE login_throttles.failures + 1 END,
+		    last_failed_at = NOW()
+		RETURNING key, failures, last_failed_at, locked_until`
+
+	return scanLoginThrottle(p.conn.QueryRow(ctx, query, key, windowStart))
+}
+
+func (p *postgresLoginThrottleRepository) Lock(ctx context.Context, key string, until time.Time) error {
+	_, err := p.conn.Exec(ctx, `UPDATE login_throttles SET locked_until = $2 WHERE key = $1`, key, until)
+	return err
+}
+
+func (p *postgresLoginThrottleRepository) Delete(ctx context.Context, key string) error {
+	_, err := p.conn.Exec(ctx, `DELETE FROM login_throttles WHERE key = $1`, key)
+	return err
+}
@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/forfarm/backend/internal/domain"
+)
+
+type postgresOutboxRepository struct {
+	conn Connection
+}
+
+func NewPostgresOutbox(conn Connection) domain.OutboxRepository {
+	return &postgresOutboxRepository{conn: conn}
+}
+
+// writeOutboxEvent stores an event in the outbox using the caller's
+// transaction, so the event is persisted if and only if the row change is.
+func writeOutboxEvent(ctx context.Context, tx pgx.Tx, event domain.Event) error {
+	if err := domain.ValidateEvent(event); err != nil {
+		return err
+	}
+	// Passed as json.RawMessage so it is sent as JSON, not bytea.
+	payload, err := json.Marshal(event.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s event payload: %w", event.Type, err)
+	}
+
+	query := `
+		INSERT INTO event_outbox (id, event_type, source, aggregate_id, payload, schema_version, occurred_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	_, err = tx.Exec(ctx, query, event.ID, event.Type, event.Source, event.AggregateID, json.RawMessage(payload), event.SchemaVersion, event.Timestamp)
+	if err != nil {
+		return fmt.Errorf("failed to write %s event to outbox: %w", event.Type, err)
+	}
+	return nil
+}
+
+func (p *postgresOutboxRepository) ClaimPending(ctx context.Context, limit int, lease time.Duration) ([]domain.OutboxMessage, error) {
+	query := `
+		UPDATE event_outbox o
+		SET attempts = o.attempts + 1,
+		    next_attempt_at = NOW() + make_interval(secs => $2)
+		WHERE o.id IN (
+			SELECT id FROM event_outbox
+			WHERE delivered_at IS NULL AND next_attempt_at <= NOW()
+			ORDER BY created_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING o.id, o.event_type, o.source, o.aggregate_id, o.payload, o.schema_version, o.occurred_at, o.attempts, o.created_at`
+
+	rows, err := p.conn.Query(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []domain.OutboxMessage
+	undecodable := map[string]error{}
+	for rows.Next() {
+		var m domain.OutboxMessage
+		var payload []byte
+		if err := rows.Scan(
+			&m.Event.ID,
+			&m.Event.Type,
+			&m.Event.Source,
+			&m.Event.AggregateID,
+			&payload,
+			&m.Event.SchemaVersion,
+			&m.Event.Timestamp,
+			&m.Attempts,
+			&m.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		m.Event.Payload, m.Event.SchemaVersion, err = domain.DecodeEventPayload(m.Event.Type, m.Event.SchemaVersion, payload)
+		if err != nil {
+			undecodable[m.Event.ID] = fmt.Errorf("failed to decode outbox payload: %w", err)
+			continue
+		}
+		messages = append(messages, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Retrying cannot fix a payload, so it is parked rather than claimed
+	// again on every poll.
+	for id, cause := range undecodable {
+		if err := p.park(ctx, id, cause); err != nil {
+			return nil, err
+		}
+	}
+
+	// RETURNING does not preserve the subquery order.
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].CreatedAt.Before(messages[j].CreatedAt)
+	})
+	return messages, nil
+}
+
+// park takes a message out of the pending set for good, keeping the reason in
+// last_error. Setting next_attempt_at back to NOW() retries it.
+func (p *postgresOutboxRepository) park(ctx context.Context, eventID string, cause error) error {
+	query := `UPDATE event_outbox SET last_error = $2, next_attempt_at = 'infinity' WHERE id = $1`
+	if _, err := p.conn.Exec(ctx, query, eventID, cause.Error()); err != nil {
+		return fmt.Errorf("failed to park outbox event %s: %w", eventID, err)
+	}
+	return nil
+}
+
+func (p *postgresOutboxRepository) MarkDelivered(ctx context.Context, eventID string) error {
+	query := `UPDATE event_outbox SET delivered_at = NOW(), last_error = NULL WHERE id = $1`
+	_, err := p.conn.Exec(ctx, query, eventID)
+	return err
+}
+
+func (p *postgresOutboxRepository) MarkFailed(ctx context.Context, eventID string, cause error, nextAttemptAt time.Time) error {
+	var lastError *string
+	if cause != nil {
+		msg := cause.Error()
+		lastError = &msg
+	}
+	query := `UPDATE event_outbox SET last_error = $2, next_attempt_at = $3 WHERE id = $1`
+	_, err := p.conn.Exec(ctx, query, eventID, lastError, nextAttemptAt)
+	return err
+}
+
+func (p *postgresOutboxRepository) DeleteDelivered(ctx context.Context, olderThan time.Time) (int64, error) {
+	query := `DELETE FROM event_outbox WHERE delivered_at IS NOT NULL AND delivered_at < $1`
+	tag, err := p.conn.Exec(ctx, query, olderThan)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
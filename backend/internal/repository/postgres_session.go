@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/forfarm/backend/internal/domain"
+)
+
+type postgresSessionRepository struct {
+	conn Connection
+}
+
+func NewPostgresSession(conn Connection) domain.SessionRepository {
+	return &postgresSessionRepository{conn: conn}
+}
+
+const sessionColumns = `uuid, user_id, refresh_token_hash, user_agent, two_factor, created_at, last_used_at, expires_at, revoked_at`
+
+func scanSession(row pgx.Row) (*domain.Session, error) {
+	var s domain.Session
+	if err := row.Scan(
+		&s.UUID,
+		&s.UserID,
+		&s.RefreshTokenHash,
+		&s.UserAgent,
+		&s.TwoFactor,
+		&s.CreatedAt,
+		&s.LastUsedAt,
+		&s.ExpiresAt,
+		&s.RevokedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (p *postgresSessionRepository) Create(ctx context.Context, s *domain.Session) error {
+	if strings.TrimSpace(s.UUID) == "" {
+		s.UUID = uuid.New().String()
+	}
+
+	query := `
+		INSERT INTO sessions (uuid, user_id, refresh_token_hash, user_agent, two_factor, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING created_at, last_used_at`
+
+	return p.conn.QueryRow(ctx, query, s.UUID, s.UserID, s.RefreshTokenHash, s.UserAgent, s.TwoFactor, s.ExpiresAt).
+		Scan(&s.CreatedAt, &s.LastUsedAt)
+}
+
+func (p *postgresSessionRepository) GetByID(ctx context.Context, id string) (*domain.Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM sessions WHERE uuid = $1`
+	s, err := scanSession(p.conn.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	return s, err
+}
+
+func (p *postgresSessionRepository) GetActiveByUserID(ctx context.Context, userID string) ([]domain.Session, error) {
+	query := `
+		SELECT ` + sessionColumns + `
+		FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		ORDER BY last_used_at DESC`
+
+	rows, err := p.conn.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []domain.Session{}
+	for rows.Next() {
+		s, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *s)
+	}
+	return sessions, rows.Err()
+}
+
+func (p *postgresSessionRepository) Rotate(ctx context.Context, tokenHash, newHash string, expiresAt time.Time) (*domain.Session, error) {
+	query := `
+		UPDATE sessions
+		SET previous_token_hash = refresh_token_hash,
+		    refresh_token_hash = $2,
+		    last_used_at = NOW(),
+		    expires_at = $3
+		WHERE refresh_token_hash = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		RETURNING ` + sessionColumns
+
+	s, err := scanSession(p.conn.QueryRow(ctx, query, tokenHash, newHash, expiresAt))
+	if err == nil {
+		return s, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+
+	tag, err := p.conn.Exec(ctx, `
+		UPDATE sessions SET revoked_at = NOW()
+		WHERE previous_token_hash = $1 AND revoked_at IS NULL`, tokenHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to revoke session of reused refresh token: %w", err)
+	}
+	if tag.RowsAffected() > 0 {
+		return nil, domain.ErrRefreshTokenReused
+	}
+	return nil, domain.ErrNotFound
+}
+
+func (p *postgresSessionRepository) IsActive(ctx context.Context, id string) (bool, error) {
+	var active bool
+	query := `SELECT EXISTS (SELECT 1 FROM sessions WHERE uuid = $1 AND revoked_at IS NULL AND expires_at > NOW())`
+	if err := p.conn.QueryRow(ctx, query, id).Scan(&active); err != nil {
+		return false, err
+	}
+	return active, nil
+}
+
+func (p *postgresSessionRepository) Revoke(ctx context.Context, id string) error {
+	tag, err := p.conn.Exec(ctx, `UPDATE sessions SET revoked_at = COALESCE(revoked_at, NOW()) WHERE uuid = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (p *postgresSessionRepository) RevokeAllForUser(ctx context.Context, userID string) error {
+	_, err := p.conn.Exec(ctx, `UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`, userID)
+	return err
+}
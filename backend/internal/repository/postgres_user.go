@@ -2,9 +2,12 @@ package repository
 
 import (
 	"context"
+	"fmt"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 
 	"github.com/forfarm/backend/internal/domain"
 )
@@ -36,6 +39,10 @@ func (p *postgresUserRepository) fetch(ctx context.Context, query string, args .
 			&u.CreatedAt,
 			&u.UpdatedAt,
 			&u.IsActive,
+			&u.EmailVerifiedAt,
+			&u.PasswordSet,
+			&u.DeletionScheduledAt,
+			&u.Role,
 		); err != nil {
 			return nil, err
 		}
@@ -46,7 +53,7 @@ func (p *postgresUserRepository) fetch(ctx context.Context, query string, args .
 
 func (p *postgresUserRepository) GetByID(ctx context.Context, id int64) (domain.User, error) {
 	query := `
-		SELECT id, uuid, username, password, email, created_at, updated_at, is_active
+		SELECT id, uuid, username, password, email, created_at, updated_at, is_active, email_verified_at, password_set, deletion_scheduled_at, role
 		FROM users
 		WHERE id = $1`
 
@@ -62,7 +69,7 @@ func (p *postgresUserRepository) GetByID(ctx context.Context, id int64) (domain.
 
 func (p *postgresUserRepository) GetByUUID(ctx context.Context, uuid string) (domain.User, error) {
 	query := `
-		SELECT id, uuid, username, password, email, created_at, updated_at, is_active
+		SELECT id, uuid, username, password, email, created_at, updated_at, is_active, email_verified_at, password_set, deletion_scheduled_at, role
 		FROM users
 		WHERE uuid = $1`
 
@@ -78,7 +85,7 @@ func (p *postgresUserRepository) GetByUUID(ctx context.Context, uuid string) (do
 
 func (p *postgresUserRepository) GetByUsername(ctx context.Context, username string) (domain.User, error) {
 	query := `
-		SELECT id, uuid, username, password, email, created_at, updated_at, is_active  
+		SELECT id, uuid, username, password, email, created_at, updated_at, is_active, email_verified_at, password_set, deletion_scheduled_at, role
 		FROM users
 		WHERE username = $1`
 
@@ -96,7 +103,7 @@ func (p *postgresUserRepository) GetByUsername(ctx context.Context, username str
 
 func (p *postgresUserRepository) GetByEmail(ctx context.Context, email string) (domain.User, error) {
 	query := `
-		SELECT id, uuid, username, password, email, created_at, updated_at, is_active  
+		SELECT id, uuid, username, password, email, created_at, updated_at, is_active, email_verified_at, password_set, deletion_scheduled_at, role
 		FROM users
 		WHERE email = $1`
 
@@ -120,16 +127,22 @@ func (p *postgresUserRepository) CreateOrUpdate(ctx context.Context, u *domain.U
 	}
 
 	u.NormalizedUsername()
+	if u.Role == "" {
+		u.Role = domain.RoleUser
+	}
 
 	query := `  
-		INSERT INTO users (uuid, username, password, email, created_at, updated_at, is_active)  
-		VALUES ($1, $2, $3, $4, NOW(), NOW(), $5)
+		INSERT INTO users (uuid, username, password, email, created_at, updated_at, is_active, email_verified_at, password_set, deletion_scheduled_at, role)
+		VALUES ($1, $2, $3, $4, NOW(), NOW(), $5, $6, $7, $8, $9)
 		ON CONFLICT (uuid) DO UPDATE
 		SET username = EXCLUDED.username,
 		    password = EXCLUDED.password,
 		    email = EXCLUDED.email,
 		    updated_at = NOW(),
-		    is_active = EXCLUDED.is_active
+		    is_active = EXCLUDED.is_active,
+		    email_verified_at = EXCLUDED.email_verified_at,
+		    password_set = EXCLUDED.password_set,
+		    role = EXCLUDED.role
 		RETURNING id, created_at, updated_at`
 
 	return p.conn.QueryRow(
@@ -140,6 +153,10 @@ func (p *postgresUserRepository) CreateOrUpdate(ctx context.Context, u *domain.U
 		u.Password,
 		u.Email,
 		u.IsActive,
+		u.EmailVerifiedAt,
+		u.PasswordSet,
+		u.DeletionScheduledAt,
+		u.Role,
 	).Scan(&u.ID, &u.CreatedAt, &u.UpdatedAt)
 }
 
@@ -148,3 +165,120 @@ func (p *postgresUserRepository) Delete(ctx context.Context, id int64) error {
 	_, err := p.conn.Exec(ctx, query, id)
 	return err
 }
+
+func (p *postgresUserRepository) SetActive(ctx context.Context, userID string, active bool) error {
+	query := `UPDATE users SET is_active = $2, updated_at = NOW() WHERE uuid = $1`
+	tag, err := p.conn.Exec(ctx, query, userID, active)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (p *postgresUserRepository) SetDeletionScheduledAt(ctx context.Context, userID string, at *time.Time) error {
+	query := `UPDATE users SET deletion_scheduled_at = $2, updated_at = NOW() WHERE uuid = $1`
+	tag, err := p.conn.Exec(ctx, query, userID, at)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (p *postgresUserRepository) GetDueForDeletion(ctx context.Context, now time.Time) ([]domain.User, error) {
+	query := `
+		SELECT id, uuid, username, password, email, created_at, updated_at, is_active, email_verified_at, password_set, deletion_scheduled_at, role
+		FROM users
+		WHERE deletion_scheduled_at <= $1
+		ORDER BY deletion_scheduled_at`
+
+	return p.fetch(ctx, query, now)
+}
+
+func (p *postgresUserRepository) DeleteIfDue(ctx context.Context, userID string, now time.Time, cleanup func(domain.User, domain.AccountDeletionStores) error) (bool, error) {
+	tx, err := p.conn.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	conn := txConnection{tx}
+	txRepo := &postgresUserRepository{conn: conn}
+	query := `
+		SELECT id, uuid, username, password, email, created_at, updated_at, is_active, email_verified_at, password_set, deletion_scheduled_at, role
+		FROM users
+		WHERE uuid = $1 AND deletion_scheduled_at <= $2
+		FOR UPDATE SKIP LOCKED`
+	users, err := txRepo.fetch(ctx, query, userID, now)
+	if err != nil {
+		return false, err
+	}
+	if len(users) == 0 {
+		return false, nil
+	}
+
+	if err := cleanup(users[0], domain.AccountDeletionStores{
+		Farms:   NewPostgresFarm(conn),
+		Members: NewPostgresFarmMember(conn),
+		Audit:   NewPostgresAuditLog(conn),
+		Events:  NewPostgresEventStore(conn),
+	}); err != nil {
+		return false, err
+	}
+	if err := txRepo.Delete(ctx, users[0].ID); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return true, nil
+}
+
+// likeEscaper escapes the LIKE wildcards in a search term, so it matches
+// literally.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func (p *postgresUserRepository) List(ctx context.Context, filter domain.UserFilter) ([]domain.User, error) {
+	var conditions []string
+	var args []interface{}
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if q := strings.TrimSpace(filter.Query); q != "" {
+		where("(email ILIKE $%[1]d OR username ILIKE $%[1]d)", "%"+likeEscaper.Replace(q)+"%")
+	}
+	if filter.Role != "" {
+		where("role = $%d", filter.Role)
+	}
+	if filter.IsActive != nil {
+		where("is_active = $%d", *filter.IsActive)
+	}
+	if filter.BeforeID > 0 {
+		where("id < $%d", filter.BeforeID)
+	}
+
+	query := `
+		SELECT id, uuid, username, password, email, created_at, updated_at, is_active, email_verified_at, password_set, deletion_scheduled_at, role
+		FROM users`
+	if len(conditions) > 0 {
+		query += "\n\t\tWHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf("\n\t\tORDER BY id DESC\n\t\tLIMIT $%d", len(args))
+
+	users, err := p.fetch(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if users == nil {
+		users = []domain.User{}
+	}
+	return users, nil
+}
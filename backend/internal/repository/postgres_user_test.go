@@ -0,0 +1,23 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/forfarm/backend/internal/domain"
+	"github.com/forfarm/backend/internal/repository"
+)
+
+func TestPostgresUser_ListEscapesSearchWildcards(t *testing.T) {
+	errStop := errors.New("stop")
+	conn := new(MockConnection)
+	conn.On("Query", mock.Anything, mock.Anything, `%50\%\_off\\%`, 20).Return(nil, errStop)
+
+	_, err := repository.NewPostgresUser(conn).List(context.Background(), domain.UserFilter{Query: ` 50%_off\ `, Limit: 20})
+	assert.ErrorIs(t, err, errStop)
+	conn.AssertExpectations(t)
+}
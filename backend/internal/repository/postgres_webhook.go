@@ -0,0 +1,263 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/forfarm/backend/internal/domain"
+)
+
+type postgresWebhookRepository struct {
+	conn Connection
+}
+
+func NewPostgresWebhook(conn Connection) domain.WebhookRepository {
+	return &postgresWebhookRepository{conn: conn}
+}
+
+const webhookColumns = `uuid, owner_id, url, secret, event_types, farm_ids::text[], active, consecutive_failures, disabled_at, created_at, updated_at`
+
+func scanWebhook(row pgx.Row) (*domain.Webhook, error) {
+	var w domain.Webhook
+	err := row.Scan(
+		&w.UUID,
+		&w.OwnerID,
+		&w.URL,
+		&w.Secret,
+		&w.EventTypes,
+		&w.FarmIDs,
+		&w.Active,
+		&w.ConsecutiveFailures,
+		&w.DisabledAt,
+		&w.CreatedAt,
+		&w.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+func (p *postgresWebhookRepository) GetByID(ctx context.Context, id string) (*domain.Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE uuid = $1`
+	w, err := scanWebhook(p.conn.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	return w, err
+}
+
+func (p *postgresWebhookRepository) GetByOwnerID(ctx context.Context, ownerID string) ([]domain.Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE owner_id = $1 ORDER BY created_at`
+	rows, err := p.conn.Query(ctx, query, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []domain.Webhook{}
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, *w)
+	}
+	return webhooks, rows.Err()
+}
+
+func (p *postgresWebhookRepository) CreateOrUpdate(ctx context.Context, w *domain.Webhook) error {
+	if strings.TrimSpace(w.UUID) == "" {
+		w.UUID = uuid.New().String()
+	}
+	if w.EventTypes == nil {
+		w.EventTypes = []string{}
+	}
+	if w.FarmIDs == nil {
+		w.FarmIDs = []string{}
+	}
+
+	query := `
+		INSERT INTO webhooks (uuid, owner_id, url, secret, event_types, farm_ids, active)
+		VALUES ($1, $2, $3, $4, $5, $6::uuid[], $7)
+		ON CONFLICT (uuid) DO UPDATE
+		SET url = EXCLUDED.url,
+		    secret = EXCLUDED.secret,
+		    event_types = EXCLUDED.event_types,
+		    farm_ids = EXCLUDED.farm_ids,
+		    active = EXCLUDED.active,
+		    consecutive_failures = CASE WHEN EXCLUDED.active AND NOT webhooks.active THEN 0 ELSE webhooks.consecutive_failures END,
+		    disabled_at = CASE WHEN EXCLUDED.active THEN NULL ELSE COALESCE(webhooks.disabled_at, NOW()) END,
+		    updated_at = NOW()
+		RETURNING consecutive_failures, disabled_at, created_at, updated_at`
+
+	return p.conn.QueryRow(ctx, query, w.UUID, w.OwnerID, w.URL, w.Secret, w.EventTypes, w.FarmIDs, w.Active).
+		Scan(&w.ConsecutiveFailures, &w.DisabledAt, &w.CreatedAt, &w.UpdatedAt)
+}
+
+func (p *postgresWebhookRepository) Delete(ctx context.Context, id string) error {
+	tag, err := p.conn.Exec(ctx, `DELETE FROM webhooks WHERE uuid = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (p *postgresWebhookRepository) EnqueueDelivery(ctx context.Context, d *domain.WebhookDelivery) error {
+	query := `
+		INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (webhook_id, event_id) DO NOTHING`
+
+	_, err := p.conn.Exec(ctx, query, d.WebhookID, d.EventID, d.EventType, d.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue delivery of event %s to webhook %s: %w", d.EventID, d.WebhookID, err)
+	}
+	return nil
+}
+
+const webhookDeliveryColumns = `d.id, d.webhook_id, d.event_id, d.event_type, d.payload, d.status, d.attempts, d.next_attempt_at, d.last_status_code, d.last_error, d.created_at, d.delivered_at`
+
+func scanWebhookDeliveries(rows pgx.Rows) ([]domain.WebhookDelivery, error) {
+	defer rows.Close()
+
+	deliveries := []domain.WebhookDelivery{}
+	for rows.Next() {
+		var d domain.WebhookDelivery
+		if err := rows.Scan(
+			&d.ID,
+			&d.WebhookID,
+			&d.EventID,
+			&d.EventType,
+			&d.Payload,
+			&d.Status,
+			&d.Attempts,
+			&d.NextAttemptAt,
+			&d.LastStatusCode,
+			&d.LastError,
+			&d.CreatedAt,
+			&d.DeliveredAt,
+		); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+func (p *postgresWebhookRepository) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]domain.WebhookDelivery, error) {
+	query := `
+		UPDATE webhook_deliveries d
+		SET attempts = d.attempts + 1,
+		    next_attempt_at = NOW() + make_interval(secs => $2)
+		WHERE d.id IN (
+			SELECT dd.id FROM webhook_deliveries dd
+			JOIN webhooks w ON w.uuid = dd.webhook_id
+			WHERE dd.status = 'pending' AND dd.next_attempt_at <= NOW() AND w.active
+			ORDER BY dd.id
+			LIMIT $1
+			FOR UPDATE OF dd SKIP LOCKED
+		)
+		RETURNING ` + webhookDeliveryColumns
+
+	rows, err := p.conn.Query(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	deliveries, err := scanWebhookDeliveries(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	// RETURNING does not preserve the subquery order.
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].ID < deliveries[j].ID })
+	return deliveries, nil
+}
+
+func (p *postgresWebhookRepository) MarkDelivered(ctx context.Context, deliveryID int64, statusCode int) error {
+	tx, err := p.conn.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var webhookID string
+	query := `
+		UPDATE webhook_deliveries
+		SET status = 'succeeded', last_status_code = $2, last_error = NULL, delivered_at = NOW()
+		WHERE id = $1
+		RETURNING webhook_id`
+	if err := tx.QueryRow(ctx, query, deliveryID, statusCode).Scan(&webhookID); err != nil {
+		return fmt.Errorf("failed to mark webhook delivery %d delivered: %w", deliveryID, err)
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE webhooks SET consecutive_failures = 0 WHERE uuid = $1`, webhookID); err != nil {
+		return fmt.Errorf("failed to reset failures of webhook %s: %w", webhookID, err)
+	}
+	return tx.Commit(ctx)
+}
+
+func (p *postgresWebhookRepository) MarkFailed(ctx context.Context, deliveryID int64, statusCode *int, cause string, nextAttemptAt *time.Time, disableAfter int) (bool, error) {
+	tx, err := p.conn.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	status := domain.WebhookDeliveryPending
+	if nextAttemptAt == nil {
+		status = domain.WebhookDeliveryFailed
+	}
+
+	var webhookID string
+	query := `
+		UPDATE webhook_deliveries
+		SET status = $2, last_status_code = $3, last_error = $4, next_attempt_at = COALESCE($5, next_attempt_at)
+		WHERE id = $1
+		RETURNING webhook_id`
+	if err := tx.QueryRow(ctx, query, deliveryID, status, statusCode, cause, nextAttemptAt).Scan(&webhookID); err != nil {
+		return false, fmt.Errorf("failed to mark webhook delivery %d failed: %w", deliveryID, err)
+	}
+
+	var disabled bool
+	query = `
+		UPDATE webhooks
+		SET consecutive_failures = consecutive_failures + 1,
+		    active = active AND consecutive_failures + 1 < $2,
+		    disabled_at = CASE WHEN active AND consecutive_failures + 1 >= $2 THEN NOW() ELSE disabled_at END
+		WHERE uuid = $1
+		RETURNING disabled_at IS NOT NULL AND NOT active AND consecutive_failures = $2`
+	if err := tx.QueryRow(ctx, query, webhookID, disableAfter).Scan(&disabled); err != nil {
+		return false, fmt.Errorf("failed to record failure of webhook %s: %w", webhookID, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, err
+	}
+	return disabled, nil
+}
+
+func (p *postgresWebhookRepository) ListDeliveries(ctx context.Context, webhookID string, limit int) ([]domain.WebhookDelivery, error) {
+	query := `
+		SELECT ` + webhookDeliveryColumns + `
+		FROM webhook_deliveries d
+		WHERE d.webhook_id = $1
+		ORDER BY d.id DESC
+		LIMIT $2`
+
+	rows, err := p.conn.Query(ctx, query, webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanWebhookDeliveries(rows)
+}
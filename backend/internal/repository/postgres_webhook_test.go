@@ -0,0 +1,89 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/pressly/goose/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/forfarm/backend/internal/domain"
+	"github.com/forfarm/backend/internal/repository"
+	"github.com/forfarm/backend/migrations"
+)
+
+// newTestPool connects to the database in TEST_DATABASE_URL and migrates it.
+// Tests using it are skipped without a database.
+func newTestPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	db, err := sql.Open("pgx", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+	goose.SetBaseFS(migrations.EmbedMigrations)
+	require.NoError(t, goose.UpContext(context.Background(), db, "."))
+
+	pool, err := pgxpool.New(context.Background(), dsn)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+	return pool
+}
+
+func TestPostgresWebhook_MarkFailedDisablesAfterThreshold(t *testing.T) {
+	pool := newTestPool(t)
+	ctx := context.Background()
+	const disableAfter = 3
+
+	tests := []struct {
+		name         string
+		failures     int
+		active       bool
+		wantDisabled bool
+		wantActive   bool
+	}{
+		{"below the threshold", disableAfter - 2, true, false, true},
+		{"reaching the threshold", disableAfter - 1, true, true, false},
+		{"already disabled", disableAfter, false, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ownerID := uuid.NewString()
+			_, err := pool.Exec(ctx, `INSERT INTO users (uuid, password, email) VALUES ($1, '', $2)`, ownerID, ownerID+"@example.com")
+			require.NoError(t, err)
+			t.Cleanup(func() { pool.Exec(ctx, `DELETE FROM users WHERE uuid = $1`, ownerID) })
+
+			repo := repository.NewPostgresWebhook(pool)
+			webhook := &domain.Webhook{OwnerID: ownerID, URL: "https://example.com/hook", Secret: "secret", Active: true}
+			require.NoError(t, repo.CreateOrUpdate(ctx, webhook))
+			_, err = pool.Exec(ctx, `UPDATE webhooks SET consecutive_failures = $2, active = $3 WHERE uuid = $1`, webhook.UUID, tt.failures, tt.active)
+			require.NoError(t, err)
+
+			require.NoError(t, repo.EnqueueDelivery(ctx, &domain.WebhookDelivery{
+				WebhookID: webhook.UUID, EventID: uuid.NewString(), EventType: "farm.updated", Payload: []byte(`{}`),
+			}))
+			deliveries, err := repo.ListDeliveries(ctx, webhook.UUID, 1)
+			require.NoError(t, err)
+			require.Len(t, deliveries, 1)
+
+			code := 500
+			disabled, err := repo.MarkFailed(ctx, deliveries[0].ID, &code, "endpoint responded 500", nil, disableAfter)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantDisabled, disabled)
+
+			got, err := repo.GetByID(ctx, webhook.UUID)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantActive, got.Active)
+			assert.Equal(t, tt.failures+1, got.ConsecutiveFailures)
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/forfarm/backend/internal/domain"
+)
+
+// auditExportPageSize is how many audit entries an export reads at a time.
+const auditExportPageSize = 500
+
+const exportReadme = `This archive holds the data ForFarm stores about your account, as of %s.
+
+  profile.json           your account
+  farms.json             the farms you own or belong to, with the croplands
+                         and their GeoJSON boundaries for the farms you own
+  inventory.json         your inventory items
+  farm_invitations.json  pending invitations to your email address
+  linked_identities.json the sign-in providers linked to your account
+  api_keys.json          your active API keys (the keys themselves are never stored)
+  sessions.json          your signed-in sessions
+  audit_log.json         the audit log entries about your account or made by you
+
+Conversations with the farming assistant are not included: ForFarm does not
+store them. They are kept only by the app you chatted in.
+`
+
+// AccountService gathers everything stored about a user for a data export,
+// and deletes accounts along with their data.
+type AccountService struct {
+	logger        *slog.Logger
+	userRepo      domain.UserRepository
+	farmRepo      domain.FarmRepository
+	memberRepo    domain.FarmMemberRepository
+	cropRepo      domain.CroplandRepository
+	inventoryRepo domain.InventoryRepository
+	identityRepo  domain.IdentityRepository
+	apiKeyRepo    domain.APIKeyRepository
+	sessionRepo   domain.SessionRepository
+	auditRepo     domain.AuditLogRepository
+	loginGuard    *LoginGuard
+}
+
+func NewAccountService(
+	logger *slog.Logger,
+	userRepo domain.UserRepository,
+	farmRepo domain.FarmRepository,
+	memberRepo domain.FarmMemberRepository,
+	cropRepo domain.CroplandRepository,
+	inventoryRepo domain.InventoryRepository,
+	identityRepo domain.IdentityRepository,
+	apiKeyRepo domain.APIKeyRepository,
+	sessionRepo domain.SessionRepository,
+	auditRepo domain.AuditLogRepository,
+	loginGuard *LoginGuard,
+) *AccountService {
+	return &AccountService{
+		logger:        logger,
+		userRepo:      userRepo,
+		farmRepo:      farmRepo,
+		memberRepo:    memberRepo,
+		cropRepo:      cropRepo,
+		inventoryRepo: inventoryRepo,
+		identityRepo:  identityRepo,
+		apiKeyRepo:    apiKeyRepo,
+		sessionRepo:   sessionRepo,
+		auditRepo:     auditRepo,
+		loginGuard:    loginGuard,
+	}
+}
+
+// Export builds a zip archive of the user's data, one JSON file per kind.
+func (s *AccountService) Export(ctx context.Context, userID string) ([]byte, error) {
+	user, err := s.userRepo.GetByUUID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	farms, err := s.farmRepo.GetByMemberID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get farms: %w", err)
+	}
+	for i := range farms {
+		if farms[i].OwnerID != userID {
+			continue
+		}
+		crops, err := s.cropRepo.GetByFarmID(ctx, farms[i].UUID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get croplands of farm %s: %w", farms[i].UUID, err)
+		}
+		farms[i].Crops = crops
+	}
+
+	inventory, err := s.inventoryRepo.GetByUserID(ctx, userID, domain.InventoryFilter{UserID: userID}, domain.InventorySort{Field: "created_at", Direction: "asc"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get inventory: %w", err)
+	}
+	invitations, err := s.memberRepo.ListInvitationsForEmail(ctx, user.Email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get farm invitations: %w", err)
+	}
+	identities, err := s.identityRepo.ListByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get linked identities: %w", err)
+	}
+	apiKeys, err := s.apiKeyRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API keys: %w", err)
+	}
+	sessions, err := s.sessionRepo.GetActiveByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sessions: %w", err)
+	}
+	audit, err := s.auditEntries(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get audit log: %w", err)
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	readme, err := archive.Create("README.txt")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fmt.Fprintf(readme, exportReadme, time.Now().UTC().Format(time.RFC1123)); err != nil {
+		return nil, err
+	}
+	for _, file := range []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", user},
+		{"farms.json", farms},
+		{"inventory.json", inventory},
+		{"farm_invitations.json", invitations},
+		{"linked_identities.json", identities},
+		{"api_keys.json", apiKeys},
+		{"sessions.json", sessions},
+		{"audit_log.json", audit},
+	} {
+		w, err := archive.Create(file.name)
+		if err != nil {
+			return nil, err
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(file.data); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", file.name, err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// auditEntries returns the entries about the user or made by them, newest
+// first.
+func (s *AccountService) auditEntries(ctx context.Context, userID string) ([]domain.AuditEntry, error) {
+	seen := make(map[string]bool)
+	entries := []domain.AuditEntry{}
+	for _, filter := range []domain.AuditLogFilter{{UserID: userID}, {ActorID: userID}} {
+		filter.Limit = auditExportPageSize
+		for {
+			page, err := s.auditRepo.List(ctx, filter)
+			if err != nil {
+				return nil, err
+			}
+			for _, e := range page {
+				if !seen[e.UUID] {
+					seen[e.UUID] = true
+					entries = append(entries, e)
+				}
+			}
+			if len(page) < filter.Limit {
+				break
+			}
+			last := page[len(page)-1]
+			filter.Before = &domain.AuditCursor{CreatedAt: last.CreatedAt, UUID: last.UUID}
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].CreatedAt.After(entries[j].CreatedAt) })
+	return entries, nil
+}
+
+// DeleteIfDue deletes an account whose deletion is still due at now, with
+// the farms it owns. Audit entries about the user are kept but anonymized.
+// Everything in Postgres goes in one transaction, so a cancellation either
+// lands first and keeps the account or waits for the deletion. It returns
+// false if the account was not due or another instance is deleting it.
+func (s *AccountService) DeleteIfDue(ctx context.Context, userID string, now time.Time) (bool, error) {
+	var user domain.User
+	var farmIDs []string
+	deleted, err := s.userRepo.DeleteIfDue(ctx, userID, now, func(u domain.User, stores domain.AccountDeletionStores) error {
+		owned, err := stores.Farms.GetByOwnerID(ctx, u.UUID)
+		if err != nil {
+			return fmt.Errorf("failed to get owned farms: %w", err)
+		}
+		farmIDs = make([]string, len(owned))
+		for i, farm := range owned {
+			farmIDs[i] = farm.UUID
+			// Deleting through the repository queues farm.deleted in the
+			// outbox, so analytics, webhooks and streams hear of it.
+			if err := stores.Farms.Delete(ctx, farm.UUID); err != nil {
+				return fmt.Errorf("failed to delete farm %s: %w", farm.UUID, err)
+			}
+		}
+
+		if err := stores.Audit.Anonymize(ctx, u.UUID, farmIDs); err != nil {
+			return fmt.Errorf("failed to anonymize audit log: %w", err)
+		}
+		if err := stores.Events.Forget(ctx, u.UUID, farmIDs); err != nil {
+			return fmt.Errorf("failed to remove stored events: %w", err)
+		}
+		if err := stores.Members.DeleteInvitationsForEmail(ctx, u.Email); err != nil {
+			return fmt.Errorf("failed to delete farm invitations: %w", err)
+		}
+		// Sessions, keys, identities, memberships and inventory are removed
+		// by the database along with the user.
+		user = u
+		return nil
+	})
+	if err != nil || !deleted {
+		return false, err
+	}
+
+	if err := s.loginGuard.Forget(ctx, user.Email); err != nil {
+		s.logger.Error("Failed to clear failed sign-ins of deleted account", "user_uuid", user.UUID, "error", err)
+	}
+	details, _ := json.Marshal(map[string]any{"farmsDeleted": len(farmIDs)})
+	if err := s.auditRepo.Record(ctx, &domain.AuditEntry{
+		Action:       domain.AuditAccountDeleted,
+		ResourceType: domain.AuditResourceUser,
+		ResourceID:   user.UUID,
+		Details:      details,
+	}); err != nil {
+		s.logger.Error("Failed to write audit log", "action", domain.AuditAccountDeleted, "error", err)
+	}
+	s.logger.Info("Account deleted", "user_uuid", user.UUID, "farms_deleted", len(farmIDs))
+	return true, nil
+}
@@ -20,6 +20,7 @@ type ChatService struct {
 	logger        *slog.Logger
 	analyticsRepo domain.AnalyticsRepository
 	farmRepo      domain.FarmRepository
+	memberRepo    domain.FarmMemberRepository
 	cropRepo      domain.CroplandRepository
 	inventoryRepo domain.InventoryRepository
 	plantRepo     domain.PlantRepository
@@ -29,6 +30,7 @@ func NewChatService(
 	logger *slog.Logger,
 	analyticsRepo domain.AnalyticsRepository,
 	farmRepo domain.FarmRepository,
+	memberRepo domain.FarmMemberRepository,
 	cropRepo domain.CroplandRepository,
 	inventoryRepo domain.InventoryRepository,
 	plantRepo domain.PlantRepository,
@@ -51,6 +53,7 @@ func NewChatService(
 		logger:        logger,
 		analyticsRepo: analyticsRepo,
 		farmRepo:      farmRepo,
+		memberRepo:    memberRepo,
 		cropRepo:      cropRepo,
 		inventoryRepo: inventoryRepo,
 		plantRepo:     plantRepo,
@@ -67,6 +70,21 @@ type GenerateResponseInput struct {
 
 // --- Context Building Helpers ---
 
+// canViewFarm reports whether the user owns or is a member of the farm.
+func (s *ChatService) canViewFarm(ctx context.Context, farmID, ownerID, userID string) bool {
+	if ownerID == userID {
+		return true
+	}
+	role, err := s.memberRepo.GetRole(ctx, farmID, userID)
+	if err != nil {
+		if !errors.Is(err, domain.ErrNotFound) {
+			s.logger.Error("Failed to get farm role for context", "farmId", farmID, "userId", userID, "error", err)
+		}
+		return false
+	}
+	return domain.FarmRoleAllows(role, domain.FarmPermView)
+}
+
 func (s *ChatService) buildCropContextString(ctx context.Context, cropID, userID string) (string, error) {
 	var contextBuilder strings.Builder
 	contextBuilder.WriteString("## Current Crop & Plant Context ##\n")
@@ -83,8 +101,8 @@ func (s *ChatService) buildCropContextString(ctx context.Context, cropID, userID
 	}
 
 	farm, err := s.farmRepo.GetByID(ctx, cropAnalytics.FarmID)
-	if err != nil || farm.OwnerID != userID {
-		s.logger.Warn("Ownership check failed for crop context", "cropId", cropID, "farmId", cropAnalytics.FarmID, "userId", userID)
+	if err != nil || !s.canViewFarm(ctx, farm.UUID, farm.OwnerID, userID) {
+		s.logger.Warn("Access check failed for crop context", "cropId", cropID, "farmId", cropAnalytics.FarmID, "userId", userID)
 		return "", fmt.Errorf("unauthorized access to crop data")
 	}
 
@@ -184,14 +202,18 @@ func (s *ChatService) buildFarmContextString(ctx context.Context, farmID, userID
 	contextBuilder.WriteString("## Current Farm Context ##\n")
 
 	farmAnalytics, err := s.analyticsRepo.GetFarmAnalytics(ctx, farmID)
-	if err != nil || farmAnalytics.OwnerID != userID {
-		if errors.Is(err, domain.ErrNotFound) || farmAnalytics == nil || farmAnalytics.OwnerID != userID {
-			s.logger.Warn("Farm analytics not found or ownership mismatch for context", "farmId", farmID, "userId", userID)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			s.logger.Warn("Farm analytics not found for context", "farmId", farmID, "userId", userID)
 			return "", fmt.Errorf("farm not found or access denied")
 		}
 		s.logger.Error("Failed to fetch farm analytics context", "farmId", farmID, "error", err)
 		return "", fmt.Errorf("failed to fetch farm details")
 	}
+	if !s.canViewFarm(ctx, farmAnalytics.FarmID, farmAnalytics.OwnerID, userID) {
+		s.logger.Warn("Access check failed for farm context", "farmId", farmID, "userId", userID)
+		return "", fmt.Errorf("farm not found or access denied")
+	}
 
 	fmt.Fprintf(&contextBuilder, "Farm Name: %s (ID: %s)\n", farmAnalytics.FarmName, farmAnalytics.FarmID)
 	if farmAnalytics.FarmType != nil {
@@ -298,7 +320,7 @@ func (s *ChatService) buildGeneralContextString(ctx context.Context, userID stri
 	var contextBuilder strings.Builder
 	contextBuilder.WriteString("## General Farming Context ##\n")
 
-	farms, err := s.farmRepo.GetByOwnerID(ctx, userID)
+	farms, err := s.farmRepo.GetByMemberID(ctx, userID)
 	if err == nil && len(farms) > 0 {
 		contextBuilder.WriteString("Your Farms:\n")
 		for i, farm := range farms {
@@ -306,7 +328,7 @@ func (s *ChatService) buildGeneralContextString(ctx context.Context, userID stri
 				fmt.Fprintf(&contextBuilder, "- ... and %d more\n", len(farms)-5)
 				break
 			}
-			fmt.Fprintf(&contextBuilder, "- %s (Type: %s, Size: %s)\n", farm.Name, farm.FarmType, farm.TotalSize)
+			fmt.Fprintf(&contextBuilder, "- %s (Type: %s, Size: %s, Your Role: %s)\n", farm.Name, farm.FarmType, farm.TotalSize, farm.Role)
 		}
 	} else if err != nil {
 		s.logger.Warn("Failed to fetch farms for general context", "userId", userID, "error", err)
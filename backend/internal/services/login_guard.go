@@ -0,0 +1,254 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/forfarm/backend/internal/config"
+	"github.com/forfarm/backend/internal/domain"
+)
+
+const (
+	// loginFreeAttempts is how many failures an account may have before each
+	// further attempt is delayed.
+	loginFreeAttempts = 3
+
+	defaultLoginLockoutThreshold   = 10
+	defaultLoginIPLockoutThreshold = 100
+	defaultLoginLockoutDuration    = 15 * time.Minute
+	defaultLoginFailureDelay       = time.Second
+)
+
+// LoginAttempt identifies a sign-in. Email is empty while the account is not
+// known yet, and UserID when no such account exists.
+type LoginAttempt struct {
+	Email  string
+	IP     string
+	UserID string
+}
+
+// LoginThrottledError is returned for attempts made too soon after failures.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+	// Locked is set when the account or address is locked out, rather than
+	// just asked to slow down.
+	Locked bool
+}
+
+func (e *LoginThrottledError) Error() string {
+	if e.Locked {
+		return fmt.Sprintf("temporarily locked after too many failed sign-ins; retry in %s", e.RetryAfter)
+	}
+	return fmt.Sprintf("too many failed sign-ins; retry in %s", e.RetryAfter)
+}
+
+// LoginGuard slows down and then locks out repeated failed sign-ins, both
+// per account, against guessing one password, and per client address,
+// against trying many accounts. Lockouts and unlocks are audited. Storage
+// errors are logged and let the attempt through, so an outage of the
+// counters does not stop everyone signing in.
+type LoginGuard struct {
+	logger    *slog.Logger
+	throttles domain.LoginThrottleRepository
+	audit     domain.AuditLogRepository
+	now       func() time.Time
+}
+
+func NewLoginGuard(logger *slog.Logger, throttles domain.LoginThrottleRepository, audit domain.AuditLogRepository) *LoginGuard {
+	return &LoginGuard{logger: logger, throttles: throttles, audit: audit, now: time.Now}
+}
+
+func accountThrottleKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipThrottleKey(ip string) string {
+	return "ip:" + ip
+}
+
+func lockoutDuration() time.Duration {
+	if config.LOGIN_LOCKOUT_DURATION > 0 {
+		return config.LOGIN_LOCKOUT_DURATION
+	}
+	return defaultLoginLockoutDuration
+}
+
+func lockoutThreshold() int {
+	if config.LOGIN_LOCKOUT_THRESHOLD > 0 {
+		return config.LOGIN_LOCKOUT_THRESHOLD
+	}
+	return defaultLoginLockoutThreshold
+}
+
+func ipLockoutThreshold() int {
+	if config.LOGIN_IP_LOCKOUT_THRESHOLD > 0 {
+		return config.LOGIN_IP_LOCKOUT_THRESHOLD
+	}
+	return defaultLoginIPLockoutThreshold
+}
+
+// failureDelay is how long an account must wait after its failures-th
+// failure: nothing for the first few, then doubling up to the lockout time.
+func failureDelay(failures int) time.Duration {
+	if failures < loginFreeAttempts {
+		return 0
+	}
+	base := config.LOGIN_FAILURE_DELAY
+	if base <= 0 {
+		base = defaultLoginFailureDelay
+	}
+	delay := base
+	for i := loginFreeAttempts; i < failures && delay < lockoutDuration(); i++ {
+		delay *= 2
+	}
+	return min(delay, lockoutDuration())
+}
+
+// Check returns a *LoginThrottledError if the attempt must not be made yet.
+func (g *LoginGuard) Check(ctx context.Context, attempt LoginAttempt) error {
+	now := g.now()
+	if attempt.IP != "" {
+		if t := g.get(ctx, ipThrottleKey(attempt.IP)); t != nil && t.IsLocked(now) {
+			return &LoginThrottledError{RetryAfter: t.LockedUntil.Sub(now), Locked: true}
+		}
+	}
+	if attempt.Email == "" {
+		return nil
+	}
+
+	t := g.get(ctx, accountThrottleKey(attempt.Email))
+	if t == nil {
+		return nil
+	}
+	if t.IsLocked(now) {
+		return &LoginThrottledError{RetryAfter: t.LockedUntil.Sub(now), Locked: true}
+	}
+	if t.LockedUntil != nil {
+		// The lockout has run out; start the account afresh.
+		_ = g.unlock(ctx, attempt, "", "expired")
+		return nil
+	}
+	if wait := t.LastFailedAt.Add(failureDelay(t.Failures)).Sub(now); wait > 0 {
+		return &LoginThrottledError{RetryAfter: wait}
+	}
+	return nil
+}
+
+// Fail counts a failed attempt, locking out the account or address once it
+// has too many recent failures.
+func (g *LoginGuard) Fail(ctx context.Context, attempt LoginAttempt) {
+	now := g.now()
+	windowStart := now.Add(-lockoutDuration())
+
+	if attempt.Email != "" {
+		key := accountThrottleKey(attempt.Email)
+		if t, err := g.throttles.RecordFailure(ctx, key, windowStart); err != nil {
+			g.logger.Error("Failed to record failed sign-in", "key", key, "error", err)
+		} else if t.Failures >= lockoutThreshold() && !t.IsLocked(now) {
+			g.lock(ctx, key, now, domain.AuditAccountLocked, attempt, map[string]any{"email": attempt.Email, "failures": t.Failures})
+		}
+	}
+	if attempt.IP != "" {
+		key := ipThrottleKey(attempt.IP)
+		if t, err := g.throttles.RecordFailure(ctx, key, windowStart); err != nil {
+			g.logger.Error("Failed to record failed sign-in", "key", key, "error", err)
+		} else if t.Failures >= ipLockoutThreshold() && !t.IsLocked(now) {
+			g.lock(ctx, key, now, domain.AuditIPLocked, LoginAttempt{IP: attempt.IP}, map[string]any{"failures": t.Failures})
+		}
+	}
+}
+
+// Succeed clears the account's failures after a complete sign-in. The
+// address keeps its count, since one success says little about the rest of
+// its traffic.
+func (g *LoginGuard) Succeed(ctx context.Context, attempt LoginAttempt) {
+	if attempt.Email == "" {
+		return
+	}
+	key := accountThrottleKey(attempt.Email)
+	if err := g.throttles.Delete(ctx, key); err != nil {
+		g.logger.Error("Failed to clear failed sign-ins", "key", key, "error", err)
+	}
+}
+
+// Forget drops the account's failed sign-ins without recording anything,
+// when the account is deleted.
+func (g *LoginGuard) Forget(ctx context.Context, email string) error {
+	return g.throttles.Delete(ctx, accountThrottleKey(email))
+}
+
+// Unlock lifts a lockout of the account early, for example after a password
+// reset or at an administrator's request, and reports whether it was locked.
+func (g *LoginGuard) Unlock(ctx context.Context, attempt LoginAttempt, actorID, reason string) (bool, error) {
+	t, err := g.throttles.Get(ctx, accountThrottleKey(attempt.Email))
+	if errors.Is(err, domain.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !t.IsLocked(g.now()) {
+		return false, g.throttles.Delete(ctx, t.Key)
+	}
+	return true, g.unlock(ctx, attempt, actorID, reason)
+}
+
+// get returns the key's record, or nil if it has none or it could not be
+// read.
+func (g *LoginGuard) get(ctx context.Context, key string) *domain.LoginThrottle {
+	t, err := g.throttles.Get(ctx, key)
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		g.logger.Error("Failed to check failed sign-ins", "key", key, "error", err)
+	}
+	return t
+}
+
+func (g *LoginGuard) lock(ctx context.Context, key string, now time.Time, action string, attempt LoginAttempt, details map[string]any) {
+	until := now.Add(lockoutDuration())
+	if err := g.throttles.Lock(ctx, key, until); err != nil {
+		g.logger.Error("Failed to lock out sign-ins", "key", key, "error", err)
+		return
+	}
+	g.logger.Warn("Sign-ins locked out after repeated failures", "key", key, "until", until)
+	details["lockedUntil"] = until
+	g.record(ctx, action, attempt, "", details)
+}
+
+func (g *LoginGuard) unlock(ctx context.Context, attempt LoginAttempt, actorID, reason string) error {
+	key := accountThrottleKey(attempt.Email)
+	if err := g.throttles.Delete(ctx, key); err != nil {
+		g.logger.Error("Failed to unlock sign-ins", "key", key, "error", err)
+		return err
+	}
+	g.logger.Info("Sign-ins unlocked", "key", key, "reason", reason)
+	g.record(ctx, domain.AuditAccountUnlocked, attempt, actorID, map[string]any{"email": attempt.Email, "reason": reason})
+	return nil
+}
+
+func (g *LoginGuard) record(ctx context.Context, action string, attempt LoginAttempt, actorID string, details map[string]any) {
+	raw, err := json.Marshal(details)
+	if err != nil {
+		g.logger.Error("Failed to encode audit details", "action", action, "error", err)
+		return
+	}
+	entry := &domain.AuditEntry{
+		Action:    action,
+		UserID:    attempt.UserID,
+		ActorID:   actorID,
+		IPAddress: attempt.IP,
+		UserAgent: domain.RequestInfoFromContext(ctx).UserAgent,
+		Details:   raw,
+	}
+	if attempt.UserID != "" {
+		entry.ResourceType = domain.AuditResourceUser
+		entry.ResourceID = attempt.UserID
+	}
+	if err := g.audit.Record(ctx, entry); err != nil {
+		g.logger.Error("Failed to write audit log", "action", action, "error", err)
+	}
+}
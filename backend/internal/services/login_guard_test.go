@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/forfarm/backend/internal/domain"
+)
+
+type memoryThrottles struct {
+	now     func() time.Time
+	records map[string]*domain.LoginThrottle
+}
+
+func (m *memoryThrottles) Get(_ context.Context, key string) (*domain.LoginThrottle, error) {
+	t, ok := m.records[key]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	record := *t
+	return &record, nil
+}
+
+func (m *memoryThrottles) RecordFailure(_ context.Context, key string, windowStart time.Time) (*domain.LoginThrottle, error) {
+	t, ok := m.records[key]
+	if !ok {
+		t = &domain.LoginThrottle{Key: key}
+		m.records[key] = t
+	}
+	if t.LastFailedAt.Before(windowStart) {
+		t.Failures = 0
+	}
+	t.Failures++
+	t.LastFailedAt = m.now()
+	record := *t
+	return &record, nil
+}
+
+func (m *memoryThrottles) Lock(_ context.Context, key string, until time.Time) error {
+	m.records[key].LockedUntil = &until
+	return nil
+}
+
+func (m *memoryThrottles) Delete(_ context.Context, key string) error {
+	delete(m.records, key)
+	return nil
+}
+
+type memoryAudit []domain.AuditEntry
+
+func (m *memoryAudit) Record(_ context.Context, e *domain.AuditEntry) error {
+	*m = append(*m, *e)
+	return nil
+}
+
+func (m *memoryAudit) List(context.Context, domain.AuditLogFilter) ([]domain.AuditEntry, error) {
+	return *m, nil
+}
+
+func (m *memoryAudit) Anonymize(context.Context, string, []string) error {
+	return nil
+}
+
+func TestLoginGuard(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	throttles := &memoryThrottles{now: clock, records: map[string]*domain.LoginThrottle{}}
+	audit := &memoryAudit{}
+	guard := NewLoginGuard(slog.New(slog.NewTextHandler(io.Discard, nil)), throttles, audit)
+	guard.now = clock
+
+	attempt := LoginAttempt{Email: "Farmer@Example.com", IP: "203.0.113.7", UserID: "123e4567-e89b-12d3-a456-426614174000"}
+	var throttled *LoginThrottledError
+
+	// The first failures cost nothing, then each attempt waits longer.
+	for i := 0; i < loginFreeAttempts-1; i++ {
+		guard.Fail(ctx, attempt)
+		require.NoError(t, guard.Check(ctx, attempt))
+	}
+	guard.Fail(ctx, attempt)
+	require.True(t, errors.As(guard.Check(ctx, attempt), &throttled))
+	assert.False(t, throttled.Locked)
+	assert.Equal(t, time.Second, throttled.RetryAfter)
+
+	now = now.Add(time.Second)
+	require.NoError(t, guard.Check(ctx, attempt))
+	guard.Fail(ctx, attempt)
+	require.True(t, errors.As(guard.Check(ctx, attempt), &throttled))
+	assert.Equal(t, 2*time.Second, throttled.RetryAfter)
+
+	// Enough failures lock the account, whatever the case of the address.
+	for i := loginFreeAttempts + 1; i < defaultLoginLockoutThreshold; i++ {
+		guard.Fail(ctx, attempt)
+	}
+	require.True(t, errors.As(guard.Check(ctx, LoginAttempt{Email: "farmer@example.com"}), &throttled))
+	assert.True(t, throttled.Locked)
+	assert.Equal(t, defaultLoginLockoutDuration, throttled.RetryAfter)
+	require.Len(t, *audit, 1)
+	assert.Equal(t, domain.AuditAccountLocked, (*audit)[0].Action)
+	assert.Equal(t, attempt.UserID, (*audit)[0].UserID)
+
+	// Another address is not affected, and the lock runs out on its own.
+	require.NoError(t, guard.Check(ctx, LoginAttempt{Email: "other@example.com", IP: attempt.IP}))
+	now = now.Add(defaultLoginLockoutDuration)
+	require.NoError(t, guard.Check(ctx, attempt))
+	require.Len(t, *audit, 2)
+	assert.Equal(t, domain.AuditAccountUnlocked, (*audit)[1].Action)
+	assert.JSONEq(t, `{"email":"Farmer@Example.com","reason":"expired"}`, string((*audit)[1].Details))
+
+	// An administrator can lift a lock early; a success clears the count.
+	for i := 0; i < defaultLoginLockoutThreshold; i++ {
+		guard.Fail(ctx, attempt)
+	}
+	unlocked, err := guard.Unlock(ctx, attempt, "admin-id", "admin")
+	require.NoError(t, err)
+	assert.True(t, unlocked)
+	require.NoError(t, guard.Check(ctx, attempt))
+	guard.Fail(ctx, attempt)
+	guard.Succeed(ctx, attempt)
+	_, err = throttles.Get(ctx, accountThrottleKey(attempt.Email))
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+}
+
+func TestLoginGuard_IPLockout(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	audit := &memoryAudit{}
+	guard := NewLoginGuard(slog.New(slog.NewTextHandler(io.Discard, nil)),
+		&memoryThrottles{now: clock, records: map[string]*domain.LoginThrottle{}}, audit)
+	guard.now = clock
+
+	// Credential stuffing: one failure each on many accounts.
+	for i := 0; i < defaultLoginIPLockoutThreshold; i++ {
+		guard.Fail(ctx, LoginAttempt{Email: string(rune('a'+i%26)) + "@example.com", IP: "198.51.100.1"})
+		now = now.Add(time.Second)
+	}
+
+	var throttled *LoginThrottledError
+	require.True(t, errors.As(guard.Check(ctx, LoginAttempt{Email: "new@example.com", IP: "198.51.100.1"}), &throttled))
+	assert.True(t, throttled.Locked)
+	require.NoError(t, guard.Check(ctx, LoginAttempt{Email: "new@example.com", IP: "198.51.100.2"}))
+	assert.Equal(t, domain.AuditIPLocked, (*audit)[len(*audit)-1].Action)
+}
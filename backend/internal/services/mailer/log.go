@@ -0,0 +1,23 @@
+package mailer
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/forfarm/backend/internal/domain"
+)
+
+// LogMailer logs emails instead of sending them. Bodies carry single-use
+// links, so it is only meant for development.
+type LogMailer struct {
+	logger *slog.Logger
+}
+
+func NewLogMailer(logger *slog.Logger) domain.Mailer {
+	return &LogMailer{logger: logger}
+}
+
+func (m *LogMailer) Send(_ context.Context, email domain.Email) error {
+	m.logger.Info("Email not sent (MAILER_DRIVER=log)", "to", email.To, "subject", email.Subject, "body", email.Body)
+	return nil
+}
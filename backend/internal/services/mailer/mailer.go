@@ -0,0 +1,25 @@
+// Package mailer provides the domain.Mailer implementations selected by
+// MAILER_DRIVER.
+package mailer
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/forfarm/backend/internal/config"
+	"github.com/forfarm/backend/internal/domain"
+)
+
+// New returns the mailer configured by MAILER_DRIVER: smtp, file or log.
+func New(logger *slog.Logger) (domain.Mailer, error) {
+	switch config.MAILER_DRIVER {
+	case "smtp":
+		return NewSMTPMailer(config.SMTP_HOST, config.SMTP_PORT, config.SMTP_USERNAME, config.SMTP_PASSWORD, config.MAIL_FROM)
+	case "file":
+		return NewFileMailer(config.MAIL_FILE_DIR, config.MAIL_FROM)
+	case "log", "":
+		return NewLogMailer(logger), nil
+	default:
+		return nil, fmt.Errorf("unknown MAILER_DRIVER %q", config.MAILER_DRIVER)
+	}
+}
@@ -0,0 +1,60 @@
+package oidc
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/forfarm/backend/internal/config"
+)
+
+// wellKnownProviders fills in the issuer of providers that only need a client
+// ID configured.
+var wellKnownProviders = map[string]ProviderConfig{
+	"google": {
+		Issuer:       "https://accounts.google.com",
+		ExtraIssuers: []string{"accounts.google.com"},
+	},
+	// The common endpoint accepts work, school and personal accounts; set
+	// OIDC_MICROSOFT_ISSUER to a tenant's issuer to accept only that tenant.
+	"microsoft": {Issuer: "https://login.microsoftonline.com/common/v2.0"},
+	"line":      {Issuer: "https://access.line.me"},
+}
+
+// New returns the providers listed in OIDC_PROVIDERS. Each is configured by
+// OIDC_<NAME>_ISSUER, which well-known providers may omit, and by
+// OIDC_<NAME>_CLIENT_ID, a comma-separated list of accepted audiences. Google
+// falls back to GOOGLE_CLIENT_ID.
+func New(client *http.Client) (*Registry, error) {
+	var configs []ProviderConfig
+	for _, name := range strings.Split(config.OIDC_PROVIDERS, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		cfg := wellKnownProviders[name]
+		cfg.Name = name
+		if issuer := config.OIDCProviderSetting(name, "ISSUER"); issuer != "" {
+			cfg.Issuer = issuer
+		}
+		clientIDs := config.OIDCProviderSetting(name, "CLIENT_ID")
+		if clientIDs == "" && name == "google" {
+			clientIDs = config.GOOGLE_CLIENT_ID
+		}
+		for _, id := range strings.Split(clientIDs, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				cfg.ClientIDs = append(cfg.ClientIDs, id)
+			}
+		}
+
+		if cfg.Issuer == "" {
+			return nil, fmt.Errorf("OIDC provider %q has no issuer; set OIDC_%s_ISSUER", name, strings.ToUpper(name))
+		}
+		if len(cfg.ClientIDs) == 0 {
+			return nil, fmt.Errorf("OIDC provider %q has no client ID; set OIDC_%s_CLIENT_ID", name, strings.ToUpper(name))
+		}
+		configs = append(configs, cfg)
+	}
+	return NewRegistry(client, configs...), nil
+}
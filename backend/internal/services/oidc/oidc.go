@@ -0,0 +1,273 @@
+// Package oidc verifies ID tokens issued by OpenID Connect providers. Each
+// provider's signing keys are found through its discovery document and
+// cached until a token names a key that is not in the cache.
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrUnknownProvider = errors.New("unknown identity provider")
+	ErrInvalidToken    = errors.New("invalid ID token")
+)
+
+const (
+	// keyRefreshInterval is how soon the keys may be fetched again when a
+	// token names an unknown key, so forged key IDs cannot flood the provider.
+	keyRefreshInterval = time.Minute
+	// clockSkew is the leeway allowed on exp, iat and nbf.
+	clockSkew = time.Minute
+	// tenantPlaceholder stands for the tid claim in the issuer of
+	// multi-tenant providers such as Microsoft's common endpoint.
+	tenantPlaceholder = "{tenantid}"
+)
+
+// signingMethods are the algorithms ID tokens may be signed with. Symmetric
+// algorithms are excluded: the client ID is not a secret.
+var signingMethods = []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// ProviderConfig describes a provider users can sign in with.
+type ProviderConfig struct {
+	// Name identifies the provider in the API and in linked identities.
+	Name string
+	// Issuer is the provider's issuer URL; its discovery document is at
+	// Issuer + "/.well-known/openid-configuration".
+	Issuer string
+	// ClientIDs are the audiences tokens may be issued to.
+	ClientIDs []string
+	// ExtraIssuers are other iss values the provider uses, such as Google's
+	// "accounts.google.com".
+	ExtraIssuers []string
+}
+
+// Identity is the account an ID token was issued for.
+type Identity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+type discoveryDocument struct {
+	Issuer  string `json:"issuer"`
+	JWKSURI string `json:"jwks_uri"`
+}
+
+// Provider verifies the ID tokens of one provider.
+type Provider struct {
+	cfg    ProviderConfig
+	client *http.Client
+	now    func() time.Time
+
+	mu          sync.Mutex
+	discovery   *discoveryDocument
+	keys        map[string]interface{}
+	keysFetched time.Time
+}
+
+func NewProvider(client *http.Client, cfg ProviderConfig) *Provider {
+	cfg.Issuer = strings.TrimRight(cfg.Issuer, "/")
+	return &Provider{cfg: cfg, client: client, now: time.Now}
+}
+
+func (p *Provider) Name() string {
+	return p.cfg.Name
+}
+
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	Email           string   `json:"email"`
+	EmailVerified   flexBool `json:"email_verified"`
+	Name            string   `json:"name"`
+	Nonce           string   `json:"nonce"`
+	AuthorizedParty string   `json:"azp"`
+	TenantID        string   `json:"tid"`
+}
+
+// flexBool accepts both true and "true"; some providers send the string.
+type flexBool bool
+
+func (b *flexBool) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case bool:
+		*b = flexBool(v)
+	case string:
+		*b = flexBool(v == "true")
+	}
+	return nil
+}
+
+// Verify checks the signature and claims of an ID token. When nonce is not
+// empty the token must carry it.
+func (p *Provider) Verify(ctx context.Context, rawToken, nonce string) (*Identity, error) {
+	doc, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var claims idTokenClaims
+	_, err = jwt.ParseWithClaims(rawToken, &claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.key(ctx, doc, kid)
+	},
+		jwt.WithValidMethods(signingMethods),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(clockSkew),
+		jwt.WithTimeFunc(p.now),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	if !p.validIssuer(doc, claims.Issuer, claims.TenantID) {
+		return nil, fmt.Errorf("%w: unexpected issuer %q", ErrInvalidToken, claims.Issuer)
+	}
+	if !slices.ContainsFunc(claims.Audience, func(aud string) bool { return slices.Contains(p.cfg.ClientIDs, aud) }) {
+		return nil, fmt.Errorf("%w: not issued to this application", ErrInvalidToken)
+	}
+	if claims.AuthorizedParty != "" && !slices.Contains(p.cfg.ClientIDs, claims.AuthorizedParty) {
+		return nil, fmt.Errorf("%w: unexpected authorized party", ErrInvalidToken)
+	}
+	if nonce != "" && claims.Nonce != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidToken)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidToken)
+	}
+
+	return &Identity{
+		Provider:      p.cfg.Name,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: bool(claims.EmailVerified),
+		Name:          claims.Name,
+	}, nil
+}
+
+func (p *Provider) validIssuer(doc *discoveryDocument, iss, tenantID string) bool {
+	expected := doc.Issuer
+	if strings.Contains(expected, tenantPlaceholder) {
+		if tenantID == "" {
+			return false
+		}
+		expected = strings.ReplaceAll(expected, tenantPlaceholder, tenantID)
+	}
+	return iss == expected || slices.Contains(p.cfg.ExtraIssuers, iss)
+}
+
+// discover fetches the discovery document once and keeps it.
+func (p *Provider) discover(ctx context.Context) (*discoveryDocument, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	var doc discoveryDocument
+	if err := p.getJSON(ctx, p.cfg.Issuer+"/.well-known/openid-configuration", &doc); err != nil {
+		return nil, fmt.Errorf("failed to discover %s: %w", p.cfg.Name, err)
+	}
+	doc.Issuer = strings.TrimRight(doc.Issuer, "/")
+	// Multi-tenant endpoints publish a template instead of their own URL.
+	if doc.Issuer != p.cfg.Issuer && !strings.Contains(doc.Issuer, tenantPlaceholder) {
+		return nil, fmt.Errorf("%s discovery document names issuer %q", p.cfg.Name, doc.Issuer)
+	}
+	if doc.JWKSURI == "" {
+		return nil, fmt.Errorf("%s discovery document has no jwks_uri", p.cfg.Name)
+	}
+	p.discovery = &doc
+	return p.discovery, nil
+}
+
+// key returns the public key with ID kid, fetching the provider's keys again
+// if it is unknown, as happens after the provider rotates them.
+func (p *Provider) key(ctx context.Context, doc *discoveryDocument, kid string) (interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	if p.keys != nil && p.now().Sub(p.keysFetched) < keyRefreshInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	var set jsonWebKeySet
+	if err := p.getJSON(ctx, doc.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("failed to fetch %s signing keys: %w", p.cfg.Name, err)
+	}
+	keys, err := set.publicKeys()
+	if err != nil {
+		return nil, err
+	}
+	p.keys = keys
+	p.keysFetched = p.now()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func (p *Provider) getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// Registry holds the providers users can sign in with.
+type Registry struct {
+	providers map[string]*Provider
+	names     []string
+}
+
+func NewRegistry(client *http.Client, configs ...ProviderConfig) *Registry {
+	r := &Registry{providers: make(map[string]*Provider, len(configs))}
+	for _, cfg := range configs {
+		r.providers[cfg.Name] = NewProvider(client, cfg)
+		r.names = append(r.names, cfg.Name)
+	}
+	return r
+}
+
+// Names lists the providers in the order they were configured.
+func (r *Registry) Names() []string {
+	return slices.Clone(r.names)
+}
+
+// Verify checks an ID token issued by the named provider.
+func (r *Registry) Verify(ctx context.Context, provider, rawToken, nonce string) (*Identity, error) {
+	p, ok := r.providers[provider]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return p.Verify(ctx, rawToken, nonce)
+}
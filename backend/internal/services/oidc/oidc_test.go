@@ -0,0 +1,109 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/forfarm/backend/internal/services/oidc/oidctest"
+)
+
+func TestProviderVerify(t *testing.T) {
+	iss := oidctest.NewIssuer()
+	defer iss.Close()
+	ctx := context.Background()
+	p := NewProvider(iss.Client(), ProviderConfig{Name: "mock", Issuer: iss.URL(), ClientIDs: []string{"web", "mobile"}})
+
+	identity, err := p.Verify(ctx, iss.Token("mobile", "user-1", jwt.MapClaims{
+		"email":          "farmer@example.com",
+		"email_verified": "true",
+		"nonce":          "n-1",
+	}), "n-1")
+	require.NoError(t, err)
+	assert.Equal(t, &Identity{Provider: "mock", Subject: "user-1", Email: "farmer@example.com", EmailVerified: true}, identity)
+
+	tests := []struct {
+		name  string
+		token string
+		nonce string
+	}{
+		{"other audience", iss.Token("someone-else", "user-1", nil), ""},
+		{"expired", iss.Token("web", "user-1", jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()}), ""},
+		{"no expiry", iss.Token("web", "user-1", jwt.MapClaims{"exp": nil}), ""},
+		{"other issuer", iss.Token("web", "user-1", jwt.MapClaims{"iss": "https://evil.example"}), ""},
+		{"foreign authorized party", iss.Token("web", "user-1", jwt.MapClaims{"azp": "someone-else"}), ""},
+		{"nonce mismatch", iss.Token("web", "user-1", jwt.MapClaims{"nonce": "other"}), "n-1"},
+		{"no subject", iss.Token("web", "", nil), ""},
+		{"shared secret", hmacToken(t, iss.URL()), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := p.Verify(ctx, tt.token, tt.nonce)
+			assert.True(t, errors.Is(err, ErrInvalidToken), "got %v", err)
+		})
+	}
+}
+
+func hmacToken(t *testing.T, issuer string) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"iss": issuer, "aud": "web", "sub": "user-1", "exp": time.Now().Add(time.Hour).Unix(),
+	})
+	token.Header["kid"] = "key-1"
+	signed, err := token.SignedString([]byte("web"))
+	require.NoError(t, err)
+	return signed
+}
+
+func TestProviderKeyRotation(t *testing.T) {
+	iss := oidctest.NewIssuer()
+	defer iss.Close()
+	ctx := context.Background()
+	now := time.Now()
+	p := NewProvider(iss.Client(), ProviderConfig{Name: "mock", Issuer: iss.URL(), ClientIDs: []string{"web"}})
+	p.now = func() time.Time { return now }
+
+	_, err := p.Verify(ctx, iss.Token("web", "user-1", nil), "")
+	require.NoError(t, err)
+	_, err = p.Verify(ctx, iss.Token("web", "user-1", nil), "")
+	require.NoError(t, err)
+	assert.Equal(t, 1, iss.KeyFetches(), "keys are cached")
+
+	// A token naming a key the provider has not published is refused, and
+	// the keys are not fetched again right away.
+	other := oidctest.NewIssuer()
+	defer other.Close()
+	other.RotateKey()
+	forged := other.Token("web", "user-1", jwt.MapClaims{"iss": iss.URL()})
+	_, err = p.Verify(ctx, forged, "")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	assert.Equal(t, 1, iss.KeyFetches())
+
+	// Once the provider rotates, the new key is fetched.
+	iss.RotateKey()
+	now = now.Add(keyRefreshInterval)
+	_, err = p.Verify(ctx, iss.Token("web", "user-1", nil), "")
+	require.NoError(t, err)
+	assert.Equal(t, 2, iss.KeyFetches())
+}
+
+func TestProviderTenantIssuer(t *testing.T) {
+	p := NewProvider(nil, ProviderConfig{Name: "microsoft"})
+	doc := &discoveryDocument{Issuer: "https://login.microsoftonline.com/{tenantid}/v2.0"}
+
+	assert.True(t, p.validIssuer(doc, "https://login.microsoftonline.com/tenant-a/v2.0", "tenant-a"))
+	assert.False(t, p.validIssuer(doc, "https://login.microsoftonline.com/tenant-a/v2.0", "tenant-b"))
+	assert.False(t, p.validIssuer(doc, "https://login.microsoftonline.com/{tenantid}/v2.0", ""))
+}
+
+func TestRegistryUnknownProvider(t *testing.T) {
+	r := NewRegistry(nil, ProviderConfig{Name: "google"})
+	assert.Equal(t, []string{"google"}, r.Names())
+	_, err := r.Verify(context.Background(), "facebook", "token", "")
+	assert.ErrorIs(t, err, ErrUnknownProvider)
+}
@@ -0,0 +1,58 @@
+package utilities
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrActionTokenInvalid = errors.New("token is invalid")
+	ErrActionTokenExpired = errors.New("token has expired")
+)
+
+// NewActionToken returns a signed token allowing purpose to be carried out
+// for subject until ttl passes. fingerprint should summarise the state the
+// action changes, such as the password hash for a reset: the caller compares
+// it on use, so the token stops working once the action has been done.
+func NewActionToken(secret []byte, purpose, subject, fingerprint string, ttl time.Duration) string {
+	exp := strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+	payload := base64.RawURLEncoding.EncodeToString([]byte(strings.Join([]string{purpose, subject, exp, fingerprint}, "\n")))
+	return payload + "." + signActionToken(secret, payload)
+}
+
+// ParseActionToken verifies a token made by NewActionToken for purpose and
+// returns its subject and fingerprint.
+func ParseActionToken(secret []byte, token, purpose string) (subject, fingerprint string, err error) {
+	payload, sig, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(signActionToken(secret, payload))) {
+		return "", "", ErrActionTokenInvalid
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return "", "", ErrActionTokenInvalid
+	}
+	parts := strings.Split(string(raw), "\n")
+	if len(parts) != 4 || parts[0] != purpose {
+		return "", "", ErrActionTokenInvalid
+	}
+	exp, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return "", "", ErrActionTokenInvalid
+	}
+	if time.Now().Unix() > exp {
+		return "", "", ErrActionTokenExpired
+	}
+	return parts[1], parts[3], nil
+}
+
+func signActionToken(secret []byte, payload string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,39 @@
+package utilities
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// APIKeyPrefix starts every API key, so the auth middleware can tell keys
+// from access tokens and secret scanners can recognise leaked ones.
+const APIKeyPrefix = "ffk_"
+
+// apiKeyDisplayLength is how much of a key is kept to identify it.
+const apiKeyDisplayLength = len(APIKeyPrefix) + 8
+
+// NewAPIKey returns a random API key, the prefix that identifies it in
+// listings, and the hash to store in its place.
+func NewAPIKey() (key, displayPrefix, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", "", err
+	}
+	key = APIKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return key, key[:apiKeyDisplayLength], HashAPIKey(key), nil
+}
+
+// IsAPIKey reports whether a bearer credential is an API key rather than an
+// access token.
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
+
+// HashAPIKey returns the stored form of an API key.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -8,16 +8,44 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
-// TODO: Change later
-var defaultSecretKey = []byte(config.JWT_SECRET_KEY)
+const defaultAccessTokenTTL = 15 * time.Minute
 
-func CreateJwtToken(uuid string) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"uuid": uuid,
-		"exp":  time.Now().Add(time.Hour * 24).Unix(),
-	})
+// AccessTokenTTL is how long access tokens are valid.
+func AccessTokenTTL() time.Duration {
+	if config.ACCESS_TOKEN_TTL > 0 {
+		return config.ACCESS_TOKEN_TTL
+	}
+	return defaultAccessTokenTTL
+}
 
-	tokenString, err := token.SignedString(defaultSecretKey)
+// CreateJwtToken issues a short-lived access token for a user's session.
+func CreateJwtToken(uuid string, sessionID string) (string, error) {
+	return IssueAccessToken(TokenClaims{UserID: uuid, SessionID: sessionID})
+}
+
+// IssueAccessToken issues a short-lived access token carrying c.
+func IssueAccessToken(c TokenClaims) (string, error) {
+	claims := jwt.MapClaims{
+		"uuid": c.UserID,
+		"sid":  c.SessionID,
+		"exp":  time.Now().Add(AccessTokenTTL()).Unix(),
+	}
+	if c.Role != "" {
+		claims["role"] = c.Role
+	}
+	if c.TwoFactor {
+		claims["mfa"] = true
+	}
+
+	var tokenString string
+	var err error
+	if ks := signingKeys.Load(); ks != nil {
+		token := jwt.NewWithClaims(ks.active.Method, claims)
+		token.Header["kid"] = ks.active.ID
+		tokenString, err = token.SignedString(ks.active.Private)
+	} else {
+		tokenString, err = jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secretKey())
+	}
 	if err != nil {
 		return "", err
 	}
@@ -25,22 +53,40 @@ func CreateJwtToken(uuid string) (string, error) {
 	return tokenString, nil
 }
 
-func VerifyJwtToken(tokenString string, customKey ...[]byte) error {
-	secretKey := defaultSecretKey
+// secretKey is read on every use, since the package may be initialised before
+// the configuration is loaded.
+func secretKey() []byte {
+	return []byte(config.JWT_SECRET_KEY)
+}
+
+// verificationKeyFunc picks the key a token is verified with: customKey if
+// given, else the token's kid in the configured key set, else the HMAC secret.
+func verificationKeyFunc(customKey [][]byte) (jwt.Keyfunc, error) {
+	hmacKey := secretKey()
 	if len(customKey) > 0 {
 		if len(customKey[0]) < 32 {
-			return errors.New("provided key is too short, minimum length is 32 bytes")
+			return nil, errors.New("provided key is too short, minimum length is 32 bytes")
 		}
-		secretKey = customKey[0]
+		hmacKey = customKey[0]
+	} else if ks := signingKeys.Load(); ks != nil {
+		return ks.verificationKey, nil
 	}
 
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+	return func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, jwt.ErrSignatureInvalid
 		}
+		return hmacKey, nil
+	}, nil
+}
+
+func VerifyJwtToken(tokenString string, customKey ...[]byte) error {
+	keyFunc, err := verificationKeyFunc(customKey)
+	if err != nil {
+		return err
+	}
 
-		return secretKey, nil
-	})
+	token, err := jwt.Parse(tokenString, keyFunc)
 
 	if err != nil {
 		return err
@@ -53,40 +99,54 @@ func VerifyJwtToken(tokenString string, customKey ...[]byte) error {
 	return nil
 }
 
-func ExtractUUIDFromToken(tokenString string, customKey ...[]byte) (string, error) {
-	secretKey := defaultSecretKey
-	if len(customKey) > 0 {
-		if len(customKey[0]) < 32 {
-			return "", errors.New("provided key is too short, minimum length is 32 bytes")
-		}
-		secretKey = customKey[0]
-	}
+// TokenClaims are the claims of a verified access token.
+type TokenClaims struct {
+	UserID string
+	// Role is empty for tokens that carry no role.
+	Role string
+	// SessionID is the login session the token was issued for.
+	SessionID string
+	// TwoFactor is set when the session was opened with a second factor.
+	TwoFactor bool
+}
 
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, jwt.ErrSignatureInvalid
-		}
+// ParseJwtToken verifies a token and returns its claims.
+func ParseJwtToken(tokenString string, customKey ...[]byte) (TokenClaims, error) {
+	keyFunc, err := verificationKeyFunc(customKey)
+	if err != nil {
+		return TokenClaims{}, err
+	}
 
-		return secretKey, nil
-	})
+	token, err := jwt.Parse(tokenString, keyFunc)
 
 	if err != nil {
-		return "", err
+		return TokenClaims{}, err
 	}
 
 	if !token.Valid {
-		return "", jwt.ErrSignatureInvalid
+		return TokenClaims{}, jwt.ErrSignatureInvalid
 	}
 
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok {
-		return "", errors.New("unable to parse claims")
+		return TokenClaims{}, errors.New("unable to parse claims")
 	}
 
 	userID, ok := claims["uuid"].(string)
 	if !ok || userID == "" {
-		return "", errors.New("uuid claim is missing or invalid")
+		return TokenClaims{}, errors.New("uuid claim is missing or invalid")
 	}
+	role, _ := claims["role"].(string)
+	sessionID, _ := claims["sid"].(string)
+	twoFactor, _ := claims["mfa"].(bool)
+
+	return TokenClaims{UserID: userID, Role: role, SessionID: sessionID, TwoFactor: twoFactor}, nil
+}
 
-	return userID, nil
+func ExtractUUIDFromToken(tokenString string, customKey ...[]byte) (string, error) {
+	claims, err := ParseJwtToken(tokenString, customKey...)
+	if err != nil {
+		return "", err
+	}
+	return claims.UserID, nil
 }
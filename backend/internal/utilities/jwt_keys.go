@@ -0,0 +1,265 @@
+package utilities
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	KeyAlgEdDSA = "EdDSA"
+	KeyAlgRS256 = "RS256"
+
+	rsaKeyBits = 3072
+	// keyFileExt is the extension of key files in a signing key directory.
+	// The file name without it is the key ID.
+	keyFileExt = ".pem"
+)
+
+// SigningKey is one key pair of a KeySet. Retired keys have no private key
+// and only verify tokens issued before they were retired.
+type SigningKey struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private crypto.Signer
+	Public  crypto.PublicKey
+}
+
+// KeySet holds the key that signs new tokens and every key whose tokens are
+// still accepted.
+type KeySet struct {
+	active *SigningKey
+	keys   map[string]*SigningKey
+}
+
+var signingKeys atomic.Pointer[KeySet]
+
+// SetSigningKeys makes tokens be signed with the active key of ks and verified
+// by kid against its keys. Until it is called, tokens are signed with HS256
+// and JWT_SECRET_KEY.
+func SetSigningKeys(ks *KeySet) {
+	signingKeys.Store(ks)
+}
+
+// NewKeySet builds a key set that signs with the key whose ID is activeID.
+func NewKeySet(keys []*SigningKey, activeID string) (*KeySet, error) {
+	ks := &KeySet{keys: make(map[string]*SigningKey, len(keys))}
+	for _, k := range keys {
+		if _, dup := ks.keys[k.ID]; dup {
+			return nil, fmt.Errorf("duplicate signing key id %q", k.ID)
+		}
+		ks.keys[k.ID] = k
+	}
+
+	active, ok := ks.keys[activeID]
+	if !ok {
+		return nil, fmt.Errorf("active signing key %q not found", activeID)
+	}
+	if active.Private == nil {
+		return nil, fmt.Errorf("active signing key %q has no private key", activeID)
+	}
+	ks.active = active
+	return ks, nil
+}
+
+// LoadSigningKeys reads every <kid>.pem file of dir. Files hold a PKCS#8
+// private key or, for retired keys, a PKIX public key. The key named activeID
+// signs new tokens; if activeID is empty, the private key with the greatest
+// ID does, which is the newest one for IDs made by GenerateSigningKey.
+func LoadSigningKeys(dir, activeID string) (*KeySet, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key directory: %w", err)
+	}
+
+	var keys []*SigningKey
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != keyFileExt {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read signing key %s: %w", e.Name(), err)
+		}
+		key, err := ParseSigningKey(strings.TrimSuffix(e.Name(), keyFileExt), data)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no signing keys in %s", dir)
+	}
+
+	if activeID == "" {
+		sort.Slice(keys, func(i, j int) bool { return keys[i].ID > keys[j].ID })
+		for _, k := range keys {
+			if k.Private != nil {
+				activeID = k.ID
+				break
+			}
+		}
+	}
+	return NewKeySet(keys, activeID)
+}
+
+// ParseSigningKey decodes a PEM encoded Ed25519 or RSA key.
+func ParseSigningKey(id string, data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("signing key %s: no PEM data", id)
+	}
+
+	key := &SigningKey{ID: id}
+	switch block.Type {
+	case "PRIVATE KEY":
+		priv, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %s: %w", id, err)
+		}
+		signer, ok := priv.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("signing key %s: unsupported key type %T", id, priv)
+		}
+		key.Private = signer
+		key.Public = signer.Public()
+	case "PUBLIC KEY":
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %s: %w", id, err)
+		}
+		key.Public = pub
+	default:
+		return nil, fmt.Errorf("signing key %s: unexpected PEM block %q", id, block.Type)
+	}
+
+	switch pub := key.Public.(type) {
+	case ed25519.PublicKey:
+		key.Method = jwt.SigningMethodEdDSA
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < 2048 {
+			return nil, fmt.Errorf("signing key %s: RSA keys must be at least 2048 bits", id)
+		}
+		key.Method = jwt.SigningMethodRS256
+	default:
+		return nil, fmt.Errorf("signing key %s: unsupported key type %T", id, key.Public)
+	}
+	return key, nil
+}
+
+// GenerateSigningKey creates a key pair for alg (KeyAlgEdDSA or KeyAlgRS256)
+// and returns its ID and PKCS#8 PEM encoded private key. IDs start with the
+// creation time so that they sort by age.
+func GenerateSigningKey(alg string) (string, []byte, error) {
+	var priv crypto.Signer
+	var err error
+	switch alg {
+	case KeyAlgEdDSA:
+		_, priv, err = ed25519.GenerateKey(rand.Reader)
+	case KeyAlgRS256:
+		priv, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	default:
+		return "", nil, fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to encode key: %w", err)
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", nil, err
+	}
+	id := time.Now().UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix)
+
+	return id, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// PublicKeyPEM returns the PKIX PEM encoding of the key's public half.
+func (k *SigningKey) PublicKeyPEM() ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(k.Public)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+// ActiveKeyID is the ID of the key that signs new tokens.
+func (ks *KeySet) ActiveKeyID() string {
+	return ks.active.ID
+}
+
+func (ks *KeySet) verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, errors.New("token signed with an unknown key")
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, jwt.ErrSignatureInvalid
+	}
+	return key.Public, nil
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+// JWKS returns the public keys that tokens are verified with, sorted by ID.
+func (ks *KeySet) JWKS() []JWK {
+	jwks := make([]JWK, 0, len(ks.keys))
+	for _, k := range ks.keys {
+		jwk := JWK{KeyID: k.ID, Algorithm: k.Method.Alg(), Use: "sig"}
+		switch pub := k.Public.(type) {
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		}
+		jwks = append(jwks, jwk)
+	}
+	sort.Slice(jwks, func(i, j int) bool { return jwks[i].KeyID < jwks[j].KeyID })
+	return jwks
+}
+
+// PublishedKeys returns the JWKS of the configured key set, or no keys when
+// tokens are signed with the shared HMAC secret.
+func PublishedKeys() []JWK {
+	ks := signingKeys.Load()
+	if ks == nil {
+		return []JWK{}
+	}
+	return ks.JWKS()
+}
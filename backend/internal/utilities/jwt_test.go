@@ -1,20 +1,38 @@
 package utilities
 
 import (
-	"github.com/golang-jwt/jwt/v5"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/forfarm/backend/internal/config"
 )
 
 func TestJWTTokenCreationAndVerification(t *testing.T) {
 	testUUID := "123e4567-e89b-12d3-a456-426614174000"
 
-	token, err := CreateJwtToken(testUUID)
+	testSessionID := "0b6f3c4e-9d1a-4f5e-8c2b-7a1d2e3f4a5b"
+
+	token, err := CreateJwtToken(testUUID, testSessionID)
 	assert.NoError(t, err)
 	assert.NotEmpty(t, token)
 
+	claims, err := ParseJwtToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, testSessionID, claims.SessionID)
+	assert.False(t, claims.TwoFactor)
+
+	token2fa, err := IssueAccessToken(TokenClaims{UserID: testUUID, SessionID: testSessionID, Role: "admin", TwoFactor: true})
+	assert.NoError(t, err)
+	claims, err = ParseJwtToken(token2fa)
+	assert.NoError(t, err)
+	assert.Equal(t, TokenClaims{UserID: testUUID, SessionID: testSessionID, Role: "admin", TwoFactor: true}, claims)
+
 	err = VerifyJwtToken(token)
 	assert.NoError(t, err)
 
@@ -25,9 +43,9 @@ func TestJWTTokenCreationAndVerification(t *testing.T) {
 
 func TestExpiredJWTToken(t *testing.T) {
 
-	oldKey := defaultSecretKey
-	defaultSecretKey = []byte("test-secret-key-1234567890-1234567890")
-	defer func() { defaultSecretKey = oldKey }()
+	oldKey := config.JWT_SECRET_KEY
+	config.JWT_SECRET_KEY = "test-secret-key-1234567890-1234567890"
+	defer func() { config.JWT_SECRET_KEY = oldKey }()
 
 	testUUID := "123e4567-e89b-12d3-a456-426614174000"
 
@@ -35,10 +53,101 @@ func TestExpiredJWTToken(t *testing.T) {
 		"uuid": testUUID,
 		"exp":  time.Now().Add(-time.Hour).Unix(),
 	})
-	tokenString, err := token.SignedString(defaultSecretKey)
+	tokenString, err := token.SignedString([]byte(config.JWT_SECRET_KEY))
 	assert.NoError(t, err)
 
 	err = VerifyJwtToken(tokenString)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "token is expired")
 }
+
+func TestSigningKeyRotation(t *testing.T) {
+	dir := t.TempDir()
+	writeKey := func(alg string) *SigningKey {
+		id, privPEM, err := GenerateSigningKey(alg)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, id+".pem"), privPEM, 0o600))
+		key, err := ParseSigningKey(id, privPEM)
+		require.NoError(t, err)
+		return key
+	}
+
+	oldKey := writeKey(KeyAlgRS256)
+	time.Sleep(time.Second) // key IDs have second resolution
+	newKey := writeKey(KeyAlgEdDSA)
+
+	ks, err := LoadSigningKeys(dir, oldKey.ID)
+	require.NoError(t, err)
+	SetSigningKeys(ks)
+	defer SetSigningKeys(nil)
+
+	oldToken, err := CreateJwtToken("user", "session")
+	require.NoError(t, err)
+	parsed, _, err := jwt.NewParser().ParseUnverified(oldToken, jwt.MapClaims{})
+	require.NoError(t, err)
+	assert.Equal(t, oldKey.ID, parsed.Header["kid"])
+	assert.Equal(t, "RS256", parsed.Method.Alg())
+
+	// Without a pinned key the newest one signs, and the old key is retired.
+	pubPEM, err := oldKey.PublicKeyPEM()
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, oldKey.ID+".pem"), pubPEM, 0o600))
+	ks, err = LoadSigningKeys(dir, "")
+	require.NoError(t, err)
+	assert.Equal(t, newKey.ID, ks.ActiveKeyID())
+	SetSigningKeys(ks)
+
+	newToken, err := CreateJwtToken("user", "session")
+	require.NoError(t, err)
+	parsed, _, err = jwt.NewParser().ParseUnverified(newToken, jwt.MapClaims{})
+	require.NoError(t, err)
+	assert.Equal(t, newKey.ID, parsed.Header["kid"])
+	assert.Equal(t, "EdDSA", parsed.Method.Alg())
+
+	for _, token := range []string{oldToken, newToken} {
+		claims, err := ParseJwtToken(token)
+		require.NoError(t, err)
+		assert.Equal(t, "user", claims.UserID)
+	}
+
+	_, err = LoadSigningKeys(dir, oldKey.ID)
+	assert.Error(t, err, "a retired key cannot sign")
+
+	hmacToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"uuid": "user",
+		"exp":  time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte(config.JWT_SECRET_KEY))
+	require.NoError(t, err)
+	assert.Error(t, VerifyJwtToken(hmacToken), "shared-secret tokens are refused once keys are configured")
+
+	jwks := PublishedKeys()
+	require.Len(t, jwks, 2)
+	byID := map[string]JWK{jwks[0].KeyID: jwks[0], jwks[1].KeyID: jwks[1]}
+	assert.Equal(t, "RSA", byID[oldKey.ID].KeyType)
+	assert.Equal(t, "AQAB", byID[oldKey.ID].E)
+	assert.Equal(t, "OKP", byID[newKey.ID].KeyType)
+	assert.Equal(t, "Ed25519", byID[newKey.ID].Curve)
+}
+
+func TestActionToken(t *testing.T) {
+	secret := []byte("test-secret-key-1234567890-1234567890")
+
+	token := NewActionToken(secret, "reset", "user-1", "fp", time.Hour)
+	subject, fingerprint, err := ParseActionToken(secret, token, "reset")
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", subject)
+	assert.Equal(t, "fp", fingerprint)
+
+	_, _, err = ParseActionToken(secret, token, "verify")
+	assert.ErrorIs(t, err, ErrActionTokenInvalid, "purpose is checked")
+
+	_, _, err = ParseActionToken([]byte("another-secret-key-1234567890-123"), token, "reset")
+	assert.ErrorIs(t, err, ErrActionTokenInvalid, "signature is checked")
+
+	_, _, err = ParseActionToken(secret, token+"x", "reset")
+	assert.ErrorIs(t, err, ErrActionTokenInvalid)
+
+	expired := NewActionToken(secret, "reset", "user-1", "fp", -time.Minute)
+	_, _, err = ParseActionToken(secret, expired, "reset")
+	assert.ErrorIs(t, err, ErrActionTokenExpired)
+}
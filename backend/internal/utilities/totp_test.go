@@ -0,0 +1,48 @@
+package utilities
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTOTP(t *testing.T) {
+	// RFC 6238 appendix B test key, truncated to six digits.
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	for unix, want := range map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		2000000000: "279037",
+	} {
+		code, err := TOTPCode(secret, TOTPStep(time.Unix(unix, 0)))
+		require.NoError(t, err)
+		assert.Equal(t, want, code, "time %d", unix)
+	}
+
+	now := time.Unix(1111111109, 0)
+	step, ok := ValidateTOTP(secret, "081804", now.Add(totpPeriod))
+	assert.True(t, ok, "a code from the previous period is accepted")
+	assert.Equal(t, TOTPStep(now), step)
+	_, ok = ValidateTOTP(secret, "081804", now.Add(3*totpPeriod))
+	assert.False(t, ok)
+	_, ok = ValidateTOTP(secret, "12345", now)
+	assert.False(t, ok)
+
+	generated, err := NewTOTPSecret()
+	require.NoError(t, err)
+	assert.Contains(t, TOTPProvisioningURI(generated, "ForFarm", "a@b.c"), "otpauth://totp/ForFarm:a@b.c?")
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := NewRecoveryCodes(10)
+	require.NoError(t, err)
+	require.Len(t, codes, 10)
+	assert.Regexp(t, `^[0-9a-z]{4}-[0-9a-z]{4}-[0-9a-z]{4}$`, codes[0])
+	assert.NotEqual(t, codes[0], codes[1])
+
+	loose := " " + codes[0][:4] + " " + codes[0][5:9] + codes[0][10:] + " "
+	assert.Equal(t, HashRecoveryCode(codes[0]), HashRecoveryCode(loose))
+}
@@ -0,0 +1,165 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/forfarm/backend/internal/domain"
+	"github.com/forfarm/backend/internal/services"
+)
+
+// dataExportLease is how long a worker may take to build an export before
+// another one picks it up again.
+const dataExportLease = 10 * time.Minute
+
+// AccountJobs builds requested data exports, removes them once they expire,
+// and deletes accounts whose deletion grace period is over.
+type AccountJobs struct {
+	exportRepo     domain.DataExportRepository
+	userRepo       domain.UserRepository
+	accountService *services.AccountService
+	logger         *slog.Logger
+	pollInterval   time.Duration
+	exportTTL      time.Duration
+	now            func() time.Time
+	stopChan       chan struct{}
+	wg             sync.WaitGroup
+}
+
+func NewAccountJobs(
+	exportRepo domain.DataExportRepository,
+	userRepo domain.UserRepository,
+	accountService *services.AccountService,
+	logger *slog.Logger,
+	pollInterval time.Duration,
+	exportTTL time.Duration,
+) (*AccountJobs, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if pollInterval <= 0 {
+		pollInterval = time.Minute
+	}
+	if exportTTL <= 0 {
+		exportTTL = 7 * 24 * time.Hour
+	}
+	if exportRepo == nil {
+		return nil, fmt.Errorf("exportRepo cannot be nil")
+	}
+	if userRepo == nil {
+		return nil, fmt.Errorf("userRepo cannot be nil")
+	}
+	if accountService == nil {
+		return nil, fmt.Errorf("accountService cannot be nil")
+	}
+
+	return &AccountJobs{
+		exportRepo:     exportRepo,
+		userRepo:       userRepo,
+		accountService: accountService,
+		logger:         logger,
+		pollInterval:   pollInterval,
+		exportTTL:      exportTTL,
+		now:            time.Now,
+		stopChan:       make(chan struct{}),
+	}, nil
+}
+
+func (w *AccountJobs) Start(ctx context.Context) {
+	w.logger.Info("Starting Account Jobs worker", "interval", w.pollInterval)
+	ticker := time.NewTicker(w.pollInterval)
+
+	w.wg.Add(1)
+
+	go func() {
+		defer w.wg.Done()
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				w.runOnce(ctx)
+			case <-w.stopChan:
+				w.logger.Info("Account Jobs received stop signal, stopping...")
+				return
+			case <-ctx.Done():
+				w.logger.Info("Account Jobs context cancelled, stopping...", "reason", ctx.Err())
+				return
+			}
+		}
+	}()
+}
+
+func (w *AccountJobs) Stop() {
+	select {
+	case <-w.stopChan:
+	default:
+		close(w.stopChan)
+	}
+	w.wg.Wait()
+	w.logger.Info("Account Jobs worker stopped")
+}
+
+func (w *AccountJobs) runOnce(ctx context.Context) {
+	w.buildExports(ctx)
+
+	if n, err := w.exportRepo.DeleteExpired(ctx, w.now()); err != nil {
+		w.logger.Error("Failed to delete expired data exports", "error", err)
+	} else if n > 0 {
+		w.logger.Info("Deleted expired data exports", "count", n)
+	}
+
+	w.deleteDueAccounts(ctx)
+}
+
+// buildExports builds pending exports one at a time until none are left.
+func (w *AccountJobs) buildExports(ctx context.Context) {
+	for ctx.Err() == nil {
+		export, err := w.exportRepo.Claim(ctx, dataExportLease)
+		if errors.Is(err, domain.ErrNotFound) {
+			return
+		}
+		if err != nil {
+			w.logger.Error("Failed to claim data export", "error", err)
+			return
+		}
+
+		archive, err := w.accountService.Export(ctx, export.UserID)
+		if err != nil {
+			w.logger.Error("Failed to build data export", "export_id", export.UUID, "user_uuid", export.UserID, "error", err)
+			if err := w.exportRepo.Fail(ctx, export.UUID, "The export could not be built; request a new one"); err != nil {
+				w.logger.Error("Failed to mark data export failed", "export_id", export.UUID, "error", err)
+			}
+			continue
+		}
+		if err := w.exportRepo.Complete(ctx, export.UUID, archive, w.now().Add(w.exportTTL)); err != nil {
+			w.logger.Error("Failed to store data export", "export_id", export.UUID, "error", err)
+			continue
+		}
+		w.logger.Info("Data export ready", "export_id", export.UUID, "user_uuid", export.UserID, "size", len(archive))
+	}
+}
+
+func (w *AccountJobs) deleteDueAccounts(ctx context.Context) {
+	now := w.now()
+	users, err := w.userRepo.GetDueForDeletion(ctx, now)
+	if err != nil {
+		w.logger.Error("Failed to list accounts due for deletion", "error", err)
+		return
+	}
+	for _, user := range users {
+		if ctx.Err() != nil {
+			return
+		}
+		// The schedule is checked again under a row lock: the user may have
+		// cancelled since the listing, or another instance may be deleting
+		// them. A failed deletion is retried on the next run.
+		if _, err := w.accountService.DeleteIfDue(ctx, user.UUID, now); err != nil {
+			w.logger.Error("Failed to delete account", "user_uuid", user.UUID, "error", err)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/forfarm/backend/internal/domain"
+)
+
+const (
+	outboxBatchSize      = 100
+	outboxLease          = 30 * time.Second
+	outboxRetryBase      = 1 * time.Second
+	outboxRetryMax       = 5 * time.Minute
+	outboxCleanupEvery   = 1 * time.Hour
+	outboxDeliveredTTL   = 24 * time.Hour
+	outboxPublishTimeout = 5 * time.Second
+)
+
+// OutboxRelay publishes events written to the transactional outbox by the
+// repositories. Delivery is at-least-once: an event is only marked delivered
+// after the bus accepted it, and failed events are retried with backoff.
+type OutboxRelay struct {
+	outboxRepo     domain.OutboxRepository
+	eventPublisher domain.EventPublisher
+	logger         *slog.Logger
+	pollInterval   time.Duration
+	stopChan       chan struct{}
+	wg             sync.WaitGroup
+}
+
+func NewOutboxRelay(
+	outboxRepo domain.OutboxRepository,
+	eventPublisher domain.EventPublisher,
+	logger *slog.Logger,
+	pollInterval time.Duration,
+) (*OutboxRelay, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if pollInterval <= 0 {
+		pollInterval = time.Second
+	}
+	if outboxRepo == nil {
+		return nil, fmt.Errorf("outboxRepo cannot be nil")
+	}
+	if eventPublisher == nil {
+		return nil, fmt.Errorf("eventPublisher cannot be nil")
+	}
+
+	return &OutboxRelay{
+		outboxRepo:     outboxRepo,
+		eventPublisher: eventPublisher,
+		logger:         logger,
+		pollInterval:   pollInterval,
+		stopChan:       make(chan struct{}),
+	}, nil
+}
+
+func (r *OutboxRelay) Start(ctx context.Context) {
+	r.logger.Info("Starting Outbox Relay worker", "interval", r.pollInterval)
+	ticker := time.NewTicker(r.pollInterval)
+	cleanup := time.NewTicker(outboxCleanupEvery)
+
+	r.wg.Add(1)
+
+	go func() {
+		defer r.wg.Done()
+		defer ticker.Stop()
+		defer cleanup.Stop()
+
+		r.relayPending(ctx)
+
+		for {
+			select {
+			case <-ticker.C:
+				r.relayPending(ctx)
+			case <-cleanup.C:
+				r.deleteDelivered(ctx)
+			case <-r.stopChan:
+				r.logger.Info("Outbox Relay received stop signal, stopping...")
+				return
+			case <-ctx.Done():
+				r.logger.Info("Outbox Relay context cancelled, stopping...", "reason", ctx.Err())
+				return
+			}
+		}
+	}()
+}
+
+func (r *OutboxRelay) Stop() {
+	select {
+	case <-r.stopChan:
+	default:
+		close(r.stopChan)
+	}
+	r.wg.Wait()
+	r.logger.Info("Outbox Relay worker stopped")
+}
+
+// relayPending drains the outbox batch by batch until nothing is due.
+func (r *OutboxRelay) relayPending(ctx context.Context) {
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+		messages, err := r.outboxRepo.ClaimPending(ctx, outboxBatchSize, outboxLease)
+		if err != nil {
+			r.logger.Error("Failed to claim outbox events", "error", err)
+			return
+		}
+
+		for _, m := range messages {
+			r.relay(ctx, m)
+		}
+
+		if len(messages) < outboxBatchSize {
+			return
+		}
+	}
+}
+
+func (r *OutboxRelay) relay(ctx context.Context, m domain.OutboxMessage) {
+	pubCtx, cancel := context.WithTimeout(ctx, outboxPublishTimeout)
+	defer cancel()
+
+	err := r.eventPublisher.Publish(pubCtx, m.Event)
+	if errors.Is(err, domain.ErrEventUnroutable) {
+		// Nothing subscribes to this event type; retrying would not change
+		// that, and the event store already has it for replays.
+		r.logger.Warn("Outbox event has no subscribers", "event_id", m.Event.ID, "type", m.Event.Type)
+		err = nil
+	}
+	if err != nil {
+		next := time.Now().Add(outboxBackoff(m.Attempts))
+		r.logger.Error("Failed to publish outbox event", "event_id", m.Event.ID, "type", m.Event.Type, "attempts", m.Attempts, "retry_at", next, "error", err)
+		if err := r.outboxRepo.MarkFailed(ctx, m.Event.ID, err, next); err != nil {
+			r.logger.Error("Failed to record outbox publish failure", "event_id", m.Event.ID, "error", err)
+		}
+		return
+	}
+
+	if err := r.outboxRepo.MarkDelivered(ctx, m.Event.ID); err != nil {
+		// The lease expires and the event is published again; consumers must
+		// tolerate the duplicate.
+		r.logger.Error("Failed to mark outbox event delivered", "event_id", m.Event.ID, "error", err)
+		return
+	}
+	r.logger.Debug("Relayed outbox event", "event_id", m.Event.ID, "type", m.Event.Type)
+}
+
+func (r *OutboxRelay) deleteDelivered(ctx context.Context) {
+	deleted, err := r.outboxRepo.DeleteDelivered(ctx, time.Now().Add(-outboxDeliveredTTL))
+	if err != nil {
+		r.logger.Error("Failed to delete delivered outbox events", "error", err)
+		return
+	}
+	if deleted > 0 {
+		r.logger.Info("Deleted delivered outbox events", "count", deleted)
+	}
+}
+
+// outboxBackoff returns the delay before the next publish attempt, doubling
+// from outboxRetryBase up to outboxRetryMax.
+func outboxBackoff(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	delay := outboxRetryBase
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= outboxRetryMax {
+			return outboxRetryMax
+		}
+	}
+	return delay
+}
@@ -9,7 +9,6 @@ import (
 	"time"
 
 	"github.com/forfarm/backend/internal/domain"
-	"github.com/google/uuid"
 )
 
 type WeatherUpdater struct {
@@ -155,27 +154,15 @@ func (w *WeatherUpdater) fetchAndPublishWeather(ctx context.Context, farm domain
 		return
 	}
 
-	payloadMap := map[string]interface{}{
-		"farm_id":            farm.UUID,
-		"lat":                farm.Lat,
-		"lon":                farm.Lon,
-		"tempCelsius":        weatherData.TempCelsius,
-		"humidity":           weatherData.Humidity,
-		"description":        weatherData.Description,
-		"icon":               weatherData.Icon,
-		"windSpeed":          weatherData.WindSpeed,
-		"rainVolume1h":       weatherData.RainVolume1h,
-		"observedAt":         weatherData.ObservedAt,
-		"weatherLastUpdated": weatherData.WeatherLastUpdated,
-	}
-
-	event := domain.Event{
-		ID:          uuid.NewString(),
-		Type:        "weather.updated",
-		Source:      "weather-updater-worker",
-		Timestamp:   time.Now().UTC(),
-		AggregateID: farm.UUID,
-		Payload:     payloadMap,
+	event, err := domain.NewEvent("weather.updated", "weather-updater-worker", farm.UUID, &domain.WeatherUpdatedPayload{
+		FarmID:      farm.UUID,
+		Lat:         farm.Lat,
+		Lon:         farm.Lon,
+		WeatherData: *weatherData,
+	})
+	if err != nil {
+		w.logger.Error("Failed to build weather.updated event", "farm_id", farm.UUID, "error", err)
+		return
 	}
 
 	pubCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
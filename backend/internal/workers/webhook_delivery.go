@@ -0,0 +1,310 @@
+package workers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/forfarm/backend/internal/domain"
+)
+
+const (
+	webhookBatchSize      = 50
+	webhookConcurrency    = 8
+	webhookTimeout        = 10 * time.Second
+	webhookLease          = 30 * time.Second
+	webhookRetryBase      = 10 * time.Second
+	webhookRetryMax       = 1 * time.Hour
+	webhookMaxAttempts    = 8
+	webhookDisableAfter   = 20
+	webhookErrorBodyLimit = 512
+
+	WebhookSignatureHeader = "X-ForFarm-Signature"
+	WebhookTimestampHeader = "X-ForFarm-Timestamp"
+	WebhookEventHeader     = "X-ForFarm-Event"
+	WebhookDeliveryHeader  = "X-ForFarm-Delivery"
+)
+
+var errPrivateAddress = errors.New("webhook address is not publicly routable")
+
+var (
+	// cgnatPrefix is the shared address space carriers use behind NAT.
+	cgnatPrefix = netip.MustParsePrefix("100.64.0.0/10")
+	// nat64Prefix embeds an IPv4 address in its last 32 bits, which a NAT64
+	// gateway connects to.
+	nat64Prefix = netip.MustParsePrefix("64:ff9b::/96")
+	// localNAT64Prefix is for NAT64 inside a private network.
+	localNAT64Prefix = netip.MustParsePrefix("64:ff9b:1::/48")
+)
+
+// WebhookDelivery POSTs queued events to webhook endpoints. Each request is
+// signed with the webhook's secret; failed deliveries are retried with
+// exponential backoff, and a webhook that keeps failing is disabled.
+type WebhookDelivery struct {
+	webhookRepo  domain.WebhookRepository
+	client       *http.Client
+	logger       *slog.Logger
+	pollInterval time.Duration
+	stopChan     chan struct{}
+	wg           sync.WaitGroup
+}
+
+// NewWebhookDelivery creates the delivery worker. Unless allowPrivate is set,
+// endpoints resolving to loopback, private, link-local or carrier-grade NAT
+// addresses are refused, so webhooks cannot be used to reach internal
+// services.
+func NewWebhookDelivery(
+	webhookRepo domain.WebhookRepository,
+	logger *slog.Logger,
+	pollInterval time.Duration,
+	allowPrivate bool,
+) (*WebhookDelivery, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if pollInterval <= 0 {
+		pollInterval = 2 * time.Second
+	}
+	if webhookRepo == nil {
+		return nil, fmt.Errorf("webhookRepo cannot be nil")
+	}
+
+	dialer := &net.Dialer{Timeout: webhookTimeout}
+	if !allowPrivate {
+		dialer.Control = refusePrivateAddress
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &WebhookDelivery{
+		webhookRepo: webhookRepo,
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   webhookTimeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		logger:       logger,
+		pollInterval: pollInterval,
+		stopChan:     make(chan struct{}),
+	}, nil
+}
+
+func (w *WebhookDelivery) Start(ctx context.Context) {
+	w.logger.Info("Starting Webhook Delivery worker", "interval", w.pollInterval)
+	ticker := time.NewTicker(w.pollInterval)
+
+	w.wg.Add(1)
+
+	go func() {
+		defer w.wg.Done()
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				w.deliverDue(ctx)
+			case <-w.stopChan:
+				w.logger.Info("Webhook Delivery received stop signal, stopping...")
+				return
+			case <-ctx.Done():
+				w.logger.Info("Webhook Delivery context cancelled, stopping...", "reason", ctx.Err())
+				return
+			}
+		}
+	}()
+}
+
+func (w *WebhookDelivery) Stop() {
+	select {
+	case <-w.stopChan:
+	default:
+		close(w.stopChan)
+	}
+	w.wg.Wait()
+	w.logger.Info("Webhook Delivery worker stopped")
+}
+
+// deliverDue sends due deliveries batch by batch until nothing is due.
+func (w *WebhookDelivery) deliverDue(ctx context.Context) {
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+		deliveries, err := w.webhookRepo.ClaimDueDeliveries(ctx, webhookBatchSize, webhookLease)
+		if err != nil {
+			w.logger.Error("Failed to claim webhook deliveries", "error", err)
+			return
+		}
+
+		// Webhooks are loaded once per batch; most deliveries share a few.
+		webhooks := make(map[string]*domain.Webhook)
+		for _, d := range deliveries {
+			if _, ok := webhooks[d.WebhookID]; ok {
+				continue
+			}
+			webhook, err := w.webhookRepo.GetByID(ctx, d.WebhookID)
+			if err != nil && !errors.Is(err, domain.ErrNotFound) {
+				w.logger.Error("Failed to load webhook", "webhook_id", d.WebhookID, "error", err)
+			}
+			webhooks[d.WebhookID] = webhook
+		}
+
+		sem := make(chan struct{}, webhookConcurrency)
+		var wg sync.WaitGroup
+		for _, d := range deliveries {
+			webhook := webhooks[d.WebhookID]
+			if webhook == nil {
+				// Deleted, or not loadable; the lease runs out and it is
+				// claimed again if it still exists.
+				continue
+			}
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(d domain.WebhookDelivery) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				w.deliver(ctx, webhook, d)
+			}(d)
+		}
+		wg.Wait()
+
+		if len(deliveries) < webhookBatchSize {
+			return
+		}
+	}
+}
+
+func (w *WebhookDelivery) deliver(ctx context.Context, webhook *domain.Webhook, d domain.WebhookDelivery) {
+	statusCode, err := w.post(ctx, webhook, d)
+	if err == nil {
+		if err := w.webhookRepo.MarkDelivered(ctx, d.ID, statusCode); err != nil {
+			w.logger.Error("Failed to mark webhook delivery delivered", "delivery_id", d.ID, "error", err)
+			return
+		}
+		w.logger.Debug("Delivered webhook", "webhook_id", webhook.UUID, "delivery_id", d.ID, "event_type", d.EventType, "status", statusCode)
+		return
+	}
+
+	var code *int
+	if statusCode != 0 {
+		code = &statusCode
+	}
+	var next *time.Time
+	if d.Attempts < webhookMaxAttempts {
+		t := time.Now().Add(webhookBackoff(d.Attempts))
+		next = &t
+	}
+	w.logger.Warn("Webhook delivery failed", "webhook_id", webhook.UUID, "delivery_id", d.ID, "attempts", d.Attempts, "retry_at", next, "error", err)
+
+	disabled, err := w.webhookRepo.MarkFailed(ctx, d.ID, code, err.Error(), next, webhookDisableAfter)
+	if err != nil {
+		w.logger.Error("Failed to record webhook delivery failure", "delivery_id", d.ID, "error", err)
+		return
+	}
+	if disabled {
+		w.logger.Warn("Disabled webhook after repeated failures", "webhook_id", webhook.UUID, "failures", webhookDisableAfter)
+	}
+}
+
+// post sends one delivery and returns the response status code, which is 0
+// when no response was received.
+func (w *WebhookDelivery) post(ctx context.Context, webhook *domain.Webhook, d domain.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "ForFarm-Webhooks/1.0")
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(webhook.Secret, timestamp, d.Payload))
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	req.Header.Set(WebhookEventHeader, d.EventType)
+	req.Header.Set(WebhookDeliveryHeader, d.EventID)
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, webhookErrorBodyLimit))
+		return resp.StatusCode, fmt.Errorf("endpoint responded %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, webhookErrorBodyLimit))
+	return resp.StatusCode, nil
+}
+
+// SignWebhook returns the signature header value for a webhook body: the
+// hex HMAC-SHA256 of "<timestamp>.<body>" keyed with the webhook's secret.
+// Receivers recompute it to check the request came from us and was not
+// replayed with a different timestamp.
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookBackoff returns the delay before the next delivery attempt,
+// doubling from webhookRetryBase up to webhookRetryMax.
+func webhookBackoff(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	delay := webhookRetryBase
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= webhookRetryMax {
+			return webhookRetryMax
+		}
+	}
+	return delay
+}
+
+// refusePrivateAddress is a dialer control that rejects connections to
+// addresses that are not publicly routable. It runs after DNS resolution,
+// so a hostname cannot be pointed at an internal address to get around it.
+func refusePrivateAddress(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil || !isPublicAddress(ip) {
+		return fmt.Errorf("%w: %s", errPrivateAddress, host)
+	}
+	return nil
+}
+
+// isPublicAddress reports whether ip is publicly routable. IPv4 addresses in
+// IPv4-mapped and NAT64 form are judged by the IPv4 address they carry.
+func isPublicAddress(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if nat64Prefix.Contains(ip) {
+		b := ip.As16()
+		ip = netip.AddrFrom4([4]byte(b[12:]))
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	return !cgnatPrefix.Contains(ip) && !localNAT64Prefix.Contains(ip)
+}
@@ -0,0 +1,155 @@
+package workers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/forfarm/backend/internal/domain"
+)
+
+func TestSignWebhook(t *testing.T) {
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		body      string
+		want      string
+	}{
+		{"event body", "whsec_test", "1700000000", `{"id":"evt-1"}`, "sha256=5056f09710e0bebdbcd623bb1a7714db4eac94f18745b31b96dd55a69f444e14"},
+		{"empty secret and body", "", "0", "", "sha256=b849d5a581847b281957065739df36df2463d1977ea8d6e1e4e6cf33fadc68c3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, SignWebhook(tt.secret, tt.timestamp, []byte(tt.body)))
+		})
+	}
+
+	assert.NotEqual(t,
+		SignWebhook("whsec_test", "1700000000", []byte(`{"id":"evt-1"}`)),
+		SignWebhook("whsec_test", "1700000001", []byte(`{"id":"evt-1"}`)),
+		"the timestamp is signed")
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, webhookRetryBase},
+		{1, webhookRetryBase},
+		{2, 2 * webhookRetryBase},
+		{3, 4 * webhookRetryBase},
+		{9, 256 * webhookRetryBase},
+		{10, webhookRetryMax},
+		{100, webhookRetryMax},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, webhookBackoff(tt.attempts), "attempts=%d", tt.attempts)
+	}
+}
+
+func TestRefusePrivateAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		refused bool
+	}{
+		{"93.184.216.34:443", false},
+		{"[2606:2800:220:1:248:1893:25c8:1946]:443", false},
+		{"[64:ff9b::5db8:d822]:443", false}, // NAT64 form of 93.184.216.34
+		{"127.0.0.1:80", true},
+		{"[::1]:80", true},
+		{"10.1.2.3:80", true},
+		{"172.16.0.1:80", true},
+		{"192.168.1.1:80", true},
+		{"[fd00::1]:80", true},
+		{"169.254.169.254:80", true},
+		{"[fe80::1%eth0]:80", true},
+		{"0.0.0.0:80", true},
+		{"[::]:80", true},
+		{"224.0.0.1:80", true},
+		{"100.64.0.1:80", true},
+		{"100.127.255.254:80", true},
+		{"[::ffff:127.0.0.1]:80", true},
+		{"[::ffff:10.0.0.1]:80", true},
+		{"[::ffff:169.254.169.254]:80", true},
+		{"[64:ff9b::7f00:1]:80", true},  // NAT64 form of 127.0.0.1
+		{"[64:ff9b::a00:1]:80", true},   // NAT64 form of 10.0.0.1
+		{"[64:ff9b::6440:1]:80", true},  // NAT64 form of 100.64.0.1
+		{"[64:ff9b:1::a00:1]:80", true}, // local-use NAT64
+		{"example.com:80", true},
+	}
+	for _, tt := range tests {
+		err := refusePrivateAddress("tcp", tt.address, nil)
+		if tt.refused {
+			assert.ErrorIs(t, err, errPrivateAddress, tt.address)
+		} else {
+			assert.NoError(t, err, tt.address)
+		}
+	}
+}
+
+type markFailedCall struct {
+	deliveryID    int64
+	statusCode    *int
+	nextAttemptAt *time.Time
+	disableAfter  int
+}
+
+type recordingWebhooks struct {
+	domain.WebhookRepository
+	disable bool
+	failed  []markFailedCall
+}
+
+func (r *recordingWebhooks) MarkFailed(ctx context.Context, deliveryID int64, statusCode *int, cause string, nextAttemptAt *time.Time, disableAfter int) (bool, error) {
+	r.failed = append(r.failed, markFailedCall{deliveryID, statusCode, nextAttemptAt, disableAfter})
+	return r.disable, nil
+}
+
+func TestWebhookDelivery_MarkFailed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		attempts int
+		disable  bool
+		retry    bool
+	}{
+		{"first failure is retried", 1, false, true},
+		{"last attempt gives up", webhookMaxAttempts, false, false},
+		{"failure that disables the webhook", 3, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &recordingWebhooks{disable: tt.disable}
+			worker, err := NewWebhookDelivery(repo, nil, time.Second, true)
+			require.NoError(t, err)
+
+			before := time.Now()
+			worker.deliver(context.Background(), &domain.Webhook{UUID: "webhook-1", URL: server.URL, Secret: "secret"},
+				domain.WebhookDelivery{ID: 7, EventID: "evt-1", EventType: "farm.updated", Payload: []byte(`{}`), Attempts: tt.attempts})
+
+			require.Len(t, repo.failed, 1)
+			call := repo.failed[0]
+			assert.Equal(t, int64(7), call.deliveryID)
+			require.NotNil(t, call.statusCode)
+			assert.Equal(t, http.StatusInternalServerError, *call.statusCode)
+			assert.Equal(t, webhookDisableAfter, call.disableAfter)
+			if tt.retry {
+				require.NotNil(t, call.nextAttemptAt)
+				assert.WithinDuration(t, before.Add(webhookBackoff(tt.attempts)), *call.nextAttemptAt, time.Second)
+			} else {
+				assert.Nil(t, call.nextAttemptAt)
+			}
+		})
+	}
+}
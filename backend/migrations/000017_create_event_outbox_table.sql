@@ -0,0 +1,22 @@
+-- +goose Up
+-- Domain events written in the same transaction as the row change they describe.
+-- A relay worker publishes pending rows to the event bus and marks them delivered.
+CREATE TABLE public.event_outbox (
+    id UUID PRIMARY KEY,
+    event_type TEXT NOT NULL,
+    source TEXT NOT NULL,
+    aggregate_id TEXT NOT NULL,
+    payload JSONB,
+    occurred_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    delivered_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_event_outbox_pending ON public.event_outbox(next_attempt_at) WHERE delivered_at IS NULL;
+CREATE INDEX idx_event_outbox_delivered_at ON public.event_outbox(delivered_at) WHERE delivered_at IS NOT NULL;
+
+-- +goose Down
+DROP TABLE IF EXISTS public.event_outbox;
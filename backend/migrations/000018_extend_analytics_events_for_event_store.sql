@@ -0,0 +1,28 @@
+-- +goose Up
+-- Turn analytics_events into an append-only store of every published domain event,
+-- so projections such as farm_analytics can be rebuilt by replaying it.
+-- History must outlive the farm it belongs to, so farm_id loses its foreign key.
+ALTER TABLE public.analytics_events DROP CONSTRAINT IF EXISTS fk_analytics_farm;
+ALTER TABLE public.analytics_events ALTER COLUMN farm_id DROP NOT NULL;
+
+ALTER TABLE public.analytics_events
+    ADD COLUMN event_id UUID,
+    ADD COLUMN source TEXT,
+    ADD COLUMN aggregate_id TEXT,
+    ADD COLUMN occurred_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+CREATE UNIQUE INDEX idx_analytics_events_event_id ON public.analytics_events(event_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS public.idx_analytics_events_event_id;
+
+ALTER TABLE public.analytics_events
+    DROP COLUMN IF EXISTS occurred_at,
+    DROP COLUMN IF EXISTS aggregate_id,
+    DROP COLUMN IF EXISTS source,
+    DROP COLUMN IF EXISTS event_id;
+
+DELETE FROM public.analytics_events WHERE farm_id IS NULL OR farm_id NOT IN (SELECT uuid FROM public.farms);
+ALTER TABLE public.analytics_events ALTER COLUMN farm_id SET NOT NULL;
+ALTER TABLE public.analytics_events
+    ADD CONSTRAINT fk_analytics_farm FOREIGN KEY (farm_id) REFERENCES public.farms(uuid) ON DELETE CASCADE;
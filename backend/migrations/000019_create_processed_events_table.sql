@@ -0,0 +1,24 @@
+-- +goose Up
+-- Events each consumer has already applied, so redeliveries are skipped.
+CREATE TABLE public.processed_events (
+    consumer TEXT NOT NULL,
+    event_id UUID NOT NULL,
+    processed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (consumer, event_id)
+);
+
+CREATE INDEX idx_processed_events_processed_at ON public.processed_events(processed_at);
+
+-- Timestamp of the newest event each consumer applied per aggregate and stream
+-- (e.g. "farm", "weather"), so an older event arriving late is not applied over newer data.
+CREATE TABLE public.consumer_aggregate_positions (
+    consumer TEXT NOT NULL,
+    aggregate_id TEXT NOT NULL,
+    stream TEXT NOT NULL,
+    last_event_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (consumer, aggregate_id, stream)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS public.consumer_aggregate_positions;
+DROP TABLE IF EXISTS public.processed_events;
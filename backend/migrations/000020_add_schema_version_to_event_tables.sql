@@ -0,0 +1,9 @@
+-- +goose Up
+-- Payload schema version of each stored event. Rows written before payloads
+-- were versioned are version 1.
+ALTER TABLE public.event_outbox ADD COLUMN schema_version INT NOT NULL DEFAULT 1;
+ALTER TABLE public.analytics_events ADD COLUMN schema_version INT NOT NULL DEFAULT 1;
+
+-- +goose Down
+ALTER TABLE public.analytics_events DROP COLUMN IF EXISTS schema_version;
+ALTER TABLE public.event_outbox DROP COLUMN IF EXISTS schema_version;
@@ -0,0 +1,38 @@
+-- +goose Up
+-- Event log of the Postgres event bus (EVENT_BUS_DRIVER=postgres). Rows with a
+-- target_consumer are dead-lettered events replayed to that consumer only.
+CREATE TABLE public.event_log (
+    position BIGSERIAL PRIMARY KEY,
+    event_id UUID NOT NULL,
+    routing_key TEXT NOT NULL,
+    body JSONB NOT NULL,
+    target_consumer TEXT,
+    published_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_event_log_published_at ON public.event_log(published_at);
+
+-- Last event log position each durable consumer has handled.
+CREATE TABLE public.event_consumer_offsets (
+    consumer TEXT PRIMARY KEY,
+    position BIGINT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE public.event_dead_letters (
+    id BIGSERIAL PRIMARY KEY,
+    consumer TEXT NOT NULL,
+    event_id UUID NOT NULL,
+    routing_key TEXT NOT NULL,
+    body JSONB NOT NULL,
+    retries INT NOT NULL,
+    last_error TEXT NOT NULL,
+    dead_lettered_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_event_dead_letters_consumer ON public.event_dead_letters(consumer, id);
+
+-- +goose Down
+DROP TABLE IF EXISTS public.event_dead_letters;
+DROP TABLE IF EXISTS public.event_consumer_offsets;
+DROP TABLE IF EXISTS public.event_log;
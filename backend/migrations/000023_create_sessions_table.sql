@@ -0,0 +1,22 @@
+-- +goose Up
+-- Login sessions. Each holds the hash of its current refresh token; the hash
+-- of the token it replaced is kept so that reuse of a rotated token can be
+-- detected and the session revoked.
+CREATE TABLE public.sessions (
+    uuid UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(uuid) ON DELETE CASCADE,
+    refresh_token_hash TEXT NOT NULL,
+    previous_token_hash TEXT,
+    user_agent TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX idx_sessions_refresh_token_hash ON public.sessions(refresh_token_hash);
+CREATE INDEX idx_sessions_previous_token_hash ON public.sessions(previous_token_hash) WHERE previous_token_hash IS NOT NULL;
+CREATE INDEX idx_sessions_user_id ON public.sessions(user_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS public.sessions;
@@ -0,0 +1,30 @@
+-- +goose Up
+-- Users other than the owner with access to a farm. The owner stays in
+-- farms.owner_id and has no row here.
+CREATE TABLE public.farm_members (
+    farm_id UUID NOT NULL REFERENCES farms(uuid) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(uuid) ON DELETE CASCADE,
+    role TEXT NOT NULL CHECK (role IN ('manager', 'worker', 'viewer')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (farm_id, user_id)
+);
+
+CREATE INDEX idx_farm_members_user_id ON public.farm_members(user_id);
+
+CREATE TABLE public.farm_invitations (
+    uuid UUID PRIMARY KEY,
+    farm_id UUID NOT NULL REFERENCES farms(uuid) ON DELETE CASCADE,
+    email TEXT NOT NULL,
+    role TEXT NOT NULL CHECK (role IN ('manager', 'worker', 'viewer')),
+    invited_by UUID NOT NULL REFERENCES users(uuid) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    accepted_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_farm_invitations_farm_id ON public.farm_invitations(farm_id);
+CREATE INDEX idx_farm_invitations_email ON public.farm_invitations(LOWER(email)) WHERE accepted_at IS NULL;
+
+-- +goose Down
+DROP TABLE IF EXISTS public.farm_invitations;
+DROP TABLE IF EXISTS public.farm_members;
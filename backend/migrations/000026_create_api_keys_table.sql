@@ -0,0 +1,22 @@
+-- +goose Up
+-- Personal API keys for machine clients. Only a hash of each key is stored;
+-- prefix is the start of the key, kept so users can tell their keys apart.
+CREATE TABLE public.api_keys (
+    uuid UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(uuid) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL,
+    key_hash TEXT NOT NULL,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    farm_id UUID REFERENCES farms(uuid) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX idx_api_keys_key_hash ON public.api_keys(key_hash);
+CREATE INDEX idx_api_keys_user_id ON public.api_keys(user_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS public.api_keys;
@@ -0,0 +1,29 @@
+-- +goose Up
+-- TOTP two-factor authentication. A row is created when a user starts
+-- enrolling and enabled_at is set once they confirm a code. last_used_step is
+-- the most recent time step a code was accepted for, so a code cannot be
+-- replayed.
+CREATE TABLE public.user_two_factor (
+    user_id UUID PRIMARY KEY REFERENCES users(uuid) ON DELETE CASCADE,
+    secret TEXT NOT NULL,
+    enabled_at TIMESTAMPTZ,
+    last_used_step BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Single-use codes that stand in for a TOTP code when the device is lost.
+CREATE TABLE public.two_factor_recovery_codes (
+    user_id UUID NOT NULL REFERENCES users(uuid) ON DELETE CASCADE,
+    code_hash TEXT NOT NULL,
+    used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, code_hash)
+);
+
+-- Whether a session was opened with a second factor; its access tokens say so.
+ALTER TABLE public.sessions ADD COLUMN two_factor BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+ALTER TABLE public.sessions DROP COLUMN IF EXISTS two_factor;
+DROP TABLE IF EXISTS public.two_factor_recovery_codes;
+DROP TABLE IF EXISTS public.user_two_factor;
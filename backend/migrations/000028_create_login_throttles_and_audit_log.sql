@@ -0,0 +1,26 @@
+-- +goose Up
+-- Failed sign-in counters, keyed by 'account:<email>' or 'ip:<address>'.
+CREATE TABLE public.login_throttles (
+    key TEXT PRIMARY KEY,
+    failures INT NOT NULL DEFAULT 0,
+    last_failed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    locked_until TIMESTAMPTZ
+);
+
+-- Security-relevant actions such as lockouts.
+CREATE TABLE public.audit_log (
+    uuid UUID PRIMARY KEY,
+    action TEXT NOT NULL,
+    user_id UUID REFERENCES users(uuid) ON DELETE SET NULL,
+    actor_id UUID REFERENCES users(uuid) ON DELETE SET NULL,
+    ip_address TEXT NOT NULL DEFAULT '',
+    details JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_audit_log_user_id ON public.audit_log(user_id, created_at DESC);
+CREATE INDEX idx_audit_log_created_at ON public.audit_log(created_at DESC);
+
+-- +goose Down
+DROP TABLE IF EXISTS public.audit_log;
+DROP TABLE IF EXISTS public.login_throttles;
@@ -0,0 +1,24 @@
+-- +goose Up
+-- Accounts at OpenID Connect providers that users sign in with. A provider
+-- account belongs to at most one user, and a user has at most one account per
+-- provider.
+CREATE TABLE public.user_identities (
+    uuid UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(uuid) ON DELETE CASCADE,
+    provider TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    email TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMPTZ,
+    UNIQUE (provider, subject),
+    UNIQUE (user_id, provider)
+);
+
+-- Users created by a provider sign-in get a random password they never saw.
+-- Earlier accounts cannot be told apart and keep counting as having one; the
+-- password reset flow works for them either way.
+ALTER TABLE users ADD COLUMN password_set BOOLEAN NOT NULL DEFAULT TRUE;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN IF EXISTS password_set;
+DROP TABLE IF EXISTS public.user_identities;
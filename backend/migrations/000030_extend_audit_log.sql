@@ -0,0 +1,52 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE public.audit_log
+    ADD COLUMN api_key_id UUID,
+    ADD COLUMN resource_type TEXT NOT NULL DEFAULT '',
+    ADD COLUMN resource_id TEXT NOT NULL DEFAULT '',
+    ADD COLUMN farm_id UUID,
+    ADD COLUMN user_agent TEXT NOT NULL DEFAULT '',
+    ADD COLUMN changes JSONB;
+
+-- farm_id has no foreign key so that a farm's history outlives it.
+CREATE INDEX idx_audit_log_farm_id ON public.audit_log(farm_id, created_at DESC) WHERE farm_id IS NOT NULL;
+CREATE INDEX idx_audit_log_actor_id ON public.audit_log(actor_id, created_at DESC);
+
+-- The log is append-only. The one change allowed is clearing user_id and
+-- actor_id, which the foreign keys do when an account is deleted.
+CREATE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'UPDATE'
+        AND (NEW.user_id IS NULL OR NEW.user_id = OLD.user_id)
+        AND (NEW.actor_id IS NULL OR NEW.actor_id = OLD.actor_id)
+        AND (NEW.uuid, NEW.action, NEW.api_key_id, NEW.resource_type, NEW.resource_id, NEW.farm_id,
+             NEW.ip_address, NEW.user_agent, NEW.changes, NEW.details, NEW.created_at)
+            IS NOT DISTINCT FROM
+            (OLD.uuid, OLD.action, OLD.api_key_id, OLD.resource_type, OLD.resource_id, OLD.farm_id,
+             OLD.ip_address, OLD.user_agent, OLD.changes, OLD.details, OLD.created_at)
+    THEN
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_append_only
+    BEFORE UPDATE OR DELETE ON public.audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS audit_log_append_only ON public.audit_log;
+DROP FUNCTION IF EXISTS audit_log_append_only();
+DROP INDEX IF EXISTS idx_audit_log_actor_id;
+DROP INDEX IF EXISTS idx_audit_log_farm_id;
+ALTER TABLE public.audit_log
+    DROP COLUMN IF EXISTS changes,
+    DROP COLUMN IF EXISTS user_agent,
+    DROP COLUMN IF EXISTS farm_id,
+    DROP COLUMN IF EXISTS resource_id,
+    DROP COLUMN IF EXISTS resource_type,
+    DROP COLUMN IF EXISTS api_key_id;
+-- +goose StatementEnd
@@ -0,0 +1,67 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE public.data_exports (
+    uuid UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(uuid) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'ready', 'failed')),
+    archive BYTEA,
+    size BIGINT NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    lease_expires_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_data_exports_user_id ON public.data_exports(user_id, created_at DESC);
+CREATE INDEX idx_data_exports_pending ON public.data_exports(created_at) WHERE status IN ('pending', 'running');
+
+ALTER TABLE public.users ADD COLUMN deletion_scheduled_at TIMESTAMPTZ;
+CREATE INDEX idx_users_deletion_scheduled_at ON public.users(deletion_scheduled_at) WHERE deletion_scheduled_at IS NOT NULL;
+
+-- Deleting an account also scrubs what the audit log says about it, so the
+-- log accepts clearing the fields that identify someone as well.
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'UPDATE'
+        AND (NEW.user_id IS NULL OR NEW.user_id = OLD.user_id)
+        AND (NEW.actor_id IS NULL OR NEW.actor_id = OLD.actor_id)
+        AND (NEW.api_key_id IS NULL OR NEW.api_key_id = OLD.api_key_id)
+        AND (NEW.ip_address = '' OR NEW.ip_address = OLD.ip_address)
+        AND (NEW.user_agent = '' OR NEW.user_agent = OLD.user_agent)
+        AND (NEW.changes IS NULL OR NEW.changes = OLD.changes)
+        AND (NEW.details = '{}'::jsonb OR NEW.details = OLD.details)
+        AND (NEW.uuid, NEW.action, NEW.resource_type, NEW.resource_id, NEW.farm_id, NEW.created_at)
+            IS NOT DISTINCT FROM
+            (OLD.uuid, OLD.action, OLD.resource_type, OLD.resource_id, OLD.farm_id, OLD.created_at)
+    THEN
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'UPDATE'
+        AND (NEW.user_id IS NULL OR NEW.user_id = OLD.user_id)
+        AND (NEW.actor_id IS NULL OR NEW.actor_id = OLD.actor_id)
+        AND (NEW.uuid, NEW.action, NEW.api_key_id, NEW.resource_type, NEW.resource_id, NEW.farm_id,
+             NEW.ip_address, NEW.user_agent, NEW.changes, NEW.details, NEW.created_at)
+            IS NOT DISTINCT FROM
+            (OLD.uuid, OLD.action, OLD.api_key_id, OLD.resource_type, OLD.resource_id, OLD.farm_id,
+             OLD.ip_address, OLD.user_agent, OLD.changes, OLD.details, OLD.created_at)
+    THEN
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP INDEX IF EXISTS idx_users_deletion_scheduled_at;
+ALTER TABLE public.users DROP COLUMN IF EXISTS deletion_scheduled_at;
+DROP TABLE IF EXISTS public.data_exports;
+-- +goose StatementEnd
@@ -0,0 +1,151 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/pressly/goose/v3"
+
+	"github.com/forfarm/backend/internal/domain"
+)
+
+// Croplands stored their location as {"type": "marker", "position": {...}}
+// or {"type": "polygon", "path": [...]} before it became GeoJSON. This
+// migration is written in Go to share the conversion and the area
+// computation with the API.
+func init() {
+	goose.AddMigrationContext(upConvertCroplandGeoFeatures, downConvertCroplandGeoFeatures)
+}
+
+type croplandGeoFeature struct {
+	uuid       string
+	geoFeature []byte
+}
+
+func selectCroplandGeoFeatures(ctx context.Context, tx *sql.Tx) ([]croplandGeoFeature, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT uuid, geo_feature FROM croplands WHERE geo_feature IS NOT NULL FOR UPDATE`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var croplands []croplandGeoFeature
+	for rows.Next() {
+		var c croplandGeoFeature
+		if err := rows.Scan(&c.uuid, &c.geoFeature); err != nil {
+			return nil, err
+		}
+		croplands = append(croplands, c)
+	}
+	return croplands, rows.Err()
+}
+
+// upConvertCroplandGeoFeatures rewrites legacy locations as GeoJSON and sets
+// the land size of every boundary to the area it encloses. A legacy location
+// that does not convert to a valid geometry is left as it was and logged, so
+// that it can be redrawn; blobs in neither format are left alone.
+func upConvertCroplandGeoFeatures(ctx context.Context, tx *sql.Tx) error {
+	croplands, err := selectCroplandGeoFeatures(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range croplands {
+		g, err := domain.ParseGeometry(c.geoFeature)
+		if err != nil {
+			var ok bool
+			if g, ok = domain.ConvertLegacyGeoFeature(c.geoFeature); !ok {
+				continue
+			}
+			if err := g.Validate(); err != nil {
+				slog.Warn("Leaving cropland location unconverted; it is not a valid geometry", "cropland_uuid", c.uuid, "error", err)
+				continue
+			}
+		}
+
+		data, err := json.Marshal(g)
+		if err != nil {
+			return err
+		}
+		if g.Type != domain.GeometryPoint {
+			_, err = tx.ExecContext(ctx, `UPDATE croplands SET geo_feature = $2, land_size = $3 WHERE uuid = $1`, c.uuid, string(data), g.Hectares())
+		} else {
+			_, err = tx.ExecContext(ctx, `UPDATE croplands SET geo_feature = $2 WHERE uuid = $1`, c.uuid, string(data))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// downConvertCroplandGeoFeatures turns Points back into markers and
+// boundaries into polygon paths. Only the outer ring of the first polygon
+// survives.
+func downConvertCroplandGeoFeatures(ctx context.Context, tx *sql.Tx) error {
+	croplands, err := selectCroplandGeoFeatures(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	type latLng struct {
+		Lat float64 `json:"lat"`
+		Lng float64 `json:"lng"`
+	}
+	for _, c := range croplands {
+		var raw struct {
+			Type        string          `json:"type"`
+			Coordinates json.RawMessage `json:"coordinates"`
+		}
+		if err := json.Unmarshal(c.geoFeature, &raw); err != nil {
+			continue
+		}
+
+		var legacy interface{}
+		switch raw.Type {
+		case domain.GeometryPoint:
+			var p []float64
+			if err := json.Unmarshal(raw.Coordinates, &p); err != nil || len(p) < 2 {
+				continue
+			}
+			legacy = map[string]interface{}{"type": "marker", "position": latLng{Lat: p[1], Lng: p[0]}}
+		case domain.GeometryPolygon, domain.GeometryMultiPolygon:
+			var rings [][][]float64
+			if raw.Type == domain.GeometryPolygon {
+				if err := json.Unmarshal(raw.Coordinates, &rings); err != nil {
+					continue
+				}
+			} else {
+				var polygons [][][][]float64
+				if err := json.Unmarshal(raw.Coordinates, &polygons); err != nil || len(polygons) == 0 {
+					continue
+				}
+				rings = polygons[0]
+			}
+			if len(rings) == 0 || len(rings[0]) < 2 {
+				continue
+			}
+			outer := rings[0][:len(rings[0])-1]
+			path := make([]latLng, 0, len(outer))
+			for _, p := range outer {
+				if len(p) >= 2 {
+					path = append(path, latLng{Lat: p[1], Lng: p[0]})
+				}
+			}
+			legacy = map[string]interface{}{"type": "polygon", "path": path}
+		default:
+			continue
+		}
+
+		data, err := json.Marshal(legacy)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE croplands SET geo_feature = $2 WHERE uuid = $1`, c.uuid, string(data)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+-- +goose Up
+-- Events a durable consumer of the Postgres event bus failed to handle,
+-- waiting for their next attempt. They carry their own copy of the event so
+-- the consumer's offset can move past it and the log can be cleaned up.
+CREATE TABLE public.event_retries (
+    id BIGSERIAL PRIMARY KEY,
+    consumer TEXT NOT NULL,
+    event_id UUID NOT NULL,
+    routing_key TEXT NOT NULL,
+    body JSONB NOT NULL,
+    retries INT NOT NULL,
+    last_error TEXT NOT NULL,
+    next_attempt_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_event_retries_due ON public.event_retries(consumer, next_attempt_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS public.event_retries;
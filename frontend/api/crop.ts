@@ -1,16 +1,30 @@
 // frontend/api/crop.ts
 import axiosInstance from "./config";
-import type { Cropland, CropAnalytics } from "@/types";
+import type { Cropland, CropAnalytics, GeoFeatureData } from "@/types";
+import { fromGeoJSON, toGeoJSON } from "@/lib/geojson";
 
 export interface CropResponse {
   croplands: Cropland[];
 }
 
+/**
+ * The API stores locations as GeoJSON; the map draws GeoFeatureData.
+ */
+function withGeoFeature(cropland: Cropland): Cropland {
+  return { ...cropland, geoFeature: fromGeoJSON(cropland.geoFeature) };
+}
+
+function geoFeaturePayload(geoFeature: GeoFeatureData | null | undefined) {
+  return geoFeature ? toGeoJSON(geoFeature) : null;
+}
+
 /**
  * Fetch all Croplands for a specific FarmID.
  */
 export async function getCropsByFarmId(farmId: string): Promise<CropResponse> {
-  return axiosInstance.get<{ croplands: Cropland[] }>(`/crop/farm/${farmId}`).then((res) => res.data);
+  return axiosInstance
+    .get<{ croplands: Cropland[] }>(`/crop/farm/${farmId}`)
+    .then((res) => ({ croplands: (res.data.croplands ?? []).map(withGeoFeature) }));
 }
 
 /**
@@ -18,7 +32,7 @@ export async function getCropsByFarmId(farmId: string): Promise<CropResponse> {
  */
 export async function getCropById(cropId: string): Promise<Cropland> {
   const response = await axiosInstance.get<{ cropland: Cropland }>(`/crop/${cropId}`);
-  return response.data.cropland;
+  return withGeoFeature(response.data.cropland);
 }
 
 /**
@@ -32,7 +46,7 @@ export async function createCrop(data: {
   growthStage: string;
   plantId: string;
   farmId: string;
-  geoFeature?: unknown | null;
+  geoFeature?: GeoFeatureData | null;
 }): Promise<Cropland> {
   if (!data.farmId) {
     throw new Error("farmId is required to create a crop.");
@@ -46,11 +60,11 @@ export async function createCrop(data: {
     growthStage: data.growthStage,
     plantId: data.plantId,
     farmId: data.farmId,
-    geoFeature: data.geoFeature,
+    geoFeature: geoFeaturePayload(data.geoFeature),
   };
 
   const response = await axiosInstance.post<{ cropland: Cropland }>(`/crop`, payload);
-  return response.data.cropland;
+  return withGeoFeature(response.data.cropland);
 }
 
 /**
@@ -66,7 +80,7 @@ export async function updateCrop(
     landSize: number;
     growthStage: string;
     plantId: string;
-    geoFeature: unknown | null;
+    geoFeature?: GeoFeatureData | null;
   }
 ): Promise<Cropland> {
   if (!cropId) {
@@ -80,11 +94,11 @@ export async function updateCrop(
     landSize: data.landSize,
     growthStage: data.growthStage,
     plantId: data.plantId,
-    geoFeature: data.geoFeature,
+    geoFeature: geoFeaturePayload(data.geoFeature),
   };
 
   const response = await axiosInstance.put<{ cropland: Cropland }>(`/crop/${cropId}`, payload);
-  return response.data.cropland;
+  return withGeoFeature(response.data.cropland);
 }
 
 /**
@@ -0,0 +1,51 @@
+import type { GeoFeatureData, GeoJSONGeometry, GeoPosition } from "@/types";
+
+export type { GeoJSONGeometry };
+
+const toPosition = ([lng, lat]: number[]): GeoPosition => ({ lat, lng });
+
+/**
+ * Converts a shape drawn on the map to GeoJSON. A polygon loaded from the API
+ * and left untouched is returned as it came. A polyline is closed into a
+ * polygon; a path too short to enclose anything becomes a point.
+ */
+export function toGeoJSON(feature: GeoFeatureData): GeoJSONGeometry | null {
+  if (feature.type === "polygon" && feature.geometry) {
+    return feature.geometry;
+  }
+  if (feature.type === "marker") {
+    return { type: "Point", coordinates: [feature.position.lng, feature.position.lat] };
+  }
+
+  const ring = feature.path.map((p) => [p.lng, p.lat]);
+  if (ring.length === 0) return null;
+  if (ring.length < 3) return { type: "Point", coordinates: ring[0] };
+
+  const [first, last] = [ring[0], ring[ring.length - 1]];
+  if (first[0] !== last[0] || first[1] !== last[1]) ring.push(first);
+  return { type: "Polygon", coordinates: [ring] };
+}
+
+/**
+ * Converts a GeoJSON geometry from the API to a shape the map can draw. The
+ * map shows the outer boundary of the first polygon; the full geometry is kept
+ * alongside it so that saving without redrawing loses nothing.
+ */
+export function fromGeoJSON(geometry: unknown): GeoFeatureData | null {
+  if (!geometry || typeof geometry !== "object") return null;
+  const g = geometry as GeoJSONGeometry;
+
+  switch (g.type) {
+    case "Point":
+      return { type: "marker", position: toPosition(g.coordinates) };
+    case "Polygon":
+    case "MultiPolygon": {
+      const outer = g.type === "Polygon" ? g.coordinates[0] : g.coordinates[0]?.[0];
+      if (!outer) return null;
+      // The closing position repeats the first.
+      return { type: "polygon", path: outer.slice(0, -1).map(toPosition), geometry: g };
+    }
+    default:
+      return null;
+  }
+}
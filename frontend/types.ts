@@ -3,6 +3,15 @@ export interface GeoPosition {
   lng: number;
 }
 
+/**
+ * GeoJSON geometry as the API stores a cropland's location.
+ * Positions are [longitude, latitude].
+ */
+export type GeoJSONGeometry =
+  | { type: "Point"; coordinates: number[] }
+  | { type: "Polygon"; coordinates: number[][][] }
+  | { type: "MultiPolygon"; coordinates: number[][][][] };
+
 export interface GeoMarker {
   type: "marker";
   position: GeoPosition;
@@ -11,6 +20,11 @@ export interface GeoMarker {
 export interface GeoPolygon {
   type: "polygon";
   path: GeoPosition[];
+  /**
+   * The geometry the API returned, holes and all parts included. It is sent
+   * back unchanged unless the shape is redrawn.
+   */
+  geometry?: GeoJSONGeometry;
 }
 
 export interface GeoPolyline {